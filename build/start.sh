@@ -3,13 +3,14 @@
 killall pyramid
 sleep   5
 target_folder=./mytestnet-scale380p/192.168.0.4
-start_time=15:34
 enable_pipeline=false
 bash remove-file.sh $target_folder
+# every server must have the same genesis, set its time before distributing the folders:
+# ./pyramid genesis --root=./mytestnet-scale380p --time=2024-05-01T15:34:00Z
 for subfolder in "$target_folder"/*; do
     if [ -d "$subfolder" ]; then  
-        # (./urd --root="$subfolder" --start-time="$start_time" --enable-pipeline=$enable_pipeline > "$subfolder"/.out 2>&1 &)
-        (./pyramid --root="$subfolder" --start-time="$start_time" > "$subfolder"/.out 2>&1 &)
+        # (./urd --root="$subfolder" --enable-pipeline=$enable_pipeline > "$subfolder"/.out 2>&1 &)
+        (./pyramid --root="$subfolder" > "$subfolder"/.out 2>&1 &)
     fi
 done
 
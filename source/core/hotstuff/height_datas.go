@@ -9,8 +9,11 @@ import (
 
 type HeightDataPackage struct {
 	ProposalHash []byte
-	View         int64
-	Round        int32
+	// ForNecessaryData is what the votes for ProposalHash must carry, it is
+	// part of the bytes they sign
+	ForNecessaryData [][]byte
+	View             int64
+	Round            int32
 
 	Votes          []*Vote
 	VotesBitVector *utils.BitVector
@@ -18,12 +21,12 @@ type HeightDataPackage struct {
 	RejectTotal    int
 	VotesNeeded    int
 	PerVote        []int
-	ValidatorSet   *crypto.Verifier
+	ValidatorSet   crypto.Verifier
 
 	AggVote *AggregatedVote
 }
 
-func NewHeightDataPackage(validatorSet *crypto.Verifier, perVote []int, view int64, round int32) *HeightDataPackage {
+func NewHeightDataPackage(validatorSet crypto.Verifier, perVote []int, view int64, round int32) *HeightDataPackage {
 	votesNeeded := 0
 	for _, vote := range perVote {
 		votesNeeded += vote
@@ -44,9 +47,19 @@ func (hdp *HeightDataPackage) addVote(vote *Vote) error {
 	if !hdp.ValidatorSet.Verify(string(vote.GetSign()), vote.SignBytes(), vote.ValidatorIndex) {
 		return utils.ErrInvalidSign
 	}
+	return hdp.addVerifiedVote(vote)
+}
+
+// addVerifiedVote is addVote for votes whose signature was already checked,
+// e.g. by a VotePool.
+func (hdp *HeightDataPackage) addVerifiedVote(vote *Vote) error {
+	if vote.ValidatorIndex < 0 || vote.ValidatorIndex >= len(hdp.Votes) {
+		return utils.ErrInvalidValidatorIndex
+	}
 	if hdp.View != vote.View ||
 		hdp.Round != vote.Round ||
-		!bytes.Equal(hdp.ProposalHash, vote.ForHash) {
+		!bytes.Equal(hdp.ProposalHash, vote.ForHash) ||
+		!equalData(hdp.ForNecessaryData, vote.ForNecessaryData) {
 		return utils.ErrInvalidVoteCode
 	}
 	if hdp.Votes[vote.ValidatorIndex] == nil {
@@ -73,11 +86,12 @@ func (hdp *HeightDataPackage) getMaj23() (*AggregatedVote, error) {
 	}
 	bv := utils.NewBitVector(hdp.ValidatorSet.Size())
 	aggVote := &Vote{
-		Round:   hdp.Round,
-		View:    hdp.View,
-		ForHash: hdp.ProposalHash,
+		Round:            hdp.Round,
+		View:             hdp.View,
+		ForHash:          hdp.ProposalHash,
+		ForNecessaryData: hdp.ForNecessaryData,
 	}
-	votes := []string{}
+	votes, indexes := []string{}, []int{}
 
 	if hdp.VotesTotal >= 2*hdp.VotesNeeded/3 {
 		aggVote.SetOK()
@@ -90,13 +104,16 @@ func (hdp *HeightDataPackage) getMaj23() (*AggregatedVote, error) {
 		if vote == nil {
 			continue
 		}
-		if vote.IsOK() == aggVote.IsOK() {
-			bv.SetIndex(index, true)
+		// the votes all carry ForNecessaryData, those with the decision of
+		// the quorum signed the same bytes
+		if vote.IsOK() != aggVote.IsOK() {
+			continue
 		}
+		bv.SetIndex(index, true)
 		votes = append(votes, string(vote.GetSign()))
-		aggVote.ForNecessaryData = vote.ForNecessaryData
+		indexes = append(indexes, index)
 	}
-	aggSig, err := crypto.AggregateSignatures(votes)
+	aggSig, err := hdp.ValidatorSet.Aggregate(votes, indexes)
 	if err != nil {
 		return nil, err
 	}
@@ -119,6 +136,18 @@ func (hdp *HeightDataPackage) validate_aggregated(aggVote *AggregatedVote) error
 	return nil
 }
 
+func equalData(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func (hdp *HeightDataPackage) HasAggVote() bool {
 	return hdp.AggVote != nil
 }
@@ -0,0 +1,58 @@
+package hotstuff
+
+import (
+	"emulator/utils/signer"
+	"testing"
+)
+
+// TestVotesCarryNecessaryData checks that a vote signed over other data than
+// the leader expects is refused, and that the quorum of the others verifies.
+func TestVotesCarryNecessaryData(t *testing.T) {
+	var pubs []string
+	var signers []*signer.BLSSigner
+	for i := 0; i < 4; i++ {
+		priv, pub, err := signer.NewBLSKeyPair(signer.BaseCurve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := signer.NewSigner(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs, signers = append(pubs, pub), append(signers, s)
+	}
+	verifier, err := signer.NewVerifier(pubs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := NewState(0, 0, signers[0], 0, verifier, []int{1, 1, 1, 1})
+	state.EnterNewView()
+	last := [][]byte{[]byte("last")}
+	state.SetHash([]byte("block"), last)
+
+	vote := func(index int, data [][]byte) *Vote {
+		vote := NewVote(1, 0, []byte("block"), data, index)
+		vote.SetOK()
+		sig, err := signers[index].Sign(vote.SignBytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		vote.Sign = sig
+		return vote
+	}
+	if err := state.AddVote(vote(0, [][]byte{[]byte("other")})); err == nil {
+		t.Fatal("a vote with other necessary data was accepted")
+	}
+	for i := 1; i < 4; i++ {
+		if err := state.AddVote(vote(i, last)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	agg, err := state.GetMaj23()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verifier.VerifyAggregateSignature(string(agg.Sign), agg.SignBytes(), agg.SignerIndexer.Byte()) {
+		t.Fatal("the aggregate of the quorum does not verify")
+	}
+}
@@ -9,15 +9,15 @@ type State struct {
 	View  int64
 	Round int32
 
-	signer      *sig.Signer
+	signer      sig.Signer
 	signerIndex int
 
 	heightDatas  *HeightDataPackage
-	ValidatorSet *crypto.Verifier
+	ValidatorSet crypto.Verifier
 	PerVotes     []int
 }
 
-func NewState(view int64, round int32, signer *sig.Signer, index int, validatorSet *crypto.Verifier, perVotes []int) *State {
+func NewState(view int64, round int32, signer sig.Signer, index int, validatorSet crypto.Verifier, perVotes []int) *State {
 	return &State{
 		View:         view,
 		Round:        round,
@@ -29,14 +29,17 @@ func NewState(view int64, round int32, signer *sig.Signer, index int, validatorS
 	}
 }
 
-func (state *State) SetHash(hash []byte) {
+// SetHash sets the hash of the block the votes are for and the data they
+// must carry with it.
+func (state *State) SetHash(hash []byte, necessaryData [][]byte) {
 	if state.heightDatas == nil {
 		state.heightDatas = NewHeightDataPackage(state.ValidatorSet, state.PerVotes, state.View, state.Round)
 	}
 	state.heightDatas.ProposalHash = hash
+	state.heightDatas.ForNecessaryData = necessaryData
 }
 
-func (state *State) UpdateValidators(validatorSet *crypto.Verifier, perVotes []int) {
+func (state *State) UpdateValidators(validatorSet crypto.Verifier, perVotes []int) {
 	state.ValidatorSet = validatorSet
 	state.PerVotes = perVotes
 }
@@ -61,6 +64,10 @@ func (state *State) AddVote(vote *Vote) error {
 	return nil
 }
 
+func (state *State) AddVerifiedVote(vote *Vote) error {
+	return state.heightDatas.addVerifiedVote(vote)
+}
+
 func (state *State) IsQuorum() bool {
 	return state.heightDatas.isQuorum()
 }
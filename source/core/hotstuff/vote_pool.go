@@ -0,0 +1,108 @@
+package hotstuff
+
+import (
+	"emulator/utils"
+	crypto "emulator/utils/signer"
+	"runtime"
+)
+
+const (
+	defaultVoteQueueSize = 4096
+	maxVoteBatch         = 64
+)
+
+// VotePool verifies incoming votes outside the consensus lock. Each worker
+// drains whatever is queued, groups the votes by the bytes they sign and
+// checks every group with one VerifyBatch call, a single pairing under BLS.
+// Votes that pass are handed to deliver, the others to reject.
+type VotePool struct {
+	validatorSet crypto.Verifier
+	workers      int
+
+	queue chan *Vote
+	quit  chan struct{}
+
+	deliver func(*Vote)
+	reject  func(*Vote, error)
+}
+
+func NewVotePool(validatorSet crypto.Verifier, workers int, deliver func(*Vote), reject func(*Vote, error)) *VotePool {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &VotePool{
+		validatorSet: validatorSet,
+		workers:      workers,
+		queue:        make(chan *Vote, defaultVoteQueueSize),
+		quit:         make(chan struct{}),
+		deliver:      deliver,
+		reject:       reject,
+	}
+}
+
+func (vp *VotePool) Start() {
+	for i := 0; i < vp.workers; i++ {
+		go vp.worker()
+	}
+}
+
+func (vp *VotePool) Stop() {
+	close(vp.quit)
+}
+
+// Submit queues a vote for verification, it blocks when the queue is full.
+func (vp *VotePool) Submit(vote *Vote) {
+	select {
+	case vp.queue <- vote:
+	case <-vp.quit:
+	}
+}
+
+func (vp *VotePool) worker() {
+	for {
+		var batch []*Vote
+		select {
+		case vote := <-vp.queue:
+			batch = append(batch, vote)
+		case <-vp.quit:
+			return
+		}
+	drain:
+		for len(batch) < maxVoteBatch {
+			select {
+			case vote := <-vp.queue:
+				batch = append(batch, vote)
+			default:
+				break drain
+			}
+		}
+		vp.verify(batch)
+	}
+}
+
+func (vp *VotePool) verify(batch []*Vote) {
+	groups := make(map[string][]*Vote)
+	order := []string{}
+	for _, vote := range batch {
+		msg := string(vote.SignBytes())
+		if _, ok := groups[msg]; !ok {
+			order = append(order, msg)
+		}
+		groups[msg] = append(groups[msg], vote)
+	}
+	for _, msg := range order {
+		votes := groups[msg]
+		sigs, indexes := make([]string, len(votes)), make([]int, len(votes))
+		for i, vote := range votes {
+			sigs[i], indexes[i] = vote.GetSign(), vote.ValidatorIndex
+		}
+		valid := vp.validatorSet.VerifyBatch(sigs, []byte(msg), indexes)
+		for i, vote := range votes {
+			if valid[i] {
+				vp.deliver(vote)
+			} else {
+				vp.reject(vote, utils.ErrInvalidSign)
+			}
+		}
+	}
+}
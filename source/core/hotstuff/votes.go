@@ -182,20 +182,18 @@ func (v *AggregatedVote) ProtoBytes() []byte {
 func (v *AggregatedVote) Hash() []byte {
 	return merkle.HashFromByteSlices([][]byte{v.ProtoBytes()})
 }
-func (v *AggregatedVote) SignBytes() []byte {
-	return utils.MustProtoBytes(
-		&protovote.AggregatedVote{
-			Code: int32(v.Code),
 
-			Round:            v.Round,
-			View:             v.View,
-			ForHash:          v.ForHash,
-			ForNecessaryData: v.ForNecessaryData,
+// SignBytes are the bytes every vote of the aggregate signed.
+func (v *AggregatedVote) SignBytes() []byte {
+	vote := &Vote{
+		Code: v.Code,
 
-			Sign:        "",
-			SignerIndex: nil,
-		},
-	)
+		View:             v.View,
+		Round:            v.Round,
+		ForHash:          v.ForHash,
+		ForNecessaryData: v.ForNecessaryData,
+	}
+	return vote.SignBytes()
 }
 
 func NewAggregatedVoteFromProto(p *protovote.AggregatedVote) *AggregatedVote {
@@ -0,0 +1,54 @@
+package hotstuff
+
+import (
+	"emulator/utils"
+	"emulator/utils/signer"
+	"testing"
+)
+
+func TestAggregatedVoteSignBytes(t *testing.T) {
+	var pubs []string
+	var signers []*signer.BLSSigner
+	for i := 0; i < 3; i++ {
+		priv, pub, err := signer.NewBLSKeyPair(signer.BaseCurve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := signer.NewSigner(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs, signers = append(pubs, pub), append(signers, s)
+	}
+	verifier, err := signer.NewVerifier(pubs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vote := NewVote(7, 0, []byte("block"), [][]byte{[]byte("last")}, 0)
+	var sigs []string
+	for _, s := range signers {
+		sig, err := s.Sign(vote.SignBytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigs = append(sigs, sig)
+	}
+	aggSig, err := verifier.Aggregate(sigs, []int{0, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bv := utils.NewBitVector(3)
+	for i := 0; i < 3; i++ {
+		bv.SetIndex(i, true)
+	}
+	vote.Sign = aggSig
+	agg := vote.GetAggregated(bv)
+	if !verifier.VerifyAggregateSignature(string(agg.Sign), agg.SignBytes(), agg.SignerIndexer.Byte()) {
+		t.Fatal("the aggregate does not verify against the bytes its votes signed")
+	}
+	agg.View++
+	if verifier.VerifyAggregateSignature(string(agg.Sign), agg.SignBytes(), agg.SignerIndexer.Byte()) {
+		t.Fatal("the aggregate verifies for another view")
+	}
+}
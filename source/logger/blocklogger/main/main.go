@@ -1,17 +1,42 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	reader "emulator/logger/blocklogger"
+	"emulator/logger/txtrace"
 )
 
+// ./logger ./node1/node1-blocklogger-brief.txt
+// ./logger report [--format=text|csv|json] [--window=10s] [--windows] ./run-a ./run-b ./node5-blocklogger-brief.txt
+// ./logger trace [--format=text|csv|json] [--txs] ./run-a ./node5-txtrace.txt
+
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: logger <brief log> | logger report [flags] <brief logs or run directories>... | logger trace [flags] <trace files or directories>...")
+		os.Exit(2)
+	}
+	var command func([]string) error
+	switch os.Args[1] {
+	case "report":
+		command = report
+	case "trace":
+		command = trace
+	}
+	if command != nil {
+		if err := command(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	path := os.Args[1]
-	rd := reader.NewReader(path)
-	if rd == nil {
-		panic("There exists some problems")
+	rd, err := reader.NewReader(path)
+	if err != nil {
+		panic(err)
 	}
 	is, js, err := rd.NoneZeroPeriods()
 	if err != nil {
@@ -35,3 +60,64 @@ func main() {
 		fmt.Printf("[%d-%d] : %.3f tps, %.3f %% commit\n", is[i], js[i], tps, commit_rate)
 	}
 }
+
+// report merges the brief logs of the nodes per shard and prints the
+// throughput, view time percentiles, abort rate and cross-shard share of
+// every shard, a directory is a run and stands for every brief log below it.
+func report(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format: text, csv or json")
+	window := fs.Duration("window", 10*time.Second, "Length of the intervals of the abort rate over time")
+	windows := fs.Bool("windows", false, "With --format=csv, write a row per interval instead of per shard")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no brief logs or run directories given")
+	}
+	r, err := reader.NewReport(fs.Args(), *window)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		r.WriteText(os.Stdout)
+		return nil
+	case "csv":
+		return r.WriteCSV(os.Stdout, *windows)
+	case "json":
+		return r.WriteJSON(os.Stdout)
+	}
+	return fmt.Errorf("unknown format %q, want text, csv or json", *format)
+}
+
+// trace joins the trace files of all shards and prints the latency of txs
+// from their admission to every stage, intra- and cross-shard apart.
+func trace(args []string) error {
+	fs := flag.NewFlagSet("trace", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format: text, csv or json")
+	txs := fs.Bool("txs", false, "With --format=csv or json, write every tx instead of the distributions only")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no trace files or directories given")
+	}
+	r, err := txtrace.Join(fs.Args())
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		r.WriteText(os.Stdout)
+		return nil
+	case "csv":
+		return r.WriteCSV(os.Stdout, *txs)
+	case "json":
+		if !*txs {
+			r.Txs = nil
+		}
+		return r.WriteJSON(os.Stdout)
+	}
+	return fmt.Errorf("unknown format %q, want text, csv or json", *format)
+}
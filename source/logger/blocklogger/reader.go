@@ -2,9 +2,8 @@ package blocklogger
 
 import (
 	"bufio"
+	"fmt"
 	"os"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 )
@@ -16,32 +15,44 @@ type BlockLoggerReader struct {
 	events []*ConsensusEvent
 }
 
-func NewReader(p string) *BlockLoggerReader {
+// NewReader reads the brief log of a node, the finish events of older logs
+// get their counts from the message.
+func NewReader(p string) (*BlockLoggerReader, error) {
 	var b BlockLoggerReader
 	b.path = p
 	f, err := os.Open(b.path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	h := bufio.NewReader(f)
 	defer f.Close()
-	for {
-		line, _, err := h.ReadLine()
-		if err != nil {
-			break
+	h := bufio.NewScanner(f)
+	h.Buffer(make([]byte, 64*1024), 1024*1024)
+	for h.Scan() {
+		if len(h.Bytes()) == 0 {
+			continue
 		}
-		event, err := NewConsensusEventFromJson(line)
+		event, err := NewConsensusEventFromJson(h.Bytes())
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("%s: %v", p, err)
 		}
 		if event.IsRoundEnd && event.IsRoundStart {
 			event.IsRoundStart = false
 		}
+		if err := event.parseFinish(); err != nil {
+			return nil, fmt.Errorf("%s: %v", p, err)
+		}
 		b.events = append(b.events, event)
 	}
-	return &b
+	if err := h.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	return &b, nil
 }
 
+func (b *BlockLoggerReader) Path() string { return b.path }
+
+func (b *BlockLoggerReader) Events() []*ConsensusEvent { return b.events }
+
 func (b *BlockLoggerReader) CalculateTPS(i, j int) (float64, float64, error) {
 	var start, end time.Time
 	var count, commit, inner int
@@ -62,24 +73,10 @@ func (b *BlockLoggerReader) CalculateTPS(i, j int) (float64, float64, error) {
 		if event.IsRoundEnd && event.Height == int64(j) {
 			end = event.Time
 		}
-		if event.IsRoundEnd && strings.HasPrefix(event.Message, BLOCK_FINISH_HEIGHT) && event.Height >= int64(i) && event.Height <= int64(j) {
-			finishDataStr := event.Message[len(BLOCK_FINISH_HEIGHT)+1 : len(event.Message)-1]
-			uStr := strings.Split(finishDataStr, ",")
-			if u, err := strconv.Atoi(uStr[0]); err == nil {
-				inner += u
-			} else {
-				panic(err)
-			}
-			if u, err := strconv.Atoi(uStr[1]); err == nil {
-				commit += u
-			} else {
-				panic(err)
-			}
-			if u, err := strconv.Atoi(uStr[2]); err == nil {
-				count += u
-			} else {
-				panic(err)
-			}
+		if event.IsFinish() && event.Height >= int64(i) && event.Height <= int64(j) {
+			inner += event.Inner
+			commit += event.Commit
+			count += event.Count
 		}
 	}
 	times := float64(end.Sub(start)/time.Microsecond) / 1000.0 / 1000.0
@@ -90,20 +87,8 @@ func (b *BlockLoggerReader) NoneZeroPeriods() ([]int, []int, error) {
 	var starts, ends = []int{}, []int{}
 	stateMachine := false
 	for _, event := range b.events {
-		if event.IsRoundEnd && strings.HasPrefix(event.Message, BLOCK_FINISH_HEIGHT) {
-			finishDataStr := event.Message[len(BLOCK_FINISH_HEIGHT)+1 : len(event.Message)-1]
-			uStr := strings.Split(finishDataStr, ",")
-			var total = 0
-			if u, err := strconv.Atoi(uStr[0]); err == nil {
-				total += u
-			} else {
-				panic(err)
-			}
-			if u, err := strconv.Atoi(uStr[2]); err == nil {
-				total += u
-			} else {
-				panic(err)
-			}
+		if event.IsFinish() {
+			total := event.Txs()
 			if stateMachine && total == 0 {
 				ends = append(ends, int(event.Height)-1)
 				stateMachine = false
@@ -0,0 +1,319 @@
+package blocklogger
+
+import (
+	"bufio"
+	"emulator/utils"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A report merges the brief logs of many nodes, of one or more runs, per
+// shard: a height is finished when the first node of its shard logged its
+// finish, and started when the first one logged its start. Only the span from
+// the first to the last height with txs is measured, so idle views before and
+// after the workload do not count.
+
+const briefSuffix = "-blocklogger-brief.txt"
+
+// Window is an interval of a shard's span, by the time heights finished.
+type Window struct {
+	// Start and End are seconds from the start of the span
+	Start     float64 `json:"start_s"`
+	End       float64 `json:"end_s"`
+	Heights   int     `json:"heights"`
+	Txs       int     `json:"txs"`
+	TPS       float64 `json:"tps"`
+	AbortRate float64 `json:"abort_pct"`
+}
+
+type ShardReport struct {
+	Run     string   `json:"run,omitempty"`
+	ChainID string   `json:"chain_id"`
+	Nodes   []string `json:"nodes"`
+	Heights int      `json:"heights"`
+	Seconds float64  `json:"seconds"`
+	// Txs are the committed ones: intra-shard and committed cross-shard txs
+	Txs int     `json:"txs"`
+	TPS float64 `json:"tps"`
+	// ViewP50, ViewP95 and ViewP99 are percentiles of the seconds between
+	// the finish of consecutive heights
+	ViewP50         float64  `json:"view_p50_s"`
+	ViewP95         float64  `json:"view_p95_s"`
+	ViewP99         float64  `json:"view_p99_s"`
+	AbortRate       float64  `json:"abort_pct"`
+	CrossShardShare float64  `json:"cross_shard_pct"`
+	Windows         []Window `json:"windows"`
+}
+
+type Report struct {
+	Shards []*ShardReport `json:"shards"`
+}
+
+// height is a height merged over the nodes of a shard.
+type height struct {
+	start, finish        time.Time
+	inner, commit, count int
+	finished             bool
+}
+
+type shardLogs struct {
+	run, chainID string
+	nodes        []string
+	heights      map[int64]*height
+}
+
+// NewReport reads the brief logs at paths, a directory stands for every brief
+// log below it and names the run in the report. Heights are grouped by
+// window in the abort rate over time.
+func NewReport(paths []string, window time.Duration) (*Report, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("window must be positive, got %v", window)
+	}
+	shards := map[string]*shardLogs{}
+	var keys []string
+	for _, p := range paths {
+		run, files, err := briefLogs(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			reader, err := NewReader(file)
+			if err != nil {
+				return nil, err
+			}
+			chainID, node, err := origin(reader)
+			if err != nil {
+				return nil, err
+			}
+			key := run + "/" + chainID
+			shard, ok := shards[key]
+			if !ok {
+				shard = &shardLogs{run: run, chainID: chainID, heights: map[int64]*height{}}
+				shards[key] = shard
+				keys = append(keys, key)
+			}
+			if shard.has(node) {
+				// a copy, like the ones the testnet collects into logs
+				continue
+			}
+			shard.nodes = append(shard.nodes, node)
+			shard.merge(reader.Events())
+		}
+	}
+	sort.Strings(keys)
+	report := new(Report)
+	for _, key := range keys {
+		report.Shards = append(report.Shards, shards[key].report(window))
+	}
+	return report, nil
+}
+
+// briefLogs are the brief logs at p, with the run they belong to.
+func briefLogs(p string) (string, []string, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", nil, err
+	}
+	if !info.IsDir() {
+		return "", []string{p}, nil
+	}
+	var files []string
+	err = filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(path, briefSuffix) {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err == nil && len(files) == 0 {
+		err = fmt.Errorf("no brief logs below %s", p)
+	}
+	return filepath.Base(filepath.Clean(p)), files, err
+}
+
+// origin is the chain and the node that wrote a log. Logs of older versions
+// do not have them in their events, their chain is read from the config of
+// the node directory they are in.
+func origin(reader *BlockLoggerReader) (string, string, error) {
+	node := strings.TrimSuffix(filepath.Base(reader.Path()), briefSuffix)
+	for _, event := range reader.Events() {
+		if event.ChainID != "" {
+			return event.ChainID, event.Node, nil
+		}
+	}
+	f, err := os.Open(filepath.Join(filepath.Dir(reader.Path()), "config", "config.toml"))
+	if err != nil {
+		return "", "", fmt.Errorf("%s has no chain id and %v", reader.Path(), err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok && strings.TrimSpace(key) == "chain_id" {
+			return strings.Trim(strings.TrimSpace(value), `"`), node, nil
+		}
+	}
+	return "", "", fmt.Errorf("%s has no chain id, nor has %s", reader.Path(), f.Name())
+}
+
+func (shard *shardLogs) has(node string) bool {
+	for _, n := range shard.nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+func (shard *shardLogs) merge(events []*ConsensusEvent) {
+	for _, event := range events {
+		h, ok := shard.heights[event.Height]
+		if !ok {
+			h = new(height)
+			shard.heights[event.Height] = h
+		}
+		if event.IsRoundStart && (h.start.IsZero() || event.Time.Before(h.start)) {
+			h.start = event.Time
+		}
+		if event.IsFinish() && (!h.finished || event.Time.Before(h.finish)) {
+			h.finish, h.finished = event.Time, true
+			h.inner, h.commit, h.count = event.Inner, event.Commit, event.Count
+		}
+	}
+}
+
+func (shard *shardLogs) report(window time.Duration) *ShardReport {
+	sort.Strings(shard.nodes)
+	r := &ShardReport{Run: shard.run, ChainID: shard.chainID, Nodes: shard.nodes}
+
+	var order []int64
+	for n, h := range shard.heights {
+		if h.finished {
+			order = append(order, n)
+		}
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+	first, last := -1, -1
+	for i, n := range order {
+		if h := shard.heights[n]; h.inner+h.count > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return r
+	}
+	order = order[first : last+1]
+
+	begin := shard.heights[order[0]].start
+	if prev, ok := shard.heights[order[0]-1]; begin.IsZero() && ok && prev.finished {
+		// no node logged the start, the height began when the one before
+		// finished
+		begin = prev.finish
+	}
+	end := shard.heights[order[len(order)-1]].finish
+	r.Seconds = end.Sub(begin).Seconds()
+
+	var views []float64
+	var inner, commit, count int
+	// aborted and cross-shard txs per window
+	var aborted, crossShard []int
+	prev := begin
+	for _, n := range order {
+		h := shard.heights[n]
+		views = append(views, h.finish.Sub(prev).Seconds())
+		prev = h.finish
+		inner, commit, count = inner+h.inner, commit+h.commit, count+h.count
+
+		i := int(h.finish.Sub(begin) / window)
+		if i > 0 && time.Duration(i)*window == end.Sub(begin) {
+			// the last height closes the window before, not an empty one
+			i--
+		}
+		for len(r.Windows) <= i {
+			start := time.Duration(len(r.Windows)) * window
+			r.Windows = append(r.Windows, Window{Start: start.Seconds(), End: (start + window).Seconds()})
+			aborted, crossShard = append(aborted, 0), append(crossShard, 0)
+		}
+		r.Windows[i].Heights++
+		r.Windows[i].Txs += h.inner + h.commit
+		aborted[i] += h.count - h.commit
+		crossShard[i] += h.count
+	}
+	for i := range r.Windows {
+		w := &r.Windows[i]
+		w.End = math.Min(w.End, r.Seconds)
+		if w.End > w.Start {
+			w.TPS = float64(w.Txs) / (w.End - w.Start)
+		}
+		w.AbortRate = percent(aborted[i], crossShard[i])
+	}
+
+	r.Heights = len(order)
+	r.Txs = inner + commit
+	if r.Seconds > 0 {
+		r.TPS = float64(r.Txs) / r.Seconds
+	}
+	sort.Float64s(views)
+	r.ViewP50, r.ViewP95, r.ViewP99 = utils.Percentile(views, 50), utils.Percentile(views, 95), utils.Percentile(views, 99)
+	r.AbortRate = percent(count-commit, count)
+	r.CrossShardShare = percent(count, inner+count)
+	return r
+}
+
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes a row per shard, or with windows a row per window of every
+// shard.
+func (r *Report) WriteCSV(w io.Writer, windows bool) error {
+	out := csv.NewWriter(w)
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+	if windows {
+		out.Write([]string{"run", "chain_id", "start_s", "end_s", "heights", "txs", "tps", "abort_pct"})
+		for _, s := range r.Shards {
+			for _, win := range s.Windows {
+				out.Write([]string{s.Run, s.ChainID, f(win.Start), f(win.End), strconv.Itoa(win.Heights),
+					strconv.Itoa(win.Txs), f(win.TPS), f(win.AbortRate)})
+			}
+		}
+	} else {
+		out.Write([]string{"run", "chain_id", "nodes", "heights", "seconds", "txs", "tps",
+			"view_p50_s", "view_p95_s", "view_p99_s", "abort_pct", "cross_shard_pct"})
+		for _, s := range r.Shards {
+			out.Write([]string{s.Run, s.ChainID, strings.Join(s.Nodes, " "), strconv.Itoa(s.Heights), f(s.Seconds),
+				strconv.Itoa(s.Txs), f(s.TPS), f(s.ViewP50), f(s.ViewP95), f(s.ViewP99), f(s.AbortRate), f(s.CrossShardShare)})
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%-10s %-8s %6s %8s %9s %12s %9s %9s %9s %8s %8s\n", "run", "shard", "nodes", "heights",
+		"seconds", "tps", "p50 (s)", "p95 (s)", "p99 (s)", "abort %", "cross %")
+	for _, s := range r.Shards {
+		fmt.Fprintf(w, "%-10s %-8s %6d %8d %9.3f %12.3f %9.3f %9.3f %9.3f %8.3f %8.3f\n", s.Run, s.ChainID, len(s.Nodes),
+			s.Heights, s.Seconds, s.TPS, s.ViewP50, s.ViewP95, s.ViewP99, s.AbortRate, s.CrossShardShare)
+	}
+}
@@ -0,0 +1,92 @@
+package blocklogger
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeBrief(t *testing.T, path string, events ...*ConsensusEvent) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	for _, event := range events {
+		if err := event.WriteJson(&b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func at(ce *ConsensusEvent, t0 time.Time, seconds float64, chainID, node string) *ConsensusEvent {
+	ce.Time = t0.Add(time.Duration(seconds * float64(time.Second)))
+	ce.ChainID, ce.Node = chainID, node
+	return ce
+}
+
+func TestReport(t *testing.T) {
+	dir := t.TempDir()
+	t0 := time.Now()
+	// node1 and node2 are in shard i1, heights 2 to 4 have txs and finish a
+	// second apart, node2 is always later
+	for i, node := range []string{"node1", "node2"} {
+		delay := float64(i) / 10
+		writeBrief(t, filepath.Join(dir, "run", node, node+briefSuffix),
+			at(NewFinishEvent(1, 0, "", 0, 0, 0), t0, delay, "i1", node),
+			at(NewConsensusEvent(2, 0, "", true, false, "start"), t0, delay, "i1", node),
+			at(NewFinishEvent(2, 0, "", 10, 4, 5), t0, 1+delay, "i1", node),
+			at(NewFinishEvent(3, 0, "", 10, 5, 5), t0, 2+delay, "i1", node),
+			at(NewFinishEvent(4, 0, "", 10, 0, 10), t0, 4+delay, "i1", node),
+			at(NewFinishEvent(5, 0, "", 0, 0, 0), t0, 5+delay, "i1", node),
+		)
+	}
+	// an older log: counts only in the message, chain in the config
+	legacy := filepath.Join(dir, "run", "node3", "node3"+briefSuffix)
+	writeBrief(t, legacy,
+		at(NewConsensusEvent(2, 0, "", true, false, "start"), t0, 0, "", ""),
+		at(NewConsensusEvent(2, 0, "", false, true, "finish[6,2,2]"), t0, 2, "", ""),
+	)
+	os.MkdirAll(filepath.Join(dir, "run", "node3", "config"), os.ModePerm)
+	os.WriteFile(filepath.Join(dir, "run", "node3", "config", "config.toml"), []byte("chain_id  = \"i2\"\n"), 0666)
+
+	r, err := NewReport([]string{filepath.Join(dir, "run")}, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Shards) != 2 {
+		t.Fatalf("got %d shards", len(r.Shards))
+	}
+	i1, i2 := r.Shards[0], r.Shards[1]
+	if i1.Run != "run" || i1.ChainID != "i1" || len(i1.Nodes) != 2 || i1.Heights != 3 || i1.Seconds != 4 || i1.Txs != 39 {
+		t.Fatalf("unexpected shard %+v", i1)
+	}
+	if i1.ViewP50 != 1 || i1.ViewP99 != 2 || math.Abs(i1.AbortRate-55) > 1e-9 || i1.CrossShardShare != 40 {
+		t.Fatalf("unexpected shard %+v", i1)
+	}
+	if len(i1.Windows) != 2 || i1.Windows[0].Heights != 1 || i1.Windows[1].Heights != 2 || math.Abs(i1.Windows[1].AbortRate-200.0/3) > 1e-9 {
+		t.Fatalf("unexpected windows %+v", i1.Windows)
+	}
+	if i2.ChainID != "i2" || i2.Txs != 8 || i2.TPS != 4 || i2.AbortRate != 0 {
+		t.Fatalf("unexpected shard %+v", i2)
+	}
+
+	var b bytes.Buffer
+	if err := r.WriteCSV(&b, false); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(b.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[1], "run,i1,node1 node2,3,") {
+		t.Fatalf("unexpected csv\n%s", b.String())
+	}
+
+	writeBrief(t, legacy, at(NewConsensusEvent(2, 0, "", false, true, "finish[6,2]"), t0, 2, "", ""))
+	if _, err := NewReport([]string{legacy}, time.Second); err == nil {
+		t.Fatal("accepted a malformed finish event")
+	}
+}
@@ -2,7 +2,9 @@ package blocklogger
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"text/template"
@@ -15,6 +17,17 @@ type ConsensusEvent struct {
 	IsRoundStart, IsRoundEnd bool
 	Message                  string
 	Time                     time.Time
+
+	// ChainID and Node are filled in by the writer, logs of older versions
+	// leave them empty
+	ChainID string `json:",omitempty"`
+	Node    string `json:",omitempty"`
+	// Inner, Commit and Count are set on the finish event of a height: the
+	// intra-shard txs of its block, and the committed and the total
+	// cross-shard txs
+	Inner  int `json:",omitempty"`
+	Commit int `json:",omitempty"`
+	Count  int `json:",omitempty"`
 }
 
 func NewConsensusEvent(height int64, round int32, roundStep string, IsStart, IsEnd bool, Message string) *ConsensusEvent {
@@ -28,6 +41,35 @@ func NewConsensusEvent(height int64, round int32, roundStep string, IsStart, IsE
 	}
 }
 
+// NewFinishEvent is the event that ends a height, with the txs of its block.
+func NewFinishEvent(height int64, round int32, roundStep string, inner, commit, count int) *ConsensusEvent {
+	ce := NewConsensusEvent(height, round, roundStep, false, true, fmt.Sprintf("%s[%d,%d,%d]", BLOCK_FINISH_HEIGHT, inner, commit, count))
+	ce.Inner, ce.Commit, ce.Count = inner, commit, count
+	return ce
+}
+
+// IsFinish tells whether the event ends a height.
+func (ce *ConsensusEvent) IsFinish() bool {
+	return ce.IsRoundEnd && strings.HasPrefix(ce.Message, BLOCK_FINISH_HEIGHT)
+}
+
+// Txs is the number of txs the block of a finish event had.
+func (ce *ConsensusEvent) Txs() int { return ce.Inner + ce.Count }
+
+// parseFinish fills in the counts of a finish event that only has them in
+// its message, "finish[inner,commit,count]", as older versions wrote it.
+func (ce *ConsensusEvent) parseFinish() error {
+	if !ce.IsFinish() || ce.Inner != 0 || ce.Commit != 0 || ce.Count != 0 {
+		return nil
+	}
+	var inner, commit, count int
+	if _, err := fmt.Sscanf(ce.Message, BLOCK_FINISH_HEIGHT+"[%d,%d,%d]", &inner, &commit, &count); err != nil {
+		return fmt.Errorf("height %d: malformed finish event %q: %v", ce.Height, ce.Message, err)
+	}
+	ce.Inner, ce.Commit, ce.Count = inner, commit, count
+	return nil
+}
+
 const ConsensusEventTemplate = `[{{.Time}}](Height={{.Height}}, Round={{.Round}}) RoundStep={{.RoundStep}}   Event="{{.Message}}"
 `
 
@@ -66,9 +66,21 @@ func (writer *BlockLoggerWriter) OnStart() error {
 	}
 	return nil
 }
+
+// OnStop flushes and closes the files, events written afterwards are dropped.
 func (writer *BlockLoggerWriter) OnStop() error {
+	writer.mtx.Lock()
+	defer writer.mtx.Unlock()
+	if writer.handler == nil {
+		return nil
+	}
+	writer.briefWriter.Flush()
+	writer.writer.Flush()
+	writer.briefHandler.Sync()
+	writer.handler.Sync()
 	err1 := writer.briefHandler.Close()
 	err2 := writer.handler.Close()
+	writer.briefHandler, writer.handler = nil, nil
 
 	if err1 != nil {
 		return err1
@@ -82,6 +94,12 @@ func (writer *BlockLoggerWriter) OnStop() error {
 func (writer *BlockLoggerWriter) Write(event *ConsensusEvent) error {
 	writer.mtx.Lock()
 	defer writer.mtx.Unlock()
+	if writer.handler == nil {
+		return nil
+	}
+	if event.ChainID == "" {
+		event.ChainID, event.Node = writer.chain_id, writer.nodeName
+	}
 	if event.IsRoundStart {
 		if _, err := writer.writer.WriteString("\n\n"); err != nil {
 			return err
@@ -0,0 +1,358 @@
+package txtrace
+
+import (
+	"bufio"
+	"emulator/utils"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Join reads the trace files of every shard and follows each tx from its
+// admission to a mempool until it is committed in all the shards it touches,
+// or aborted in one of them. A stage a tx reaches in a shard counts when the
+// first node of the shard logged it, and a stage of a cross-shard tx is
+// reached once it is reached in every shard of the tx, for the relays from
+// or to every shard.
+
+const (
+	Intra = "intra"
+	Cross = "cross"
+
+	OutcomeCommitted = "committed"
+	OutcomeAborted   = "aborted"
+	// OutcomePending txs were not finished when the logs ended
+	OutcomePending = "pending"
+)
+
+// Milestones are the stages a tx is measured at, in order.
+var Milestones = []Stage{Included, Locked, Relayed, Unlocked, Committed, Aborted}
+
+// Tx is a traced tx, its milestones are the seconds from its admission.
+type Tx struct {
+	Hash       string            `json:"tx"`
+	Class      string            `json:"class"`
+	Outcome    string            `json:"outcome"`
+	Shards     []string          `json:"shards"`
+	Admit      time.Time         `json:"admit"`
+	Milestones map[Stage]float64 `json:"milestones"`
+	Reason     string            `json:"reason,omitempty"`
+}
+
+// Distribution of latencies in seconds.
+type Distribution struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean_s"`
+	P50   float64 `json:"p50_s"`
+	P95   float64 `json:"p95_s"`
+	P99   float64 `json:"p99_s"`
+	Max   float64 `json:"max_s"`
+}
+
+// Summary of the txs of a class, intra or cross.
+type Summary struct {
+	Class     string                 `json:"class"`
+	Txs       int                    `json:"txs"`
+	Committed int                    `json:"committed"`
+	Aborted   int                    `json:"aborted"`
+	Pending   int                    `json:"pending"`
+	Latency   map[Stage]Distribution `json:"latency"`
+}
+
+type Result struct {
+	Summaries []*Summary `json:"summaries"`
+	Txs       []*Tx      `json:"txs,omitempty"`
+}
+
+type txEvents struct {
+	cross  bool
+	shards map[string]bool
+	// first time of every stage per chain and peer
+	stages map[Stage]map[string]map[string]time.Time
+	reason string
+}
+
+// Join reads the trace files at paths, a directory stands for every trace
+// file below it.
+func Join(paths []string) (*Result, error) {
+	txs := map[string]*txEvents{}
+	for _, p := range paths {
+		files, err := traceFiles(p)
+		if err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for _, file := range files {
+			// the testnet collects copies of the files into logs
+			if name := filepath.Base(file); seen[name] {
+				continue
+			} else {
+				seen[name] = true
+			}
+			if err := readEvents(file, txs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	result := new(Result)
+	for hash, events := range txs {
+		if tx := events.tx(hash); tx != nil {
+			result.Txs = append(result.Txs, tx)
+		}
+	}
+	sort.Slice(result.Txs, func(i, j int) bool { return result.Txs[i].Admit.Before(result.Txs[j].Admit) })
+	for _, class := range []string{Intra, Cross} {
+		result.Summaries = append(result.Summaries, summarize(class, result.Txs))
+	}
+	return result, nil
+}
+
+func traceFiles(p string) ([]string, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{p}, nil
+	}
+	var files []string
+	err = filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(path, Suffix) {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err == nil && len(files) == 0 {
+		err = fmt.Errorf("no trace files below %s", p)
+	}
+	return files, err
+}
+
+func readEvents(file string, txs map[string]*txEvents) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("%s:%d: %v", file, line, err)
+		}
+		tx, ok := txs[event.Tx]
+		if !ok {
+			tx = &txEvents{shards: map[string]bool{}, stages: map[Stage]map[string]map[string]time.Time{}}
+			txs[event.Tx] = tx
+		}
+		tx.cross = tx.cross || event.Cross || len(event.Shards) > 1
+		for _, shard := range event.Shards {
+			tx.shards[shard] = true
+		}
+		if event.Stage == Aborted && tx.reason == "" {
+			tx.reason = event.Reason
+		}
+		chains, ok := tx.stages[event.Stage]
+		if !ok {
+			chains = map[string]map[string]time.Time{}
+			tx.stages[event.Stage] = chains
+		}
+		peers, ok := chains[event.Chain]
+		if !ok {
+			peers = map[string]time.Time{}
+			chains[event.Chain] = peers
+		}
+		if first, ok := peers[event.Peer]; !ok || event.Time.Before(first) {
+			peers[event.Peer] = event.Time
+		}
+	}
+	return scanner.Err()
+}
+
+// tx joins the events of a tx, nil if it was never admitted or included.
+func (events *txEvents) tx(hash string) *Tx {
+	start, ok := earliest(events.stages[Admit])
+	if !ok {
+		if start, ok = earliest(events.stages[Included]); !ok {
+			return nil
+		}
+	}
+	tx := &Tx{Hash: hash, Class: Intra, Outcome: OutcomePending, Admit: start, Milestones: map[Stage]float64{}, Reason: events.reason}
+	if events.cross {
+		tx.Class = Cross
+	}
+	for shard := range events.shards {
+		tx.Shards = append(tx.Shards, shard)
+	}
+	sort.Strings(tx.Shards)
+
+	for _, stage := range Milestones {
+		chains := events.stages[stage]
+		var at time.Time
+		switch stage {
+		case Included, Aborted:
+			// the first shard to include or abort it
+			if at, ok = earliest(chains); !ok {
+				continue
+			}
+		default:
+			// every shard of the tx, their relays can only be counted
+			// once the shards are known
+			if len(tx.Shards) == 0 {
+				continue
+			}
+			for _, shard := range tx.Shards {
+				t, ok := latest(chains[shard])
+				if !ok {
+					at = time.Time{}
+					break
+				}
+				if t.After(at) {
+					at = t
+				}
+			}
+			if at.IsZero() {
+				continue
+			}
+		}
+		tx.Milestones[stage] = at.Sub(start).Seconds()
+	}
+	if _, ok := tx.Milestones[Aborted]; ok {
+		tx.Outcome = OutcomeAborted
+	} else if _, ok := tx.Milestones[Committed]; ok {
+		tx.Outcome = OutcomeCommitted
+	}
+	return tx
+}
+
+// earliest is the first time any chain reached a stage.
+func earliest(chains map[string]map[string]time.Time) (time.Time, bool) {
+	var first time.Time
+	for _, peers := range chains {
+		for _, t := range peers {
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+		}
+	}
+	return first, !first.IsZero()
+}
+
+// latest is the time a chain reached a stage for all its peers, like the
+// relays of every shard applied.
+func latest(peers map[string]time.Time) (time.Time, bool) {
+	var last time.Time
+	for _, t := range peers {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last, !last.IsZero()
+}
+
+func summarize(class string, txs []*Tx) *Summary {
+	s := &Summary{Class: class, Latency: map[Stage]Distribution{}}
+	latencies := map[Stage][]float64{}
+	for _, tx := range txs {
+		if tx.Class != class {
+			continue
+		}
+		s.Txs++
+		switch tx.Outcome {
+		case OutcomeCommitted:
+			s.Committed++
+		case OutcomeAborted:
+			s.Aborted++
+		default:
+			s.Pending++
+		}
+		for stage, seconds := range tx.Milestones {
+			if tx.Outcome == OutcomeAborted && stage != Aborted {
+				// only the stages of txs that went through count
+				continue
+			}
+			latencies[stage] = append(latencies[stage], seconds)
+		}
+	}
+	for stage, values := range latencies {
+		s.Latency[stage] = distribution(values)
+	}
+	return s
+}
+
+func distribution(values []float64) Distribution {
+	sort.Float64s(values)
+	d := Distribution{Count: len(values), Max: values[len(values)-1]}
+	for _, v := range values {
+		d.Mean += v
+	}
+	d.Mean /= float64(len(values))
+	d.P50, d.P95, d.P99 = utils.Percentile(values, 50), utils.Percentile(values, 95), utils.Percentile(values, 99)
+	return d
+}
+
+func (r *Result) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes a row per class and milestone, or with txs a row per tx.
+func (r *Result) WriteCSV(w io.Writer, txs bool) error {
+	out := csv.NewWriter(w)
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+	if txs {
+		header := []string{"tx", "class", "outcome", "shards", "admit"}
+		for _, stage := range Milestones {
+			header = append(header, string(stage)+"_s")
+		}
+		out.Write(header)
+		for _, tx := range r.Txs {
+			row := []string{tx.Hash, tx.Class, tx.Outcome, strings.Join(tx.Shards, " "), tx.Admit.Format(time.RFC3339Nano)}
+			for _, stage := range Milestones {
+				if seconds, ok := tx.Milestones[stage]; ok {
+					row = append(row, f(seconds))
+				} else {
+					row = append(row, "")
+				}
+			}
+			out.Write(row)
+		}
+	} else {
+		out.Write([]string{"class", "stage", "count", "mean_s", "p50_s", "p95_s", "p99_s", "max_s"})
+		for _, s := range r.Summaries {
+			for _, stage := range Milestones {
+				if d, ok := s.Latency[stage]; ok {
+					out.Write([]string{s.Class, string(stage), strconv.Itoa(d.Count), f(d.Mean), f(d.P50), f(d.P95), f(d.P99), f(d.Max)})
+				}
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func (r *Result) WriteText(w io.Writer) {
+	for _, s := range r.Summaries {
+		fmt.Fprintf(w, "%s-shard txs: %d, %d committed, %d aborted, %d pending\n", s.Class, s.Txs, s.Committed, s.Aborted, s.Pending)
+		if len(s.Latency) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %-10s %8s %9s %9s %9s %9s %9s\n", "stage", "txs", "mean (s)", "p50 (s)", "p95 (s)", "p99 (s)", "max (s)")
+		for _, stage := range Milestones {
+			if d, ok := s.Latency[stage]; ok {
+				fmt.Fprintf(w, "  %-10s %8d %9.3f %9.3f %9.3f %9.3f %9.3f\n", stage, d.Count, d.Mean, d.P50, d.P95, d.P99, d.Max)
+			}
+		}
+	}
+}
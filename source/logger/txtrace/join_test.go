@@ -0,0 +1,85 @@
+package txtrace
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJoin(t *testing.T) {
+	dir := t.TempDir()
+	intra, cross, aborted := sha256.Sum256([]byte("intra")), sha256.Sum256([]byte("cross")), sha256.Sum256([]byte("aborted"))
+	shards := []string{"i1", "i2"}
+
+	// every shard writes its own file, the tracer is global
+	if err := Open(dir, "node1", "i1", 1); err != nil {
+		t.Fatal(err)
+	}
+	Record(intra[:], Event{Stage: Admit})
+	Record(cross[:], Event{Stage: Admit, Cross: true})
+	Record(aborted[:], Event{Stage: Admit, Cross: true})
+	Record(intra[:], Event{Stage: Included})
+	Record(cross[:], Event{Stage: Included, Cross: true})
+	Record(intra[:], Event{Stage: Committed, Shards: []string{"i1"}})
+	Record(cross[:], Event{Stage: Locked, Cross: true, Shards: shards})
+	Record(aborted[:], Event{Stage: Aborted, Cross: true, Shards: shards, Reason: "lock_conflict"})
+	Record(cross[:], Event{Stage: Relayed, Cross: true, Peer: "i2"})
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := Open(dir, "node5", "i2", 1); err != nil {
+		t.Fatal(err)
+	}
+	Record(cross[:], Event{Stage: Locked, Cross: true, Shards: shards})
+	Record(cross[:], Event{Stage: Relayed, Cross: true, Peer: "i1"})
+	Record(cross[:], Event{Stage: Unlocked, Cross: true, Peer: "i1"})
+	Record(cross[:], Event{Stage: Committed, Cross: true, Shards: shards})
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	// traced after Close, dropped
+	Record(intra[:], Event{Stage: Aborted})
+
+	r, err := Join([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Txs) != 3 {
+		t.Fatalf("joined %d txs", len(r.Txs))
+	}
+	byClass := map[string]*Summary{}
+	for _, s := range r.Summaries {
+		byClass[s.Class] = s
+	}
+	if s := byClass[Intra]; s.Txs != 1 || s.Committed != 1 || s.Latency[Committed].Count != 1 {
+		t.Fatalf("unexpected intra-shard summary %+v", s)
+	}
+	if s := byClass[Cross]; s.Txs != 2 || s.Committed != 0 || s.Aborted != 1 || s.Pending != 1 {
+		t.Fatalf("unexpected cross-shard summary %+v", s)
+	}
+	for _, tx := range r.Txs {
+		if tx.Class == Cross && tx.Outcome == OutcomePending {
+			// i1 never applied the relay from i2 nor committed
+			if _, ok := tx.Milestones[Locked]; !ok || tx.Milestones[Relayed] < tx.Milestones[Locked] {
+				t.Fatalf("unexpected milestones %+v", tx.Milestones)
+			}
+			if _, ok := tx.Milestones[Committed]; ok {
+				t.Fatalf("committed in only one shard %+v", tx.Milestones)
+			}
+		}
+		if tx.Outcome == OutcomeAborted && tx.Reason != "lock_conflict" {
+			t.Fatalf("unexpected reason %q", tx.Reason)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := r.WriteCSV(&b, true); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(b.String()), "\n"); len(lines) != 4 {
+		t.Fatalf("unexpected csv\n%s", b.String())
+	}
+}
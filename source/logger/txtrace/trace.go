@@ -0,0 +1,121 @@
+package txtrace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The lifecycle of txs, keyed by their hash: every node appends an event to
+// <node>-txtrace.txt when a tx reaches a stage, and Join puts the events of
+// all shards together into end-to-end latencies. Whether a tx is traced only
+// depends on its hash, so every shard traces the same txs.
+
+const Suffix = "-txtrace.txt"
+
+type Stage string
+
+const (
+	// Admit is a tx accepted by a mempool
+	Admit Stage = "admit"
+	// Included is a tx the leader put in a block, in CrossShardTxs if cross
+	Included Stage = "included"
+	// Locked is a cross-shard tx pre-executed with its keys locked
+	Locked Stage = "locked"
+	// Relayed is the relay of a cross-shard tx sent to Peer in a cross-shard
+	// message
+	Relayed Stage = "relayed"
+	// Unlocked is the relay from Peer applied at this shard
+	Unlocked  Stage = "unlocked"
+	Committed Stage = "committed"
+	Aborted   Stage = "aborted"
+)
+
+type Event struct {
+	Tx    string    `json:"tx"`
+	Stage Stage     `json:"stage"`
+	Time  time.Time `json:"time"`
+	Chain string    `json:"chain"`
+	Node  string    `json:"node"`
+
+	Cross bool `json:"cross,omitempty"`
+	// Shards are the shards the tx touches
+	Shards []string `json:"shards,omitempty"`
+	// Peer is the shard a relay is sent to or came from
+	Peer   string `json:"peer,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type tracer struct {
+	mtx         sync.Mutex
+	file        *os.File
+	writer      *bufio.Writer
+	node, chain string
+	// threshold is compared with the first 8 bytes of a tx hash
+	threshold uint64
+}
+
+var current atomic.Pointer[tracer]
+
+// Open starts tracing a sample of the txs, between 0 and 1, to
+// <dir>/<node>-txtrace.txt.
+func Open(dir, node, chain string, sample float64) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, node+Suffix), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	threshold := uint64(math.MaxUint64)
+	if sample < 1 {
+		threshold = uint64(sample * math.MaxUint64)
+	}
+	current.Store(&tracer{file: f, writer: bufio.NewWriterSize(f, 1<<20), node: node, chain: chain, threshold: threshold})
+	return nil
+}
+
+// Close flushes the events and stops tracing.
+func Close() error {
+	t := current.Swap(nil)
+	if t == nil {
+		return nil
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if err := t.writer.Flush(); err != nil {
+		t.file.Close()
+		return err
+	}
+	return t.file.Close()
+}
+
+// Enabled tells whether txs are traced, to skip hashing them otherwise.
+func Enabled() bool { return current.Load() != nil }
+
+// Record writes the event of the tx with hash if the tx is traced, its tx,
+// time, chain and node are filled in.
+func Record(hash []byte, event Event) {
+	t := current.Load()
+	if t == nil || len(hash) < 8 || binary.BigEndian.Uint64(hash) > t.threshold {
+		return
+	}
+	event.Tx = hex.EncodeToString(hash)
+	event.Time = time.Now()
+	event.Chain, event.Node = t.chain, t.node
+	bz, err := json.Marshal(&event)
+	if err != nil {
+		return
+	}
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.writer.Write(bz)
+	t.writer.WriteByte('\n')
+}
@@ -99,6 +99,63 @@ func (x *ABCIExecutionReceipt) GetOptionValue() []string {
 	return nil
 }
 
+// ABCIExecutionResponse is what a block was executed into, as it is kept
+// with the block.
+type ABCIExecutionResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Responses           []*ABCIExecutionReceipt `protobuf:"bytes,1,rep,name=responses,proto3" json:"responses,omitempty"`
+	CrossShardResponses []*ABCIExecutionReceipt `protobuf:"bytes,2,rep,name=cross_shard_responses,json=crossShardResponses,proto3" json:"cross_shard_responses,omitempty"`
+}
+
+func (x *ABCIExecutionResponse) Reset() {
+	*x = ABCIExecutionResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_urd_types_abci_resp_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ABCIExecutionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ABCIExecutionResponse) ProtoMessage() {}
+
+func (x *ABCIExecutionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_urd_types_abci_resp_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ABCIExecutionResponse.ProtoReflect.Descriptor instead.
+func (*ABCIExecutionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_urd_types_abci_resp_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ABCIExecutionResponse) GetResponses() []*ABCIExecutionReceipt {
+	if x != nil {
+		return x.Responses
+	}
+	return nil
+}
+
+func (x *ABCIExecutionResponse) GetCrossShardResponses() []*ABCIExecutionReceipt {
+	if x != nil {
+		return x.CrossShardResponses
+	}
+	return nil
+}
+
 var File_proto_urd_types_abci_resp_proto protoreflect.FileDescriptor
 
 var file_proto_urd_types_abci_resp_proto_rawDesc = []byte{
@@ -113,10 +170,21 @@ var file_proto_urd_types_abci_resp_proto_rawDesc = []byte{
 	0x1c, 0x0a, 0x09, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x4b, 0x65, 0x79, 0x18, 0x04, 0x20, 0x03,
 	0x28, 0x09, 0x52, 0x09, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x4b, 0x65, 0x79, 0x12, 0x20, 0x0a,
 	0x0b, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x05, 0x20, 0x03,
-	0x28, 0x09, 0x52, 0x0b, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x42,
-	0x1a, 0x5a, 0x18, 0x65, 0x6d, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2f, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x2f, 0x75, 0x72, 0x64, 0x2f, 0x74, 0x79, 0x70, 0x65, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x33,
+	0x28, 0x09, 0x52, 0x0b, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x22,
+	0xab, 0x01, 0x0a, 0x15, 0x41, 0x42, 0x43, 0x49, 0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f,
+	0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3d, 0x0a, 0x09, 0x72, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x75,
+	0x72, 0x64, 0x2e, 0x74, 0x79, 0x70, 0x65, 0x73, 0x2e, 0x41, 0x42, 0x43, 0x49, 0x45, 0x78, 0x65,
+	0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x63, 0x65, 0x69, 0x70, 0x74, 0x52, 0x09, 0x72,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73, 0x12, 0x53, 0x0a, 0x15, 0x63, 0x72, 0x6f, 0x73,
+	0x73, 0x5f, 0x73, 0x68, 0x61, 0x72, 0x64, 0x5f, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x75, 0x72, 0x64, 0x2e, 0x74, 0x79,
+	0x70, 0x65, 0x73, 0x2e, 0x41, 0x42, 0x43, 0x49, 0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f,
+	0x6e, 0x52, 0x65, 0x63, 0x65, 0x69, 0x70, 0x74, 0x52, 0x13, 0x63, 0x72, 0x6f, 0x73, 0x73, 0x53,
+	0x68, 0x61, 0x72, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73, 0x42, 0x1a, 0x5a,
+	0x18, 0x65, 0x6d, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f,
+	0x75, 0x72, 0x64, 0x2f, 0x74, 0x79, 0x70, 0x65, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
 }
 
 var (
@@ -131,16 +199,19 @@ func file_proto_urd_types_abci_resp_proto_rawDescGZIP() []byte {
 	return file_proto_urd_types_abci_resp_proto_rawDescData
 }
 
-var file_proto_urd_types_abci_resp_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_proto_urd_types_abci_resp_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
 var file_proto_urd_types_abci_resp_proto_goTypes = []interface{}{
-	(*ABCIExecutionReceipt)(nil), // 0: urd.types.ABCIExecutionReceipt
+	(*ABCIExecutionReceipt)(nil),  // 0: urd.types.ABCIExecutionReceipt
+	(*ABCIExecutionResponse)(nil), // 1: urd.types.ABCIExecutionResponse
 }
 var file_proto_urd_types_abci_resp_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0, // 0: urd.types.ABCIExecutionResponse.responses:type_name -> urd.types.ABCIExecutionReceipt
+	0, // 1: urd.types.ABCIExecutionResponse.cross_shard_responses:type_name -> urd.types.ABCIExecutionReceipt
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_proto_urd_types_abci_resp_proto_init() }
@@ -161,6 +232,18 @@ func file_proto_urd_types_abci_resp_proto_init() {
 				return nil
 			}
 		}
+		file_proto_urd_types_abci_resp_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ABCIExecutionResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -168,7 +251,7 @@ func file_proto_urd_types_abci_resp_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_proto_urd_types_abci_resp_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   1,
+			NumMessages:   2,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -6,16 +6,34 @@ import (
 	"emulator/pyramid/definition"
 	"emulator/pyramid/types"
 	"emulator/utils"
+	"emulator/utils/logging"
+	"emulator/utils/metrics"
 	"emulator/utils/store"
 	"errors"
 	"fmt"
 	"sync"
-	"time"
 
 	"google.golang.org/protobuf/proto"
 )
 
-const initBalance = 1000000
+// DefaultInitialBalance is the balance accounts start with, unless the
+// genesis says otherwise
+const DefaultInitialBalance = 1000000
+
+var initBalance uint32 = DefaultInitialBalance
+
+var log = logging.New("abci")
+
+// The errors txs abort with, by the reason they count under in the abort
+// metrics.
+var (
+	errKeyLocked = metrics.Abort(metrics.AbortKeyLocked, errors.New("Locked primary key accessed"))
+	errBalance   = metrics.Abort(metrics.AbortBalance, errors.New("Balance is not Enough"))
+)
+
+// SetInitialBalance sets the balance accounts start with, from the initial
+// state of the genesis. It must be called before the application is used.
+func SetInitialBalance(balance uint32) { initBalance = balance }
 
 type Application struct {
 	blockDBConn *store.PrefixStore
@@ -94,7 +112,7 @@ func (app *Application) AcceptCommitShard(hashes [][]byte, commits []bool, chain
 	crossShard, crossTotal := 0, 0
 	rt := app.KeyRangeTree[chain_id]
 	for i, hs := range hashes {
-		fmt.Println(i, "Execute cross shard blocks", hs)
+		log.Debug("executing commit of cross-shard block", "index", i, "hash", hs, "commit", commits[i])
 		blockBz, _ := app.blockDBConn.GetBlockByHash(hs)
 		/*
 			if err != nil || len(blockBz) == 0 {
@@ -131,9 +149,7 @@ func (app *Application) AcceptCommitShard(hashes [][]byte, commits []bool, chain
 			}
 			out.Receipts = append(out.Receipts, &types.ABCIExecutionReceipt{Code: types.CodeTypeOK})
 			crossShard += cnt
-			fmt.Printf("(%v)Completed cross-shard block execution for B shards (%s,height=%d) with %d committed transactions\n",
-				time.Now(), block.ChainID, block.Height,
-				block.CrossShardTxs.Size())
+			log.Info("committed cross-shard block", "source_shard", block.ChainID, "block_height", block.Height, "committed", cnt)
 		} else {
 			for key := range writeSet {
 				if !rt.Search(key) {
@@ -142,9 +158,8 @@ func (app *Application) AcceptCommitShard(hashes [][]byte, commits []bool, chain
 				app.undoLock(key)
 			}
 			out.Receipts = append(out.Receipts, &types.ABCIExecutionReceipt{Code: types.CodeTypeAbort})
-			fmt.Printf("(%v) completed the cross-shard block execution of B shard (%s,height=%d), and the number of aborted transactions was %d\n",
-				time.Now(), block.ChainID, block.Height,
-				block.CrossShardTxs.Size())
+			metrics.Aborts.With(metrics.AbortCrossShard).Add(float64(cnt))
+			log.Info("aborted cross-shard block", "source_shard", block.ChainID, "block_height", block.Height, "aborted", cnt)
 		}
 	}
 	if err := batch.WriteSync(); err != nil {
@@ -159,14 +174,12 @@ func (app *Application) AcceptCrossShard(block *types.Block, chain_id string, if
 	app.abciLock.Lock()
 	defer app.abciLock.Unlock()
 	defer func() {
-		words := "lock"
+		phase := "lock"
 		if !ifcommit {
-			words = "Conflict detection"
+			phase = "conflict_detection"
 		}
-		fmt.Printf("(%v) completed a cross-shard block %s of B shards (%s,height=%d), totaling %d transactions\n",
-			time.Now(), block.ChainID, block.Height,
-			words, block.CrossShardTxs.Size())
-		fmt.Println(block.Hash())
+		log.Info("accepted cross-shard block", "source_shard", block.ChainID, "block_height", block.Height,
+			"phase", phase, "txs", block.CrossShardTxs.Size(), "hash", block.Hash())
 		app.blockDBConn.SetBlockByHeight(block.Height, block.ChainID, block)
 	}()
 	receipts := make([]*types.ABCIExecutionReceipt, 0, block.CrossShardTxs.Size())
@@ -192,6 +205,7 @@ func (app *Application) AcceptCrossShard(block *types.Block, chain_id string, if
 			if err != nil {
 				receipt.Code = types.CodeTypeUnknownError
 				receipt.Log = err.Error()
+				metrics.Aborts.With(metrics.AbortReason(err)).Inc()
 			} else {
 				receipt.Code = types.CodeTypeOK
 			}
@@ -244,6 +258,7 @@ func (app *Application) ExecutionInnerShard(block *types.Block) (*types.ABCIExec
 		if err != nil {
 			abciResp.Code = types.CodeTypeAbort
 			abciResp.Log = err.Error()
+			metrics.Aborts.With(metrics.AbortReason(err)).Inc()
 		} else {
 			abciResp.Code = types.CodeTypeOK
 			commitTxsCount++
@@ -254,9 +269,8 @@ func (app *Application) ExecutionInnerShard(block *types.Block) (*types.ABCIExec
 	if err := db.Write(); err != nil {
 		panic(err)
 	}
-	fmt.Printf("(%v) completed the execution of the in-slice block of shard I (%s,height=%d), totaling %d transactions, and the number of committed transactions is %d\n",
-		time.Now(), block.ChainID, block.Height,
-		block.BodyTxs.Size(), commitTxsCount)
+	log.Info("executed intra-shard block", "source_shard", block.ChainID, "block_height", block.Height,
+		"txs", block.BodyTxs.Size(), "committed", commitTxsCount)
 	app.blockDBConn.SetBlockByHeight(block.Height, block.ChainID, block)
 	return &types.ABCIExecutionResponse{Receipts: receipts}, block.BodyTxs.Size(), 0, 0
 }
@@ -459,20 +473,20 @@ func (app *Application) doTransfer(tx *bank.TransferTx, db GetSetMemDB) error {
 	fromBalance, toBalance := make([]uint32, len(tx.From)), make([]uint32, len(tx.To))
 	for i, fromKey := range tx.From {
 		if _, ok := app.LockedKeys[fromKey]; ok {
-			return errors.New("Locked primary key accessed")
+			return errKeyLocked
 		}
 		if balance, err := db.Get(fromKey); err != nil {
 			// return err
 			fromBalance[i] = initBalance - tx.FromMoney[i]
 		} else if balance < tx.FromMoney[i] {
-			return errors.New("Balance is not Enough")
+			return errBalance
 		} else {
 			fromBalance[i] = balance - tx.FromMoney[i]
 		}
 	}
 	for i, toKey := range tx.To {
 		if _, ok := app.LockedKeys[toKey]; ok {
-			return errors.New("Locked primary key accessed")
+			return errKeyLocked
 		}
 		if balance, err := db.Get(toKey); err != nil {
 			toBalance[i] = initBalance + tx.ToMoney[i]
@@ -483,7 +497,7 @@ func (app *Application) doTransfer(tx *bank.TransferTx, db GetSetMemDB) error {
 
 	for i, fromKey := range tx.From {
 		if _, ok := app.LockedKeys[fromKey]; ok {
-			return errors.New("Locked primary key accessed")
+			return errKeyLocked
 		}
 
 		if err := db.Set(fromKey, fromBalance[i]); err != nil {
@@ -492,7 +506,7 @@ func (app *Application) doTransfer(tx *bank.TransferTx, db GetSetMemDB) error {
 	}
 	for i, toKey := range tx.To {
 		if _, ok := app.LockedKeys[toKey]; ok {
-			return errors.New("Locked primary key accessed")
+			return errKeyLocked
 		}
 		if err := db.Set(toKey, toBalance[i]); err != nil {
 			return err
@@ -5,9 +5,8 @@ import (
 	"emulator/pyramid/definition"
 	"emulator/pyramid/types"
 	"emulator/utils"
+	"emulator/utils/logging"
 	"emulator/utils/p2p"
-	"fmt"
-	"log"
 	"math"
 	"math/rand"
 	"strconv"
@@ -15,6 +14,8 @@ import (
 	"time"
 )
 
+var importerLog = logging.New("importer")
+
 type AddTxInterface interface {
 	AddTx(*types.Tx) error
 }
@@ -39,6 +40,8 @@ type Importor struct {
 
 	p2pConn *p2p.Sender
 	myChain string
+
+	quit chan struct{}
 }
 
 var batch = 1
@@ -73,6 +76,25 @@ func NewImportor(
 
 		p2pConn: p2pConn,
 		myChain: chain,
+
+		quit: make(chan struct{}),
+	}
+}
+
+// Stop ends the broadcast of the remaining txs.
+func (im *Importor) Stop() {
+	close(im.quit)
+}
+
+// wait sleeps for d, it returns false once the importor is stopped.
+func (im *Importor) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-im.quit:
+		return false
 	}
 }
 
@@ -85,7 +107,7 @@ func (im *Importor) StartMempool() {
 			rate := int(math.Ceil(im.commit_rate))
 			if rate > 0 {
 				dur := time.Second / time.Duration(rate) * time.Duration(batch)
-				log.Printf("I shard start mempool, broadcast time interval:%d/%v", rate, dur)
+				importerLog.Info("broadcasting txs", "mempool", "intra", "rate", rate, "batch_interval", dur, "txs", len(im.txPool))
 				for len(im.txPool) > 0 {
 					startTime := time.Now()
 					dst := batch
@@ -101,11 +123,11 @@ func (im *Importor) StartMempool() {
 						}
 					}
 					im.txPool = im.txPool[dst:]
-					time.Sleep(time.Until(startTime.Add(dur)))
+					if !im.wait(time.Until(startTime.Add(dur))) {
+						return
+					}
 				}
-				log.Println("=======================================")
-				log.Println("        transaction broadcast end                  ")
-				log.Println("=======================================")
+				importerLog.Info("txs broadcast", "mempool", "intra")
 			}
 		}()
 	} else {
@@ -115,7 +137,7 @@ func (im *Importor) StartMempool() {
 		rate1 := int(math.Ceil(im.commit_rate * (1.0 - im.cross_shard_rate)))
 		if rate1 > 0 {
 			dur1 := time.Second / time.Duration(rate1) * time.Duration(batch)
-			log.Printf("B shard start mempool, broadcast time interval:%d/%v", rate1, dur1)
+			importerLog.Info("broadcasting txs", "mempool", "intra", "rate", rate1, "batch_interval", dur1, "txs", len(im.txPool))
 			go func() {
 				for len(im.txPool) > 0 {
 					startTime := time.Now()
@@ -132,18 +154,18 @@ func (im *Importor) StartMempool() {
 						}
 					}
 					im.txPool = im.txPool[dst:]
-					time.Sleep(time.Until(startTime.Add(dur1)))
+					if !im.wait(time.Until(startTime.Add(dur1))) {
+						return
+					}
 				}
-				log.Println("=======================================")
-				log.Println("        transaction broadcast end                    ")
-				log.Println("=======================================")
+				importerLog.Info("txs broadcast", "mempool", "intra")
 			}()
 		}
 
 		rate2 := int(math.Ceil(im.commit_rate * im.cross_shard_rate))
 		if rate2 > 0 {
 			dur2 := time.Second / time.Duration(rate2) * time.Duration(batch)
-			log.Printf("B shard start mempool, broadcast time interval:%d/%v", rate2, dur2)
+			importerLog.Info("broadcasting txs", "mempool", "cross", "rate", rate2, "batch_interval", dur2, "txs", len(im.cross_shard_txPool))
 			go func() {
 				for len(im.cross_shard_txPool) > 0 {
 					startTime := time.Now()
@@ -160,18 +182,18 @@ func (im *Importor) StartMempool() {
 						}
 					}
 					im.cross_shard_txPool = im.cross_shard_txPool[dst:]
-					time.Sleep(time.Until(startTime.Add(dur2)))
+					if !im.wait(time.Until(startTime.Add(dur2))) {
+						return
+					}
 				}
-				log.Println("=======================================")
-				log.Println("   cross shrad transaction broadcast end              ")
-				log.Println("=======================================")
+				importerLog.Info("txs broadcast", "mempool", "cross")
 			}()
 		}
 	}
 }
 
 func (im *Importor) RandomGenerateTx(n int) {
-	fmt.Println("Generate intra-shard transactions", n)
+	importerLog.Info("generating intra-shard txs", "txs", n)
 	src := rand.NewSource(time.Now().UnixNano())
 	r := rand.New(src)
 	inputSize := im.transferSize / 2
@@ -194,7 +216,7 @@ func (im *Importor) RandomGenerateTx(n int) {
 	}
 }
 func (im *Importor) RandomGenerateCrossShardTx(n int) {
-	fmt.Println("Generate cross-shard transactions", n)
+	importerLog.Info("generating cross-shard txs", "txs", n)
 	src := rand.NewSource(time.Now().UnixNano())
 	r := rand.New(src)
 	inputSize := im.transferSize / 2
@@ -202,7 +224,6 @@ func (im *Importor) RandomGenerateCrossShardTx(n int) {
 	myPrefix := make([]string, 0)
 	for _, prerange := range myPrefixRange {
 		myPrefix = append(myPrefix, prerange.Range()[0][0])
-		fmt.Println(prerange.Range()[0][0])
 	}
 
 	im.cross_shard_txPool = make([]*bank.TransferTx, n)
@@ -0,0 +1,95 @@
+package minibank
+
+import (
+	"emulator/logger/blocklogger"
+	"emulator/pyramid/consensus/constypes"
+	"emulator/pyramid/types"
+	"emulator/utils"
+	"emulator/utils/store"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MeasureLatency goes through the blocks a node committed while it had txs to
+// process, as recorded by its blocklogger, and sums how long their txs took
+// from submission to commit. The node must be stopped.
+func MeasureLatency(rootPath string, chain_id string) (*utils.Latency, error) {
+	nodeName := filepath.Base(rootPath)
+	briefPath := filepath.Join(rootPath, fmt.Sprintf("%s-blocklogger-brief.txt", nodeName))
+	if _, err := os.Stat(briefPath); err != nil {
+		return nil, err
+	}
+	reader, err := blocklogger.NewReader(briefPath)
+	if err != nil {
+		return nil, err
+	}
+	blockRangeA, blockRangeB, err := reader.NoneZeroPeriods()
+	if err != nil {
+		return nil, err
+	}
+
+	db := store.NewPrefixStore("consensus", filepath.Join(rootPath, "database"))
+	defer db.Close()
+
+	latency := new(utils.Latency)
+	for i := 0; i < len(blockRangeA); i++ {
+		start, end := blockRangeA[i], blockRangeB[i]
+		for j := start; j <= end; j++ {
+			var block, blockNext *types.Block
+
+			if bz, err := db.GetBlockByHeight(int64(j), chain_id); err != nil || len(bz) == 0 {
+				continue
+			} else if block = types.NewBlockFromBytes(bz); block == nil {
+				continue
+			}
+			if bz, err := db.GetBlockByHeight(int64(j+1), chain_id); err != nil || len(bz) == 0 {
+				continue
+			} else if blockNext = types.NewBlockFromBytes(bz); blockNext == nil {
+				continue
+			}
+			commitTime := blockNext.Time
+
+			switch block.BlockType {
+			case types.BLOCKTYPE_InnerShard:
+				for _, txBytes := range block.BodyTxs {
+					tx, err := NewTransferTxFromBytes(txBytes)
+					if err != nil {
+						continue
+					}
+					latency.IntraShardTotal += commitTime.Sub(utils.ThirdPartyUnmarshalTime(tx.Time))
+					latency.IntraShardTxs++
+				}
+			case types.BLOCKTYPE_BCommitBlock:
+				cmt, err := constypes.NewMessageAcceptSetFromBytes(block.BodyTxs[0])
+				if err != nil {
+					return nil, err
+				}
+				isok := true
+				for _, ma := range cmt.Accepts {
+					if !ma.CollectiveSignatures.IsOK() {
+						isok = false
+					}
+				}
+				if isok {
+					ublockBz, err := db.GetBlockByHash(cmt.BlockHash)
+					if err != nil {
+						return nil, err
+					}
+					ublock := types.NewBlockFromBytes(ublockBz)
+					for _, txBytes := range ublock.CrossShardTxs {
+						tx, err := NewTransferTxFromBytes(txBytes)
+						if err != nil {
+							continue
+						}
+						latency.CrossShardTotal += commitTime.Sub(utils.ThirdPartyUnmarshalTime(tx.Time)) * time.Duration(4) / time.Duration(3)
+						latency.CrossShardTxs++
+					}
+				}
+			}
+
+		}
+	}
+	return latency, nil
+}
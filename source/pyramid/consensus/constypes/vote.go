@@ -387,7 +387,7 @@ func (p *PrecommitAggregated) Precommit() *Precommit {
 	return precommit
 }
 
-func (p *PrecommitAggregated) VerifySignatures(verifier *signer.Verifier, bitVectorBytes []byte) bool {
+func (p *PrecommitAggregated) VerifySignatures(verifier signer.Verifier, bitVectorBytes []byte) bool {
 	if verifier == nil {
 		return false
 	}
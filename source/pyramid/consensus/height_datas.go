@@ -7,7 +7,6 @@ import (
 	"emulator/utils/p2p"
 	crypto "emulator/utils/signer"
 	"fmt"
-	"log"
 )
 
 var (
@@ -22,7 +21,7 @@ type HeightDataPackage struct {
 
 	ShardInfo       *shardinfo.ShardInfo
 	ShardVotes      map[string]int
-	AllValidatorSet map[string]*crypto.Verifier
+	AllValidatorSet map[string]crypto.Verifier
 
 	Proposal *constypes.Proposal
 
@@ -48,7 +47,9 @@ func NewHeightData(mychainid string, height int64, si *shardinfo.ShardInfo, send
 	h.PassStep = RoundStepNewHeight
 	h.MyChainID = mychainid
 	h.p2pConn = sender
-	h.SetShardInfo(si)
+	if err := h.SetShardInfo(si); err != nil {
+		panic(err)
+	}
 	h.clearAll()
 	h.randomHeaderBuffer = nil
 	return h
@@ -59,8 +60,8 @@ func (h *HeightDataPackage) NextHeight() {
 	h.PassStep = RoundStepNewHeight
 	h.clearAll()
 }
-func (h *HeightDataPackage) setKeys(pubkeys []string, chain_id string) error {
-	verifier, err := crypto.NewVerifier(pubkeys)
+func (h *HeightDataPackage) setKeys(pubkeys []string, pops []string, chain_id string) error {
+	verifier, err := crypto.NewVerifierWithPop(pubkeys, pops)
 	if err != nil {
 		return err
 	} else if verifier == nil {
@@ -72,18 +73,20 @@ func (h *HeightDataPackage) setKeys(pubkeys []string, chain_id string) error {
 
 func (h *HeightDataPackage) SetShardInfo(s *shardinfo.ShardInfo) error {
 	h.ShardInfo = s
-	h.AllValidatorSet = make(map[string]*crypto.Verifier)
+	h.AllValidatorSet = make(map[string]crypto.Verifier)
 	h.ShardVotes = make(map[string]int)
 	//h.AllValidatorSetSize = make(map[string]int)
 	for chain_id, peers := range s.PeerList {
 		totalVotes := 0
 		peerPubKeys := make([]string, len(peers))
+		peerPops := make([]string, len(peers))
 		for i, peer := range peers {
 			peerPubKeys[i] = peer.Pubkey
+			peerPops[i] = peer.Pop
 			totalVotes += int(peer.Vote)
 		}
-		if err := h.setKeys(peerPubKeys, chain_id); err != nil {
-			return err
+		if err := h.setKeys(peerPubKeys, peerPops, chain_id); err != nil {
+			return fmt.Errorf("shard %s: %v", chain_id, err)
 		}
 		h.ShardVotes[chain_id] = totalVotes
 	}
@@ -157,7 +160,7 @@ func (h *HeightDataPackage) AddProposal(p *constypes.Proposal, index int) error
 	if h.Proposal != nil {
 		return DoNothing
 	}
-	log.Println("Start processing the Proposal")
+	log.Debug("processing proposal", "view", h.Height, "proposer", p.ProposerIndex)
 	if h.Height != p.Header.Height || h.Round != p.Header.Round {
 		return DoNothing
 	}
@@ -187,7 +190,7 @@ func (h *HeightDataPackage) AddPart(p *types.Part) error {
 	}
 	if h.PartSet == nil {
 		h.randomHeaderBuffer = append(h.randomHeaderBuffer, p)
-		log.Println("Part joins the cache pool")
+		log.Debug("buffering part before the proposal", "view", h.Height, "index", p.Index())
 		return DoNothing
 	} else if err := h.PartSet.AddPart(p); err != nil && err != types.DuplicatedPartPassError {
 		return err
@@ -199,7 +202,7 @@ func (h *HeightDataPackage) AddPart(p *types.Part) error {
 			if err != nil {
 				return err
 			}
-			log.Printf("(height=%d) shard %s block has been collected\n", h.Height, p.ChainID)
+			log.Debug("block complete", "view", h.Height, "source_shard", p.ChainID)
 			h.Block = block
 		}
 	}
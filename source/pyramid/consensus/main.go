@@ -9,28 +9,38 @@ import (
 	inter "emulator/pyramid/definition"
 	"emulator/pyramid/shardinfo"
 	types "emulator/pyramid/types"
+	"emulator/utils/logging"
+	"emulator/utils/metrics"
 	p2p "emulator/utils/p2p"
 	sig "emulator/utils/signer"
+	"emulator/utils/status"
 	store "emulator/utils/store"
+	"encoding/hex"
 	"fmt"
-	"log"
 	"math/rand"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var log = logging.New("consensus")
+
 type ConsensusState struct {
 	Height int64 `json:"height"`
 	Round  int   `json:"round"`
 	Step   int8  `json:"step"`
 
+	// MaxHeight ends the run once this height is reached, 0 runs until Stop
+	MaxHeight int64 `json:"-"`
+
 	mempool             inter.MempoolConn
 	cross_shard_mempool inter.MempoolConn
 	abci                inter.ABCIConn
 	p2p                 *p2p.Sender
 	store               *store.PrefixStore
 
-	signer      *sig.Signer
+	signer      sig.Signer
 	signerIndex int
 
 	heightDatas         *HeightDataPackage
@@ -50,6 +60,12 @@ type ConsensusState struct {
 	logger blocklogger.BlockWriter
 
 	stateMtx sync.Mutex
+	// qcs holds the collective signatures of the other shards verified
+	// without stateMtx
+	qcs *qcCache
+	// published is what Status reports, it is refreshed under stateMtx so
+	// that Status does not wait for an execution
+	published atomic.Pointer[status.Consensus]
 
 	crossShardBlockPool map[string]*constypes.CrossShardBlock
 	commitPool          map[string]*constypes.MessageCommit
@@ -62,10 +78,20 @@ type ConsensusState struct {
 	maCount                    int
 	maPool                     map[string]*constypes.MessageAccept
 	shardHeight                map[string]int64
+
+	// heightStart, heightVotes and commitWaitStart feed the metrics of a
+	// height
+	heightStart     time.Time
+	heightVotes     int
+	commitWaitStart time.Time
+
+	done     chan struct{}
+	doneOnce sync.Once
+	stopped  bool
 }
 
 func NewConsensusState(chain_id string, si *shardinfo.ShardInfo,
-	signer *sig.Signer, signerIndex int,
+	signer sig.Signer, signerIndex int,
 	mempool inter.MempoolConn, cross_shard_mempool inter.MempoolConn,
 	abci inter.ABCIConn, sender *p2p.Sender,
 	storeDir string, minBlockInterval time.Duration, maxPartSize int, maxBlockTxNum int,
@@ -102,6 +128,7 @@ func NewConsensusState(chain_id string, si *shardinfo.ShardInfo,
 		logger: logger,
 
 		stateMtx: sync.Mutex{},
+		qcs:      newQCCache(),
 
 		maCount: -1,
 
@@ -110,6 +137,8 @@ func NewConsensusState(chain_id string, si *shardinfo.ShardInfo,
 		committed:           make(map[string]bool),
 
 		shardHeight: make(map[string]int64),
+
+		done: make(chan struct{}),
 	}
 	cs.abci.SetBlockStore(cs.store)
 	return cs
@@ -118,19 +147,50 @@ func NewConsensusState(chain_id string, si *shardinfo.ShardInfo,
 func (cs *ConsensusState) Start() {
 	cs.stateMtx.Lock()
 	defer cs.stateMtx.Unlock()
+	if cs.stopped {
+		return
+	}
 	if cs.Height == 0 {
 		cs.enterNewHeight()
 	} else {
 		cs.handleStateTransition()
 	}
 }
+
+// Stop waits for the message being handled, drops the later ones and closes
+// the store. It can be called more than once.
 func (cs *ConsensusState) Stop() {
+	cs.stateMtx.Lock()
+	defer cs.stateMtx.Unlock()
+	if cs.stopped {
+		return
+	}
+	cs.stopped = true
+	cs.finish()
 	cs.store.Close()
 }
+
+// Done is closed once MaxHeight is reached or the state is stopped.
+func (cs *ConsensusState) Done() <-chan struct{} { return cs.done }
+
+func (cs *ConsensusState) finish() {
+	cs.doneOnce.Do(func() { close(cs.done) })
+}
 func (cs *ConsensusState) WriteLogger(msg string, is_start, is_end bool) {
 	cs.logger.Write(blocklogger.NewConsensusEvent(cs.Height, int32(cs.Round), RoundStepString(cs.Step), is_start, is_end, msg))
 }
 
+// WriteFinish logs the end of the height with the txs of its block.
+func (cs *ConsensusState) WriteFinish(inner, commit, count int) {
+	cs.logger.Write(blocklogger.NewFinishEvent(cs.Height, int32(cs.Round), RoundStepString(cs.Step), inner, commit, count))
+}
+
+// viewLog is the logger of the height, round and step the state is in, the
+// height is logged as the view like urd does.
+func (cs *ConsensusState) viewLog() *logging.Logger {
+	return log.With("view", cs.Height, "round", cs.Round, "step", RoundStepString(cs.Step))
+}
+
 func (cs *ConsensusState) IsIShard() bool { return cs.heightDatas.IsIShard() }
 func (cs *ConsensusState) IsBShard() bool { return cs.heightDatas.IsBShard() }
 
@@ -146,6 +206,13 @@ func (cs *ConsensusState) addRelayMessageIfOverTime(height int64, round int, msg
 	return false
 }
 
+// verifyQC verifies the collective signatures of a shard. Receive calls it
+// before taking stateMtx, the blocks embedding the same signatures then find
+// them in the cache.
+func (cs *ConsensusState) verifyQC(sig *constypes.PrecommitAggregated) bool {
+	return cs.qcs.verify(sig, cs.heightDatas.AllValidatorSet[sig.Header.ChainID])
+}
+
 func (cs *ConsensusState) Next() {
 	if cs.Step == RoundStepCommit {
 		delete(cs.relayMessageChannel, cs.Height)
@@ -155,6 +222,7 @@ func (cs *ConsensusState) Next() {
 	} else {
 		cs.Step++
 	}
+	cs.publishStatus()
 }
 
 func (cs *ConsensusState) Receive(channel_id byte, bz []byte, messageType uint32) error {
@@ -167,8 +235,9 @@ func (cs *ConsensusState) Receive(channel_id byte, bz []byte, messageType uint32
 		if err := part.ValidateBasic(); err != nil {
 			return err
 		}
+		metrics.PartsReceived.Inc()
 		cs.stateMtx.Lock()
-		fmt.Printf("%v (height = % d, step = % s) received Part (height = % d, chain = % s, index = % d)\n", time.Now(), cs.Height, RoundStepString(cs.Step), part.Height, part.ChainID, part.Index())
+		cs.viewLog().Debug("received part", "msg_type", "Part", "part_height", part.Height, "source_shard", part.ChainID, "index", part.Index())
 		defer cs.stateMtx.Unlock()
 		err := cs.doMessage(part)
 		return err
@@ -182,7 +251,7 @@ func (cs *ConsensusState) Receive(channel_id byte, bz []byte, messageType uint32
 			return err
 		}
 		cs.stateMtx.Lock()
-		fmt.Printf("%v (height=%d,step=%s) Proposal received (height=%d,chain=%s,index=%d)\n", time.Now(), cs.Height, RoundStepString(cs.Step), proposal.Header.Height, proposal.Header.ChainID, proposal.ProposerIndex)
+		cs.viewLog().Debug("received proposal", "msg_type", "Proposal", "proposal_height", proposal.Header.Height, "source_shard", proposal.Header.ChainID, "proposer", proposal.ProposerIndex)
 		defer cs.stateMtx.Unlock()
 		err := cs.doMessage(proposal)
 		return err
@@ -196,7 +265,7 @@ func (cs *ConsensusState) Receive(channel_id byte, bz []byte, messageType uint32
 			return err
 		}
 		cs.stateMtx.Lock()
-		fmt.Printf("%v (height=%d,step=%s) received  Prevote(height=%d,index=%d)\n", time.Now(), cs.Height, RoundStepString(cs.Step), prevote.Height, prevote.ValidatorIndex)
+		cs.viewLog().Debug("received prevote", "msg_type", "Prevote", "vote_height", prevote.Height, "validator", prevote.ValidatorIndex)
 		defer cs.stateMtx.Unlock()
 		err := cs.doMessage(prevote)
 		return err
@@ -210,7 +279,7 @@ func (cs *ConsensusState) Receive(channel_id byte, bz []byte, messageType uint32
 			return err
 		}
 		cs.stateMtx.Lock()
-		fmt.Printf("%v (height=%d,step=%s) received Precommit(height=%d,index=%d)\n", time.Now(), cs.Height, RoundStepString(cs.Step), precommit.Height, precommit.ValidatorIndex)
+		cs.viewLog().Debug("received precommit", "msg_type", "Precommit", "vote_height", precommit.Height, "validator", precommit.ValidatorIndex)
 		defer cs.stateMtx.Unlock()
 		err := cs.doMessage(precommit)
 		return err
@@ -225,9 +294,12 @@ func (cs *ConsensusState) Receive(channel_id byte, bz []byte, messageType uint32
 		if err := csb.ColleciveSignatures.ValidateBasic(); err != nil {
 			return err
 		}
+		if !cs.verifyQC(csb.ColleciveSignatures) {
+			return fmt.Errorf("CrossShardBlock signature verification failed")
+		}
 
 		cs.stateMtx.Lock()
-		fmt.Printf("%v (height=%d,step=%s) received CrossShardBlock(height=%d,type=%d)\n", time.Now(), cs.Height, RoundStepString(cs.Step), csb.Block.Height, csb.Block.BlockType)
+		cs.viewLog().Debug("received cross-shard block", "msg_type", "CrossShardBlock", "block_height", csb.Block.Height, "source_shard", csb.Block.ChainID, "block_type", csb.Block.BlockType)
 		defer cs.stateMtx.Unlock()
 		err = cs.doMessage(csb)
 		return err
@@ -239,9 +311,12 @@ func (cs *ConsensusState) Receive(channel_id byte, bz []byte, messageType uint32
 		if err := ma.CollectiveSignatures.ValidateBasic(); err != nil {
 			return err
 		}
+		if !cs.verifyQC(ma.CollectiveSignatures) {
+			return fmt.Errorf("MessageAccept signature verification failed")
+		}
 
 		cs.stateMtx.Lock()
-		fmt.Printf("%v (height=%d,step=%s) MessageAccept(height=%d,code=%d)\n", time.Now(), cs.Height, RoundStepString(cs.Step), ma.CollectiveSignatures.Header.Height, ma.CollectiveSignatures.Code)
+		cs.viewLog().Debug("received accept", "msg_type", "MessageAccept", "block_height", ma.CollectiveSignatures.Header.Height, "code", ma.CollectiveSignatures.Code)
 		defer cs.stateMtx.Unlock()
 		err = cs.doMessage(ma)
 		return err
@@ -253,9 +328,12 @@ func (cs *ConsensusState) Receive(channel_id byte, bz []byte, messageType uint32
 		if err := mc.CollectiveSignatures.ValidateBasic(); err != nil {
 			return err
 		}
+		if !cs.verifyQC(mc.CollectiveSignatures) {
+			return fmt.Errorf("MessageCommit signature verification failed")
+		}
 
 		cs.stateMtx.Lock()
-		fmt.Printf("%v (height=%d,step=%s) MessageCommit(height=%d,code=%d,chain=%s) received\n", time.Now(), cs.Height, RoundStepString(cs.Step), mc.CollectiveSignatures.Header.Height, mc.CollectiveSignatures.Code, mc.CollectiveSignatures.Header.ChainID)
+		cs.viewLog().Debug("received commit", "msg_type", "MessageCommit", "block_height", mc.CollectiveSignatures.Header.Height, "code", mc.CollectiveSignatures.Code, "source_shard", mc.CollectiveSignatures.Header.ChainID)
 		defer cs.stateMtx.Unlock()
 		err = cs.doMessage(mc)
 		return err
@@ -265,7 +343,7 @@ func (cs *ConsensusState) Receive(channel_id byte, bz []byte, messageType uint32
 			return fmt.Errorf("MO Unmarshal Error: " + err.Error())
 		}
 		cs.stateMtx.Lock()
-		fmt.Printf("%v (height=%d,step=%s) Received MessageOK(height=%d,chain=%s)\n", time.Now(), cs.Height, RoundStepString(cs.Step), mo.Height, mo.SrcChain)
+		cs.viewLog().Debug("received ok", "msg_type", "MessageOK", "block_height", mo.Height, "source_shard", mo.SrcChain)
 		defer cs.stateMtx.Unlock()
 		err = cs.doMessage(mo)
 		return err
@@ -275,6 +353,9 @@ func (cs *ConsensusState) Receive(channel_id byte, bz []byte, messageType uint32
 }
 
 func (cs *ConsensusState) doMessage(msg interface{}) error {
+	if cs.stopped {
+		return nil
+	}
 	switch m := msg.(type) {
 	case *types.Part:
 		if cs.addRelayMessageIfOverTime(m.Height, m.Round, m) {
@@ -309,6 +390,7 @@ func (cs *ConsensusState) doMessage(msg interface{}) error {
 			}
 			return err
 		}
+		cs.heightVotes++
 		err := cs.handleStateTransition()
 		return err
 	case *constypes.Precommit:
@@ -321,6 +403,7 @@ func (cs *ConsensusState) doMessage(msg interface{}) error {
 			}
 			return err
 		}
+		cs.heightVotes++
 		err := cs.handleStateTransition()
 		return err
 	case *constypes.CrossShardBlock:
@@ -331,12 +414,10 @@ func (cs *ConsensusState) doMessage(msg interface{}) error {
 		if has, _ := cs.store.Has(m.Block.Hash()); has {
 			return nil
 		}
-		if !m.ColleciveSignatures.VerifySignatures(cs.heightDatas.AllValidatorSet[chain_id], m.ColleciveSignatures.ValidatorBitVector.Byte()) {
-			return fmt.Errorf("CrossShardBlock signature verification failed")
-		}
 		if cs.IsIShard() {
 			cs.crossShardBlockPool[string(m.Block.Hash())] = m
 		} else {
+			cs.observeCrossShardWait(chain_id)
 			cs.store.SetBlockByHeight(m.ColleciveSignatures.Header.Height, chain_id, m.Block)
 			i0 := cs.shardHeight[chain_id] + 1
 			for {
@@ -345,12 +426,13 @@ func (cs *ConsensusState) doMessage(msg interface{}) error {
 				} else {
 					iblock := types.NewBlockFromBytes(blockBz)
 					_, c1, c2, c3 := cs.processIBlock(iblock, chain_id, false, m.ColleciveSignatures.IsOK())
-					log.Printf("B shard synchronizes I shard state: %d intra-shard transaction,%d cross-shard transaction, commit %d\n", c1, c3, c2)
+					cs.viewLog().Info("synchronized I-shard block", "source_shard", chain_id, "block_height", i0, "intra_shard_txs", c1, "cross_shard_txs", c3, "committed", c2)
 				}
 				cs.shardHeight[chain_id] = i0
 				i0++
 			}
 		}
+		cs.publishStatus()
 		err := cs.handleStateTransition()
 		return err
 	case *constypes.MessageAccept:
@@ -361,27 +443,21 @@ func (cs *ConsensusState) doMessage(msg interface{}) error {
 		if !bytes.Equal(m.B_BlockHash, cs.pendingCrossShardBlockHash) {
 			return nil
 		}
-		if !m.CollectiveSignatures.VerifySignatures(cs.heightDatas.AllValidatorSet[chain_id], m.CollectiveSignatures.ValidatorBitVector.Byte()) {
-			return fmt.Errorf("MessageAccept signature verification failed")
-		}
 		if _, ok := cs.maPool[chain_id]; ok {
 			return nil
 		}
 		cs.maCount--
 		cs.maPool[chain_id] = m
+		cs.publishStatus()
 		err := cs.handleStateTransition()
 		return err
 	case *constypes.MessageCommit:
-		chain_id := m.CollectiveSignatures.Header.ChainID
 		if cs.IsBShard() {
 			return fmt.Errorf("Shard B received a Commit message")
 		}
 		if cs.committed[string(m.B_BlockHash)] {
 			return nil
 		}
-		if !m.CollectiveSignatures.VerifySignatures(cs.heightDatas.AllValidatorSet[chain_id], m.CollectiveSignatures.ValidatorBitVector.Byte()) {
-			return fmt.Errorf("MessageCommit signature verification failed")
-		}
 		cs.commitPool[string(m.B_BlockHash)] = m
 		err := cs.handleStateTransition()
 		return err
@@ -397,6 +473,8 @@ func (cs *ConsensusState) doMessage(msg interface{}) error {
 		}
 		cs.bseen[m.SrcChain] = true
 		cs.bcount--
+		cs.observeCrossShardWait(m.SrcChain)
+		cs.publishStatus()
 		err := cs.handleStateTransition()
 		return err
 	default:
@@ -412,7 +490,7 @@ func (cs *ConsensusState) handleStateTransition() error {
 			cs.doPropose()
 			cs.WriteLogger("Enter Prevote", false, false)
 		} else {
-			fmt.Println("I didn't finish the Propose phase")
+			cs.viewLog().Debug("waiting for the proposal")
 			return nil
 		}
 	case RoundStepPrevote:
@@ -439,9 +517,9 @@ func (cs *ConsensusState) handleStateTransition() error {
 			}
 		} else {
 			for cid := range cs.heightDatas.ShardInfo.RelatedShards {
-				fmt.Println(cid)
 				height := cs.shardHeight[cid]
 				if height < cs.Height {
+					cs.viewLog().Debug("waiting for a related shard", "related_shard", cid, "related_height", height)
 					return nil
 				}
 			}
@@ -461,22 +539,64 @@ func (cs *ConsensusState) isProposer() bool {
 	return cs.calculateProposer() == cs.signerIndex
 }
 
+// Status reports the height of the node and, at commit, the related shards
+// it still waits for, as of the last step change or message.
+func (cs *ConsensusState) Status() status.Consensus {
+	if s := cs.published.Load(); s != nil {
+		return *s
+	}
+	return status.Consensus{WaitingFor: []string{}}
+}
+
+// publishStatus refreshes what Status reports, it is called with stateMtx
+// held.
+func (cs *ConsensusState) publishStatus() {
+	s := status.Consensus{
+		Leader:     cs.isProposer(),
+		View:       cs.Height,
+		Round:      int64(cs.Round),
+		Step:       RoundStepString(cs.Step),
+		WaitingFor: []string{},
+	}
+	for cid := range cs.heightDatas.ShardInfo.RelatedShards {
+		waiting := false
+		if cs.Step == RoundStepCommit {
+			if cs.IsIShard() {
+				waiting = !cs.bseen[cid]
+			} else {
+				waiting = cs.shardHeight[cid] < cs.Height
+			}
+		}
+		if _, ok := cs.maPool[cid]; cs.maCount > 0 && !ok {
+			waiting = true
+		}
+		if waiting {
+			s.WaitingFor = append(s.WaitingFor, cid)
+		}
+	}
+	sort.Strings(s.WaitingFor)
+	if cs.Height > 0 {
+		s.Tip = status.Tip{View: cs.Height - 1, Hash: hex.EncodeToString(cs.LastBlockHash)}
+	}
+	cs.published.Store(&s)
+}
+
 func (cs *ConsensusState) doPropose() {
 	proposalBlock := cs.heightDatas.Block
 	cs.BlockHash = proposalBlock.Hash()
 	flag := true
 	pvote := true
 	if cs.IsBShard() {
+		cs.viewLog().Debug("checking proposal", "block_type", proposalBlock.BlockType)
 		switch proposalBlock.BlockType {
 		case types.BLOCKTYPE_InnerShard:
 			flag = true
-			fmt.Println("BlockType Inner")
 		case types.BLOCKTYPE_CrossShard:
+			start := time.Now()
 			resp := cs.abci.PreExecutionB(proposalBlock)
+			metrics.ExecutionDuration.With("pre_execution").ObserveSince(start)
 			flag = resp.IsOK() //&& utils.CompareBytesList(resp.CrossShardDatas, proposalBlock.CrossShardDatas)
-			fmt.Println("BlockType Cross")
 		case types.BLOCKTYPE_BCommitBlock:
-			fmt.Println("BlockType Commit")
 			if proposalBlock.BodyTxs.Size() != 1 {
 				flag = false
 			} else if ba, err := constypes.NewMessageAcceptSetFromBytes(proposalBlock.BodyTxs[0]); err != nil {
@@ -491,7 +611,7 @@ func (cs *ConsensusState) doPropose() {
 						flag = false
 						break
 					}
-					if !ma.CollectiveSignatures.VerifySignatures(cs.heightDatas.AllValidatorSet[ma.CollectiveSignatures.Header.ChainID], ma.CollectiveSignatures.ValidatorBitVector.Byte()) {
+					if !cs.verifyQC(ma.CollectiveSignatures) {
 						flag = false
 						break
 					}
@@ -515,10 +635,12 @@ func (cs *ConsensusState) doPropose() {
 				flag = false
 			} else if sig := block.ColleciveSignatures; !cs.heightDatas.ShardInfo.RelatedShards[sig.Header.ChainID] {
 				flag = false
-			} else if !sig.VerifySignatures(cs.heightDatas.AllValidatorSet[sig.Header.ChainID], sig.ValidatorBitVector.Byte()) {
+			} else if !cs.verifyQC(sig) {
 				flag = false
 			} else {
+				start := time.Now()
 				resp := cs.abci.PreExecutionI(block.Block)
+				metrics.ExecutionDuration.With("pre_execution").ObserveSince(start)
 				pvote = resp.IsOK()
 			}
 		case types.BLOCKTYPE_ICommitBlock:
@@ -529,7 +651,7 @@ func (cs *ConsensusState) doPropose() {
 				} else if !cs.heightDatas.ShardInfo.RelatedShards[mc.CollectiveSignatures.Header.ChainID] {
 					flag = false
 					break
-				} else if !mc.CollectiveSignatures.VerifySignatures(cs.heightDatas.AllValidatorSet[mc.CollectiveSignatures.Header.ChainID], mc.CollectiveSignatures.ValidatorBitVector.Byte()) {
+				} else if !cs.verifyQC(mc.CollectiveSignatures) {
 					flag = false
 					break
 				}
@@ -591,9 +713,21 @@ func (cs *ConsensusState) doPrecommit() {
 
 	cs.store.SetBlockByHeight(block.Height, block.ChainID, block)
 
-	cs.WriteLogger(fmt.Sprintf("finish[%d,%d,%d]", innerShardCount, crossShardCommit, crossShardCount), false, true)
+	cs.WriteFinish(innerShardCount, crossShardCommit, crossShardCount)
 
 	cs.Next()
+	// the commit step waits for the related shards
+	cs.commitWaitStart = time.Now()
+}
+
+// observeCrossShardWait records how long the commit step waited for a
+// related shard, 0 if the shard was ready before.
+func (cs *ConsensusState) observeCrossShardWait(chain_id string) {
+	wait := time.Duration(0)
+	if cs.Step == RoundStepCommit && !cs.commitWaitStart.IsZero() {
+		wait = time.Since(cs.commitWaitStart)
+	}
+	metrics.CrossShardWait.With(chain_id).Observe(wait.Seconds())
 }
 
 func (cs *ConsensusState) doCommit() {
@@ -603,7 +737,7 @@ func (cs *ConsensusState) doCommit() {
 	}
 	if cs.isProposer() && cs.IsBShard() {
 		for chain_id := range cs.heightDatas.ShardInfo.RelatedShards {
-			fmt.Printf("Broadcast MessageOK to the %s shard\n", chain_id)
+			cs.viewLog().Debug("broadcasting ok", "msg_type", "MessageOK", "target_shard", chain_id)
 			cs.SendTo(chain_id, messageOK.ProtoBytes(), definition.MessageOK)
 		}
 
@@ -670,23 +804,27 @@ func (cs *ConsensusState) processIBlock(proposalBlock *types.Block, chain_id str
 
 	switch proposalBlock.BlockType {
 	case types.BLOCKTYPE_InnerShard:
-		log.Println("Ishard performs intra-shard blocks")
+		cs.viewLog().Debug("executing intra-shard block", "source_shard", chain_id)
+		start := time.Now()
 		resp, innerShardCount, crossShardCommit, crossShardCount = cs.abci.ExecutionInnerShard(proposalBlock)
+		metrics.ExecutionDuration.With("inner_shard").ObserveSince(start)
 		if isConsensusRound {
 			cs.updateIState(proposalBlock, proposalBlock.Hash())
 		}
 		return
 	case types.BLOCKTYPE_IAcceptBlock:
-		log.Println("I shard process the Acceptblock")
+		cs.viewLog().Debug("executing accept block", "source_shard", chain_id)
 		blocks, err := constypes.NewCrossShardBlockFromBytes(proposalBlock.BodyTxs[0])
 		if err != nil {
 			panic(err)
 		}
+		start := time.Now()
 		if isConsensusRound {
 			resp, innerShardCount, crossShardCommit, crossShardCount = cs.abci.AcceptCrossShard(blocks.Block, chain_id, aggSig.IsOK())
 		} else {
 			resp, innerShardCount, crossShardCommit, crossShardCount = cs.abci.AcceptCrossShard(blocks.Block, chain_id, isok)
 		}
+		metrics.ExecutionDuration.With("accept_cross_shard").ObserveSince(start)
 		ma := &constypes.MessageAccept{
 			B_BlockHash:          blocks.Block.Hash(),
 			CollectiveSignatures: aggSig,
@@ -699,7 +837,7 @@ func (cs *ConsensusState) processIBlock(proposalBlock *types.Block, chain_id str
 		}
 		return
 	case types.BLOCKTYPE_ICommitBlock:
-		log.Println("I shards process Commitblock")
+		cs.viewLog().Debug("executing commit block", "source_shard", chain_id)
 		hashes, commits := [][]byte{}, []bool{}
 		for _, tx := range proposalBlock.BodyTxs {
 			if mc, err := constypes.NewMessageCommitFromBytes(tx); err != nil {
@@ -709,7 +847,9 @@ func (cs *ConsensusState) processIBlock(proposalBlock *types.Block, chain_id str
 				commits = append(commits, mc.CollectiveSignatures.IsOK())
 			}
 		}
+		start := time.Now()
 		resp, innerShardCount, crossShardCommit, crossShardCount = cs.abci.AcceptCommitShard(hashes, commits, chain_id)
+		metrics.ExecutionDuration.With("commit_cross_shard").ObserveSince(start)
 		if isConsensusRound {
 			for _, hs := range hashes {
 				cs.updateIState(proposalBlock, hs)
@@ -725,7 +865,9 @@ func (cs *ConsensusState) processBBlock(proposalBlock *types.Block, chain_id str
 	resp = new(types.ABCIExecutionResponse)
 	switch proposalBlock.BlockType {
 	case types.BLOCKTYPE_InnerShard:
+		start := time.Now()
 		resp, innerShardCount, crossShardCommit, crossShardCount = cs.abci.ExecutionInnerShard(proposalBlock)
+		metrics.ExecutionDuration.With("inner_shard").ObserveSince(start)
 		if isConsensusRound {
 			cs.updateBState(proposalBlock, proposalBlock.Hash(), false)
 		}
@@ -787,6 +929,18 @@ func (cs *ConsensusState) enterNewHeight() {
 		cs.bseen = map[string]bool{}
 	}
 	cs.Next()
+	if !cs.heightStart.IsZero() {
+		metrics.ViewDuration.ObserveSince(cs.heightStart)
+		metrics.VotesPerView.Observe(float64(cs.heightVotes))
+	}
+	cs.heightStart, cs.heightVotes, cs.commitWaitStart = time.Now(), 0, time.Time{}
+	metrics.View.Set(float64(cs.Height))
+	if cs.MaxHeight > 0 && cs.Height > cs.MaxHeight {
+		// the node stops driving consensus, InitNode shuts it down
+		cs.viewLog().Info("max_height reached, stopping", "max_height", cs.MaxHeight)
+		cs.finish()
+		return
+	}
 	cs.WriteLogger("enter New Height", true, false)
 	cs.heightDatas.NextHeight()
 
@@ -806,7 +960,7 @@ func (cs *ConsensusState) enterNewRound() {
 	}
 }
 func (cs *ConsensusState) createBlockAndBroadcast() {
-	log.Println("Start generating blocks and broadcast them")
+	cs.viewLog().Debug("generating block")
 	var block *types.Block
 	if cs.IsBShard() {
 		block = cs.createBBlockTxs()
@@ -838,8 +992,7 @@ func (cs *ConsensusState) createBlockAndBroadcast() {
 	} else {
 		proposal.Signature = sig
 	}
-	log.Println("PartSize", len(part_set.Parts))
-	log.Println("Proposal", *proposal)
+	cs.viewLog().Info("generated block", "hash", part_set.BlockHeaderHash, "block_type", block.BlockType, "txs", block.BodyTxs.Size(), "parts", len(part_set.Parts))
 
 	cs.heightDatas.Block = block
 	cs.heightDatas.PartSet = part_set
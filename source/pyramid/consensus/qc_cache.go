@@ -0,0 +1,50 @@
+package tendermint
+
+import (
+	"emulator/pyramid/consensus/constypes"
+	"emulator/utils/signer"
+	"sync"
+)
+
+const qcCacheSize = 4096
+
+// qcCache remembers the collective signatures verified so far. Receive
+// verifies them before it takes the state lock, so that a block embedding
+// the same signatures later is checked under the lock by a lookup. The
+// oldest entries are dropped once it holds qcCacheSize of them.
+type qcCache struct {
+	mtx   sync.Mutex
+	seen  map[string]struct{}
+	order []string
+}
+
+func newQCCache() *qcCache {
+	return &qcCache{seen: make(map[string]struct{})}
+}
+
+// verify reports whether sig is a valid collective signature of the shard
+// verifier belongs to.
+func (c *qcCache) verify(sig *constypes.PrecommitAggregated, verifier signer.Verifier) bool {
+	key := string(sig.ProtoBytes())
+	c.mtx.Lock()
+	_, ok := c.seen[key]
+	c.mtx.Unlock()
+	if ok {
+		return true
+	}
+	if !sig.VerifySignatures(verifier, sig.ValidatorBitVector.Byte()) {
+		return false
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.seen[key]; ok {
+		return true
+	}
+	if len(c.order) == qcCacheSize {
+		delete(c.seen, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.seen[key] = struct{}{}
+	c.order = append(c.order, key)
+	return true
+}
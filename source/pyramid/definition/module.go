@@ -3,6 +3,7 @@ package definition
 import (
 	"emulator/pyramid/types"
 	"emulator/utils/p2p"
+	"emulator/utils/status"
 	"emulator/utils/store"
 )
 
@@ -11,6 +12,7 @@ type MempoolConn interface {
 	ReapTx(maxTxs int) (types.Txs, int, error)
 	Update(txs types.Txs, commitStatus []byte) error
 	RemoveTx(tx *types.Tx) error
+	Size() int
 
 	p2p.Reactor
 }
@@ -41,4 +43,7 @@ type ConsensusConn interface {
 	p2p.Reactor
 	Start()
 	Stop()
+	// Done is closed once the node has run for the configured length
+	Done() <-chan struct{}
+	Status() status.Consensus
 }
@@ -1,127 +1,54 @@
 package main
 
 import (
+	"emulator/pyramid/abci/minibank"
 	"emulator/pyramid/shardinfo"
+	"emulator/utils/config"
+	"emulator/utils/genesis"
+	"emulator/utils/keystore"
 	"emulator/utils/p2p"
 	"emulator/utils/signer"
+	"emulator/utils/topology"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
+	"time"
 )
 
-const (
-	privateKeyPath = "config/private_key.txt"
-	shardInfoPath  = "config/shard_info.json"
-	configPath     = "config/config.toml"
-	configDir      = "config"
-	storeDir       = "database"
-)
-
-const (
-	ProtocolPyramid = "pyramid"
-)
-
-const (
-	defaultMinBlockInterval = "10ms"
-	defaultMaxBlockPartSize = 1024 * 200 // 20 KB
-	defaultMaxBlockTxNum    = 4096
-	defaultProtocal         = ProtocolPyramid
-	defaultABCI             = "minibank"
-)
-
-type Config struct {
-	NodeName string
-	DirRoot  string
-	ChainID  string
-	IsI      bool
+// Config is the config.toml of a node, see emulator/utils/config.
+type Config = config.Config
 
-	BShardNum int
-	IShardNum int
-
-	// P2P
-	LocalIP   string
-	LocalPort int
-
-	// consensus
-	Protocal         string
-	MinBlockInterval string
-	MaxPartSize      int
-	MaxBlockTxNum    int
-
-	SignerIndex int
-
-	// abci
-	ABCIApp string
+// GetConfig loads a config.toml of pyramid with the environment and
+// overrides applied.
+func GetConfig(path string, overrides ...string) (*Config, error) {
+	return config.Load(path, genesis.ProtocolPyramid, overrides)
 }
 
-func (c *Config) PrivateKeyPath() string { return filepath.Join(c.DirRoot, privateKeyPath) }
-func (c *Config) ShardInfoPath() string  { return filepath.Join(c.DirRoot, shardInfoPath) }
-func (c *Config) StoreDirRoot() string   { return filepath.Join(c.DirRoot, storeDir) }
-func (c *Config) ConfigPath() string     { return filepath.Join(c.DirRoot, configPath) }
-func (c *Config) ConfigDir() string      { return filepath.Join(c.DirRoot, configDir) }
-
-func GenerateConfigFiles(shard_config_path string, store_dir string) {
-	shardConfig := new(ShardConfig)
-	if err := shardConfig.ReadJSONFromFile(shard_config_path); err != nil {
+func GenerateConfigFiles(shard_config_path string, store_dir string, passphrase string, genesisTime time.Time, overrides []string) {
+	shardConfig, err := topology.Load(shard_config_path, genesis.ProtocolPyramid)
+	if err != nil {
 		panic(err)
 	}
-	bnum, inum := 0, 0
-
-	IPPortToUse := map[string]int{}
-	IPs := []string{}
-	IPGun := []string{}
-	IPGunPointer := 0
-	maxCounter := -1
-	for key, value := range shardConfig.IPInUse {
-		IPs = append(IPs, key)
-		IPPortToUse[key] = 26601
-		if int(value) > maxCounter {
-			maxCounter = int(value)
-		}
-	}
-	sort.Strings(IPs)
-	for i := 0; i < maxCounter; i++ {
-		for _, ip := range IPs {
-			if shardConfig.IPInUse[ip] > uint32(i) {
-				IPGun = append(IPGun, ip)
-			}
-		}
-	}
-	GetIP := func() (string, int) {
-		if IPGunPointer == len(IPGun) {
-			IPGunPointer = 0
-		}
-		theip := IPGun[IPGunPointer]
-		theport := IPPortToUse[theip]
-		IPPortToUse[theip] = theport + 1
-		IPGunPointer++
-		return theip, theport
-	}
-
-	totalNodes := 0
-	for _, si := range shardConfig.Shards {
-		totalNodes += int(si.PeerNum)
-		if si.IsI {
-			inum++
-		} else {
-			bnum++
-		}
-	}
+	inum, bnum := shardConfig.ShardNums()
+	totalNodes := int(shardConfig.Nodes())
+	addrs := shardConfig.Addresses()
 
 	publicKeys := make([]string, totalNodes)
 	privateKeys := make([]string, totalNodes)
-	ipList := make([]string, totalNodes)
-	portList := make([]int, totalNodes)
+	pops := make([]string, totalNodes)
 	for i := 0; i < totalNodes; i++ {
 		priveKey, pubkey, err := signer.NewBLSKeyPair(signer.BaseCurve)
 		if err != nil {
 			panic(err)
 		}
+		pop, err := signer.BLSPop(priveKey)
+		if err != nil {
+			panic(err)
+		}
 		publicKeys[i] = pubkey
 		privateKeys[i] = priveKey
-		ipList[i], portList[i] = GetIP()
+		pops[i] = pop
 	}
 
 	PeerRelatedMap := make(map[string][]string)
@@ -131,17 +58,33 @@ func GenerateConfigFiles(shard_config_path string, store_dir string) {
 	for _, si := range shardConfig.Shards {
 		PeerRelatedMap[si.ChainID] = si.RelatedShards
 		for i := 0; i < int(si.PeerNum); i++ {
-			peer, err := p2p.NewPeer(fmt.Sprintf("%s:%d", ipList[count], portList[count]),
+			peer, err := p2p.NewPeer(addrs[count].String(),
 				map[string]bool{si.ChainID: true},
 				publicKeys[count], 1)
 			if err != nil {
 				panic(err)
 			}
+			peer.Pop = pops[count]
 			PeerList[si.ChainID] = append(PeerList[si.ChainID], peer)
 			count++
 		}
 		keyRangeMap[si.ChainID] = si.KeyRange
 	}
+	gen := &genesis.Genesis{
+		GenesisTime: genesisTime,
+		Protocol:    genesis.ProtocolPyramid,
+		InitialState: &genesis.InitialState{
+			Balance:          minibank.DefaultInitialBalance,
+			AccountsPerShard: accountNumTotal / len(shardConfig.Shards),
+		},
+	}
+	for _, si := range shardConfig.Shards {
+		shard := si.GenesisShard()
+		shard.SignScheme = signer.SchemeBLS
+		shard.Validators = genesis.NewValidators(PeerList[si.ChainID])
+		gen.Shards = append(gen.Shards, shard)
+	}
+
 	var shardInfoList = make([]*shardinfo.ShardInfo, 0, totalNodes)
 	for _, si := range shardConfig.Shards {
 		var shardInfo = new(shardinfo.ShardInfo)
@@ -162,37 +105,40 @@ func GenerateConfigFiles(shard_config_path string, store_dir string) {
 		}
 	}
 
+	// every config starts from the defaults with overrides applied
+	base, err := config.DefaultWith(genesis.ProtocolPyramid, overrides)
+	if err != nil {
+		panic(err)
+	}
 	configList := make([]*Config, totalNodes)
 	count = 0
 	for _, si := range shardConfig.Shards {
 		for i := 0; i < int(si.PeerNum); i++ {
 			nodeName := fmt.Sprintf("node%d", count+1)
-			dirRoot := filepath.Join(store_dir, ipList[count], nodeName)
-			configList[count] = &Config{
-				DirRoot:  dirRoot,
-				NodeName: nodeName,
-				ChainID:  si.ChainID,
-				IsI:      si.IsI,
-
-				LocalIP:   ipList[count],
-				LocalPort: portList[count],
-
-				Protocal:         defaultProtocal,
-				MinBlockInterval: defaultMinBlockInterval,
-				MaxPartSize:      defaultMaxBlockPartSize,
-				MaxBlockTxNum:    defaultMaxBlockTxNum,
-
-				SignerIndex: i,
-
-				ABCIApp: defaultABCI,
-
-				IShardNum: inum,
-				BShardNum: bnum,
+			dirRoot := filepath.Join(store_dir, addrs[count].IP, nodeName)
+			cfg := new(Config)
+			*cfg = *base
+			cfg.DirRoot = dirRoot
+			cfg.NodeName = nodeName
+			cfg.ChainID = si.ChainID
+			cfg.P2P.IP = addrs[count].IP
+			cfg.P2P.Port = addrs[count].Port
+			cfg.Consensus.SignerIndex = i
+			cfg.Shard = config.Shard{IsI: si.IsI, BShardNum: bnum, IShardNum: inum}
+			if err := cfg.Validate(); err != nil {
+				panic(err)
 			}
+			configList[count] = cfg
 			count++
 		}
 	}
 
+	gen.Params = configList[0].GenesisParams()
+	if err := gen.Validate(); err != nil {
+		panic(err)
+	}
+	fmt.Println("genesis time:", gen.GenesisTime.Format(time.RFC3339))
+
 	for i, cfg := range configList {
 		if err := os.MkdirAll(cfg.StoreDirRoot(), os.ModePerm); err != nil {
 			panic(err)
@@ -200,17 +146,21 @@ func GenerateConfigFiles(shard_config_path string, store_dir string) {
 		if err := os.MkdirAll(cfg.ConfigDir(), os.ModePerm); err != nil {
 			panic(err)
 		}
-		privKey := privateKeys[i]
 		shardInfo := shardInfoList[i]
 		if str, err := json.MarshalIndent(shardInfo, "", "    "); err != nil {
 			panic(err)
 		} else if err2 := os.WriteFile(cfg.ShardInfoPath(), []byte(str), 0666); err2 != nil {
 			panic(err)
 		}
-		if err := os.WriteFile(cfg.PrivateKeyPath(), []byte(privKey), 0666); err != nil {
+		if kf, err := keystore.Encrypt(privateKeys[i], signer.SchemeBLS, publicKeys[i], passphrase); err != nil {
+			panic(err)
+		} else if err := keystore.Write(cfg.PrivateKeyPath(), kf); err != nil {
+			panic(err)
+		}
+		if err := cfg.Write(cfg.ConfigPath()); err != nil {
 			panic(err)
 		}
-		if err := cfg.StoreConfig(cfg.ConfigPath()); err != nil {
+		if err := gen.Write(cfg.GenesisPath()); err != nil {
 			panic(err)
 		}
 	}
@@ -1,33 +1,80 @@
 package main
 
 import (
+	"emulator/utils/config"
+	"emulator/utils/genesis"
+	"emulator/utils/keystore"
+	"emulator/utils/topology"
 	"flag"
 	"fmt"
+	"os"
 	"path/filepath"
+	"time"
 )
 
 // ./pyramid --method=example  --root=.
-// ./pyramid --method=generate --config=./example-shard-config.json --root=./mytestnet
-// ./pyramid --method=start --root=./mytestnet --start-time=0:53  --wait-time="20s"
+// ./pyramid --method=generate --config=./example-shard-config.json --root=./mytestnet [--genesis-time=+2m] [--set consensus.max_part_size=40960]
+// ./pyramid --method=start --root=./mytestnet/127.0.0.1/node1 [--set consensus.max_height=100]
+// ./pyramid topology validate --config=./example-shard-config.json [--protocol=all]
+// ./pyramid config validate --root=./mytestnet [--set consensus.max_part_size=40960] [--print]
+// ./pyramid genesis --root=./mytestnet --time=2024-05-01T15:29:00Z
+// ./pyramid testnet --root=./localnet --views=50
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "genesis" {
+		if err := genesis.SetTimeCommand(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := config.Command(genesis.ProtocolPyramid, os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "topology" {
+		if err := topology.Command(genesis.ProtocolPyramid, os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "testnet" {
+		if err := TestnetCommand(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	rootDir := flag.String("root", ".", "Root directory")
 	jsonDir := flag.String("config", "./example-shard-config.json", "Shard topology json file")
-	var startTimeStr, method, waitTime string
-	flag.StringVar(&startTimeStr, "start-time", "", "Program start time in HH:MM")
-	flag.StringVar(&waitTime, "wait-time", "10", "System sleep time delay in seconds, please enter a positive integer")
+	genesisTime := flag.String("genesis-time", "+2m", "Time consensus starts at, RFC3339 (2024-05-01T15:29:00Z) or a duration from now (+2m); `genesis` changes it later")
+	var overrides config.Overrides
+	flag.Var(&overrides, "set", "Override a setting of config.toml like consensus.max_part_size=40960, can be repeated; generate writes it into every config")
+	var method, passwordFile string
 	flag.StringVar(&method, "method", "start", "Command to use")
+	flag.StringVar(&passwordFile, "password-file", "", "File holding the keystore passphrase, "+keystore.PassphraseEnv+" is used if empty")
 	flag.Parse()
 
-	fmt.Println(*rootDir)
-
 	if method == "generate" {
-		GenerateConfigFiles(*jsonDir, *rootDir)
+		passphrase, err := keystore.ReadPassphrase(passwordFile)
+		if err != nil {
+			panic(err)
+		}
+		t, err := genesis.ParseTime(*genesisTime, time.Now())
+		if err != nil {
+			panic(err)
+		}
+		GenerateConfigFiles(*jsonDir, *rootDir, passphrase, t, overrides)
 	} else if method == "start" {
-		InitNode(*rootDir, startTimeStr, waitTime)
+		InitNode(*rootDir, passwordFile, overrides)
 	} else if method == "example" {
 		config := ExampleShardConfig()
-		config.WriteJSONToFile(filepath.Join(*rootDir, "example-shard-config.json"))
+		config.Write(filepath.Join(*rootDir, "example-shard-config.json"))
 	} else {
 		panic("Undefined command")
 	}
@@ -8,16 +8,22 @@ import (
 	"emulator/pyramid/mempool"
 	"emulator/pyramid/shardinfo"
 	"emulator/utils"
+	"emulator/utils/config"
+	"emulator/utils/genesis"
+	"emulator/utils/keystore"
+	"emulator/utils/logging"
+	"emulator/utils/metrics"
 	"emulator/utils/p2p"
 	"emulator/utils/signer"
+	"emulator/utils/status"
 	"emulator/utils/store"
 	"encoding/json"
-	"flag"
 	"fmt"
 	"math"
+	"net/http"
 	"os"
-	"strconv"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"emulator/logger/blocklogger"
@@ -38,26 +44,38 @@ var (
 	bHasData = true
 )
 
-func InitNode(rootDir string, startTimeStr string, waitTime string) {
-	defer fmt.Println("test end")
+var nodeLog = logging.New("node")
 
-	var cfg = new(Config)
-	cfg.DirRoot = rootDir
-	cfg, err := GetConfig(cfg.ConfigPath())
+func InitNode(rootDir string, passwordFile string, overrides []string) {
+	defer nodeLog.Info("node stopped")
+
+	// SIGINT and SIGTERM stop the node, InitNode then returns normally
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	cfg, err := config.LoadNode(rootDir, genesis.ProtocolPyramid, overrides)
 	if err != nil {
 		panic(err)
 	}
-	cfg.DirRoot = rootDir
+	if err := logging.Configure(os.Stdout, cfg.Log.Format, cfg.Log.Level, "node", cfg.NodeName, "chain", cfg.ChainID); err != nil {
+		panic(err)
+	}
+	nodeLog.Info("starting node", "root", rootDir, "protocol", cfg.Protocol)
 
-	priveKeyBz, err := os.ReadFile(cfg.PrivateKeyPath())
+	passphrase, err := keystore.ReadPassphrase(passwordFile)
 	if err != nil {
 		panic(err)
 	}
+	privateKey, keyScheme, err := keystore.Load(cfg.PrivateKeyPath(), passphrase)
+	if err != nil {
+		panic(err)
+	} else if keyScheme != signer.SchemeBLS {
+		panic(fmt.Errorf("the key in %s is a %s key, pyramid only supports %s", cfg.PrivateKeyPath(), keyScheme, signer.SchemeBLS))
+	}
 
 	if err := bls.Init(signer.BaseCurve); err != nil {
 		panic(err)
 	}
-	privateKey := string(priveKeyBz)
 	Signer, err := signer.NewSigner(privateKey)
 	if err != nil {
 		panic(err)
@@ -71,12 +89,14 @@ func InitNode(rootDir string, startTimeStr string, waitTime string) {
 	if err := json.Unmarshal(shardInfoBz, shardInfo); err != nil {
 		panic(err)
 	}
+	gen := loadGenesis(cfg, shardInfo)
+	minibank.SetInitialBalance(gen.InitialState.Balance)
 
 	shardNum := len(shardInfo.PeerList)
-	bNum := cfg.BShardNum
-	iNum := cfg.IShardNum
+	bNum := cfg.Shard.BShardNum
+	iNum := cfg.Shard.IShardNum
 	nodeNum := len(shardInfo.PeerList[cfg.ChainID])
-	fmt.Println(shardNum, bNum, iNum, nodeNum)
+	nodeLog.Info("topology", "shards", shardNum, "b_shards", bNum, "i_shards", iNum, "nodes_per_shard", nodeNum)
 
 	cross_rate := commit_rates * cross_shard_rates
 	inner_rate := commit_rates - cross_rate
@@ -92,26 +112,25 @@ func InitNode(rootDir string, startTimeStr string, waitTime string) {
 	} else {
 		innerTxNum /= float64(iNum * nodeNum)
 		inner_rate /= float64(iNum * nodeNum)
-		if !cfg.IsI {
+		if !cfg.Shard.IsI {
 			innerTxNum = 0.0
 			inner_rate = 0.0
 		}
 	}
-	if cfg.IsI {
+	if cfg.Shard.IsI {
 		cross_rate = 0.0
 		crossTxNum = 0.0
 	}
 
-	fmt.Println(innerTxNum, crossTxNum, inner_rate, cross_rate)
+	nodeLog.Info("workload", "intra_shard_txs", innerTxNum, "cross_shard_txs", crossTxNum, "intra_shard_rate", inner_rate, "cross_shard_rate", cross_rate)
 	abci := createABCI(cfg, shardInfo, nil)
-	defer abci.Stop()
 	mempool, cross_shard_mempool := createMempool(cfg, abci)
-	sender, receiver := createP2p(cfg, shardInfo)
+	sender, receiver := createP2p(ctx, cfg, shardInfo)
+	handshake := p2p.NewHandshake(sender, gen.Hash())
 	logger := blocklogger.NewBlockWriter(cfg.DirRoot, cfg.NodeName, cfg.ChainID)
 	if err := logger.OnStart(); err != nil {
 		panic(err)
 	}
-	defer logger.OnStop()
 	consensus := createConsensus(
 		cfg, shardInfo,
 		Signer,
@@ -120,15 +139,15 @@ func InitNode(rootDir string, startTimeStr string, waitTime string) {
 		logger,
 	)
 
+	receiver.AddChennel(handshake, p2p.ChannelIDHandshake)
 	receiver.AddChennel(consensus, p2p.ChannelIDConsensusState)
 	receiver.AddChennel(mempool, p2p.ChannelIDMempool)
 	receiver.AddChennel(cross_shard_mempool, p2p.ChannelIDCrossShardMempool)
-	defer consensus.Stop()
 
 	keyRangeTree, rf := createKeyRangeTree(cfg, shardInfo)
 	var myKeyRangeTree *utils.RangeTree
 	var relatedKeyRangeTrees []*utils.RangeTree
-	if cfg.IsI {
+	if cfg.Shard.IsI {
 		myKeyRangeTree = keyRangeTree
 		relatedKeyRangeTrees = nil
 	} else {
@@ -141,45 +160,167 @@ func InitNode(rootDir string, startTimeStr string, waitTime string) {
 	minibankAdder := minibank.NewImportor(
 		mempool, cross_shard_mempool,
 		transferSize, mustLen, accountNumTotal/shardNum,
-		cross_rate+inner_rate, cfg.IsI,
+		cross_rate+inner_rate, cfg.Shard.IsI,
 		myKeyRangeTree, relatedKeyRangeTrees,
 		sender, cfg.ChainID,
 		cross_rate/(cross_rate+inner_rate),
 	)
-	if cfg.IsI {
+	if cfg.Shard.IsI {
 		minibankAdder.RandomGenerateTx(int(math.Ceil(innerTxNum)))
 	} else {
 		minibankAdder.RandomGenerateCrossShardTx(int(math.Ceil(crossTxNum)))
 		minibankAdder.RandomGenerateTx(int(math.Ceil(innerTxNum)))
 	}
 
-	receiver.Start()
-
-	startTime := time_to_start(startTimeStr)
-	fmt.Println(time.Until(startTime))
-	time.Sleep(time.Until(startTime))
-
-	if err := sender.Start(); err != nil {
-		fmt.Println(err)
+	metricsServer := createMetrics(cfg, nodeStatus(cfg, consensus, mempool, cross_shard_mempool, sender))
+	if err := receiver.Start(); err != nil {
+		panic(err)
 	}
+	// the components are stopped in order: no more messages come in, the
+	// txs are no longer broadcast, consensus finishes the message it handles
+	// and closes its store, then the peers are disconnected, the ABCI store is
+	// closed and the blocklogger files are flushed last. The mempools only
+	// live in memory and have nothing to flush.
+	defer func() {
+		receiver.Stop()
+		minibankAdder.Stop()
+		consensus.Stop()
+		sender.Stop()
+		abci.Stop()
+		if err := logger.OnStop(); err != nil {
+			nodeLog.Error("flushing the blocklogger failed", "err", err)
+		}
+		if metricsServer != nil {
+			metricsServer.Close()
+		}
+	}()
 
-	t, err := strconv.ParseInt(waitTime, 10, 32)
-	if err == nil && t >= 0 {
-		time.Sleep(time.Duration(t) * time.Second)
+	// consensus starts at genesis time, once every peer is connected
+	if err := runHandshake(ctx, handshake, cfg.P2P.HandshakeTimeout); err != nil {
+		nodeLog.Error("handshake failed", "err", err)
+		return
+	}
+	if wait := time.Until(gen.GenesisTime); wait > 0 {
+		nodeLog.Info("waiting for the genesis time", "genesis_time", gen.GenesisTime.Format(time.RFC3339), "wait", wait)
+		if !sleep(ctx, wait) {
+			return
+		}
 	} else {
-		time.Sleep(10 * time.Second)
+		nodeLog.Info("genesis time passed, starting now", "genesis_time", gen.GenesisTime.Format(time.RFC3339), "late", -wait)
 	}
 
+	nodeLog.Info("starting consensus")
 	minibankAdder.StartMempool()
 	consensus.Start()
-	select {}
+
+	select {
+	case <-ctx.Done():
+		nodeLog.Info("interrupted, stopping the node")
+	case <-consensus.Done():
+		nodeLog.Info("max_height reached, stopping the node", "max_height", cfg.Consensus.MaxHeight)
+	}
+}
+
+// createMetrics serves the metrics and the status of the node, nil if they
+// are disabled.
+func createMetrics(cfg *Config, nodeStatus func() *status.Status) *http.Server {
+	if !cfg.Metrics.Enabled {
+		return nil
+	}
+	metrics.Default.SetConstLabels("node", cfg.NodeName, "chain_id", cfg.ChainID, "protocol", cfg.Protocol)
+	server, err := metrics.Default.Serve(cfg.MetricsAddress(), status.Handlers(nodeStatus))
+	if err != nil {
+		panic(err)
+	}
+	nodeLog.Info("serving metrics", "url", "http://"+cfg.MetricsAddress()+"/metrics", "status", "http://"+cfg.MetricsAddress()+"/status")
+	return server
+}
+
+// nodeStatus reports what consensus, the mempools and the connections to
+// the peers are at. I shards have no cross-shard mempool.
+func nodeStatus(cfg *Config, consensus definition.ConsensusConn, mempool, cross_shard_mempool definition.MempoolConn,
+	sender *p2p.Sender) func() *status.Status {
+	start := time.Now()
+	return func() *status.Status {
+		s := &status.Status{
+			Node:      cfg.NodeName,
+			ChainID:   cfg.ChainID,
+			Protocol:  cfg.Protocol,
+			Time:      time.Now(),
+			Uptime:    time.Since(start).Seconds(),
+			Consensus: consensus.Status(),
+			Mempool:   mempool.Size(),
+			Peers:     status.Peers(sender),
+		}
+		if cross_shard_mempool != nil {
+			s.CrossShardMempool = cross_shard_mempool.Size()
+		}
+		return s
+	}
+}
+
+// sleep waits for d, it returns false if ctx is cancelled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		nodeLog.Info("interrupted, stopping the node")
+		return false
+	}
+}
+
+// runHandshake waits for the peers of the node, giving up after timeout
+// unless it is 0.
+func runHandshake(ctx context.Context, handshake *p2p.Handshake, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := handshake.Run(ctx)
+	if err == context.DeadlineExceeded {
+		return fmt.Errorf("peers not ready after p2p.handshake_timeout %v, stopping the node", timeout)
+	} else if err != nil {
+		return fmt.Errorf("interrupted, stopping the node")
+	}
+	return nil
+}
+
+// loadGenesis reads the genesis of the node and checks it against the
+// node's config and shard_info.json.
+func loadGenesis(cfg *Config, si *shardinfo.ShardInfo) *genesis.Genesis {
+	gen, err := genesis.Load(cfg.GenesisPath())
+	if err != nil {
+		panic(err)
+	}
+	if gen.Protocol != genesis.ProtocolPyramid {
+		panic(fmt.Errorf("%s is a %s genesis", cfg.GenesisPath(), gen.Protocol))
+	}
+	shard := gen.Shard(cfg.ChainID)
+	if shard == nil {
+		panic(fmt.Errorf("shard %s is not in %s", cfg.ChainID, cfg.GenesisPath()))
+	}
+	if err := shard.CheckValidators(si.PeerList[cfg.ChainID]); err != nil {
+		panic(err)
+	}
+	if err := gen.Params.Check(cfg.GenesisParams()); err != nil {
+		panic(err)
+	}
+	nodeLog.Info("genesis loaded", "hash", gen.Hash(), "genesis_time", gen.GenesisTime.Format(time.RFC3339))
+	return gen
 }
 
 func createKeyRangeTree(cfg *Config, si *shardinfo.ShardInfo) (*utils.RangeTree, map[string]*utils.RangeTree) {
 	var chain_id = cfg.ChainID
 	var keyRangeTree *utils.RangeTree
 	var relatedKeyRangeForest = map[string]*utils.RangeTree{}
-	if cfg.IsI {
+	if cfg.Shard.IsI {
 		keyRangeTree = utils.NewRangeTreeFromString(si.KeyRangeMap[chain_id])
 		relatedKeyRangeForest[chain_id] = utils.NewRangeTreeFromString(si.KeyRangeMap[chain_id])
 		return keyRangeTree, relatedKeyRangeForest
@@ -198,10 +339,10 @@ func createKeyRangeTree(cfg *Config, si *shardinfo.ShardInfo) (*utils.RangeTree,
 func createABCI(cfg *Config, si *shardinfo.ShardInfo, block_store *store.PrefixStore) definition.ABCIConn {
 	chain_id := cfg.ChainID
 	keyRangeTree, relatedKeyRangeForest := createKeyRangeTree(cfg, si)
-	switch cfg.ABCIApp {
-	case "minibank":
+	switch cfg.ABCI.App {
+	case config.ABCIMinibank:
 		app := minibank.NewApplication(cfg.StoreDirRoot(), chain_id,
-			cfg.IsI, keyRangeTree, relatedKeyRangeForest, block_store)
+			cfg.Shard.IsI, keyRangeTree, relatedKeyRangeForest, block_store)
 		return app
 	default:
 		panic("Undefined ABCI interface")
@@ -209,15 +350,15 @@ func createABCI(cfg *Config, si *shardinfo.ShardInfo, block_store *store.PrefixS
 }
 
 func createMempool(cfg *Config, abci definition.ABCIConn) (definition.MempoolConn, definition.MempoolConn) {
-	if cfg.IsI {
-		return mempool.NewMempool(false, abci), nil
+	if cfg.Shard.IsI {
+		return mempool.NewMempool(false, abci, cfg.Mempool.Size), nil
 	} else {
-		return mempool.NewMempool(false, abci), mempool.NewMempool(true, abci)
+		return mempool.NewMempool(false, abci, cfg.Mempool.Size), mempool.NewMempool(true, abci, cfg.Mempool.CrossShardSize)
 	}
 }
-func createP2p(cfg *Config, si *shardinfo.ShardInfo) (*p2p.Sender, *p2p.Receiver) {
-	sender := p2p.NewSender(fmt.Sprintf("%s:%d", cfg.LocalIP, cfg.LocalPort))
-	receiver := p2p.NewReceiver(cfg.LocalIP, cfg.LocalPort, context.Background())
+func createP2p(ctx context.Context, cfg *Config, si *shardinfo.ShardInfo) (*p2p.Sender, *p2p.Receiver) {
+	sender := p2p.NewSender(fmt.Sprintf("%s:%d", cfg.P2P.IP, cfg.P2P.Port))
+	receiver := p2p.NewReceiver(cfg.P2P.IP, cfg.P2P.Port, ctx)
 
 	for shard := range si.RelatedShards {
 		for _, peer := range si.PeerList[shard] {
@@ -229,67 +370,24 @@ func createP2p(cfg *Config, si *shardinfo.ShardInfo) (*p2p.Sender, *p2p.Receiver
 	}
 	return sender, receiver
 }
-func createConsensus(cfg *Config, si *shardinfo.ShardInfo, s *signer.Signer, mmp, cmmp definition.MempoolConn,
+func createConsensus(cfg *Config, si *shardinfo.ShardInfo, s signer.Signer, mmp, cmmp definition.MempoolConn,
 	abci definition.ABCIConn, sender *p2p.Sender, logger blocklogger.BlockWriter) definition.ConsensusConn {
-	interval, err := time.ParseDuration(cfg.MinBlockInterval)
-	if err != nil {
-		panic(err)
-
-	}
-	switch cfg.Protocal {
-	case ProtocolPyramid:
-		return pyramid.NewConsensusState(
+	switch cfg.Protocol {
+	case genesis.ProtocolPyramid:
+		state := pyramid.NewConsensusState(
 			cfg.ChainID, si,
-			s, cfg.SignerIndex,
+			s, cfg.Consensus.SignerIndex,
 			mmp, cmmp,
 			abci, sender,
 			cfg.StoreDirRoot(),
-			interval,
-			cfg.MaxPartSize,
-			cfg.MaxBlockTxNum,
+			cfg.Consensus.MinBlockInterval,
+			cfg.Consensus.MaxPartSize,
+			cfg.Consensus.MaxBlockTxNum,
 			logger,
 		)
+		state.MaxHeight = cfg.Consensus.MaxHeight
+		return state
 	default:
 		panic("Undefined Consensus interface")
 	}
 }
-
-func parseStartTime(startTimeStr string) (int, int, error) {
-	parts := strings.Split(startTimeStr, ":")
-	if len(parts) != 2 {
-		return 0, 0, fmt.Errorf("Time format error, should be HH:MM")
-	}
-
-	hours, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return 0, 0, fmt.Errorf("Hour parsing error: %v", err)
-	}
-	minutes, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return 0, 0, fmt.Errorf("Minute parse error: %v", err)
-	}
-	if hours < 0 || hours > 23 || minutes < 0 || minutes > 59 {
-		return 0, 0, fmt.Errorf("Hours or minutes are not valid")
-	}
-
-	return hours, minutes, nil
-}
-
-func time_to_start(startTimeStr string) time.Time {
-
-	flag.Parse()
-
-	if startTimeStr == "" {
-		return time.Now()
-	}
-
-	hours, minutes, err := parseStartTime(startTimeStr)
-	if err != nil {
-		panic(fmt.Sprintln("Startup time is formatted incorrectly:", err))
-
-	}
-
-	now := time.Now()
-
-	return time.Date(now.Year(), now.Month(), now.Day(), hours, minutes, 0, 0, time.Local)
-}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"emulator/pyramid/abci/minibank"
+	"emulator/utils"
+	"emulator/utils/config"
+	"emulator/utils/genesis"
+	"emulator/utils/testnet"
+	"time"
+)
+
+// ./pyramid testnet --root=./localnet --views=50 [--config=./example-shard-config.json] [--set consensus.max_part_size=40960]
+
+// TestnetCommand generates a testnet on localhost from a shard config, runs
+// every node as a child process until the leaders reach --views, then stops
+// them and prints the results.
+func TestnetCommand(args []string) error {
+	return testnet.Command{
+		Protocol: genesis.ProtocolPyramid,
+		Example:  ExampleShardConfig,
+		Generate: func(topologyPath, rootDir, passphrase string, overrides config.Overrides) {
+			GenerateConfigFiles(topologyPath, rootDir, passphrase, time.Now(), overrides)
+		},
+		Leader: testnetLeader,
+		Latency: func(node *testnet.Node) (*utils.Latency, error) {
+			return minibank.MeasureLatency(node.Dir, node.ChainID)
+		},
+	}.Run(args)
+}
+
+// testnetLeader picks the first node of every shard. Pyramid has no fixed
+// leader, the proposer rotates through the signers by height, and every
+// node commits the same blocks, so the results are read from one of them.
+func testnetLeader(dir string, cfg *config.Config) (bool, error) {
+	return cfg.Consensus.SignerIndex == 0, nil
+}
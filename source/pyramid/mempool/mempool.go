@@ -5,8 +5,9 @@ import (
 	"emulator/pyramid/definition"
 	"emulator/pyramid/types"
 	"emulator/utils"
+	"emulator/utils/logging"
+	"emulator/utils/metrics"
 	"fmt"
-	"log"
 	"sync"
 )
 
@@ -16,15 +17,24 @@ type Mempool struct {
 
 	abci                definition.ABCIConn
 	isCrossShardMempool bool
+	// size caps the txs held, 0 is unbounded
+	size int
+
+	sizeMetric metrics.Gauge
+	log        *logging.Logger
 }
 
-func NewMempool(isCrossShardMempool bool, abci definition.ABCIConn) *Mempool {
+func NewMempool(isCrossShardMempool bool, abci definition.ABCIConn, size int) *Mempool {
 	return &Mempool{
 		txs:    clist.New(),
 		txsMap: sync.Map{},
 
 		abci:                abci,
 		isCrossShardMempool: isCrossShardMempool,
+		size:                size,
+
+		sizeMetric: metrics.MempoolSize.With(metrics.Mempool(isCrossShardMempool)),
+		log:        logging.New("mempool").With("mempool", metrics.Mempool(isCrossShardMempool)),
 	}
 }
 
@@ -36,11 +46,15 @@ func (mpl *Mempool) Receive(chID byte, message []byte, messageType uint32) error
 }
 
 func (mpl *Mempool) AddTx(tx *types.Tx) error {
+	if mpl.size > 0 && mpl.txs.Len() >= mpl.size {
+		return fmt.Errorf("mempool is full (%d txs)", mpl.size)
+	}
 	if !mpl.abci.ValidateTx(*tx, mpl.isCrossShardMempool) {
 		return fmt.Errorf("Transaction does not pass ValidateTx")
 	}
 	e := mpl.txs.PushBack(tx)
 	mpl.txsMap.Store(tx.Key(), e)
+	mpl.sizeMetric.Set(float64(mpl.txs.Len()))
 	return nil
 }
 
@@ -49,11 +63,17 @@ func (mpl *Mempool) RemoveTx(tx *types.Tx) error {
 		if u, ok := e.(*clist.CElement); ok {
 			mpl.txs.Remove(u)
 			mpl.txsMap.Delete(tx.Key())
+			mpl.sizeMetric.Set(float64(mpl.txs.Len()))
 		}
 	}
 	return nil
 }
 
+// Size returns the number of txs waiting in the mempool.
+func (mpl *Mempool) Size() int {
+	return mpl.txs.Len()
+}
+
 func (mpl *Mempool) ReapTx(maxTxs int) (types.Txs, int, error) {
 	var (
 		txs = make(types.Txs, 0, maxTxs)
@@ -70,7 +90,7 @@ func (mpl *Mempool) ReapTx(maxTxs int) (types.Txs, int, error) {
 			break
 		}
 	}
-	log.Println("get trancations", mpl.txs.Len(), len(txs))
+	mpl.log.Debug("reaped txs", "size", mpl.txs.Len(), "txs", len(txs))
 	return txs, len(txs), nil
 }
 
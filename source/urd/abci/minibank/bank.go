@@ -2,22 +2,40 @@ package minibank
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"emulator/crypto/merkle"
+	"emulator/logger/txtrace"
 	bank "emulator/proto/urd/abci/minibank"
 	"emulator/urd/definition"
+	"emulator/urd/events"
 	"emulator/urd/shardinfo"
 	"emulator/urd/types"
 	"emulator/utils"
+	"emulator/utils/logging"
+	"emulator/utils/metrics"
 	"emulator/utils/store"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
 	"sort"
 	"time"
 
 	"google.golang.org/protobuf/proto"
 )
 
-const initBalance = 1000000
+// DefaultInitialBalance is the balance accounts start with, unless the
+// genesis says otherwise
+const DefaultInitialBalance = 1000000
+
+var initBalance uint32 = DefaultInitialBalance
+
+// SetInitialBalance sets the balance accounts start with, from the initial
+// state of the genesis. It must be called before the application is used.
+func SetInitialBalance(balance uint32) { initBalance = balance }
+
+var log = logging.New("abci")
 
 var prefix_of_undo_relay = []byte("undo")
 
@@ -25,8 +43,21 @@ func toRelayKey(key []byte) []byte {
 	return bytes.Join([][]byte{prefix_of_undo_relay, key}, nil)
 }
 
+// The lease of the locks of a cross-shard tx is kept under the view they
+// were taken in and the hash of the tx, with the keys as BankData.
+var prefix_of_lease = []byte("lease/")
+
+func toLeaseKey(view int64, hash []byte) []byte {
+	return append(binary.BigEndian.AppendUint64(append([]byte(nil), prefix_of_lease...), uint64(view)), hash...)
+}
+func fromLeaseKey(key []byte) (int64, []byte) {
+	key = key[len(prefix_of_lease):]
+	return int64(binary.BigEndian.Uint64(key[:8])), key[8:]
+}
+
 type Application struct {
-	db *store.PrefixStore
+	db   *store.PrefixStore
+	bank DB
 
 	KeyRangeTrees   map[string]*utils.RangeList
 	chain_id        string
@@ -34,11 +65,20 @@ type Application struct {
 	shard_info      *shardinfo.ShardInfo
 
 	appStatus []byte
+	// txs is nil unless IndexTxs was called
+	txs *txIndex
+
+	// view is that of the block being executed
+	view        int64
+	leaseViews  int64
+	lockedKeys  int
+	sharedLocks bool
 }
 
 func NewApplication(dbDir string, chain_id string, keyRangeTrees map[string]*utils.RangeList, shard_info *shardinfo.ShardInfo) *Application {
 	app := new(Application)
 	app.db = store.NewPrefixStore("abci.minibank", dbDir)
+	app.bank = NewCachedDB(app.db, keyRangeTrees[chain_id], defaultCacheCapacity)
 
 	app.KeyRangeTrees = keyRangeTrees
 	app.chain_id = chain_id
@@ -49,11 +89,69 @@ func NewApplication(dbDir string, chain_id string, keyRangeTrees map[string]*uti
 	app.shard_info = shard_info
 
 	app.appStatus = merkle.HashFromByteSlices(nil)
+	if err := app.eachLease(math.MaxInt64, func(view int64, hash []byte, keys *bank.BankData) {
+		app.lockedKeys += len(keys.Keys)
+	}); err != nil {
+		panic(err)
+	}
 
 	return app
 }
 
+// LeaseLocks aborts the cross-shard txs still undecided views after a shard
+// locked them, 0 never does. Every node of every shard must lease alike.
+func (app *Application) LeaseLocks(views int64) { app.leaseViews = views }
+
+// ShareLocks read locks the accounts a cross-shard tx only credits, instead
+// of write locking every account it touches. Every node of every shard must
+// share alike.
+func (app *Application) ShareLocks(shared bool) { app.sharedLocks = shared }
+
+// exclusive tells if tx write locks key rather than read locking it.
+func (app *Application) exclusive(tx *bank.TransferTx, key string) bool {
+	return !app.sharedLocks || utils.StrIn(key, tx.From)
+}
+
+// blocked tells if a transfer may not move the money of an account locked
+// so: write locked, or read locked without shared locks.
+func (app *Application) blocked(locked byte) bool {
+	return isWLock(locked) || !app.sharedLocks && !isFree(locked)
+}
+
+// BindOwners binds the accounts of this shard to their keys from the owners
+// file of hash in the genesis, unless they already are.
+func (app *Application) BindOwners(path, hash string) error {
+	var err error
+	bound := 0
+	if rerr := readOwners(path, hash, func(account string, key ed25519.PublicKey) {
+		if err != nil || !app.search_key_intra_shard(account) {
+			return
+		}
+		var owner *Owner
+		if owner, err = app.bank.Owner(account); err == nil && owner == nil {
+			err = app.bank.SetOwner(account, NewOwner(key))
+			bound++
+		}
+	}); rerr != nil {
+		return rerr
+	} else if err != nil {
+		return err
+	}
+	log.Info("accounts bound to their keys", "accounts", bound)
+	return app.bank.Flush()
+}
+
+// IndexTxs keeps the outcome of the latest capacity txs for TxResult.
+func (app *Application) IndexTxs(capacity int) {
+	if capacity > 0 {
+		app.txs = newTxIndex(capacity)
+	}
+}
+
 func (app *Application) Stop() {
+	if err := app.bank.Flush(); err != nil {
+		log.Error("flush failed", "err", err)
+	}
 	app.db.Close()
 }
 
@@ -64,47 +162,133 @@ func (app *Application) Commit() []byte {
 }
 func (app *Application) ValidateTx(tx []byte, isCrossShard bool) bool {
 	if err := app.validateTx(tx); err != nil {
-		fmt.Println(err)
+		log.Debug("invalid tx", "cross_shard", isCrossShard, "err", err)
 		return false
 	}
 	return true
 }
 
-func (app *Application) Execution(txs types.Txs, cross_shard_txs types.Txs, CTXS []types.Txs) *types.ABCIExecutionResponse {
+// Execution executes a block, its balances, owners, relay sets and leases are
+// persisted together in one batch at the end. On an error none of them is,
+// and the block can be executed again.
+func (app *Application) Execution(view int64, txs types.Txs, cross_shard_txs types.Txs, CTXS []types.Txs) (*types.ABCIExecutionResponse, error) {
+	lockedKeys := app.lockedKeys
+	resp, err := app.executeBlock(view, txs, cross_shard_txs, CTXS)
+	if err != nil {
+		app.bank.Discard()
+		app.lockedKeys = lockedKeys
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (app *Application) executeBlock(view int64, txs types.Txs, cross_shard_txs types.Txs, CTXS []types.Txs) (*types.ABCIExecutionResponse, error) {
 	resp := new(types.ABCIExecutionResponse)
-	db := NewDB(app.db, app.KeyRangeTrees[app.chain_id])
-	fmt.Println(time.Now(), "execute CTXS")
+	db := app.bank
+	app.view = view
+	aborted := 0
+	log.Debug("executing relayed cross-shard txs", "shards", len(CTXS))
+	start := time.Now()
 	for i, ctxs := range CTXS {
 		chain := app.shard_info.ShardIDList[i]
 		for _, opt := range ctxs {
-			app.executeRelay(opt, chain, db)
+			if receipt, err := app.executeRelay(opt, chain, db); err != nil {
+				log.Error("relay failed", "from", chain, "err", err)
+			} else if receipt != nil {
+				resp.CrossShardResponses = append(resp.CrossShardResponses, receipt)
+			}
 		}
 	}
-	fmt.Println(time.Now(), "execute txs")
+	if receipts, err := app.expireLeases(db); err != nil {
+		return nil, err
+	} else {
+		resp.CrossShardResponses = append(resp.CrossShardResponses, receipts...)
+	}
+	metrics.ExecutionDuration.With("relay").ObserveSince(start)
+	log.Debug("executing intra-shard txs", "txs", len(txs))
+	start = time.Now()
 	for _, tx := range txs {
 		receipt := new(types.ABCIExecutionReceipt)
 		err := app.execute(tx, db)
 		if err != nil {
 			receipt.Code = types.CodeTypeAbort
 			receipt.Info = err.Error()
+			metrics.Aborts.With(metrics.AbortReason(err)).Inc()
+			aborted++
 		} else {
 			receipt.Code = types.CodeTypeOK
 		}
+		if app.recording() {
+			var transfer *bank.TransferTx
+			if events.Active() {
+				transfer, _ = NewTransferTxFromBytes(tx)
+			}
+			app.record(types.TxHash(tx), transfer, types.TxCommitted, err, []string{app.chain_id})
+		}
 		receipt.SetRawTx(tx)
 		resp.Responses = append(resp.Responses, receipt)
 	}
 
-	fmt.Println(time.Now(), "execute cross_shard_txs")
-	resp.OPTxs = app.preExecution(cross_shard_txs)
-	fmt.Println(time.Now(), "finish")
-	return resp
+	metrics.ExecutionDuration.With("intra_shard").ObserveSince(start)
+	log.Debug("pre-executing cross-shard txs", "txs", len(cross_shard_txs))
+	start = time.Now()
+	var receipts []*types.ABCIExecutionReceipt
+	var err error
+	if resp.OPTxs, receipts, err = app.preExecution(cross_shard_txs); err != nil {
+		return nil, err
+	}
+	resp.CrossShardResponses = append(resp.CrossShardResponses, receipts...)
+	metrics.ExecutionDuration.With("cross_shard").ObserveSince(start)
+	start = time.Now()
+	if err := db.Flush(); err != nil {
+		return nil, fmt.Errorf("flush of view %d: %w", view, err)
+	}
+	metrics.ExecutionDuration.With("flush").ObserveSince(start)
+	app.observeLocks()
+	log.Info("executed block", "txs", len(txs), "aborted", aborted, "cross_shard_txs", len(cross_shard_txs))
+	return resp, nil
 }
 
 // =======================================================================================
 
-func (app *Application) preExecution(input types.Txs) []types.Txs {
+// A cross-shard tx is decided by its shards as in two-phase commit. Each
+// shard locks the keys of the tx it holds and relays their data to all of
+// them, itself included, or relays that it refused the tx. Once every shard
+// relayed, each one decides alike: the tx commits if all of them locked it
+// and the transfer succeeds on the relayed data, and aborts otherwise. A shard
+// that refused a tx knows it aborts at once.
+//
+// With leases, a tx also aborts if it is decided leaseViews or more after the
+// first of its shards locked or refused it, and a shard whose locks reach
+// that age undecided aborts the tx on its own. Shards advance their views in
+// step, the relays of a view reaching every shard with the cross-shard
+// messages, and its commit certificate, of the same later view: all shards
+// complete the relays of a tx in the same view, so those that decide it agree
+// with those whose lease expired before.
+//
+// With shared locks, a tx write locks the accounts it debits and read locks
+// those it only credits. Its shards decide on the relayed balances of the
+// accounts it debits, which must not change until then, while a credit
+// commutes with any other transfer: an account many txs credit at once stays
+// read locked until the last of them is decided, any intra-shard tx may move
+// its money meanwhile, and only a cross-shard tx debiting it is refused for a
+// lock conflict.
+
+// The errors txs abort with, by the reason they count under in the abort
+// metrics. errDuplicate is a tx that was already locked or refused, whose
+// decision stands.
+var (
+	errDuplicate    = metrics.Abort(metrics.AbortDuplicate, errors.New("tx already committed"))
+	errLockConflict = metrics.Abort(metrics.AbortLockConflict, errors.New("Abort due to lock conflict"))
+	errKeyLocked    = metrics.Abort(metrics.AbortKeyLocked, errors.New("one of its keys is locked"))
+	errBalance      = metrics.Abort(metrics.AbortBalance, errors.New("Balance is not Enough"))
+	errLeaseExpired = metrics.Abort(metrics.AbortLeaseExpired, errors.New("lease of its locks expired"))
+)
+
+func (app *Application) preExecution(input types.Txs) ([]types.Txs, []*types.ABCIExecutionReceipt, error) {
 	relayTxs := make([]types.Txs, len(app.shards_to_index))
-	db := NewDB(app.db, app.KeyRangeTrees[app.chain_id])
+	var receipts []*types.ABCIExecutionReceipt
+	db := app.bank
 	wlocks, rlocks := make(map[string]bool), make(map[string]bool)
 	for _, txBytes := range input {
 		tx, err := NewTransferTxFromBytes(txBytes)
@@ -113,29 +297,195 @@ func (app *Application) preExecution(input types.Txs) []types.Txs {
 		} else if len(tx.Shards) <= 1 {
 			continue
 		}
+		hash := types.TxHash(txBytes)
 		relayTx, dstShards, err := app.pre_doTransfer(tx, txBytes, wlocks, rlocks, db)
-		if err != nil {
-			//fmt.Println("pre do transfer error:", err)
+		if err == errDuplicate {
+			metrics.Aborts.With(metrics.AbortReason(err)).Inc()
 			continue
+		} else if err == nil {
+			// the shards are told it is locked, it must be
+			if err := app.executeCrossShard(txBytes, db); err != nil {
+				return nil, nil, err
+			}
+		} else if app.decidable(tx) {
+			var rerr error
+			if relayTx, rerr = app.refuseTransfer(tx, txBytes, err); rerr != nil {
+				return nil, nil, rerr
+			}
+			dstShards = tx.Shards
 		}
 		for _, shard := range dstShards {
 			relayTxs[app.shards_to_index[shard]] = append(relayTxs[app.shards_to_index[shard]], relayTx)
 		}
-		if err := app.executeCrossShard(txBytes, db); err != nil {
-			//fmt.Println("execute cross shard error:", err)
-			continue
+		status := types.TxLocked
+		if err != nil {
+			metrics.Aborts.With(metrics.AbortReason(err)).Inc()
+			status = types.TxAborted
+		}
+		receipts = append(receipts, crossShardReceipt(txBytes, hash, len(tx.Shards), status, err))
+		if app.recording() {
+			app.record(hash, tx, status, err, tx.Shards)
+		}
+	}
+	return relayTxs, receipts, nil
+}
+
+// decidable tells whether all the shards of tx can decide it, which this
+// shard must be one of.
+func (app *Application) decidable(tx *bank.TransferTx) bool {
+	if !utils.StrIn(app.chain_id, tx.Shards) {
+		return false
+	}
+	for _, shard := range tx.Shards {
+		if _, ok := app.shards_to_index[shard]; !ok {
+			return false
 		}
 	}
-	return relayTxs
+	return true
+}
+
+// refuseTransfer keeps that this shard refused tx because of cause, and
+// returns the relay telling the shards of tx.
+func (app *Application) refuseTransfer(tx *bank.TransferTx, raw_tx []byte, cause error) ([]byte, error) {
+	relayTx := &bank.RelayTransferTx{Datas: &bank.BankData{Reason: cause.Error(), ReasonCode: metrics.AbortReason(cause), View: app.view}, TxHash: types.TxHash(raw_tx)}
+	relayTxBz, err := RelayTransferTxBytes(relayTx)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.storeRelayTxSet(tx, raw_tx); err != nil {
+		return nil, err
+	}
+	return relayTxBz, nil
 }
 
-func (app *Application) executeRelay(txBytes []byte, chain string, db DB) error {
+// crossShardReceipt is the receipt of the cross-shard tx raw_tx reaching
+// status at this shard, aborted by err if not nil.
+func crossShardReceipt(raw_tx, hash []byte, shards int, status string, err error) *types.ABCIExecutionReceipt {
+	receipt := &types.ABCIExecutionReceipt{Code: types.CodeTypeOK}
+	if err != nil {
+		receipt.Code, receipt.Info = types.CodeTypeAbort, err.Error()
+	}
+	receipt.SetRawTx(raw_tx)
+	receipt.SetStatus(status, hash)
+	receipt.SetShards(shards)
+	return receipt
+}
+
+// executeRelay returns the receipt of the decision the relay completes, if
+// it completes one.
+func (app *Application) executeRelay(txBytes []byte, chain string, db DB) (*types.ABCIExecutionReceipt, error) {
 	tx, err := NewRelayTransferTxFromBytes(txBytes)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if txtrace.Enabled() {
+		txtrace.Record(tx.TxHash, txtrace.Event{Stage: txtrace.Unlocked, Cross: true, Peer: chain})
 	}
 	return app.unlockTransfer(tx, chain, db)
 }
+
+func (app *Application) RelayTxHash(relayTx []byte) []byte {
+	tx, err := NewRelayTransferTxFromBytes(relayTx)
+	if err != nil {
+		return nil
+	}
+	return tx.TxHash
+}
+
+// TxResult is the outcome of the tx with hash at this shard, if it is one of
+// the latest indexed.
+func (app *Application) TxResult(hash []byte) (*types.TxResult, bool) {
+	if app.txs == nil {
+		return nil, false
+	}
+	return app.txs.get(hash)
+}
+
+// Account is an account of this shard as of the last executed block.
+func (app *Application) Account(key string) (*types.Account, error) {
+	if !app.search_key_intra_shard(key) {
+		return nil, fmt.Errorf("account %s is not in shard %s", key, app.chain_id)
+	}
+	// the committed state, the cache belongs to the execution
+	bz, err := app.db.Get([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+	account := &types.Account{Key: key, Balance: initBalance, Lock: types.LockFree}
+	if owner, err := app.committedOwner(key); err != nil {
+		return nil, err
+	} else if owner != nil {
+		account.PubKey, account.Nonce = hex.EncodeToString(owner.PubKey), owner.Nonce
+	}
+	if len(bz) == 0 {
+		return account, nil
+	}
+	balance, locked, err := UnmarshalValue(bz)
+	if err != nil {
+		return nil, err
+	}
+	account.Balance = balance
+	switch {
+	case isWLock(locked):
+		account.Lock = types.LockWrite
+	case isRLock(locked):
+		account.Lock = types.LockRead
+	}
+	return account, nil
+}
+
+func (app *Application) committedOwner(key string) (*Owner, error) {
+	if bz, err := app.db.Get([]byte(ownerKey(key))); err != nil || len(bz) == 0 {
+		return nil, err
+	} else {
+		return UnmarshalOwner(bz)
+	}
+}
+
+// recording tells whether the outcome of txs is indexed, traced or
+// subscribed to, to skip hashing them otherwise.
+func (app *Application) recording() bool {
+	return app.txs != nil || txtrace.Enabled() || events.Active()
+}
+
+// record keeps that a tx touching shards got status, or was aborted by err,
+// for TxResult, the trace and the subscribers. tx gives the accounts of the
+// events, it may be nil.
+func (app *Application) record(hash []byte, tx *bank.TransferTx, status string, err error, shards []string) {
+	result := &types.TxResult{Status: status}
+	event := txtrace.Event{Stage: txtrace.Committed, Cross: len(shards) > 1, Shards: shards}
+	if status == types.TxLocked {
+		event.Stage = txtrace.Locked
+	}
+	if err != nil {
+		result.Status, result.Reason, result.Error = types.TxAborted, metrics.AbortReason(err), err.Error()
+		event.Stage, event.Reason = txtrace.Aborted, result.Reason
+	}
+	if app.txs != nil {
+		app.txs.set(hash, result)
+	}
+	txtrace.Record(hash, event)
+	if events.Active() {
+		app.publish(hash, tx, result, shards)
+	}
+}
+
+// publish sends the receipt of an intra-shard tx, the transition of a
+// cross-shard one, to the subscribers.
+func (app *Application) publish(hash []byte, tx *bank.TransferTx, result *types.TxResult, shards []string) {
+	e := events.Event{Type: events.Tx, Shard: app.chain_id, Tx: hex.EncodeToString(hash), Shards: shards,
+		Status: result.Status, Reason: result.Reason}
+	if tx != nil {
+		e.Accounts = append(append(make([]string, 0, len(tx.From)+len(tx.To)), tx.From...), tx.To...)
+	}
+	if len(shards) <= 1 {
+		e.Type, e.Receipt = events.Receipt, &events.ReceiptInfo{Code: types.CodeTypeOK}
+		if result.Status == types.TxAborted {
+			e.Receipt.Code, e.Receipt.Info = types.CodeTypeAbort, result.Error
+		}
+	}
+	events.Publish(e)
+}
 func (app *Application) executeCrossShard(txBytes []byte, db DB) error {
 	if len(txBytes) < 4 {
 		return errors.New("Invalid Transaction Type")
@@ -181,54 +531,207 @@ func (app *Application) execute(txBytes []byte, db DB) error {
 	}
 }
 
-func (app *Application) unlockTransfer(tx *bank.RelayTransferTx, chain string, db DB) error {
+// unlockTransfer adds the relay of chain to the set of the tx, and decides
+// the tx once every shard relayed. The keys this shard locked are unlocked
+// whatever the decision.
+func (app *Application) unlockTransfer(tx *bank.RelayTransferTx, chain string, db DB) (*types.ABCIExecutionReceipt, error) {
 	hash := tx.TxHash
 	var relayTxSet *bank.RelayTransferTxSet
-	if bz, err := app.db.GetSpecial(hash); err != nil {
-		return err
+	if bz, err := app.bank.Special(hash); err != nil {
+		return nil, err
 	} else if len(bz) == 0 {
 		var bankdatas map[string]*bank.BankData
-		if bank_datas_bz, err := app.db.GetSpecial(toRelayKey(hash)); err != nil {
-			return err
+		if bank_datas_bz, err := app.bank.Special(toRelayKey(hash)); err != nil {
+			return nil, err
 		} else if len(bank_datas_bz) == 0 {
 			bankdatas = map[string]*bank.BankData{}
 		} else if bankdatas, err = RelayTransferTxListFromBytes(bank_datas_bz); err != nil {
-			return err
+			return nil, err
 		}
 		bankdatas[chain] = tx.Datas
 		if rbz, err := RelayTransferTxListBytes(bankdatas); err != nil {
-			return err
-		} else if err := app.db.SetSpecial(toRelayKey(hash), rbz); err != nil {
-			return err
+			return nil, err
+		} else if err := app.bank.SetSpecial(toRelayKey(hash), rbz); err != nil {
+			return nil, err
 		}
-		return nil
+		return nil, nil
 	} else if relayTxSet, err = NewRelayTransferTxSetFromBytes(bz); err != nil {
-		return err
+		return nil, err
+	} else if relayTxSet.Outcome != "" {
+		// the tx was decided, a late relay changes nothing
+		return nil, nil
 	} else {
 		relayTxSet = app.insertBankDataToRelayTxSet(tx.Datas, chain, relayTxSet)
 	}
 
 	if !isRelayTransferTxSetFinish(relayTxSet) {
-		return nil
+		setBz, err := RelayTransferTxSetBytes(relayTxSet)
+		if err != nil {
+			return nil, err
+		}
+		return nil, app.bank.SetSpecial(hash, setBz)
+	}
+	rawTx, err := NewTransferTxFromBytes(relayTxSet.RawTx)
+	if err != nil {
+		return nil, err
 	}
 	defer db.Clear()
+	var refused error
+	first := app.view
 	for i, data := range relayTxSet.Datas {
-		if relayTxSet.Shards[i] == app.chain_id {
-			for _, key := range data.Keys {
-				if err := db.WUnlock(key); err != nil {
-					return err
-				}
+		if data.View < first {
+			first = data.View
+		}
+		if !data.OK {
+			if refused == nil {
+				refused = metrics.Abort(data.ReasonCode, fmt.Errorf("refused by shard %s: %s", relayTxSet.Shards[i], data.Reason))
 			}
+			continue
 		}
 		for i := range data.Keys {
 			db.LoadData(data.Keys[i], data.Values[i])
 		}
 	}
-	rawTx, err := NewTransferTxFromBytes(relayTxSet.RawTx)
+	own := relayTxSet.Datas[app.indexOf(relayTxSet.Shards)]
+	if own.OK {
+		if err := app.release(own.View, hash, rawTx, own.Keys, db); err != nil {
+			return nil, err
+		}
+	}
+	// checked and authorized when it was locked, every shard has the same
+	// data to transfer on
+	decision := refused
+	if decision == nil && app.leaseViews > 0 && app.view >= first+app.leaseViews {
+		decision = fmt.Errorf("%w, locked at view %d and decided at view %d", errLeaseExpired, first, app.view)
+	}
+	if decision == nil {
+		decision = app.transfer(rawTx, db)
+	}
+	relayTxSet.Outcome = types.TxCommitted
+	if decision != nil {
+		relayTxSet.Outcome = types.TxAborted
+	}
+	if setBz, err := RelayTransferTxSetBytes(relayTxSet); err != nil {
+		return nil, err
+	} else if err := app.bank.SetSpecial(hash, setBz); err != nil {
+		return nil, err
+	}
+	if !own.OK {
+		// its abort was known when it was refused here
+		return nil, nil
+	}
+	if decision != nil {
+		metrics.Aborts.With(metrics.AbortReason(decision)).Inc()
+	}
+	if app.recording() {
+		app.record(hash, rawTx, types.TxCommitted, decision, rawTx.Shards)
+	}
+	return crossShardReceipt(relayTxSet.RawTx, hash, len(relayTxSet.Shards), relayTxSet.Outcome, decision), nil
+}
+
+// release unlocks the keys this shard locked for tx in view, and ends their
+// lease.
+func (app *Application) release(view int64, hash []byte, tx *bank.TransferTx, keys []string, db DB) error {
+	for _, key := range keys {
+		unlock := db.RUnlock
+		if app.exclusive(tx, key) {
+			unlock = db.WUnlock
+		}
+		if err := unlock(key); err != nil {
+			return err
+		}
+	}
+	app.lockedKeys -= len(keys)
+	metrics.LockHeld.Observe(float64(app.view - view))
+	return app.bank.DeleteLease(view, hash)
+}
+
+// expireLeases aborts the txs whose locks this shard has held for
+// leaseViews without deciding them.
+func (app *Application) expireLeases(db DB) ([]*types.ABCIExecutionReceipt, error) {
+	if app.leaseViews <= 0 {
+		return nil, nil
+	}
+	type lease struct {
+		view int64
+		hash []byte
+		keys []string
+	}
+	var expired []lease
+	if err := app.eachLease(app.view-app.leaseViews+1, func(view int64, hash []byte, keys *bank.BankData) {
+		expired = append(expired, lease{view, hash, keys.Keys})
+	}); err != nil {
+		return nil, err
+	}
+	var receipts []*types.ABCIExecutionReceipt
+	for _, l := range expired {
+		bz, err := app.bank.Special(l.hash)
+		if err != nil {
+			return nil, err
+		}
+		relayTxSet, err := NewRelayTransferTxSetFromBytes(bz)
+		if err != nil {
+			return nil, err
+		}
+		rawTx, err := NewTransferTxFromBytes(relayTxSet.RawTx)
+		if err != nil {
+			return nil, err
+		}
+		if err := app.release(l.view, l.hash, rawTx, l.keys, db); err != nil {
+			return nil, err
+		}
+		relayTxSet.Outcome = types.TxAborted
+		if setBz, err := RelayTransferTxSetBytes(relayTxSet); err != nil {
+			return nil, err
+		} else if err := app.bank.SetSpecial(l.hash, setBz); err != nil {
+			return nil, err
+		}
+		err = fmt.Errorf("%w, locked at view %d and undecided at view %d", errLeaseExpired, l.view, app.view)
+		metrics.Aborts.With(metrics.AbortReason(err)).Inc()
+		if app.recording() {
+			app.record(l.hash, rawTx, types.TxCommitted, err, relayTxSet.Shards)
+		}
+		receipts = append(receipts, crossShardReceipt(relayTxSet.RawTx, l.hash, len(relayTxSet.Shards), types.TxAborted, err))
+	}
+	return receipts, nil
+}
+
+// eachLease calls f with the leases taken before view, oldest first.
+func (app *Application) eachLease(before int64, f func(view int64, hash []byte, keys *bank.BankData)) error {
+	return app.bank.Leases(before, func(view int64, hash, bz []byte) error {
+		keys, err := NewBankDataFromBytes(bz)
+		if err != nil {
+			return err
+		}
+		f(view, hash, keys)
+		return nil
+	})
+}
+
+// observeLocks reports the keys locked and the age of the oldest lock. It is
+// called after Flush, the leases are all in the store.
+func (app *Application) observeLocks() {
+	metrics.LockedKeys.Set(float64(app.lockedKeys))
+	oldest := app.view
+	iter, err := app.db.Iterator(toLeaseKey(0, nil), toLeaseKey(math.MaxInt64, nil))
 	if err != nil {
-		return err
+		return
+	}
+	defer iter.Close()
+	if iter.Valid() {
+		oldest, _ = fromLeaseKey(iter.Key())
+	}
+	metrics.OldestLock.Set(float64(app.view - oldest))
+}
+
+// indexOf is the index of this shard in shards.
+func (app *Application) indexOf(shards []string) int {
+	for i, shard := range shards {
+		if shard == app.chain_id {
+			return i
+		}
 	}
-	return app.doTransfer(rawTx, db)
+	return -1
 }
 
 func (app *Application) insertBankDataToRelayTxSet(bank_data *bank.BankData, chain string, relayTxSet *bank.RelayTransferTxSet) *bank.RelayTransferTxSet {
@@ -243,6 +746,32 @@ func (app *Application) insertBankDataToRelayTxSet(bank_data *bank.BankData, cha
 func (app *Application) lockTransfer(tx *bank.TransferTx, raw_tx []byte, db DB) error {
 	// we have validated this transaction when pre_execution
 	// the only thing todo is lock those txs, which should have been done in pre_execution phase
+	if err := app.storeRelayTxSet(tx, raw_tx); err != nil {
+		return err
+	}
+	lease := &bank.BankData{View: app.view}
+	for _, key := range append(tx.From, tx.To...) {
+		if !app.search_key_intra_shard(key) {
+			continue
+		}
+		if app.exclusive(tx, key) {
+			db.WLock(key)
+		} else {
+			db.RLock(key)
+		}
+		lease.Keys = append(lease.Keys, key)
+	}
+	bz, err := BankDataBytes(lease)
+	if err != nil {
+		return err
+	}
+	app.lockedKeys += len(lease.Keys)
+	return app.bank.SetLease(app.view, types.TxHash(raw_tx), bz)
+}
+
+// storeRelayTxSet starts the relay set of tx, with the relays that came
+// before it.
+func (app *Application) storeRelayTxSet(tx *bank.TransferTx, raw_tx []byte) error {
 	hash := types.TxHash(raw_tx)
 
 	relayTxSet := &bank.RelayTransferTxSet{
@@ -250,7 +779,7 @@ func (app *Application) lockTransfer(tx *bank.TransferTx, raw_tx []byte, db DB)
 		Datas:  make([]*bank.BankData, len(tx.Shards)),
 		RawTx:  raw_tx,
 	}
-	if bz, err := app.db.GetSpecial(toRelayKey(hash)); err != nil {
+	if bz, err := app.bank.Special(toRelayKey(hash)); err != nil {
 		return err
 	} else if len(bz) > 0 {
 		if datas, err := RelayTransferTxListFromBytes(bz); err != nil {
@@ -266,17 +795,7 @@ func (app *Application) lockTransfer(tx *bank.TransferTx, raw_tx []byte, db DB)
 	if err != nil {
 		return err
 	}
-	if err := app.db.SetSpecial(hash, setBz); err != nil {
-		return err
-	}
-
-	for _, key := range append(tx.From, tx.To...) {
-		if !app.search_key_intra_shard(key) {
-			continue
-		}
-		db.WLock(key)
-	}
-	return nil
+	return app.bank.SetSpecial(hash, setBz)
 }
 
 func (app *Application) pre_doTransfer(tx *bank.TransferTx, raw_tx []byte, wlocks, rlocks map[string]bool, db DB) ([]byte, []string, error) {
@@ -284,16 +803,16 @@ func (app *Application) pre_doTransfer(tx *bank.TransferTx, raw_tx []byte, wlock
 		return nil, nil, fmt.Errorf("tx is not included in related shards")
 	}
 	hash := types.TxHash(raw_tx)
-	if ok, err := app.db.HasSpecial(hash); err != nil {
+	if bz, err := app.bank.Special(hash); err != nil {
 		return nil, nil, err
-	} else if ok {
-		return nil, nil, fmt.Errorf("tx already committed")
+	} else if len(bz) > 0 {
+		return nil, nil, errDuplicate
 	}
 	if err := ValidateTransferTx(tx); err != nil {
 		return nil, nil, err
 	}
 	var relayTx = new(bank.RelayTransferTx)
-	var bankData = new(bank.BankData)
+	var bankData = &bank.BankData{OK: true, View: app.view}
 	relayTx.Datas = bankData
 	relayTx.TxHash = hash
 	related_shards := []string{}
@@ -311,10 +830,15 @@ func (app *Application) pre_doTransfer(tx *bank.TransferTx, raw_tx []byte, wlock
 		if err != nil {
 			return nil, nil, err
 		}
-		if !isFree(locked) || wlocks[key] || rlocks[key] {
-			return nil, nil, fmt.Errorf("Abort due to lock conflict")
+		exclusive := app.exclusive(tx, key)
+		if isWLock(locked) || wlocks[key] || exclusive && (!isFree(locked) || rlocks[key]) {
+			return nil, nil, errLockConflict
+		}
+		if exclusive {
+			wlocks[key] = true
+		} else {
+			rlocks[key] = true
 		}
-		wlocks[key] = true
 		bankData.Keys = append(bankData.Keys, key)
 		bankData.Values = append(bankData.Values, money)
 	}
@@ -329,9 +853,34 @@ func (app *Application) pre_doTransfer(tx *bank.TransferTx, raw_tx []byte, wlock
 	if !utils.StrEqual(tx.Shards, related_shards) {
 		return nil, nil, fmt.Errorf("invalid cross shard fields: %v != %v", tx.Shards, related_shards)
 	}
+	if err := app.authorize(tx, db); err != nil {
+		return nil, nil, err
+	}
 	return relayTxBz, related_shards, nil
 }
 
+// authorize checks the signatures of tx and the nonces of its From accounts
+// of this shard, which move to those of tx, if signatures are required.
+func (app *Application) authorize(tx *bank.TransferTx, db DB) error {
+	if !signedTxs {
+		return nil
+	}
+	// every shard of the tx verifies all of them, to agree on the outcome
+	if err := VerifyTransferTx(tx); err != nil {
+		return err
+	}
+	owners, err := checkOwners(tx, app.search_key_intra_shard, db.Owner)
+	if err != nil {
+		return err
+	}
+	for account, owner := range owners {
+		if err := db.SetOwner(account, owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (app *Application) doTransfer(tx *bank.TransferTx, db DB) error {
 	if !utils.StrIn(app.chain_id, tx.Shards) {
 		return fmt.Errorf("tx is not included in related shards")
@@ -339,38 +888,48 @@ func (app *Application) doTransfer(tx *bank.TransferTx, db DB) error {
 	if err := ValidateTransferTx(tx); err != nil {
 		return err
 	}
+	if err := app.authorize(tx, db); err != nil {
+		return err
+	}
+	return app.transfer(tx, db)
+}
+
+// transfer moves the money of a valid and authorized tx. The accounts keep
+// their read locks.
+func (app *Application) transfer(tx *bank.TransferTx, db DB) error {
 	fromBalance, toBalance := make([]uint32, len(tx.From)), make([]uint32, len(tx.To))
+	fromLocked, toLocked := make([]byte, len(tx.From)), make([]byte, len(tx.To))
 	// 1. read Balance
 	for i, fromKey := range tx.From {
 		if balance, locked, err := db.Get(fromKey); err != nil {
 			return err
 			//fromBalance[i] = initBalance - tx.FromMoney[i]
-		} else if !isFree(locked) {
-			return fmt.Errorf("one of its keys is locked")
+		} else if app.blocked(locked) {
+			return errKeyLocked
 		} else if balance < tx.FromMoney[i] {
-			return errors.New("Balance is not Enough")
+			return errBalance
 		} else {
-			fromBalance[i] = balance - tx.FromMoney[i]
+			fromBalance[i], fromLocked[i] = balance-tx.FromMoney[i], locked
 		}
 	}
 	for i, toKey := range tx.To {
 		if balance, locked, err := db.Get(toKey); err != nil {
 			return err
 			//toBalance[i] = initBalance + tx.ToMoney[i]
-		} else if !isFree(locked) {
-			return fmt.Errorf("one of its keys is locked")
+		} else if app.blocked(locked) {
+			return errKeyLocked
 		} else {
-			toBalance[i] = balance + tx.ToMoney[i]
+			toBalance[i], toLocked[i] = balance+tx.ToMoney[i], locked
 		}
 	}
 	// 2. write Balance
 	for i, fromKey := range tx.From {
-		if err := db.Set(fromKey, fromBalance[i], FreeIdentifier); err != nil {
+		if err := db.Set(fromKey, fromBalance[i], fromLocked[i]); err != nil {
 			return err
 		}
 	}
 	for i, toKey := range tx.To {
-		if err := db.Set(toKey, toBalance[i], FreeIdentifier); err != nil {
+		if err := db.Set(toKey, toBalance[i], toLocked[i]); err != nil {
 			return err
 		}
 	}
@@ -389,7 +948,15 @@ func (app *Application) validateTx(txBytes []byte) (err error) {
 		if err := proto.Unmarshal(txBytes[4:], tx); err != nil {
 			return fmt.Errorf("fail to unmarshsal")
 		}
-		return nil
+		if !signedTxs {
+			return nil
+		}
+		if err := VerifyTransferTx(tx); err != nil {
+			return err
+		}
+		// against the committed state, the cache belongs to the execution
+		_, err := checkOwners(tx, app.search_key_intra_shard, app.committedOwner)
+		return err
 	case definition.TxInsert:
 		tx := new(bank.InsertTx)
 		if err := proto.Unmarshal(txBytes[4:], tx); err != nil {
@@ -0,0 +1,265 @@
+package minibank
+
+import (
+	"emulator/urd/shardinfo"
+	"emulator/urd/types"
+	"math"
+	"strings"
+	"testing"
+)
+
+func newTestApplication(chain string) *Application {
+	app := &Application{
+		db:              newTestPrefixStore(),
+		KeyRangeTrees:   testRangeLists(),
+		chain_id:        chain,
+		shards_to_index: map[string]int{"i1": 0, "i2": 1, "i3": 2},
+		shard_info:      &shardinfo.ShardInfo{ShardIDList: []string{"i1", "i2", "i3"}},
+	}
+	app.bank = NewCachedDB(app.db, app.KeyRangeTrees[chain], 0)
+	return app
+}
+
+func execute(t *testing.T, app *Application, view int64, txs, crossShardTxs types.Txs, ctxs []types.Txs) *types.ABCIExecutionResponse {
+	t.Helper()
+	resp, err := app.Execution(view, txs, crossShardTxs, ctxs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestCrossShardDecision(t *testing.T) {
+	apps := []*Application{newTestApplication("i1"), newTestApplication("i2")}
+	transfer := func(from, to string, money uint32) []byte {
+		return TransferBytes(NewTransferTx([]string{from}, []uint32{money}, []string{to}, []uint32{money}, []string{"i1", "i2"}))
+	}
+	committed := transfer("10a", "11a", 3)
+	locked := transfer("10c", "11b", 3)
+	// 11b is locked by the tx above at i2, which refuses it
+	conflict := transfer("10d", "11b", 3)
+	poor := transfer("10e", "11e", initBalance+1)
+	txs := types.Txs{committed, locked, conflict, poor}
+
+	relays := make([][]types.Txs, len(apps))
+	for i, app := range apps {
+		resp := execute(t, app, 1, nil, txs, nil)
+		relays[i] = resp.OPTxs
+		for _, receipt := range resp.CrossShardResponses {
+			if app.chain_id == "i2" && string(receipt.GetRawTx()) == string(conflict) {
+				if receipt.Status() != types.TxAborted || receipt.IsOK() {
+					t.Fatalf("conflicting tx %s at i2", receipt.Status())
+				}
+			} else if receipt.Status() != types.TxLocked {
+				t.Fatalf("tx %s at %s, want locked", receipt.Status(), app.chain_id)
+			}
+		}
+	}
+	want := map[string]string{
+		string(committed): types.TxCommitted,
+		string(locked):    types.TxCommitted,
+		string(conflict):  types.TxAborted,
+		string(poor):      types.TxAborted,
+	}
+	var committedShares float64
+	for i, app := range apps {
+		ctxs := make([]types.Txs, 3)
+		for j := range apps {
+			ctxs[j] = relays[j][i]
+		}
+		decided := map[string]string{}
+		resp := execute(t, app, 2, nil, nil, ctxs)
+		committed, _ := resp.DecisionShares()
+		committedShares += committed
+		for _, receipt := range resp.CrossShardResponses {
+			decided[string(receipt.GetRawTx())] = receipt.Status()
+		}
+		if app.chain_id == "i2" {
+			// its abort was told when it was refused
+			delete(want, string(conflict))
+		}
+		if len(decided) != len(want) {
+			t.Fatalf("%d txs decided at %s, want %d", len(decided), app.chain_id, len(want))
+		}
+		for tx, status := range want {
+			if decided[tx] != status {
+				t.Fatalf("tx %s at %s, want %s", decided[tx], app.chain_id, status)
+			}
+		}
+	}
+
+	// each shard counts half of the two txs committed
+	if committedShares < 1.999 || committedShares > 2.001 {
+		t.Fatalf("the shards counted %.3f committed txs, want 2", committedShares)
+	}
+
+	balances := map[string]uint32{"10a": initBalance - 3, "11a": initBalance + 3, "10d": initBalance, "10e": initBalance, "11e": initBalance}
+	for key, balance := range balances {
+		app := apps[0]
+		if !app.search_key_intra_shard(key) {
+			app = apps[1]
+		}
+		account, err := app.Account(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if account.Balance != balance || account.Lock != types.LockFree {
+			t.Fatalf("account %s: %d %s, want %d free", key, account.Balance, account.Lock, balance)
+		}
+	}
+
+	// a duplicate does not undo the decision
+	for _, app := range apps {
+		if receipts := execute(t, app, 3, nil, types.Txs{committed}, nil).CrossShardResponses; len(receipts) != 0 {
+			t.Fatalf("duplicate gave %d receipts at %s", len(receipts), app.chain_id)
+		}
+	}
+}
+
+func TestLockLease(t *testing.T) {
+	apps := []*Application{newTestApplication("i1"), newTestApplication("i2")}
+	for _, app := range apps {
+		app.LeaseLocks(5)
+	}
+	stuck := TransferBytes(NewTransferTx([]string{"10a"}, []uint32{3}, []string{"11a"}, []uint32{3}, []string{"i1", "i2"}))
+	late := TransferBytes(NewTransferTx([]string{"10b"}, []uint32{3}, []string{"11b"}, []uint32{3}, []string{"i1", "i2"}))
+	decided := func(app *Application, view int64, ctxs []types.Txs) map[string]string {
+		t.Helper()
+		out := map[string]string{}
+		for _, receipt := range execute(t, app, view, nil, nil, ctxs).CrossShardResponses {
+			if receipt.Status() != types.TxAborted || !strings.HasPrefix(receipt.Info, errLeaseExpired.Error()) {
+				t.Fatalf("tx %s at %s: %s", receipt.Status(), app.chain_id, receipt.Info)
+			}
+			out[string(receipt.GetRawTx())] = receipt.Status()
+		}
+		return out
+	}
+
+	// the relays of i2 for stuck are late, i2 locks late 3 views after i1
+	relays := [][]types.Txs{execute(t, apps[0], 1, nil, types.Txs{stuck, late}, nil).OPTxs, execute(t, apps[1], 1, nil, types.Txs{stuck}, nil).OPTxs}
+	for _, app := range apps {
+		if out := decided(app, 3, []types.Txs{relays[0][app.shards_to_index[app.chain_id]]}); len(out) != 0 {
+			t.Fatalf("%s decided before the relays of i2", app.chain_id)
+		}
+	}
+	lost := relays[1]
+	relays[1] = execute(t, apps[1], 4, nil, types.Txs{late}, nil).OPTxs
+	if account, _ := apps[0].Account("10a"); account.Lock != types.LockWrite {
+		t.Fatalf("10a is %s before its lease expired", account.Lock)
+	}
+
+	// i1 locked both at view 1, i2 stuck only
+	if out := decided(apps[0], 6, nil); len(out) != 2 {
+		t.Fatalf("i1 aborted %d txs once their leases expired, want 2", len(out))
+	}
+	if out := decided(apps[1], 6, nil); len(out) != 1 || out[string(stuck)] == "" {
+		t.Fatal("i2 did not abort stuck once its lease expired")
+	}
+	for _, key := range []string{"10a", "10b", "11a"} {
+		app := apps[0]
+		if !app.search_key_intra_shard(key) {
+			app = apps[1]
+		}
+		if account, _ := app.Account(key); account.Lock != types.LockFree || account.Balance != initBalance {
+			t.Fatalf("account %s: %d %s after the lease expired", key, account.Balance, account.Lock)
+		}
+	}
+
+	// the relays of i2 come: stuck was decided everywhere, and late is
+	// complete 6 views after i1 locked it, i2 aborts it as i1 did
+	for _, app := range apps {
+		i := app.shards_to_index[app.chain_id]
+		out := decided(app, 7, []types.Txs{nil, append(lost[i], relays[1][i]...)})
+		if app.chain_id == "i1" && len(out) != 0 || app.chain_id == "i2" && (len(out) != 1 || out[string(late)] == "") {
+			t.Fatalf("%s decided %d txs at view 7", app.chain_id, len(out))
+		}
+		if app.lockedKeys != 0 {
+			t.Fatalf("%s still has %d keys locked", app.chain_id, app.lockedKeys)
+		}
+	}
+}
+
+func TestSharedLocks(t *testing.T) {
+	apps := []*Application{newTestApplication("i1"), newTestApplication("i2")}
+	for _, app := range apps {
+		app.ShareLocks(true)
+	}
+	transfer := func(from, to string, money uint32) []byte {
+		return TransferBytes(NewTransferTx([]string{from}, []uint32{money}, []string{to}, []uint32{money}, []string{"i1", "i2"}))
+	}
+	// both credit 11a, debiting it conflicts with them at i2
+	first, second := transfer("10a", "11a", 3), transfer("10b", "11a", 4)
+	debit := transfer("11a", "10c", 5)
+
+	relays := make([][]types.Txs, len(apps))
+	for i, app := range apps {
+		resp := execute(t, app, 1, nil, types.Txs{first, second, debit}, nil)
+		relays[i] = resp.OPTxs
+		for _, receipt := range resp.CrossShardResponses {
+			refused := app.chain_id == "i2" && string(receipt.GetRawTx()) == string(debit)
+			if refused != (receipt.Status() == types.TxAborted) {
+				t.Fatalf("tx %s at %s: %s", receipt.Status(), app.chain_id, receipt.Info)
+			}
+		}
+	}
+	if account, _ := apps[1].Account("11a"); account.Lock != types.LockRead {
+		t.Fatalf("11a is %s, want read locked", account.Lock)
+	}
+	if account, _ := apps[0].Account("10a"); account.Lock != types.LockWrite {
+		t.Fatalf("10a is %s, want write locked", account.Lock)
+	}
+
+	// an intra-shard tx credits the read locked account meanwhile
+	intra := TransferBytes(NewTransferTx([]string{"11b"}, []uint32{2}, []string{"11a"}, []uint32{2}, []string{"i2"}))
+	if resp := execute(t, apps[1], 2, types.Txs{intra}, nil, nil); !resp.Responses[0].IsOK() {
+		t.Fatalf("intra-shard tx aborted: %s", resp.Responses[0].Info)
+	}
+	if account, _ := apps[1].Account("11a"); account.Lock != types.LockRead || account.Balance != initBalance+2 {
+		t.Fatalf("account 11a: %d %s after the intra-shard tx", account.Balance, account.Lock)
+	}
+
+	for i, app := range apps {
+		ctxs := make([]types.Txs, 3)
+		for j := range apps {
+			ctxs[j] = relays[j][i]
+		}
+		for _, receipt := range execute(t, app, 3, nil, nil, ctxs).CrossShardResponses {
+			if aborted := string(receipt.GetRawTx()) == string(debit); aborted != (receipt.Status() == types.TxAborted) {
+				t.Fatalf("tx %s at %s: %s", receipt.Status(), app.chain_id, receipt.Info)
+			}
+		}
+		if app.lockedKeys != 0 {
+			t.Fatalf("%s still has %d keys locked", app.chain_id, app.lockedKeys)
+		}
+	}
+	balances := map[string]uint32{"10a": initBalance - 3, "10b": initBalance - 4, "10c": initBalance, "11a": initBalance + 9, "11b": initBalance - 2}
+	for key, balance := range balances {
+		app := apps[0]
+		if !app.search_key_intra_shard(key) {
+			app = apps[1]
+		}
+		if account, _ := app.Account(key); account.Balance != balance || account.Lock != types.LockFree {
+			t.Fatalf("account %s: %d %s, want %d free", key, account.Balance, account.Lock, balance)
+		}
+	}
+}
+
+func TestValidateTransferTx(t *testing.T) {
+	for _, c := range []struct {
+		name               string
+		from, to           []string
+		fromMoney, toMoney []uint32
+		ok                 bool
+	}{
+		{"transfer", []string{"10a"}, []string{"10b"}, []uint32{3}, []uint32{3}, true},
+		{"debited twice", []string{"10a", "10a"}, []string{"10b"}, []uint32{3, 3}, []uint32{6}, false},
+		{"credited twice", []string{"10a"}, []string{"10b", "10b"}, []uint32{6}, []uint32{3, 3}, false},
+		{"debited and credited", []string{"10a", "10b"}, []string{"10a"}, []uint32{3, 3}, []uint32{6}, false},
+		{"wrapping sum", []string{"10a", "10b"}, []string{"10c"}, []uint32{math.MaxUint32, 2}, []uint32{1}, false},
+	} {
+		tx := NewTransferTx(c.from, c.fromMoney, c.to, c.toMoney, []string{"i1"})
+		if err := ValidateTransferTx(tx); (err == nil) != c.ok {
+			t.Fatalf("%s: got %v, want ok %v", c.name, err, c.ok)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package minibank
+
+import (
+	"container/list"
+	"emulator/utils"
+	"emulator/utils/store"
+	"fmt"
+)
+
+const defaultCacheCapacity = 1 << 18
+
+// CachedDB sits in front of the RocksDB PrefixStore. Reads are served from an
+// LRU of recently used accounts, writes are kept in a dirty set and only hit
+// RocksDB in one batch when Flush is called at the end of a block. The relay
+// sets and the leases of a block are kept alongside until then, so that a
+// block is persisted whole or not at all.
+type CachedDB struct {
+	db        *store.PrefixStore
+	rangelist *utils.RangeList
+
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+	dirty    map[string][]byte
+	specials map[string][]byte
+	// leases holds nil for a lease released since the last Flush
+	leases map[string][]byte
+
+	retainData map[string]uint32
+}
+
+type cacheEntry struct {
+	key   string
+	value []byte
+}
+
+var _ DB = (*CachedDB)(nil)
+
+func NewCachedDB(db *store.PrefixStore, rangeList *utils.RangeList, capacity int) DB {
+	if capacity <= 0 {
+		capacity = defaultCacheCapacity
+	}
+	return &CachedDB{
+		db:         db,
+		rangelist:  rangeList,
+		capacity:   capacity,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		dirty:      make(map[string][]byte),
+		specials:   make(map[string][]byte),
+		leases:     make(map[string][]byte),
+		retainData: make(map[string]uint32),
+	}
+}
+
+func (cdb *CachedDB) Get(key string) (uint32, byte, error) {
+	if cdb.rangelist.Search(key) {
+		if bz, err := cdb.read(key); err != nil || len(bz) == 0 {
+			if err := cdb.Set(key, initBalance, FreeIdentifier); err != nil {
+				return 0, '0', err
+			}
+			return initBalance, FreeIdentifier, nil
+		} else {
+			return UnmarshalValue(bz)
+		}
+	} else {
+		if v, ok := cdb.retainData[key]; ok {
+			return v, FreeIdentifier, nil
+		} else {
+			return 0, FreeIdentifier, fmt.Errorf("key does not exists")
+		}
+	}
+}
+
+func (cdb *CachedDB) Set(key string, money uint32, locked byte) error {
+	if !cdb.rangelist.Search(key) {
+		return nil
+	}
+	value, err := MarshalValue(money, locked)
+	if err != nil {
+		return err
+	}
+	if isRLock(locked) {
+		old, err := cdb.read(key)
+		if err != nil {
+			return err
+		}
+		value = keepReaders(value, old)
+	}
+	cdb.write(key, value)
+	return nil
+}
+
+func (cdb *CachedDB) LoadData(key string, value uint32) {
+	cdb.retainData[key] = value
+}
+func (cdb *CachedDB) Clear() {
+	cdb.retainData = make(map[string]uint32)
+}
+
+func (cdb *CachedDB) Flush() error {
+	if len(cdb.dirty) == 0 && len(cdb.specials) == 0 && len(cdb.leases) == 0 {
+		return nil
+	}
+	batch, err := cdb.db.NewBatch()
+	if err != nil {
+		return err
+	}
+	defer batch.Close()
+	for key, value := range cdb.dirty {
+		if err := batch.Set([]byte(key), value); err != nil {
+			return err
+		}
+	}
+	for hash, value := range cdb.specials {
+		if err := batch.SetSpecial([]byte(hash), value); err != nil {
+			return err
+		}
+	}
+	for key, keys := range cdb.leases {
+		if keys == nil {
+			err = batch.Delete([]byte(key))
+		} else {
+			err = batch.Set([]byte(key), keys)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	cdb.dirty = make(map[string][]byte)
+	cdb.specials = make(map[string][]byte)
+	cdb.leases = make(map[string][]byte)
+	return nil
+}
+
+// Discard also drops the dirty keys from the LRU, which then reads them
+// back from RocksDB.
+func (cdb *CachedDB) Discard() {
+	for key := range cdb.dirty {
+		if e, ok := cdb.entries[key]; ok {
+			cdb.order.Remove(e)
+			delete(cdb.entries, key)
+		}
+	}
+	cdb.dirty = make(map[string][]byte)
+	cdb.specials = make(map[string][]byte)
+	cdb.leases = make(map[string][]byte)
+	cdb.Clear()
+}
+
+func (cdb *CachedDB) Special(hash []byte) ([]byte, error) {
+	if bz, ok := cdb.specials[string(hash)]; ok {
+		return bz, nil
+	}
+	return cdb.db.GetSpecial(hash)
+}
+func (cdb *CachedDB) SetSpecial(hash, value []byte) error {
+	cdb.specials[string(hash)] = value
+	return nil
+}
+
+func (cdb *CachedDB) SetLease(view int64, hash, keys []byte) error {
+	// never nil, which is a released lease
+	cdb.leases[string(toLeaseKey(view, hash))] = append([]byte{}, keys...)
+	return nil
+}
+func (cdb *CachedDB) DeleteLease(view int64, hash []byte) error {
+	cdb.leases[string(toLeaseKey(view, hash))] = nil
+	return nil
+}
+func (cdb *CachedDB) Leases(before int64, f func(view int64, hash, keys []byte) error) error {
+	return leasesBefore(cdb.db, cdb.leases, before, f)
+}
+
+func (cdb *CachedDB) Owner(key string) (*Owner, error) {
+	if bz, err := cdb.read(ownerKey(key)); err != nil || len(bz) == 0 {
+		return nil, err
+	} else {
+		return UnmarshalOwner(bz)
+	}
+}
+func (cdb *CachedDB) SetOwner(key string, owner *Owner) error {
+	cdb.write(ownerKey(key), MarshalOwner(owner))
+	return nil
+}
+
+func (cdb *CachedDB) RLock(key string) error {
+	return cdb.setLock(key, SetValueRLock)
+}
+func (cdb *CachedDB) RUnlock(key string) error {
+	return cdb.setLock(key, SetValueRUnlock)
+}
+func (cdb *CachedDB) WLock(key string) error {
+	return cdb.setLock(key, SetValueWLock)
+}
+func (cdb *CachedDB) WUnlock(key string) error {
+	return cdb.setLock(key, SetValueUnlock)
+}
+
+func (cdb *CachedDB) setLock(key string, setter func([]byte) []byte) error {
+	if bz, err := cdb.read(key); err != nil || len(bz) == 0 {
+		return fmt.Errorf("key does not exist")
+	} else if out := setter(append([]byte(nil), bz...)); out == nil {
+		return fmt.Errorf("Unknown error")
+	} else {
+		cdb.write(key, out)
+	}
+	return nil
+}
+
+// read looks the key up in the dirty set, then the LRU, then RocksDB.
+func (cdb *CachedDB) read(key string) ([]byte, error) {
+	if bz, ok := cdb.dirty[key]; ok {
+		return bz, nil
+	}
+	if e, ok := cdb.entries[key]; ok {
+		cdb.order.MoveToFront(e)
+		return e.Value.(*cacheEntry).value, nil
+	}
+	bz, err := cdb.db.Get([]byte(key))
+	if err != nil || len(bz) == 0 {
+		return bz, err
+	}
+	cdb.insert(key, bz)
+	return bz, nil
+}
+
+func (cdb *CachedDB) write(key string, value []byte) {
+	cdb.dirty[key] = value
+	cdb.insert(key, value)
+}
+
+// insert never drops a pending write: evicted keys that are still dirty are
+// read back from the dirty set until the next Flush.
+func (cdb *CachedDB) insert(key string, value []byte) {
+	if e, ok := cdb.entries[key]; ok {
+		e.Value.(*cacheEntry).value = value
+		cdb.order.MoveToFront(e)
+		return
+	}
+	cdb.entries[key] = cdb.order.PushFront(&cacheEntry{key: key, value: value})
+	for cdb.order.Len() > cdb.capacity {
+		last := cdb.order.Back()
+		cdb.order.Remove(last)
+		delete(cdb.entries, last.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,231 @@
+package minibank
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A dataset is a file of encoded txs. Two formats exist:
+//
+//   - txt: one hex encoded tx per line, the original format
+//   - bin: datasetMagic followed by every tx prefixed with its uvarint
+//     length, gzip compressed when the file name ends in .gz
+//
+// Readers detect the format from the content, so a dataset can be renamed.
+const (
+	DatasetText       = "txt"
+	DatasetBinary     = "bin"
+	DatasetBinaryGzip = "bin.gz"
+
+	DefaultDatasetFormat = DatasetBinaryGzip
+
+	datasetMagic = "URDTXS\x00\x01"
+	// txs longer than this are taken for a corrupted length prefix
+	maxDatasetTxSize = 64 * 1024 * 1024
+)
+
+// DatasetFileName is the name of the dataset of format in a dataset dir.
+func DatasetFileName(format string) string {
+	return "dataset." + format
+}
+
+// FindDataset returns the dataset in dir, whatever its format.
+func FindDataset(dir string) (string, error) {
+	for _, format := range []string{DatasetBinaryGzip, DatasetBinary, DatasetText} {
+		path := filepath.Join(dir, DatasetFileName(format))
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no dataset in %s", dir)
+}
+
+type DatasetReader interface {
+	// Next returns the next encoded tx, io.EOF after the last one.
+	Next() ([]byte, error)
+	Close() error
+}
+
+type DatasetWriter interface {
+	Write(tx []byte) error
+	Close() error
+}
+
+func OpenDataset(path string) (DatasetReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(file, 1024*1024)
+	head, err := br.Peek(len(datasetMagic))
+	if err != nil && err != io.EOF {
+		file.Close()
+		return nil, err
+	}
+	// gzip streams start with 0x1f 0x8b
+	if len(head) >= 2 && head[0] == 0x1f && head[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return newBinaryDatasetReader(path, bufio.NewReaderSize(zr, 1024*1024), file, zr)
+	}
+	if string(head) == datasetMagic {
+		return newBinaryDatasetReader(path, br, file, nil)
+	}
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 1024*1024), maxDatasetTxSize)
+	return &textDatasetReader{file: file, scanner: scanner}, nil
+}
+
+func CreateDataset(path string, format string) (DatasetWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case DatasetText:
+		return &textDatasetWriter{file: file, w: bufio.NewWriterSize(file, 1024*1024)}, nil
+	case DatasetBinary, DatasetBinaryGzip:
+		w := &binaryDatasetWriter{file: file, w: bufio.NewWriterSize(file, 1024*1024)}
+		if format == DatasetBinaryGzip {
+			w.zw = gzip.NewWriter(w.w)
+		}
+		if _, err := w.out().Write([]byte(datasetMagic)); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return w, nil
+	default:
+		file.Close()
+		return nil, fmt.Errorf("unknown dataset format %q, expected %s, %s or %s", format, DatasetText, DatasetBinary, DatasetBinaryGzip)
+	}
+}
+
+type textDatasetReader struct {
+	file    *os.File
+	scanner *bufio.Scanner
+}
+
+func (r *textDatasetReader) Next() ([]byte, error) {
+	for r.scanner.Scan() {
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		return hex.DecodeString(line)
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (r *textDatasetReader) Close() error { return r.file.Close() }
+
+type binaryDatasetReader struct {
+	r    *bufio.Reader
+	file *os.File
+	zr   *gzip.Reader
+}
+
+func newBinaryDatasetReader(path string, r *bufio.Reader, file *os.File, zr *gzip.Reader) (DatasetReader, error) {
+	head := make([]byte, len(datasetMagic))
+	if _, err := io.ReadFull(r, head); err != nil || string(head) != datasetMagic {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a dataset", path)
+	}
+	return &binaryDatasetReader{r: r, file: file, zr: zr}, nil
+}
+
+func (r *binaryDatasetReader) Next() ([]byte, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		// a clean end of file can only happen between two txs
+		return nil, err
+	}
+	if size > maxDatasetTxSize {
+		return nil, fmt.Errorf("dataset is corrupted: tx of %d bytes", size)
+	}
+	tx := make([]byte, size)
+	if _, err := io.ReadFull(r.r, tx); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (r *binaryDatasetReader) Close() error {
+	if r.zr != nil {
+		r.zr.Close()
+	}
+	return r.file.Close()
+}
+
+type textDatasetWriter struct {
+	file *os.File
+	w    *bufio.Writer
+}
+
+func (w *textDatasetWriter) Write(tx []byte) error {
+	if _, err := w.w.WriteString(hex.EncodeToString(tx)); err != nil {
+		return err
+	}
+	return w.w.WriteByte('\n')
+}
+
+func (w *textDatasetWriter) Close() error {
+	if err := w.w.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+type binaryDatasetWriter struct {
+	file *os.File
+	w    *bufio.Writer
+	zw   *gzip.Writer
+
+	lenBuf [binary.MaxVarintLen64]byte
+}
+
+func (w *binaryDatasetWriter) out() io.Writer {
+	if w.zw != nil {
+		return w.zw
+	}
+	return w.w
+}
+
+func (w *binaryDatasetWriter) Write(tx []byte) error {
+	n := binary.PutUvarint(w.lenBuf[:], uint64(len(tx)))
+	if _, err := w.out().Write(w.lenBuf[:n]); err != nil {
+		return err
+	}
+	_, err := w.out().Write(tx)
+	return err
+}
+
+func (w *binaryDatasetWriter) Close() error {
+	if w.zw != nil {
+		if err := w.zw.Close(); err != nil {
+			w.file.Close()
+			return err
+		}
+	}
+	if err := w.w.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,99 @@
+package minibank
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDatasetFormats(t *testing.T) {
+	txs := [][]byte{[]byte("a"), {}, bytes.Repeat([]byte{0xff}, 300)}
+	for _, format := range []string{DatasetText, DatasetBinary, DatasetBinaryGzip} {
+		path := filepath.Join(t.TempDir(), DatasetFileName(format))
+		w, err := CreateDataset(path, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tx := range txs {
+			if err := w.Write(tx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := OpenDataset(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, want := range txs {
+			// empty lines are skipped in the text format
+			if format == DatasetText && len(want) == 0 {
+				continue
+			}
+			got, err := r.Next()
+			if err != nil {
+				t.Fatalf("%s: tx %d: %v", format, i, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s: tx %d is %x, expected %x", format, i, got, want)
+			}
+		}
+		if _, err := r.Next(); err != io.EOF {
+			t.Fatalf("%s: expected io.EOF after the last tx, got %v", format, err)
+		}
+		r.Close()
+	}
+}
+
+type testMempool struct {
+	mtx sync.Mutex
+	txs [][]byte
+}
+
+func (m *testMempool) AddTx(tx []byte) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.txs = append(m.txs, tx)
+	return nil
+}
+
+func (m *testMempool) Size() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return len(m.txs)
+}
+
+func TestImportorStreamsDataset(t *testing.T) {
+	w := testWorkload()
+	path := filepath.Join(t.TempDir(), DatasetFileName(DefaultDatasetFormat))
+	if err := NewImportorForGenerator(testRangeLists(), w).GenerateDataset(path, DefaultDatasetFormat); err != nil {
+		t.Fatal(err)
+	}
+
+	want := 0
+	for _, tx := range decodeTransferTxs(t, NewImportorForGenerator(testRangeLists(), w).GenerateTxs()) {
+		for _, shard := range tx.Shards {
+			if shard == "i1" {
+				want++
+			}
+		}
+	}
+
+	mempool, crossShardMempool := &testMempool{}, &testMempool{}
+	importor := NewImportor(mempool, crossShardMempool, nil, "i1", path, testRangeLists(), w, true)
+	if err := importor.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer importor.Stop()
+	for deadline := time.Now().Add(5 * time.Second); mempool.Size()+crossShardMempool.Size() < want; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d txs reached the mempools, expected %d", mempool.Size()+crossShardMempool.Size(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -1,39 +1,25 @@
 package minibank
 
 import (
-	"bufio"
 	"emulator/logger/blocklogger"
 	bank "emulator/proto/urd/abci/minibank"
 	"emulator/utils"
+	"emulator/utils/logging"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"math/rand"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-var (
-	transferSize     = 4
-	mustLen          = 512
-	accountsPerShard = 100000
-	txsPerShard      = 500000
-
-	cross_shard_ratio float64 = 0.8
-
-	amountMoney = []uint32{}
-)
+var importerLog = logging.New("importer")
 
 type AddTxInterface interface {
 	AddTx([]byte) error
-}
-
-func init() {
-	for i := 0; i < transferSize; i++ {
-		amountMoney = append(amountMoney, 1)
-	}
+	Size() int
 }
 
 type Importor struct {
@@ -47,20 +33,35 @@ type Importor struct {
 
 	enabled bool
 
+	workload    *Workload
+	amountMoney []uint32
+
 	all_prefixes []string
 	rander       *rand.Rand
+
+	zipf      *zipfian
+	hotShard  int
+	generated int
+	// keys sign the generated txs, nil unless the workload is signed
+	keys *Keys
+
+	// MaxPending is how many txs a mempool holds before it is no longer fed
+	MaxPending int
+
+	quit chan struct{}
 }
 
 var batch = 1
 
-func NewImportorForGenerator(rangeList map[string]*utils.RangeList) *Importor {
-	return NewImportor(nil, nil, nil, "", "", rangeList, true)
+func NewImportorForGenerator(rangeList map[string]*utils.RangeList, workload *Workload) *Importor {
+	return NewImportor(nil, nil, nil, "", "", rangeList, workload, true)
 }
 
 func NewImportor(
 	mmp, cmmp AddTxInterface, logger blocklogger.BlockWriter,
 	chain_id string, rootDir string,
 	rangeLists map[string]*utils.RangeList,
+	workload *Workload,
 	enabled bool,
 ) *Importor {
 	im := &Importor{
@@ -73,84 +74,187 @@ func NewImportor(
 		rootDir:    rootDir,
 		enabled:    enabled,
 
+		workload:    workload,
+		amountMoney: make([]uint32, workload.TransferSize),
+
 		all_prefixes: make([]string, 0),
-		rander:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		rander:       rand.New(rand.NewSource(workload.Seed)),
+
+		MaxPending: DefaultMaxPendingTxs,
+
+		quit: make(chan struct{}),
+	}
+	for i := range im.amountMoney {
+		im.amountMoney[i] = 1
 	}
 	for _, rl := range im.rangeLists {
 		im.all_prefixes = append(im.all_prefixes, rl.StartKey())
 	}
+	// map order is random, the generated txs must only depend on the seed
+	sort.Strings(im.all_prefixes)
+	if workload.ZipfTheta > 0 {
+		im.zipf = newZipfian(workload.AccountsPerShard, workload.ZipfTheta)
+	}
+	if workload.Signed {
+		im.keys = NewKeys(workload.KeySeed)
+	}
+	if workload.HotShard != "" {
+		rl, ok := rangeLists[workload.HotShard]
+		if !ok {
+			panic(fmt.Sprintf("workload: hot_shard %s is not a shard", workload.HotShard))
+		}
+		im.hotShard = sort.SearchStrings(im.all_prefixes, rl.StartKey())
+	}
 	return im
 }
 
+const (
+	// datasetBufferSize txs are decoded ahead of the mempools
+	datasetBufferSize = 4096
+	// a mempool holding MaxPending txs is not fed until blocks drain it
+	DefaultMaxPendingTxs = 20000
+	// how often a full mempool is checked for space
+	feedInterval = 10 * time.Millisecond
+)
+
+type datasetTx struct {
+	bz         []byte
+	crossShard bool
+}
+
+// Start streams the dataset into the mempools. It returns once the mempools
+// are full or the dataset is exhausted, the rest is fed in the background as
+// blocks free up space.
 func (importor *Importor) Start() error {
 	if !importor.enabled {
 		return nil
 	}
-	useless := 0
+	importerLog.Info("streaming dataset", "dataset", importor.rootDir, "workload", importor.workload)
 
-	if lines, err := readLinesFromFile(importor.rootDir); err != nil {
+	reader, err := OpenDataset(importor.rootDir)
+	if err != nil {
 		return err
-	} else {
-		log_intervel := len(lines) / 10
-		fmt.Println("[%v] init transactons\n", time.Now())
-		for i, line := range lines {
-			var tx *bank.TransferTx
-			var txBz []byte
-			var err error
-			if txBz, err = hex.DecodeString(line); err != nil {
-				return err
-			} else if tx, err = NewTransferTxFromBytes(txBz); err != nil {
-				return err
-			}
-			if i < 5 {
-				fmt.Println(tx)
-			}
-			if utils.StrIn(importor.myChain, tx.Shards) {
-				if len(tx.Shards) == 1 {
-					if err := importor.mempool.AddTx(txBz); err != nil {
-						fmt.Printf("Error adding transaction: %v\n", err)
-						return err
-					}
-				}
-				if len(tx.Shards) > 1 {
-					if err := importor.cross_shard_mempool.AddTx(txBz); err != nil {
-						fmt.Printf("Error adding cross-shard transaction: %v\n", err)
-						return err
-					}
-				}
-			} else {
-				useless++
-			}
+	}
+	buffer := make(chan datasetTx, datasetBufferSize)
+	ready := make(chan struct{})
+	go importor.read(reader, buffer)
+	go importor.feed(buffer, ready)
+	<-ready
+	importerLog.Info("mempools filled, the rest of the dataset is streamed")
+	return nil
+}
+
+func (importor *Importor) Stop() {
+	close(importor.quit)
+}
 
-			if (i+1)%log_intervel == 0 {
-				fmt.Println("[%v] finished: %d", i+1)
+// read decodes the txs of the dataset that touch this shard into buffer.
+func (importor *Importor) read(reader DatasetReader, buffer chan<- datasetTx) {
+	defer close(buffer)
+	defer reader.Close()
+	useless := 0
+	for i := 0; ; i++ {
+		txBz, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			importerLog.Error("reading dataset failed", "dataset", importor.rootDir, "err", err)
+			return
+		}
+		tx, err := NewTransferTxFromBytes(txBz)
+		if err != nil {
+			importerLog.Error("decoding tx failed", "index", i, "err", err)
+			return
+		}
+		if i < 5 {
+			importerLog.Debug("dataset tx", "index", i, "tx", tx)
+		}
+		if !utils.StrIn(importor.myChain, tx.Shards) {
+			useless++
+			continue
+		}
+		select {
+		case buffer <- datasetTx{bz: txBz, crossShard: len(tx.Shards) > 1}:
+		case <-importor.quit:
+			return
+		}
+		if (i+1)%100000 == 0 {
+			importerLog.Info("reading dataset", "read", i+1, "useless", useless)
+		}
+	}
+	importerLog.Info("dataset read", "useless", useless)
+}
+
+// feed adds the buffered txs to the mempools, waiting while the target
+// mempool holds MaxPending txs. ready is closed the first time it waits.
+func (importor *Importor) feed(buffer <-chan datasetTx, ready chan struct{}) {
+	isReady := false
+	setReady := func() {
+		if !isReady {
+			isReady = true
+			close(ready)
+		}
+	}
+	defer setReady()
+	added := 0
+	for tx := range buffer {
+		mempool := importor.mempool
+		if tx.crossShard {
+			mempool = importor.cross_shard_mempool
+		}
+		for mempool.Size() >= importor.MaxPending {
+			setReady()
+			select {
+			case <-time.After(feedInterval):
+			case <-importor.quit:
+				return
 			}
 		}
-		fmt.Println("[%v] finished: %d, useless: %d", time.Now(), len(lines)-useless, useless)
+		if err := mempool.AddTx(tx.bz); err != nil {
+			importerLog.Warn("adding tx failed", "cross_shard", tx.crossShard, "err", err)
+			continue
+		}
+		added++
 	}
-	return nil
+	importerLog.Info("dataset fed", "added", added)
 }
-func generateSingleShardAccounts(prefixes []string, n int, k int, r *rand.Rand) []string {
-	prefix := prefixes[r.Intn(len(prefixes))]
-	return generateAccounts([]string{prefix}, n, k, r)
+
+// GenerateDataset writes the txs of the workload to a dataset file.
+func (im *Importor) GenerateDataset(path string, format string) error {
+	importerLog.Debug("generating txs", "prefixes", im.all_prefixes)
+	w, err := CreateDataset(path, format)
+	if err != nil {
+		return err
+	}
+	num := len(im.rangeLists) * im.workload.TxsPerShard
+	for i := 0; i < num; i++ {
+		if err := w.Write(TransferBytes(im.generateTx(im.all_prefixes))); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	return w.Close()
 }
-func generateAccounts(prefixes []string, n int, k int, r *rand.Rand) []string {
+
+func accountKey(prefix string, num int) string {
+	numStr := strconv.Itoa(num)
+	return prefix + strings.Repeat("0", 32-len(prefix)-len(numStr)) + numStr
+}
+
+// generateAccounts draws k distinct accounts, each from a random shard of
+// prefixes unless first is set, which then holds the first account.
+func generateAccounts(prefixes []string, first string, num func() int, k int, r *rand.Rand) []string {
 	results := make([]string, k)
 	generated := make(map[string]bool)
 
 	for i := 0; i < k; i++ {
-		prefix := prefixes[r.Intn(len(prefixes))]
-		num := r.Intn(n) + 1
-		numStr := strconv.Itoa(num)
-		numStr = strings.Repeat("0", 32-len(prefix)-len(numStr)) + numStr
-		result := prefix + numStr
-
-		for generated[result] {
+		prefix := first
+		if i > 0 || prefix == "" {
 			prefix = prefixes[r.Intn(len(prefixes))]
-			num = r.Intn(n) + 1
-			numStr = strconv.Itoa(num)
-			numStr = strings.Repeat("0", 32-len(prefix)-len(numStr)) + numStr
-			result = prefix + numStr
+		}
+		result := accountKey(prefix, num())
+		for generated[result] {
+			result = accountKey(prefix, num())
 		}
 
 		results[i] = result
@@ -160,16 +264,101 @@ func generateAccounts(prefixes []string, n int, k int, r *rand.Rand) []string {
 	return results
 }
 
+// generateSpreadAccounts draws k accounts from exactly `shards` shards, one
+// of them being first if it is set.
+func generateSpreadAccounts(prefixes []string, first string, shards int, num func() int, k int, r *rand.Rand) []string {
+	if shards > len(prefixes) {
+		shards = len(prefixes)
+	}
+	chosen := make([]string, 0, shards)
+	if first != "" {
+		chosen = append(chosen, first)
+	}
+	for _, j := range r.Perm(len(prefixes)) {
+		if len(chosen) == shards {
+			break
+		}
+		if prefixes[j] != first {
+			chosen = append(chosen, prefixes[j])
+		}
+	}
+	results := make([]string, 0, k)
+	generated := make(map[string]bool)
+	for i := 0; i < k; i++ {
+		// every chosen shard gets one account before any gets a second
+		prefix := chosen[i%shards]
+		if i >= shards {
+			prefix = chosen[r.Intn(shards)]
+		}
+		account := accountKey(prefix, num())
+		for generated[account] {
+			account = accountKey(prefix, num())
+		}
+		results = append(results, account)
+		generated[account] = true
+	}
+	return results
+}
+
+// accountNumber draws the number of an account within its shard.
+func (i *Importor) accountNumber() int {
+	if i.zipf != nil {
+		return i.zipf.next(i.rander) + 1
+	}
+	return i.rander.Intn(i.workload.AccountsPerShard) + 1
+}
+
+func (i *Importor) hotAccountNumber() int {
+	return i.rander.Intn(i.workload.HotKeys) + 1
+}
+
+// hotPrefix returns the prefix of the shard the hotspot is on for the next
+// tx, it moves to the next shard every HotspotShift txs.
+func (i *Importor) hotPrefix(prefixes []string) string {
+	shift := 0
+	if i.workload.HotspotShift > 0 {
+		shift = i.generated / i.workload.HotspotShift
+	}
+	return prefixes[(i.hotShard+shift)%len(prefixes)]
+}
+
 func (i *Importor) generateRandomTx(prefixes []string) string {
-	gun := rand.Float64()
+	return hex.EncodeToString(TransferBytes(i.generateTx(prefixes)))
+}
+
+// NextTx generates the next tx of the workload, for clients that submit txs
+// as they go instead of reading a dataset.
+func (im *Importor) NextTx() *bank.TransferTx {
+	return im.generateTx(im.all_prefixes)
+}
+
+func (i *Importor) generateTx(prefixes []string) *bank.TransferTx {
+	w := i.workload
+	hot := i.hotPrefix(prefixes)
+	i.generated++
+
+	num := i.accountNumber
+	if w.HotKeyFraction > 0 && i.rander.Float64() < w.HotKeyFraction {
+		num = i.hotAccountNumber
+	}
+	first := ""
+	if w.HotShardBias > 0 && i.rander.Float64() < w.HotShardBias {
+		first = hot
+	}
+	gun := i.rander.Float64()
 	var accounts []string
-	if gun < cross_shard_ratio {
+	if gun < w.CrossShardRatio && w.ShardsPerTx > 0 {
+		accounts = generateSpreadAccounts(prefixes, first, w.ShardsPerTx, num, w.TransferSize, i.rander)
+	} else if gun < w.CrossShardRatio {
 		// generate a cross-shard-tx
-		accounts = generateAccounts(prefixes, accountsPerShard, transferSize, i.rander)
+		accounts = generateAccounts(prefixes, first, num, w.TransferSize, i.rander)
 	} else {
-		accounts = generateSingleShardAccounts(prefixes, accountsPerShard, transferSize, i.rander)
+		if first == "" {
+			first = prefixes[i.rander.Intn(len(prefixes))]
+		}
+		accounts = generateAccounts([]string{first}, "", num, w.TransferSize, i.rander)
 	}
-	mid := transferSize / 2
+	mid := w.TransferSize / 2
 
 	shardsMap := make(map[string]bool)
 	for _, acc := range accounts {
@@ -185,37 +374,25 @@ func (i *Importor) generateRandomTx(prefixes []string) string {
 	}
 	sort.Strings(keys)
 
-	tx := NewTransferTxMustLen(accounts[:mid], amountMoney[:mid], accounts[mid:], amountMoney[mid:], keys, mustLen)
-	bz := TransferBytes(tx)
-	return hex.EncodeToString(bz)
+	if i.keys == nil {
+		return NewTransferTxMustLen(accounts[:mid], i.amountMoney[:mid], accounts[mid:], i.amountMoney[mid:], keys, w.TxSize)
+	}
+	// signatures are of a fixed size, the padding goes around them
+	tx := NewTransferTx(accounts[:mid], i.amountMoney[:mid], accounts[mid:], i.amountMoney[mid:], keys)
+	i.keys.prepare(tx)
+	if delta := w.TxSize - TransferTxSize(tx); delta > 0 {
+		tx.Buffer = GenerateRandomBytes(delta)
+	}
+	i.keys.sign(tx)
+	return tx
 }
 
 func (im *Importor) GenerateTxs() []string {
-	fmt.Println("all prefixes: ", im.all_prefixes)
-	num := len(im.rangeLists) * txsPerShard
+	importerLog.Debug("generating txs", "prefixes", im.all_prefixes)
+	num := len(im.rangeLists) * im.workload.TxsPerShard
 	out := make([]string, num)
 	for i := 0; i < num; i++ {
 		out[i] = im.generateRandomTx(im.all_prefixes)
 	}
 	return out
 }
-
-func readLinesFromFile(filePath string) ([]string, error) {
-	var out []string
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		out = append(out, line)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-	return out, nil
-}
@@ -0,0 +1,128 @@
+package minibank
+
+import (
+	"emulator/utils"
+	"fmt"
+)
+
+// InMemDB keeps the whole bank state in a Go map. It mirrors AppDB exactly
+// and is meant for tests and for runs that do not need durable state.
+type InMemDB struct {
+	data      map[string][]byte
+	specials  map[string][]byte
+	leases    map[string][]byte
+	rangelist *utils.RangeList
+
+	retainData map[string]uint32
+}
+
+var _ DB = (*InMemDB)(nil)
+
+func NewInMemDB(rangeList *utils.RangeList) DB {
+	return &InMemDB{
+		data:       make(map[string][]byte),
+		specials:   make(map[string][]byte),
+		leases:     make(map[string][]byte),
+		rangelist:  rangeList,
+		retainData: make(map[string]uint32),
+	}
+}
+
+func (mdb *InMemDB) Get(key string) (uint32, byte, error) {
+	if mdb.rangelist.Search(key) {
+		if bz, ok := mdb.data[key]; !ok || len(bz) == 0 {
+			if err := mdb.Set(key, initBalance, FreeIdentifier); err != nil {
+				return 0, '0', err
+			}
+			return initBalance, FreeIdentifier, nil
+		} else {
+			return UnmarshalValue(bz)
+		}
+	} else {
+		if v, ok := mdb.retainData[key]; ok {
+			return v, FreeIdentifier, nil
+		} else {
+			return 0, FreeIdentifier, fmt.Errorf("key does not exists")
+		}
+	}
+}
+
+func (mdb *InMemDB) Set(key string, money uint32, locked byte) error {
+	if !mdb.rangelist.Search(key) {
+		return nil
+	}
+	value, err := MarshalValue(money, locked)
+	if err != nil {
+		return err
+	}
+	if isRLock(locked) {
+		value = keepReaders(value, mdb.data[key])
+	}
+	mdb.data[key] = value
+	return nil
+}
+
+func (mdb *InMemDB) LoadData(key string, value uint32) {
+	mdb.retainData[key] = value
+}
+func (mdb *InMemDB) Clear() {
+	mdb.retainData = make(map[string]uint32)
+}
+func (mdb *InMemDB) Flush() error { return nil }
+func (mdb *InMemDB) Discard()     { mdb.Clear() }
+
+func (mdb *InMemDB) Owner(key string) (*Owner, error) {
+	if bz, ok := mdb.data[ownerKey(key)]; !ok {
+		return nil, nil
+	} else {
+		return UnmarshalOwner(bz)
+	}
+}
+func (mdb *InMemDB) SetOwner(key string, owner *Owner) error {
+	mdb.data[ownerKey(key)] = MarshalOwner(owner)
+	return nil
+}
+
+func (mdb *InMemDB) Special(hash []byte) ([]byte, error) {
+	return mdb.specials[string(hash)], nil
+}
+func (mdb *InMemDB) SetSpecial(hash, value []byte) error {
+	mdb.specials[string(hash)] = value
+	return nil
+}
+
+func (mdb *InMemDB) SetLease(view int64, hash, keys []byte) error {
+	mdb.leases[string(toLeaseKey(view, hash))] = append([]byte{}, keys...)
+	return nil
+}
+func (mdb *InMemDB) DeleteLease(view int64, hash []byte) error {
+	delete(mdb.leases, string(toLeaseKey(view, hash)))
+	return nil
+}
+func (mdb *InMemDB) Leases(before int64, f func(view int64, hash, keys []byte) error) error {
+	return leasesBefore(nil, mdb.leases, before, f)
+}
+
+func (mdb *InMemDB) RLock(key string) error {
+	return mdb.setLock(key, SetValueRLock)
+}
+func (mdb *InMemDB) RUnlock(key string) error {
+	return mdb.setLock(key, SetValueRUnlock)
+}
+func (mdb *InMemDB) WLock(key string) error {
+	return mdb.setLock(key, SetValueWLock)
+}
+func (mdb *InMemDB) WUnlock(key string) error {
+	return mdb.setLock(key, SetValueUnlock)
+}
+
+func (mdb *InMemDB) setLock(key string, setter func([]byte) []byte) error {
+	if bz, ok := mdb.data[key]; !ok || len(bz) == 0 {
+		return fmt.Errorf("key does not exist")
+	} else if out := setter(append([]byte(nil), bz...)); out == nil {
+		return fmt.Errorf("Unknown error")
+	} else {
+		mdb.data[key] = out
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package minibank
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	bank "emulator/proto/urd/abci/minibank"
+	"emulator/utils"
+	"emulator/utils/metrics"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// A transfer is signed by every From account: it carries the ed25519 public
+// key, the nonce and the signature of each, in the order of From. The
+// signatures are over the tx encoded without them. Every account is bound to
+// its key by the genesis, and only txs signed by that key spend from it.
+//
+// The nonces of an account are a sequence from 1. Intra-shard and cross-shard
+// txs are applied at different stages of the pipeline, and a tx refused for a
+// lock conflict does not use its nonce, so an account accepts any nonce above
+// its last one, and one of the ReplayWindow nonces below that it has not
+// seen: no tx is applied twice, and txs that overtake each other still are.
+
+// DefaultKeySeed is the key seed of generated workloads and clients.
+const DefaultKeySeed = "minibank"
+
+var (
+	errBadSignature = metrics.Abort(metrics.AbortBadSignature, errors.New("bad signature"))
+	errBadNonce     = metrics.Abort(metrics.AbortBadNonce, errors.New("bad nonce"))
+)
+
+var signedTxs bool
+
+// RequireSignatures makes only signed transfers valid, from the initial
+// state of the genesis. It must be called before the application is used.
+func RequireSignatures(required bool) { signedTxs = required }
+
+// SignaturesRequired tells whether transfers must be signed.
+func SignaturesRequired() bool { return signedTxs }
+
+// ReplayWindow is how far below the highest nonce of an account a tx that
+// was overtaken is still applied.
+const ReplayWindow = 64
+
+// Owner is the key an account is bound to, the highest nonce it used, and
+// the nonces of the window below it it used: bit i is Nonce-i.
+type Owner struct {
+	PubKey ed25519.PublicKey
+	Nonce  uint64
+	Seen   uint64
+}
+
+// NewOwner binds an account to key, no nonce used.
+func NewOwner(key ed25519.PublicKey) *Owner { return &Owner{PubKey: key, Seen: 1} }
+
+// use returns the owner after a tx with nonce, which must be new.
+func (o *Owner) use(nonce uint64) (*Owner, error) {
+	next := &Owner{PubKey: o.PubKey}
+	switch {
+	case nonce > o.Nonce:
+		next.Nonce, next.Seen = nonce, 1
+		if shift := nonce - o.Nonce; shift < ReplayWindow {
+			next.Seen |= o.Seen << shift
+		}
+	case o.Nonce-nonce >= ReplayWindow:
+		return nil, fmt.Errorf("%w: nonce %d is %d or more below the last nonce %d", errBadNonce, nonce, ReplayWindow, o.Nonce)
+	case o.Seen&(1<<(o.Nonce-nonce)) != 0:
+		return nil, fmt.Errorf("%w: nonce %d was used", errBadNonce, nonce)
+	default:
+		next.Nonce, next.Seen = o.Nonce, o.Seen|1<<(o.Nonce-nonce)
+	}
+	return next, nil
+}
+
+func ownerKey(account string) string { return "owner/" + account }
+
+func MarshalOwner(owner *Owner) []byte {
+	bz := binary.BigEndian.AppendUint64(append([]byte(nil), owner.PubKey...), owner.Nonce)
+	return binary.BigEndian.AppendUint64(bz, owner.Seen)
+}
+func UnmarshalOwner(bz []byte) (*Owner, error) {
+	if len(bz) != ed25519.PublicKeySize+16 {
+		return nil, fmt.Errorf("owner of %d bytes", len(bz))
+	}
+	return &Owner{
+		PubKey: bz[:ed25519.PublicKeySize],
+		Nonce:  binary.BigEndian.Uint64(bz[ed25519.PublicKeySize:]),
+		Seen:   binary.BigEndian.Uint64(bz[ed25519.PublicKeySize+8:]),
+	}, nil
+}
+
+// SignBytes is what the From accounts of tx sign.
+func SignBytes(tx *bank.TransferTx) []byte {
+	signatures := tx.Signatures
+	tx.Signatures = nil
+	defer func() { tx.Signatures = signatures }()
+	bz, err := proto.MarshalOptions{Deterministic: true}.Marshal(tx)
+	if err != nil {
+		panic(err)
+	}
+	return bz
+}
+
+// VerifyTransferTx checks that every From account signed tx.
+func VerifyTransferTx(tx *bank.TransferTx) error {
+	if len(tx.PubKeys) != len(tx.From) || len(tx.Nonces) != len(tx.From) || len(tx.Signatures) != len(tx.From) {
+		return fmt.Errorf("%w: tx lacks the signatures of its From accounts", errBadSignature)
+	}
+	msg := SignBytes(tx)
+	for i, from := range tx.From {
+		if len(tx.PubKeys[i]) != ed25519.PublicKeySize || !ed25519.Verify(tx.PubKeys[i], msg, tx.Signatures[i]) {
+			return fmt.Errorf("%w of account %s", errBadSignature, from)
+		}
+	}
+	return nil
+}
+
+// checkOwners checks the keys and nonces of the From accounts of tx that
+// pass mine against owner, and returns their owners after tx.
+func checkOwners(tx *bank.TransferTx, mine func(string) bool, owner func(string) (*Owner, error)) (map[string]*Owner, error) {
+	owners := make(map[string]*Owner, len(tx.From))
+	for i, from := range tx.From {
+		if !mine(from) {
+			continue
+		}
+		o, err := owner(from)
+		if err != nil {
+			return nil, err
+		} else if o == nil {
+			return nil, fmt.Errorf("%w: account %s is bound to no key", errBadSignature, from)
+		} else if !bytes.Equal(o.PubKey, tx.PubKeys[i]) {
+			return nil, fmt.Errorf("%w: signature of account %s is not by the key bound to it", errBadSignature, from)
+		}
+		if owners[from], err = o.use(tx.Nonces[i]); err != nil {
+			return nil, fmt.Errorf("account %s: %w", from, err)
+		}
+	}
+	return owners, nil
+}
+
+// Keys are the keys of the accounts, derived from a seed so that the
+// genesis, the dataset generator and the clients agree on them, and the
+// nonces each account signed last.
+type Keys struct {
+	seed string
+
+	mtx    sync.Mutex
+	keys   map[string]ed25519.PrivateKey
+	nonces map[string]uint64
+	first  uint64
+}
+
+func NewKeys(seed string) *Keys {
+	return &Keys{seed: seed, keys: map[string]ed25519.PrivateKey{}, nonces: map[string]uint64{}}
+}
+
+// StartAt makes the first nonce of every account nonce+1, for a signer
+// that follows another one, such as a client after the dataset.
+func (k *Keys) StartAt(nonce uint64) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	k.first = nonce
+}
+
+// PubKey is the public key of account.
+func (k *Keys) PubKey(account string) ed25519.PublicKey {
+	return k.Key(account).Public().(ed25519.PublicKey)
+}
+
+// Key is the private key of account.
+func (k *Keys) Key(account string) ed25519.PrivateKey {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	return k.key(account)
+}
+
+func (k *Keys) key(account string) ed25519.PrivateKey {
+	key, ok := k.keys[account]
+	if !ok {
+		seed := sha256.Sum256([]byte(k.seed + "/" + account))
+		key = ed25519.NewKeyFromSeed(seed[:])
+		k.keys[account] = key
+	}
+	return key
+}
+
+// Sign signs tx for its From accounts with their next nonces.
+func (k *Keys) Sign(tx *bank.TransferTx) {
+	k.prepare(tx)
+	k.sign(tx)
+}
+
+// prepare sets the keys and the next nonces of the From accounts of tx, and
+// blank signatures of their final size.
+func (k *Keys) prepare(tx *bank.TransferTx) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	tx.PubKeys, tx.Nonces, tx.Signatures = make([][]byte, len(tx.From)), make([]uint64, len(tx.From)), make([][]byte, len(tx.From))
+	for i, from := range tx.From {
+		nonce, ok := k.nonces[from]
+		if !ok {
+			nonce = k.first
+		}
+		k.nonces[from] = nonce + 1
+		tx.PubKeys[i], tx.Nonces[i] = k.key(from).Public().(ed25519.PublicKey), nonce+1
+		tx.Signatures[i] = make([]byte, ed25519.SignatureSize)
+	}
+}
+
+// sign fills in the signatures of a prepared tx.
+func (k *Keys) sign(tx *bank.TransferTx) {
+	msg := SignBytes(tx)
+	for i, from := range tx.From {
+		tx.Signatures[i] = ed25519.Sign(k.Key(from), msg)
+	}
+}
+
+// OwnersFileName is the file next to the genesis binding the accounts of a
+// shard to their keys. The genesis holds its hash.
+const OwnersFileName = "owners.bin"
+
+// OwnersFile binds the accounts of the shard of rl that a workload of
+// accounts per shard draws from to their keys, and returns the owners file
+// and its hash.
+func OwnersFile(rl *utils.RangeList, accounts int, keys *Keys) ([]byte, string) {
+	var bz []byte
+	for num := 1; num <= accounts; num++ {
+		account := accountKey(rl.StartKey(), num)
+		bz = binary.AppendUvarint(bz, uint64(len(account)))
+		bz = append(append(bz, account...), keys.PubKey(account)...)
+	}
+	hash := sha256.Sum256(bz)
+	return bz, hex.EncodeToString(hash[:])
+}
+
+// readOwners calls f with the accounts and keys of an owners file of hash.
+func readOwners(path, hash string, f func(account string, key ed25519.PublicKey)) error {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(bz); hex.EncodeToString(sum[:]) != hash {
+		return fmt.Errorf("%s does not have the hash %s of the genesis", path, hash)
+	}
+	for len(bz) > 0 {
+		n, l := binary.Uvarint(bz)
+		if l <= 0 || uint64(len(bz)-l) < n+ed25519.PublicKeySize {
+			return fmt.Errorf("%s is truncated", path)
+		}
+		bz = bz[l:]
+		f(string(bz[:n]), ed25519.PublicKey(bz[n:n+ed25519.PublicKeySize]))
+		bz = bz[n+ed25519.PublicKeySize:]
+	}
+	return nil
+}
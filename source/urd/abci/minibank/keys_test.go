@@ -0,0 +1,114 @@
+package minibank
+
+import (
+	"bytes"
+	bank "emulator/proto/urd/abci/minibank"
+	"emulator/utils/metrics"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignedWorkload(t *testing.T) {
+	w := testWorkload()
+	im := NewImportorForGenerator(testRangeLists(), w)
+	for i := 0; i < 20; i++ {
+		tx := im.NextTx()
+		if err := VerifyTransferTx(tx); err != nil {
+			t.Fatal(err)
+		}
+		// as unsigned txs, the padding is over by the bytes of its field
+		if size := TransferTxSize(tx); size < w.TxSize || size > w.TxSize+4 {
+			t.Fatalf("signed tx of %d bytes, want %d", size, w.TxSize)
+		}
+		tx.ToMoney[0]++
+		if err := VerifyTransferTx(tx); err == nil {
+			t.Fatal("changed tx still verifies")
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	RequireSignatures(true)
+	defer RequireSignatures(false)
+	app := &Application{KeyRangeTrees: testRangeLists(), chain_id: "i1"}
+	db := NewInMemDB(app.KeyRangeTrees["i1"])
+	keys := NewKeys(DefaultKeySeed)
+	newTx := func() *bank.TransferTx {
+		return NewTransferTx([]string{"10a"}, []uint32{3}, []string{"10b"}, []uint32{3}, []string{"i1"})
+	}
+	abort := func(tx *bank.TransferTx, reason string) {
+		t.Helper()
+		if err := app.doTransfer(tx, db); metrics.AbortReason(err) != reason {
+			t.Fatalf("got %v, want %s", err, reason)
+		}
+	}
+
+	// no key of its own claims an account that is bound to none
+	unbound := newTx()
+	keys.Sign(unbound)
+	abort(unbound, "bad_signature")
+	if err := db.SetOwner("10a", NewOwner(keys.PubKey("10a"))); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := newTx(), newTx()
+	keys.Sign(first)
+	keys.Sign(second)
+	abort(newTx(), "bad_signature")
+	if err := app.doTransfer(second, db); err != nil {
+		t.Fatal(err)
+	}
+	// overtaken within the window, but not replayed
+	if err := app.doTransfer(first, db); err != nil {
+		t.Fatal(err)
+	}
+	abort(second, "bad_nonce")
+	abort(first, "bad_nonce")
+
+	// only the key the account is bound to signs for it
+	other := newTx()
+	NewKeys("other").Sign(other)
+	abort(other, "bad_signature")
+
+	// too old once ReplayWindow nonces were used after it
+	old := newTx()
+	keys.Sign(old)
+	for i := 0; i < ReplayWindow; i++ {
+		keys.Sign(newTx())
+	}
+	last := newTx()
+	keys.Sign(last)
+	if err := app.doTransfer(last, db); err != nil {
+		t.Fatal(err)
+	}
+	abort(old, "bad_nonce")
+
+	owner, err := db.Owner("10a")
+	if err != nil || owner == nil || owner.Nonce != last.Nonces[0] {
+		t.Fatalf("unexpected owner %+v, %v", owner, err)
+	}
+	if balance, _, _ := db.Get("10a"); balance != initBalance-9 {
+		t.Fatalf("balance %d after three transfers", balance)
+	}
+}
+
+func TestOwnersFile(t *testing.T) {
+	app := newTestApplication("i1")
+	keys := NewKeys(DefaultKeySeed)
+	bz, hash := OwnersFile(app.KeyRangeTrees["i1"], 3, keys)
+	path := filepath.Join(t.TempDir(), OwnersFileName)
+	if err := os.WriteFile(path, bz, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.BindOwners(path, "00"); err == nil {
+		t.Fatal("bound the accounts of an owners file of another hash")
+	}
+	if err := app.BindOwners(path, hash); err != nil {
+		t.Fatal(err)
+	}
+	account := accountKey(app.KeyRangeTrees["i1"].StartKey(), 3)
+	if owner, err := app.committedOwner(account); err != nil || owner == nil || !bytes.Equal(owner.PubKey, keys.PubKey(account)) {
+		t.Fatalf("account %s is bound to %+v, %v", account, owner, err)
+	}
+}
@@ -0,0 +1,104 @@
+package minibank
+
+import (
+	"emulator/logger/blocklogger"
+	"emulator/urd/types"
+	"emulator/utils"
+	"emulator/utils/store"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MeasureLatency goes through the blocks a node committed while it had txs to
+// process, as recorded by its blocklogger, and sums how long their txs took
+// from submission to the commit of the block that executed them, the one that
+// decided them for cross-shard txs. Aborted txs are counted apart, from the
+// receipts kept with the blocks. The node must be stopped.
+func MeasureLatency(rootPath string, chain_id string) (*utils.Latency, error) {
+	nodeName := filepath.Base(rootPath)
+	briefPath := filepath.Join(rootPath, fmt.Sprintf("%s-blocklogger-brief.txt", nodeName))
+	if _, err := os.Stat(briefPath); err != nil {
+		return nil, err
+	}
+	reader, err := blocklogger.NewReader(briefPath)
+	if err != nil {
+		return nil, err
+	}
+	blockRangeA, blockRangeB, err := reader.NoneZeroPeriods()
+	if err != nil {
+		return nil, err
+	}
+
+	db := store.NewPrefixStore("consensus", filepath.Join(rootPath, "database"))
+	defer db.Close()
+
+	latency := new(utils.Latency)
+	// the submission time of the cross-shard txs locked so far, by hash
+	submitted := map[string]time.Time{}
+	for i := 0; i < len(blockRangeA); i++ {
+		start, end := blockRangeA[i], blockRangeB[i]
+		for j := start; j <= end; j++ {
+			var block, blockNext *types.Block
+			if bz, err := db.GetBlockByHeight(int64(j), chain_id); err != nil || len(bz) == 0 {
+				continue
+			} else if block = types.NewBlockFromBytes(bz); block == nil {
+				continue
+			}
+			if bz, err := db.GetBlockByHeight(int64(j+1), chain_id); err != nil || len(bz) == 0 {
+				continue
+			} else if blockNext = types.NewBlockFromBytes(bz); blockNext == nil {
+				continue
+			}
+			commitTime := blockNext.Time
+			// without receipts, as kept by older versions, every tx counts
+			// as committed with its block
+			var resp *types.ABCIExecutionResponse
+			if bz, err := db.GetReceiptsByHeight(int64(j), chain_id); err == nil && len(bz) > 0 {
+				resp, _ = types.NewABCIExecutionResponseFromBytes(bz)
+			}
+
+			for k, txBytes := range block.PTXS {
+				tx, err := NewTransferTxFromBytes(txBytes)
+				if err != nil {
+					continue
+				}
+				if resp != nil && k < len(resp.Responses) && !resp.Responses[k].IsOK() {
+					latency.IntraShardAborted++
+					continue
+				}
+				latency.IntraShardTotal += commitTime.Sub(utils.ThirdPartyUnmarshalTime(tx.Time))
+				latency.IntraShardTxs++
+			}
+			for _, txBytes := range block.CrossShardTxs {
+				tx, err := NewTransferTxFromBytes(txBytes)
+				if err != nil {
+					continue
+				}
+				if resp != nil {
+					submitted[string(types.TxHash(txBytes))] = utils.ThirdPartyUnmarshalTime(tx.Time)
+					continue
+				}
+				latency.CrossShardTotal += commitTime.Sub(utils.ThirdPartyUnmarshalTime(tx.Time))
+				latency.CrossShardTxs++
+			}
+			if resp == nil {
+				continue
+			}
+			for _, receipt := range resp.CrossShardResponses {
+				switch receipt.Status() {
+				case types.TxAborted:
+					latency.CrossShardAborted++
+				case types.TxCommitted:
+					// unless it was locked before the measured blocks
+					if submit, ok := submitted[string(receipt.TxHash())]; ok {
+						latency.CrossShardTotal += commitTime.Sub(submit)
+						latency.CrossShardTxs++
+					}
+				}
+			}
+		}
+	}
+	return latency, nil
+}
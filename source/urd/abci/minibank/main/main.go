@@ -1,17 +1,11 @@
 package main
 
 import (
-	"emulator/logger/blocklogger"
-	"emulator/utils"
-	"emulator/utils/store"
 	"fmt"
 	"os"
-	"path"
 	"path/filepath"
-	"time"
 
 	"emulator/urd/abci/minibank"
-	"emulator/urd/types"
 )
 
 func getLastFolderName(path string) string {
@@ -20,71 +14,23 @@ func getLastFolderName(path string) string {
 func main() {
 	// ./ours-latency ./mytestnet/127.0.0.1/node1  b1
 	rootPath := os.Args[1]
-	storePath := path.Join(rootPath, "database")
-	nodeName := getLastFolderName(rootPath)
-	blockloggerDir := path.Join(rootPath, fmt.Sprintf("%s-blocklogger-brief.txt", nodeName))
+	chain_id := os.Args[2]
 
-	reader := blocklogger.NewReader(blockloggerDir)
-	blockRangeA, blockRangeB, err := reader.NoneZeroPeriods()
+	latency, err := minibank.MeasureLatency(rootPath, chain_id)
 	if err != nil {
 		panic(err)
 	}
 
-	db := store.NewPrefixStore("consensus", storePath)
-	defer db.Close()
-
-	chain_id := os.Args[2]
-
-	innerShardTxCount := 0
-	crossShardTxCount := 0
-	innerShardLatencyCount := time.Duration(0)
-	crossShardLatencyCount := time.Duration(0)
-
-	for i := 0; i < len(blockRangeA); i++ {
-		start, end := blockRangeA[i], blockRangeB[i]
-		for j := start; j <= end; j++ {
-			var block, blockNext *types.Block
-			if bz, err := db.GetBlockByHeight(int64(j), chain_id); err != nil {
-				continue
-			} else if block = types.NewBlockFromBytes(bz); block == nil {
-				continue
-			}
-			if bz, err := db.GetBlockByHeight(int64(j+1), chain_id); err != nil || bz == nil {
-				continue
-			} else if blockNext = types.NewBlockFromBytes(bz); blockNext == nil {
-				continue
-			}
-			commitTime := blockNext.Time
-
-			for _, txBytes := range block.PTXS {
-				tx, err := minibank.NewTransferTxFromBytes(txBytes)
-				if err != nil {
-					continue
-				}
-				innerShardLatencyCount += commitTime.Sub(utils.ThirdPartyUnmarshalTime(tx.Time))
-				innerShardTxCount++
-			}
-			for _, txBytes := range block.CrossShardTxs {
-				tx, err := minibank.NewTransferTxFromBytes(txBytes)
-				if err != nil {
-					continue
-				}
-				crossShardLatencyCount += commitTime.Sub(utils.ThirdPartyUnmarshalTime(tx.Time))
-				crossShardTxCount++
-			}
-		}
+	if latency.IntraShardTxs > 0 {
+		fmt.Printf("片内事务：%d  平均延迟：%.3f 秒\n", latency.IntraShardTxs, latency.IntraShard().Seconds())
 	}
-
-	if innerShardTxCount > 0 {
-		fmt.Printf("片内事务：%d  平均延迟：%.3f 秒\n", innerShardTxCount, innerShardLatencyCount.Seconds()/float64(innerShardTxCount))
+	if latency.CrossShardTxs > 0 {
+		fmt.Printf("跨分片事务：%d  平均延迟：%.3f 秒\n", latency.CrossShardTxs, latency.CrossShard().Seconds())
 	}
-	if crossShardTxCount > 0 {
-		fmt.Printf("跨分片事务：%d  平均延迟：%.3f 秒\n", crossShardTxCount, crossShardLatencyCount.Seconds()/float64(crossShardTxCount))
+	if aborted := latency.IntraShardAborted + latency.CrossShardAborted; aborted > 0 {
+		fmt.Printf("中止事务：%d  片内：%d  跨分片：%d\n", aborted, latency.IntraShardAborted, latency.CrossShardAborted)
 	}
-	if innerShardTxCount+crossShardTxCount > 0 {
-		fmt.Printf("总事务：%d    平均延迟：%.3f 秒\n",
-			crossShardTxCount+innerShardTxCount,
-			(crossShardLatencyCount.Seconds()+innerShardLatencyCount.Seconds())/float64(innerShardTxCount+crossShardTxCount),
-		)
+	if latency.Txs() > 0 {
+		fmt.Printf("总事务：%d    平均延迟：%.3f 秒\n", latency.Txs(), latency.Average().Seconds())
 	}
 }
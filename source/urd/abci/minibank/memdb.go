@@ -4,6 +4,7 @@ import (
 	"emulator/utils"
 	"emulator/utils/store"
 	"fmt"
+	"sort"
 )
 
 type DB interface {
@@ -13,8 +14,36 @@ type DB interface {
 	LoadData(string, uint32)
 	Clear()
 
+	// A key is read locked by any number of txs at once, write locked by
+	// one. Set keeps the readers of a key it leaves read locked.
+	RLock(string) error
+	RUnlock(string) error
 	WLock(string) error
 	WUnlock(string) error
+
+	// Owner is the owner of an account of the shard, nil if it never signed
+	// a tx.
+	Owner(string) (*Owner, error)
+	SetOwner(string, *Owner) error
+
+	// Special is the relay set of a cross-shard tx by its hash, with the
+	// decision once taken, empty if there is none.
+	Special([]byte) ([]byte, error)
+	SetSpecial([]byte, []byte) error
+
+	// A lease is the keys a cross-shard tx locked, by the view they were
+	// locked in and the hash of the tx. Leases calls f with the leases taken
+	// before view, oldest first.
+	SetLease(view int64, hash, keys []byte) error
+	DeleteLease(view int64, hash []byte) error
+	Leases(before int64, f func(view int64, hash, keys []byte) error) error
+
+	// Flush persists every write buffered since the last call. It is called
+	// once per block; backends that write through immediately return nil.
+	Flush() error
+	// Discard drops every write buffered since the last Flush, and the data
+	// loaded. Backends that write through only drop the data loaded.
+	Discard()
 }
 
 func isRLock(locked byte) bool { return locked == RLockedIdentifier }
@@ -65,6 +94,13 @@ func (app *AppDB) Set(key string, money uint32, locked byte) error {
 	if err != nil {
 		return err
 	}
+	if isRLock(locked) {
+		old, err := app.db.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		value = keepReaders(value, old)
+	}
 	if err := app.db.Set([]byte(key), value); err != nil {
 		return err
 	}
@@ -78,6 +114,37 @@ func (app *AppDB) Clear() {
 	app.retainData = make(map[string]uint32)
 }
 
+func (app *AppDB) Flush() error { return nil }
+func (app *AppDB) Discard()     { app.Clear() }
+
+func (app *AppDB) Special(hash []byte) ([]byte, error) {
+	return app.db.GetSpecial(hash)
+}
+func (app *AppDB) SetSpecial(hash, value []byte) error {
+	return app.db.SetSpecial(hash, value)
+}
+
+func (app *AppDB) SetLease(view int64, hash, keys []byte) error {
+	return app.db.Set(toLeaseKey(view, hash), keys)
+}
+func (app *AppDB) DeleteLease(view int64, hash []byte) error {
+	return app.db.Delete(toLeaseKey(view, hash))
+}
+func (app *AppDB) Leases(before int64, f func(view int64, hash, keys []byte) error) error {
+	return leasesBefore(app.db, nil, before, f)
+}
+
+func (app *AppDB) Owner(key string) (*Owner, error) {
+	if bz, err := app.db.Get([]byte(ownerKey(key))); err != nil || len(bz) == 0 {
+		return nil, err
+	} else {
+		return UnmarshalOwner(bz)
+	}
+}
+func (app *AppDB) SetOwner(key string, owner *Owner) error {
+	return app.db.Set([]byte(ownerKey(key)), MarshalOwner(owner))
+}
+
 func (app *AppDB) RLock(key string) error {
 	if bz, err := app.db.Get([]byte(key)); err != nil || len(bz) == 0 {
 		return fmt.Errorf("key does not exist")
@@ -88,6 +155,16 @@ func (app *AppDB) RLock(key string) error {
 	}
 	return nil
 }
+func (app *AppDB) RUnlock(key string) error {
+	if bz, err := app.db.Get([]byte(key)); err != nil || len(bz) == 0 {
+		return fmt.Errorf("key does not exist")
+	} else if out := SetValueRUnlock(bz); out == nil {
+		return fmt.Errorf("Unknown error")
+	} else if err := app.db.Set([]byte(key), out); err != nil {
+		return err
+	}
+	return nil
+}
 func (app *AppDB) WLock(key string) error {
 	if bz, err := app.db.Get([]byte(key)); err != nil || len(bz) == 0 {
 		return fmt.Errorf("key does not exist")
@@ -108,3 +185,46 @@ func (app *AppDB) WUnlock(key string) error {
 	}
 	return nil
 }
+
+// leasesBefore calls f with the leases of db, if any, taken before view, oldest
+// first. pending overrides db, a nil lease there was released.
+func leasesBefore(db *store.PrefixStore, pending map[string][]byte, before int64, f func(view int64, hash, keys []byte) error) error {
+	if before <= 0 {
+		return nil
+	}
+	start, end := toLeaseKey(0, nil), toLeaseKey(before, nil)
+	leases := make(map[string][]byte)
+	if db != nil {
+		iter, err := db.Iterator(start, end)
+		if err != nil {
+			return err
+		}
+		for ; iter.Valid(); iter.Next() {
+			leases[string(iter.Key())] = append([]byte{}, iter.Value()...)
+		}
+		err = iter.Error()
+		iter.Close()
+		if err != nil {
+			return err
+		}
+	}
+	for key, keys := range pending {
+		if key >= string(start) && key < string(end) {
+			leases[key] = keys
+		}
+	}
+	order := make([]string, 0, len(leases))
+	for key, keys := range leases {
+		if keys != nil {
+			order = append(order, key)
+		}
+	}
+	sort.Strings(order)
+	for _, key := range order {
+		view, hash := fromLeaseKey([]byte(key))
+		if err := f(view, hash, leases[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package minibank
+
+import (
+	dbm "emulator/libs/db"
+	"emulator/utils"
+	"emulator/utils/store"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+var testKeys = []string{"10a", "10b", "10c", "10d", "10e", "10f", "20a", "20b"}
+
+type dbResult struct {
+	money  uint32
+	locked byte
+	ok     bool
+}
+
+func newTestPrefixStore() *store.PrefixStore {
+	return &store.PrefixStore{Database: dbm.NewMemDB()}
+}
+
+// applyOp runs the same random operation against a DB and reports what the
+// caller could observe.
+func applyOp(db DB, op int, key string, money uint32) dbResult {
+	switch op {
+	case 0, 1:
+		m, l, err := db.Get(key)
+		return dbResult{m, l, err == nil}
+	case 2:
+		return dbResult{ok: db.Set(key, money, FreeIdentifier) == nil}
+	case 3:
+		return dbResult{ok: db.WLock(key) == nil}
+	case 4:
+		return dbResult{ok: db.WUnlock(key) == nil}
+	case 5:
+		return dbResult{ok: db.RLock(key) == nil}
+	case 6:
+		return dbResult{ok: db.RUnlock(key) == nil}
+	case 7:
+		db.LoadData(key, money)
+	case 8:
+		db.Clear()
+	case 9:
+		return dbResult{ok: db.Set(key, money, RLockedIdentifier) == nil}
+	default:
+		return dbResult{ok: db.Flush() == nil}
+	}
+	return dbResult{ok: true}
+}
+
+func TestDBImplementationsAgree(t *testing.T) {
+	rl := utils.NewRangeListFromString("10,11")
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		appStore, cachedStore := newTestPrefixStore(), newTestPrefixStore()
+		dbs := []DB{
+			NewDB(appStore, rl),
+			NewInMemDB(rl),
+			NewCachedDB(cachedStore, rl, 3),
+		}
+		for i := 0; i < 200; i++ {
+			op, key, money := r.Intn(11), testKeys[r.Intn(len(testKeys))], uint32(r.Intn(1000))
+			want := applyOp(dbs[0], op, key, money)
+			for j, db := range dbs[1:] {
+				if got := applyOp(db, op, key, money); got != want {
+					t.Logf("seed %d step %d: db %d returned %+v for op %d on %s, AppDB returned %+v", seed, i, j+1, got, op, key, want)
+					return false
+				}
+			}
+		}
+		if err := dbs[2].Flush(); err != nil {
+			return false
+		}
+		for _, key := range testKeys {
+			want, _ := appStore.Get([]byte(key))
+			if got, _ := cachedStore.Get([]byte(key)); string(got) != string(want) {
+				t.Logf("seed %d: flushed value of %s is %v, AppDB stored %v", seed, key, got, want)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 200}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCachedDBDefersWrites(t *testing.T) {
+	rl := utils.NewRangeListFromString("10,11")
+	s := newTestPrefixStore()
+	db := NewCachedDB(s, rl, 2)
+	if err := db.Set("10a", 7, FreeIdentifier); err != nil {
+		t.Fatal(err)
+	}
+	if bz, _ := s.Get([]byte("10a")); len(bz) != 0 {
+		t.Fatalf("write reached the store before Flush: %v", bz)
+	}
+	// evict 10a from the LRU, the dirty set must still serve it
+	db.Get("10b")
+	db.Get("10c")
+	if money, _, err := db.Get("10a"); err != nil || money != 7 {
+		t.Fatalf("expected 7 from the dirty set, got %d (%v)", money, err)
+	}
+	// the relay sets and leases wait for the same batch
+	if err := s.Set(toLeaseKey(1, []byte("old")), []byte("k")); err != nil {
+		t.Fatal(err)
+	}
+	db.SetSpecial([]byte("tx"), []byte("set"))
+	db.SetLease(2, []byte("tx"), []byte("keys"))
+	db.DeleteLease(1, []byte("old"))
+	if bz, _ := s.GetSpecial([]byte("tx")); len(bz) != 0 {
+		t.Fatal("relay set reached the store before Flush")
+	}
+	if bz, _ := db.Special([]byte("tx")); string(bz) != "set" {
+		t.Fatalf("expected the pending relay set, got %q", bz)
+	}
+	leases := func() (views []int64) {
+		t.Helper()
+		if err := db.Leases(10, func(view int64, hash, keys []byte) error {
+			views = append(views, view)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return views
+	}
+	if views := leases(); len(views) != 1 || views[0] != 2 {
+		t.Fatalf("leases %v before Flush, want the pending one only", views)
+	}
+	if bz, _ := s.Get(toLeaseKey(1, []byte("old"))); len(bz) == 0 {
+		t.Fatal("lease released in the store before Flush")
+	}
+
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if bz, _ := s.Get([]byte("10a")); len(bz) == 0 {
+		t.Fatal("Flush did not persist the dirty set")
+	}
+	if bz, _ := s.GetSpecial([]byte("tx")); string(bz) != "set" {
+		t.Fatal("Flush did not persist the relay set")
+	}
+	if bz, _ := s.Get(toLeaseKey(1, []byte("old"))); len(bz) != 0 {
+		t.Fatal("Flush did not release the lease")
+	}
+	if views := leases(); len(views) != 1 || views[0] != 2 {
+		t.Fatalf("leases %v after Flush, want the one taken at view 2", views)
+	}
+}
+
+func TestCachedDBDiscard(t *testing.T) {
+	rl := utils.NewRangeListFromString("10,11")
+	s := newTestPrefixStore()
+	db := NewCachedDB(s, rl, 2)
+	if err := db.Set("10a", 7, FreeIdentifier); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	db.Set("10a", 9, FreeIdentifier)
+	db.SetSpecial([]byte("tx"), []byte("set"))
+	db.SetLease(2, []byte("tx"), []byte("keys"))
+	db.LoadData("20a", 5)
+	db.Discard()
+
+	if money, _, err := db.Get("10a"); err != nil || money != 7 {
+		t.Fatalf("expected the flushed 7 after Discard, got %d (%v)", money, err)
+	}
+	if bz, _ := db.Special([]byte("tx")); len(bz) != 0 {
+		t.Fatal("relay set kept after Discard")
+	}
+	db.Leases(10, func(view int64, hash, keys []byte) error {
+		t.Fatalf("lease of view %d kept after Discard", view)
+		return nil
+	})
+	if _, _, err := db.Get("20a"); err == nil {
+		t.Fatal("loaded data kept after Discard")
+	}
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if bz, _ := s.GetSpecial([]byte("tx")); len(bz) != 0 {
+		t.Fatal("Flush persisted a discarded write")
+	}
+}
@@ -0,0 +1,43 @@
+package minibank
+
+import (
+	"emulator/urd/types"
+	"sync"
+)
+
+// txIndex keeps the outcome of the latest txs executed at this shard, by
+// hash, for tx_status. The oldest are forgotten first.
+type txIndex struct {
+	mtx     sync.Mutex
+	results map[string]*types.TxResult
+	order   []string
+	next    int
+}
+
+func newTxIndex(capacity int) *txIndex {
+	return &txIndex{
+		results: make(map[string]*types.TxResult, capacity),
+		order:   make([]string, capacity),
+	}
+}
+
+func (idx *txIndex) set(hash []byte, result *types.TxResult) {
+	key := string(hash)
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	if _, ok := idx.results[key]; !ok {
+		if old := idx.order[idx.next]; old != "" {
+			delete(idx.results, old)
+		}
+		idx.order[idx.next] = key
+		idx.next = (idx.next + 1) % len(idx.order)
+	}
+	idx.results[key] = result
+}
+
+func (idx *txIndex) get(hash []byte) (*types.TxResult, bool) {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	result, ok := idx.results[string(hash)]
+	return result, ok
+}
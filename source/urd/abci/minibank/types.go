@@ -7,6 +7,8 @@ import (
 	"emulator/urd/types"
 	"emulator/utils"
 	"errors"
+	"fmt"
+	"math"
 	"time"
 
 	"google.golang.org/protobuf/proto"
@@ -82,17 +84,31 @@ func ValidateTransferTx(tx *bank.TransferTx) error {
 		return errors.New("From/To is empty")
 	}
 
-	fromMoneySum := uint32(0)
-	toMoneySum := uint32(0)
+	// transfer reads every balance before it writes any, an account given
+	// twice would be debited or credited once
+	accounts := make(map[string]bool, len(tx.From)+len(tx.To))
+	for _, account := range append(append([]string(nil), tx.From...), tx.To...) {
+		if accounts[account] {
+			return fmt.Errorf("account %s is given twice", account)
+		}
+		accounts[account] = true
+	}
+
+	// summed wider than the balances, so that a sum cannot wrap around
+	fromMoneySum := uint64(0)
+	toMoneySum := uint64(0)
 
 	for _, money := range tx.FromMoney {
-		fromMoneySum += money
+		fromMoneySum += uint64(money)
 	}
 
 	for _, money := range tx.ToMoney {
-		toMoneySum += money
+		toMoneySum += uint64(money)
 	}
 
+	if fromMoneySum > math.MaxUint32 || toMoneySum > math.MaxUint32 {
+		return errors.New("FromMoney or ToMoney overflows")
+	}
 	if fromMoneySum != toMoneySum {
 		return errors.New("FromMoney and ToMoney are not equal")
 	}
@@ -139,15 +155,44 @@ func UnmarshalValue(bz []byte) (money uint32, locked byte, err error) {
 }
 func SetValueUnlock(bz []byte) []byte {
 	bz[0] = FreeIdentifier
-	return bz
+	return bz[:valueSize]
+}
+
+// A read locked value counts its readers after the money, the key is free
+// again once the last of them unlocks it.
+const valueSize = 5
+
+func readers(bz []byte) uint32 {
+	if len(bz) < valueSize+4 || bz[0] != RLockedIdentifier {
+		return 0
+	}
+	return utils.BytesToUint32(bz[valueSize:])
 }
 func SetValueRLock(bz []byte) []byte {
+	n := readers(bz) + 1
 	bz[0] = RLockedIdentifier
-	return bz
+	return append(bz[:valueSize:valueSize], utils.Uint32ToBytes(n)...)
+}
+func SetValueRUnlock(bz []byte) []byte {
+	n := readers(bz)
+	if n == 0 {
+		return nil
+	} else if n == 1 {
+		return SetValueUnlock(bz)
+	}
+	return append(bz[:valueSize:valueSize], utils.Uint32ToBytes(n-1)...)
 }
 func SetValueWLock(bz []byte) []byte {
 	bz[0] = WLockedIdentifier
-	return bz
+	return bz[:valueSize]
+}
+
+// keepReaders gives value, read locked, the readers of old.
+func keepReaders(value, old []byte) []byte {
+	if n := readers(old); n > 0 && value[0] == RLockedIdentifier {
+		return append(value[:valueSize:valueSize], utils.Uint32ToBytes(n)...)
+	}
+	return value
 }
 
 func RelayTransferTxBytes(tx *bank.RelayTransferTx) ([]byte, error) {
@@ -174,9 +219,11 @@ func NewRelayTransferTxSetFromBytes(bz []byte) (*bank.RelayTransferTxSet, error)
 	}
 }
 
+// isRelayTransferTxSetFinish tells whether every shard relayed, the data a
+// shard did not relay is nil, or empty once stored.
 func isRelayTransferTxSetFinish(tx *bank.RelayTransferTxSet) bool {
 	for _, bd := range tx.Datas {
-		if bd == nil {
+		if bd == nil || !bd.OK && bd.Reason == "" {
 			return false
 		}
 	}
@@ -0,0 +1,148 @@
+package minibank
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/viper"
+)
+
+// WorkloadFileName is written next to dataset.txt so that every dataset
+// records the workload it was generated from.
+const WorkloadFileName = "workload.json"
+
+// Workload describes the transactions the Importor generates. It can be read
+// from a JSON or TOML file, fields that are left out keep their defaults.
+type Workload struct {
+	// Seed of the generator, 0 draws one from the clock when the dataset is generated
+	Seed int64 `json:"seed" mapstructure:"seed"`
+
+	TxsPerShard      int `json:"txs_per_shard" mapstructure:"txs_per_shard"`
+	AccountsPerShard int `json:"accounts_per_shard" mapstructure:"accounts_per_shard"`
+
+	// TransferSize accounts take part in every tx, the first half sends and the second half receives
+	TransferSize int `json:"transfer_size" mapstructure:"transfer_size"`
+	// TxSize is the number of bytes every tx is padded to
+	TxSize int `json:"tx_size" mapstructure:"tx_size"`
+
+	CrossShardRatio float64 `json:"cross_shard_ratio" mapstructure:"cross_shard_ratio"`
+	// ShardsPerTx is the number of shards a cross-shard tx touches, 0 draws
+	// the shard of every account independently
+	ShardsPerTx int `json:"shards_per_tx" mapstructure:"shards_per_tx"`
+
+	// ZipfTheta skews the account numbers drawn in a shard, account 1 being
+	// the most popular. 0 draws them uniformly, YCSB uses 0.99.
+	ZipfTheta float64 `json:"zipf_theta" mapstructure:"zipf_theta"`
+
+	// HotKeyFraction of the txs only touch the first HotKeys accounts of their shards
+	HotKeyFraction float64 `json:"hot_key_fraction" mapstructure:"hot_key_fraction"`
+	HotKeys        int     `json:"hot_keys" mapstructure:"hot_keys"`
+
+	// HotShardBias is the probability that a tx touches the hot shard, on top
+	// of its normal share. HotShard is the chain id the hotspot starts on,
+	// empty for the first shard.
+	HotShardBias float64 `json:"hot_shard_bias" mapstructure:"hot_shard_bias"`
+	HotShard     string  `json:"hot_shard" mapstructure:"hot_shard"`
+	// HotspotShift moves the hotspot to the next shard every HotspotShift
+	// generated txs, 0 keeps it in place for the whole run
+	HotspotShift int `json:"hotspot_shift" mapstructure:"hotspot_shift"`
+
+	// Signed txs are signed by their From accounts, with keys derived from
+	// KeySeed. The genesis requires signatures when the workload is signed.
+	Signed  bool   `json:"signed" mapstructure:"signed"`
+	KeySeed string `json:"key_seed" mapstructure:"key_seed"`
+}
+
+func DefaultWorkload() *Workload {
+	return &Workload{
+		Seed:             0,
+		TxsPerShard:      500000,
+		AccountsPerShard: 100000,
+		TransferSize:     4,
+		TxSize:           512,
+		CrossShardRatio:  0.8,
+		ShardsPerTx:      0,
+		ZipfTheta:        0,
+		HotKeyFraction:   0,
+		HotKeys:          0,
+		HotShardBias:     0,
+		HotShard:         "",
+		HotspotShift:     0,
+		Signed:           true,
+		KeySeed:          DefaultKeySeed,
+	}
+}
+
+// UnmarshalJSON keeps the defaults of fields missing from the input.
+func (w *Workload) UnmarshalJSON(bz []byte) error {
+	type plain Workload
+	p := (*plain)(DefaultWorkload())
+	if err := json.Unmarshal(bz, p); err != nil {
+		return err
+	}
+	*w = Workload(*p)
+	return nil
+}
+
+func (w *Workload) Validate() error {
+	switch {
+	case w.TxsPerShard <= 0:
+		return fmt.Errorf("workload: txs_per_shard must be positive")
+	case w.AccountsPerShard <= 0:
+		return fmt.Errorf("workload: accounts_per_shard must be positive")
+	case w.TransferSize < 2:
+		return fmt.Errorf("workload: transfer_size must be at least 2")
+	case w.AccountsPerShard < w.TransferSize:
+		// the accounts of a tx are distinct, and may all be in one shard
+		return fmt.Errorf("workload: accounts_per_shard must be at least transfer_size")
+	case w.TxSize < 0:
+		return fmt.Errorf("workload: tx_size must not be negative")
+	case w.CrossShardRatio < 0 || w.CrossShardRatio > 1:
+		return fmt.Errorf("workload: cross_shard_ratio must be in [0,1]")
+	case w.ShardsPerTx < 0 || w.ShardsPerTx > w.TransferSize:
+		return fmt.Errorf("workload: shards_per_tx must be in [0,transfer_size]")
+	case w.CrossShardRatio > 0 && w.ShardsPerTx == 1:
+		return fmt.Errorf("workload: shards_per_tx must be at least 2 for cross-shard txs, or 0")
+	case w.ZipfTheta < 0 || w.ZipfTheta >= 1:
+		return fmt.Errorf("workload: zipf_theta must be in [0,1)")
+	case w.HotKeyFraction < 0 || w.HotKeyFraction > 1:
+		return fmt.Errorf("workload: hot_key_fraction must be in [0,1]")
+	case w.HotKeyFraction > 0 && (w.HotKeys < w.TransferSize || w.HotKeys > w.AccountsPerShard):
+		return fmt.Errorf("workload: hot_keys must be in [transfer_size,accounts_per_shard]")
+	case w.HotShardBias < 0 || w.HotShardBias > 1:
+		return fmt.Errorf("workload: hot_shard_bias must be in [0,1]")
+	case w.HotspotShift < 0:
+		return fmt.Errorf("workload: hotspot_shift must not be negative")
+	case w.Signed && w.KeySeed == "":
+		return fmt.Errorf("workload: key_seed must be set for a signed workload")
+	}
+	return nil
+}
+
+// LoadWorkload reads a workload file, its format follows the file extension.
+func LoadWorkload(path string) (*Workload, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	w := DefaultWorkload()
+	if err := v.Unmarshal(w); err != nil {
+		return nil, err
+	}
+	return w, w.Validate()
+}
+
+func (w *Workload) WriteFile(path string) error {
+	bz, err := json.MarshalIndent(w, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, bz, 0666)
+}
+
+func (w *Workload) String() string {
+	bz, _ := json.Marshal(w)
+	return string(bz)
+}
@@ -0,0 +1,123 @@
+package minibank
+
+import (
+	bank "emulator/proto/urd/abci/minibank"
+	"emulator/utils"
+	"encoding/hex"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testRangeLists() map[string]*utils.RangeList {
+	return map[string]*utils.RangeList{
+		"i1": utils.NewRangeListFromString("10,11"),
+		"i2": utils.NewRangeListFromString("11,12"),
+		"i3": utils.NewRangeListFromString("12,13"),
+	}
+}
+
+func testWorkload() *Workload {
+	w := DefaultWorkload()
+	w.Seed, w.TxsPerShard, w.AccountsPerShard = 7, 50, 1000
+	return w
+}
+
+func decodeTransferTxs(t *testing.T, lines []string) []*bank.TransferTx {
+	txs := make([]*bank.TransferTx, len(lines))
+	for i, line := range lines {
+		bz, err := hex.DecodeString(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if txs[i], err = NewTransferTxFromBytes(bz); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return txs
+}
+
+func TestGenerateTxsIsReproducible(t *testing.T) {
+	a := decodeTransferTxs(t, NewImportorForGenerator(testRangeLists(), testWorkload()).GenerateTxs())
+	b := decodeTransferTxs(t, NewImportorForGenerator(testRangeLists(), testWorkload()).GenerateTxs())
+	for i := range a {
+		// only the timestamps may differ
+		if !reflect.DeepEqual(a[i].From, b[i].From) || !reflect.DeepEqual(a[i].To, b[i].To) || !reflect.DeepEqual(a[i].Shards, b[i].Shards) {
+			t.Fatalf("tx %d differs for the same seed: %v and %v", i, a[i], b[i])
+		}
+	}
+}
+
+func TestShardsPerTx(t *testing.T) {
+	w := testWorkload()
+	w.CrossShardRatio, w.ShardsPerTx, w.TransferSize = 1, 2, 6
+	for _, tx := range decodeTransferTxs(t, NewImportorForGenerator(testRangeLists(), w).GenerateTxs()) {
+		if len(tx.Shards) != 2 {
+			t.Fatalf("tx touches shards %v, expected 2", tx.Shards)
+		}
+		if len(tx.From)+len(tx.To) != w.TransferSize {
+			t.Fatalf("tx has %d accounts, expected %d", len(tx.From)+len(tx.To), w.TransferSize)
+		}
+	}
+}
+
+func TestLoadWorkloadKeepsDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workload.toml")
+	if err := os.WriteFile(path, []byte("cross_shard_ratio = 0.3\nseed = 42\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	w, err := LoadWorkload(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultWorkload()
+	want.CrossShardRatio, want.Seed = 0.3, 42
+	if *w != *want {
+		t.Fatalf("got %v, expected %v", w, want)
+	}
+}
+
+func TestZipfianSkew(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	z := newZipfian(1000, 0.99)
+	counts := make([]int, 1000)
+	for i := 0; i < 100000; i++ {
+		counts[z.next(r)]++
+	}
+	// rank 0 should get about 1/zeta(1000, 0.99) of the draws, around 13%
+	if counts[0] < 10000 || counts[0] < 10*counts[99] {
+		t.Fatalf("draws are not skewed: rank 0 got %d, rank 99 got %d", counts[0], counts[99])
+	}
+}
+
+func TestHotspotShift(t *testing.T) {
+	w := testWorkload()
+	w.CrossShardRatio, w.HotShardBias, w.HotShard, w.HotspotShift = 0, 1, "i2", 50
+	txs := decodeTransferTxs(t, NewImportorForGenerator(testRangeLists(), w).GenerateTxs())
+	for i, tx := range txs {
+		want := []string{"i2", "i3", "i1"}[i/50]
+		if len(tx.Shards) != 1 || tx.Shards[0] != want {
+			t.Fatalf("tx %d touches %v, expected the hot shard %s", i, tx.Shards, want)
+		}
+	}
+}
+
+func TestValidateWorkload(t *testing.T) {
+	for name, change := range map[string]func(w *Workload){
+		"fewer accounts than a tx":   func(w *Workload) { w.AccountsPerShard, w.TransferSize = 3, 4 },
+		"cross-shard txs in 1 shard": func(w *Workload) { w.CrossShardRatio, w.ShardsPerTx = 0.5, 1 },
+	} {
+		w := testWorkload()
+		change(w)
+		if err := w.Validate(); err == nil {
+			t.Fatalf("%s: validated", name)
+		}
+	}
+	w := testWorkload()
+	w.CrossShardRatio, w.ShardsPerTx = 0, 1
+	if err := w.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,54 @@
+package minibank
+
+import (
+	"math"
+	"math/rand"
+)
+
+// zipfian draws ranks in [0,n) where rank i has a weight of 1/(i+1)^theta,
+// following Gray et al., "Quickly Generating Billion-Record Synthetic
+// Databases", as YCSB does. Unlike rand.Zipf it accepts theta < 1.
+type zipfian struct {
+	n     int
+	theta float64
+
+	alpha float64
+	zetan float64
+	eta   float64
+}
+
+func newZipfian(n int, theta float64) *zipfian {
+	zeta2 := zeta(2, theta)
+	z := &zipfian{
+		n:     n,
+		theta: theta,
+		alpha: 1 / (1 - theta),
+		zetan: zeta(n, theta),
+	}
+	z.eta = (1 - math.Pow(2/float64(n), 1-theta)) / (1 - zeta2/z.zetan)
+	return z
+}
+
+func zeta(n int, theta float64) float64 {
+	sum := 0.0
+	for i := 1; i <= n; i++ {
+		sum += 1 / math.Pow(float64(i), theta)
+	}
+	return sum
+}
+
+func (z *zipfian) next(r *rand.Rand) int {
+	u := r.Float64()
+	uz := u * z.zetan
+	if uz < 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, z.theta) {
+		return 1
+	}
+	rank := int(float64(z.n) * math.Pow(z.eta*u-z.eta+1, z.alpha))
+	if rank >= z.n {
+		rank = z.n - 1
+	}
+	return rank
+}
@@ -0,0 +1,224 @@
+package client
+
+import (
+	"bufio"
+	bank "emulator/proto/urd/abci/minibank"
+	"emulator/urd/abci/minibank"
+	"emulator/urd/shardinfo"
+	"emulator/urd/types"
+	"emulator/utils"
+	"emulator/utils/p2p"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Router finds the shards of a tx from the key ranges in shard_info.json and
+// the leader that proposes the txs of every shard.
+type Router struct {
+	rangeLists map[string]*utils.RangeList
+	leaders    map[string]*p2p.Peer
+}
+
+func NewRouter(si *shardinfo.ShardInfo) (*Router, error) {
+	r := &Router{
+		rangeLists: make(map[string]*utils.RangeList),
+		leaders:    make(map[string]*p2p.Peer),
+	}
+	for id, shard := range si.Shards {
+		rl := utils.NewRangeListFromString(shard.KeyRange)
+		if rl == nil {
+			return nil, fmt.Errorf("shard %s: invalid key range %q", id, shard.KeyRange)
+		}
+		if shard.LeaderIndex < 0 || shard.LeaderIndex >= len(shard.PeerList) {
+			return nil, fmt.Errorf("shard %s: leader index %d is out of its peer list", id, shard.LeaderIndex)
+		}
+		r.rangeLists[id] = rl
+		r.leaders[id] = shard.PeerList[shard.LeaderIndex]
+	}
+	return r, nil
+}
+
+func (r *Router) RangeLists() map[string]*utils.RangeList { return r.rangeLists }
+
+// Leader is the leader of shard, nil if there is no such shard.
+func (r *Router) Leader(shard string) *p2p.Peer { return r.leaders[shard] }
+
+// Shard returns the shard holding the account key.
+func (r *Router) Shard(key string) (string, error) {
+	for id, rl := range r.rangeLists {
+		if rl.Search(key) {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("account %s is in no shard", key)
+}
+
+// Route returns the sorted shards holding the accounts of tx.
+func (r *Router) Route(tx *bank.TransferTx) ([]string, error) {
+	shardsMap := make(map[string]bool)
+	for _, acc := range append(append([]string{}, tx.From...), tx.To...) {
+		id, err := r.Shard(acc)
+		if err != nil {
+			return nil, err
+		}
+		shardsMap[id] = true
+	}
+	shards := make([]string, 0, len(shardsMap))
+	for id := range shardsMap {
+		shards = append(shards, id)
+	}
+	sort.Strings(shards)
+	return shards, nil
+}
+
+// Client submits txs to the shard leaders over the mempool channels on an
+// open-loop schedule. A single-shard tx goes to the mempool of its leader, a
+// cross-shard tx to the cross-shard mempool of the leader of every shard it
+// touches, which is where Importor.Start puts the txs of a dataset.
+//
+// Every tx is stamped with its submit time, so the latency tools measure from
+// submission, and a line "tx_hash,submit_unix_nano,shards" is written to the
+// submit log. Every leader has a worker sending its txs in submission order
+// from a queue of sendQueue txs. A tx for a leader whose queue is full is
+// dropped, so that a slow leader neither holds back the schedule nor adds
+// the wait in the client to the latency.
+type Client struct {
+	router   *Router
+	sender   *p2p.Sender
+	schedule Schedule
+	next     func() (*bank.TransferTx, error)
+
+	// Keys sign every tx once it is stamped, nil sends them unsigned
+	Keys *minibank.Keys
+
+	submitLog *bufio.Writer
+
+	queues map[string]chan send
+
+	sent    int64
+	dropped int64
+	failed  int64
+	wg      sync.WaitGroup
+}
+
+// sendQueue is how many txs wait for a leader.
+const sendQueue = 4096
+
+type send struct {
+	channel byte
+	bz      []byte
+}
+
+// NewClient creates a client, next returns io.EOF when it runs out of txs.
+func NewClient(router *Router, schedule Schedule, next func() (*bank.TransferTx, error), submitLog io.Writer) *Client {
+	c := &Client{
+		router:    router,
+		sender:    p2p.NewSender(""),
+		schedule:  schedule,
+		next:      next,
+		submitLog: bufio.NewWriter(submitLog),
+	}
+	for _, leader := range router.leaders {
+		c.sender.AddPeer(leader)
+	}
+	return c
+}
+
+// Run submits txs until duration has passed, maxTxs were sent or next runs
+// out. A zero duration or maxTxs is no limit.
+func (c *Client) Run(duration time.Duration, maxTxs int) error {
+	if err := c.sender.Start(); err != nil {
+		return err
+	}
+	defer c.sender.Stop()
+
+	c.queues = make(map[string]chan send, len(c.router.leaders))
+	for shard, leader := range c.router.leaders {
+		queue := make(chan send, sendQueue)
+		c.queues[shard] = queue
+		c.wg.Add(1)
+		go c.sendTo(leader, queue)
+	}
+	start := time.Now()
+	next := start
+	for n := 0; maxTxs <= 0 || n < maxTxs; n++ {
+		time.Sleep(time.Until(next))
+		if duration > 0 && time.Since(start) >= duration {
+			break
+		}
+		tx, err := c.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := c.submit(tx); err != nil {
+			fmt.Println(err)
+			c.dropped++
+		}
+		// the next submission is due from the previous due time, not from
+		// now, so a slow iteration does not lower the rate
+		next = next.Add(c.schedule.Next(next.Sub(start)))
+	}
+	for _, queue := range c.queues {
+		close(queue)
+	}
+	c.wg.Wait()
+	elapsed := time.Since(start)
+	if err := c.submitLog.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("sent %d txs in %.3fs (%.1f tx/s), %d dropped, %d sends failed\n",
+		c.sent, elapsed.Seconds(), float64(c.sent)/elapsed.Seconds(), c.dropped, c.failed)
+	return nil
+}
+
+func (c *Client) submit(tx *bank.TransferTx) error {
+	shards, err := c.router.Route(tx)
+	if err != nil {
+		return err
+	}
+	// only Run sends to the queues, a tx finding room in all of them is
+	// queued without waiting and never reaches only some of its shards
+	for _, shard := range shards {
+		if queue := c.queues[shard]; len(queue) == cap(queue) {
+			c.dropped++
+			return nil
+		}
+	}
+	now := time.Now()
+	tx.Time = utils.ThirdPartyProtoTime(now)
+	tx.Shards = shards
+	if c.Keys != nil {
+		c.Keys.Sign(tx)
+	}
+	bz := minibank.TransferBytes(tx)
+
+	channel := byte(p2p.ChannelIDMempool)
+	if len(shards) > 1 {
+		channel = p2p.ChannelIDCrossShardMempool
+	}
+	for _, shard := range shards {
+		c.queues[shard] <- send{channel, bz}
+	}
+	c.sent++
+	_, err = fmt.Fprintf(c.submitLog, "%s,%d,%s\n", types.TxKey(bz), now.UnixNano(), strings.Join(shards, ";"))
+	return err
+}
+
+// sendTo sends the txs of queue to leader until it is closed.
+func (c *Client) sendTo(leader *p2p.Peer, queue <-chan send) {
+	defer c.wg.Done()
+	for s := range queue {
+		if err := c.sender.Send(leader, s.channel, s.bz, 0); err != nil {
+			fmt.Println(err)
+			atomic.AddInt64(&c.failed, 1)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"emulator/urd/abci/minibank"
+	"emulator/urd/client"
+	"emulator/urd/shardinfo"
+	"emulator/utils/signer"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	bank "emulator/proto/urd/abci/minibank"
+
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+// ./urd-client --shard-info=./mytestnet/127.0.0.1/node1/config/shard_info.json --mode=poisson --rate=2000 --duration=60s
+// ./urd-client --shard-info=... --mode=ramp --rate=500 --ramp-to=5000 --ramp-duration=120s --workload=./workload.toml
+// ./urd-client --shard-info=... --mode=constant --rate=1000 --dataset=./dataset.bin.gz --submit-log=./submit.csv
+
+func main() {
+	shardInfoPath := flag.String("shard-info", "./shard_info.json", "The shard_info.json of the testnet, used to route txs")
+	mode := flag.String("mode", client.RateConstant, "Arrival process: constant, poisson or ramp")
+	rate := flag.Float64("rate", 1000, "Target rate in txs per second, the starting rate of a ramp")
+	rampTo := flag.Float64("ramp-to", 0, "ramp: final rate in txs per second")
+	rampDuration := flag.Duration("ramp-duration", time.Minute, "ramp: time to go from --rate to --ramp-to")
+	duration := flag.Duration("duration", time.Minute, "How long to send for, 0 for no limit")
+	maxTxs := flag.Int("txs", 0, "Stop after this many txs, 0 for no limit")
+	workloadPath := flag.String("workload", "", "JSON or TOML workload file to generate txs from, the default workload if empty")
+	datasetPath := flag.String("dataset", "", "Send the txs of a dataset file instead of generating them")
+	seed := flag.Int64("seed", 0, "Seed of the Poisson arrivals and of the generator, 0 picks one from the clock")
+	sign := flag.Bool("sign", true, "Sign the txs, which the genesis requires unless its initial_state.signed_txs is false")
+	keySeed := flag.String("key-seed", minibank.DefaultKeySeed, "Seed the keys of the accounts are derived from, the key_seed of the workload the testnet was generated with")
+	firstNonce := flag.Uint64("first-nonce", 0, "Sign the first tx of every account with the nonce after this one, above those of the dataset if the nodes import one")
+	submitLogPath := flag.String("submit-log", "./submit-log.csv", "File the submit time of every tx is written to")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	if err := bls.Init(signer.BaseCurve); err != nil {
+		panic(err)
+	}
+	shardInfoBz, err := os.ReadFile(*shardInfoPath)
+	if err != nil {
+		panic(err)
+	}
+	var shardInfo = new(shardinfo.ShardInfo)
+	if err := shardInfo.UnmarshalJson(shardInfoBz); err != nil {
+		panic(err)
+	}
+	router, err := client.NewRouter(shardInfo)
+	if err != nil {
+		panic(err)
+	}
+	schedule, err := client.NewSchedule(*mode, *rate, *rampTo, *rampDuration, *seed)
+	if err != nil {
+		panic(err)
+	}
+
+	var next func() (*bank.TransferTx, error)
+	if *datasetPath != "" {
+		next, err = readDataset(*datasetPath)
+	} else {
+		next, err = generate(router, *workloadPath, *seed)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	submitLog, err := os.Create(*submitLogPath)
+	if err != nil {
+		panic(err)
+	}
+	defer submitLog.Close()
+
+	c := client.NewClient(router, schedule, next, submitLog)
+	if *sign {
+		c.Keys = minibank.NewKeys(*keySeed)
+		c.Keys.StartAt(*firstNonce)
+	}
+	if err := c.Run(*duration, *maxTxs); err != nil {
+		panic(err)
+	}
+}
+
+func generate(router *client.Router, workloadPath string, seed int64) (func() (*bank.TransferTx, error), error) {
+	workload := minibank.DefaultWorkload()
+	if workloadPath != "" {
+		var err error
+		if workload, err = minibank.LoadWorkload(workloadPath); err != nil {
+			return nil, err
+		}
+	}
+	if workload.Seed == 0 {
+		workload.Seed = seed
+	}
+	// the client signs the txs once it stamped them
+	workload.Signed = false
+	fmt.Println("workload:", workload)
+	importor := minibank.NewImportorForGenerator(router.RangeLists(), workload)
+	return func() (*bank.TransferTx, error) {
+		return importor.NextTx(), nil
+	}, nil
+}
+
+// readDataset streams the txs of a dataset file of any format.
+func readDataset(path string) (func() (*bank.TransferTx, error), error) {
+	reader, err := minibank.OpenDataset(path)
+	if err != nil {
+		return nil, err
+	}
+	return func() (*bank.TransferTx, error) {
+		bz, err := reader.Next()
+		if err != nil {
+			reader.Close()
+			return nil, err
+		}
+		return minibank.NewTransferTxFromBytes(bz)
+	}, nil
+}
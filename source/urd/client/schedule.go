@@ -0,0 +1,75 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	RateConstant = "constant"
+	RatePoisson  = "poisson"
+	RateRamp     = "ramp"
+)
+
+// Schedule gives the gap between two submissions. The client sends on the
+// schedule whatever the state of the nodes, so that queueing shows up in the
+// latency instead of lowering the offered load.
+type Schedule interface {
+	// Next returns the gap before the next tx, elapsed is the time since the
+	// first one was sent.
+	Next(elapsed time.Duration) time.Duration
+}
+
+// NewSchedule builds the schedule of mode. rate is in txs per second, a ramp
+// goes linearly from rate to rampTo over duration and stays there.
+func NewSchedule(mode string, rate, rampTo float64, duration time.Duration, seed int64) (Schedule, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+	switch mode {
+	case RateConstant:
+		return &constantSchedule{gap: gap(rate)}, nil
+	case RatePoisson:
+		return &poissonSchedule{rate: rate, rander: rand.New(rand.NewSource(seed))}, nil
+	case RateRamp:
+		if rampTo <= 0 || duration <= 0 {
+			return nil, fmt.Errorf("a ramp needs a positive target rate and duration")
+		}
+		return &rampSchedule{from: rate, to: rampTo, duration: duration}, nil
+	default:
+		return nil, fmt.Errorf("unknown rate mode %q, expected %s, %s or %s", mode, RateConstant, RatePoisson, RateRamp)
+	}
+}
+
+func gap(rate float64) time.Duration {
+	return time.Duration(float64(time.Second) / rate)
+}
+
+type constantSchedule struct {
+	gap time.Duration
+}
+
+func (s *constantSchedule) Next(time.Duration) time.Duration { return s.gap }
+
+// poissonSchedule draws exponential gaps, so arrivals form a Poisson process.
+type poissonSchedule struct {
+	rate   float64
+	rander *rand.Rand
+}
+
+func (s *poissonSchedule) Next(time.Duration) time.Duration {
+	return time.Duration(s.rander.ExpFloat64() / s.rate * float64(time.Second))
+}
+
+type rampSchedule struct {
+	from, to float64
+	duration time.Duration
+}
+
+func (s *rampSchedule) Next(elapsed time.Duration) time.Duration {
+	if elapsed >= s.duration {
+		return gap(s.to)
+	}
+	return gap(s.from + (s.to-s.from)*float64(elapsed)/float64(s.duration))
+}
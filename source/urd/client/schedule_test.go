@@ -0,0 +1,45 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedules(t *testing.T) {
+	constant, err := NewSchedule(RateConstant, 1000, 0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gap := constant.Next(0); gap != time.Millisecond {
+		t.Fatalf("constant gap is %v, expected 1ms", gap)
+	}
+
+	poisson, err := NewSchedule(RatePoisson, 1000, 0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := time.Duration(0)
+	for i := 0; i < 10000; i++ {
+		total += poisson.Next(0)
+	}
+	if mean := total / 10000; mean < 900*time.Microsecond || mean > 1100*time.Microsecond {
+		t.Fatalf("poisson mean gap is %v, expected about 1ms", mean)
+	}
+
+	ramp, err := NewSchedule(RateRamp, 100, 300, 10*time.Second, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		elapsed time.Duration
+		gap     time.Duration
+	}{{0, 10 * time.Millisecond}, {5 * time.Second, 5 * time.Millisecond}, {time.Minute, time.Second / 300}} {
+		if gap := ramp.Next(c.elapsed); gap != c.gap {
+			t.Fatalf("ramp gap at %v is %v, expected %v", c.elapsed, gap, c.gap)
+		}
+	}
+
+	if _, err := NewSchedule("burst", 1000, 0, 0, 1); err == nil {
+		t.Fatal("expected an unknown mode to be rejected")
+	}
+}
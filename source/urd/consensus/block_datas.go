@@ -3,8 +3,8 @@ package consensus
 import (
 	"emulator/logger/blocklogger"
 	"emulator/urd/types"
+	"emulator/utils/logging"
 	"fmt"
-	"time"
 )
 
 type BlockData struct {
@@ -56,7 +56,7 @@ func (bd *BlockData) addPartSetHeader(header *types.PartSetHeader, headerHash []
 				if _, ok := bd.retainBlockParts[header.View][header.Round]; ok {
 					for _, part := range bd.retainBlockParts[header.View][header.Round] {
 						if err := bd.addPart(part); err != nil {
-							fmt.Println("Error: ", err)
+							log.Warn("early part not added", "block_view", header.View, "block_round", header.Round, "err", err)
 						}
 					}
 				}
@@ -193,18 +193,54 @@ func (bd *BlockData) append_part(part *types.Part) {
 	bd.retainBlockParts[part.View][part.Round] = append(bd.retainBlockParts[part.View][part.Round], part)
 }
 
+// WriteLogger logs an event of the block of the current view.
 func (state *State) WriteLogger(msg string, is_start, is_end bool) {
-	if state.EnablePipelineFlag ||
-		is_start && state.HotStuffState.View%6 == 0 ||
-		is_end && state.HotStuffState.View%6 == 2 ||
-		!is_end && !is_start && state.HotStuffState.View%6 == 1 {
-		view, round, step := state.HotStuffState.View, state.HotStuffState.Round, state.step
-		if !state.EnablePipelineFlag {
-			view /= 6
-		}
-		state.logger.Write(blocklogger.NewConsensusEvent(view, round, step, is_start, is_end, msg))
+	if height, ok := loggedHeight(state.PipelineStride, state.HotStuffState.View); ok {
+		state.logger.Write(blocklogger.NewConsensusEvent(height, state.HotStuffState.Round, state.step, is_start, is_end, msg))
 	}
 }
-func (state *State) WriteCmd(msg string) {
-	fmt.Printf("[%v] (view=%d,round=%d,step=%s) %s\n", time.Now(), state.HotStuffState.View, state.HotStuffState.Round, state.step, msg)
+
+// WriteFinish logs the end of the view with the txs of the block of
+// blockView it commits. With a full pipeline the end is logged at the view
+// it happens in, otherwise at the block: the leader executes a block one
+// view after it and the validators two views after it.
+func (state *State) WriteFinish(blockView int64, inner, commit, count int) {
+	view := blockView
+	if state.PipelineStride == 1 {
+		view = state.HotStuffState.View
+	}
+	if height, ok := loggedHeight(state.PipelineStride, view); ok {
+		state.logger.Write(blocklogger.NewFinishEvent(height, state.HotStuffState.Round, state.step, inner, commit, count))
+	}
+}
+
+// decisionShares adds up the shares of the cross-shard txs decided, of which
+// the finish events log the whole txs.
+type decisionShares struct{ commit, count float64 }
+
+// take adds the shares decided by a block and returns the whole txs they
+// complete, the rest is kept for the next blocks.
+func (s *decisionShares) take(committed, aborted float64) (commit, count int) {
+	s.commit += committed
+	s.count += committed + aborted
+	// the shares of a tx add up to 1 up to rounding
+	commit, count = int(s.commit+1e-9), int(s.count+1e-9)
+	s.commit -= float64(commit)
+	s.count -= float64(count)
+	return commit, count
+}
+
+// loggedHeight is the height the events of the block of view are logged at,
+// if they are logged: without a full pipeline only the blocks with txs are
+// logged, numbered by block.
+func loggedHeight(stride, view int64) (int64, bool) {
+	if view%stride != 0 {
+		return 0, false
+	}
+	return view / stride, true
+}
+
+// viewLog is the logger of the view, round and step the state is in.
+func (state *State) viewLog() *logging.Logger {
+	return log.With("view", state.HotStuffState.View, "round", state.HotStuffState.Round, "step", state.step)
 }
@@ -0,0 +1,95 @@
+package consensus
+
+import (
+	"emulator/core/hotstuff"
+	"emulator/logger/blocklogger"
+	"emulator/utils/config"
+	"testing"
+)
+
+type eventLog struct{ events []*blocklogger.ConsensusEvent }
+
+func (l *eventLog) OnStart() error { return nil }
+func (l *eventLog) OnStop() error  { return nil }
+func (l *eventLog) Write(event *blocklogger.ConsensusEvent) error {
+	l.events = append(l.events, event)
+	return nil
+}
+
+// TestLoggedHeights runs the views of a leader and of a validator at every
+// pipeline depth and checks which block the finish event of every logged
+// height reports, the blocks are told apart by their inner txs.
+func TestLoggedHeights(t *testing.T) {
+	const views = 40
+	for _, depth := range []int{1, 2, 3, 6} {
+		stride := int64(config.MaxPipelineDepth / depth)
+		for _, leader := range []bool{true, false} {
+			log := new(eventLog)
+			state := &State{PipelineStride: stride, HotStuffState: &hotstuff.State{}, logger: log}
+			for v := int64(1); v <= views; v++ {
+				// the leader executes block v-2 before it enters view v,
+				// a validator after it validated block v
+				if leader {
+					state.HotStuffState.View = v - 1
+					state.WriteFinish(v-2, int(v-2), 0, 0)
+					state.HotStuffState.View = v
+					state.WriteLogger("START", true, false)
+				} else {
+					state.HotStuffState.View = v
+					state.WriteLogger("validate block", false, false)
+					state.WriteFinish(v-2, int(v-2), 0, 0)
+					state.HotStuffState.View = v + 1
+					state.WriteLogger("START", true, false)
+				}
+			}
+
+			started := map[int64]bool{}
+			finished := 0
+			for _, event := range log.events {
+				if event.IsRoundStart {
+					started[event.Height] = true
+				}
+				if !event.IsFinish() {
+					continue
+				}
+				want := event.Height * stride
+				if stride == 1 {
+					// a full pipeline logs the end at the view it happens in
+					want = event.Height - 1
+					if !leader {
+						want = event.Height - 2
+					}
+				}
+				if int64(event.Inner) != want {
+					t.Fatalf("depth %d, leader %v: height %d finishes block %d, want %d", depth, leader, event.Height, event.Inner, want)
+				}
+				// the genesis block is executed but never started
+				if stride > 1 && event.Height > 0 && !started[event.Height] {
+					t.Fatalf("depth %d, leader %v: height %d finishes before it starts", depth, leader, event.Height)
+				}
+				finished++
+			}
+			if want := (views - 2) / int(stride); finished < want {
+				t.Fatalf("depth %d, leader %v: %d heights finished, want %d", depth, leader, finished, want)
+			}
+		}
+	}
+}
+
+func TestDecisionShares(t *testing.T) {
+	// txs of three shards decided one share at a time: two committed, one
+	// aborted
+	var shares decisionShares
+	commit, count := 0, 0
+	for i := 0; i < 9; i++ {
+		committed, aborted := 1.0/3, 0.0
+		if i%3 == 2 {
+			committed, aborted = 0, 1.0/3
+		}
+		c, n := shares.take(committed, aborted)
+		commit, count = commit+c, count+n
+	}
+	if commit != 2 || count != 3 {
+		t.Fatalf("logged %d committed of %d txs, want 2 of 3", commit, count)
+	}
+}
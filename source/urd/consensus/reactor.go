@@ -1,9 +1,6 @@
 package consensus
 
-import (
-	"emulator/utils/p2p"
-	"fmt"
-)
+import "emulator/utils/p2p"
 
 func (cs *State) SendToShard(shardID string, bz []byte, messageType uint32) {
 	if err := cs.p2p.SendToShard(shardID, p2p.ChannelIDConsensusState, bz, messageType); err != nil {
@@ -17,5 +14,5 @@ func (cs *State) SendTo(shard string, index int, bz []byte, messageType uint32)
 }
 
 func (cs *State) write_p2p_error(err error) {
-	cs.WriteCmd(fmt.Sprintf("p2p error: %v", err))
+	cs.viewLog().Warn("send failed", "err", err)
 }
@@ -9,17 +9,35 @@ import (
 	inter "emulator/urd/definition"
 	"emulator/urd/shardinfo"
 	"emulator/urd/types"
+	"emulator/utils/logging"
+	"emulator/utils/metrics"
 	"emulator/utils/p2p"
 	sig "emulator/utils/signer"
+	"emulator/utils/status"
 	"emulator/utils/store"
 	"encoding/hex"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var log = logging.New("consensus")
+
 type State struct {
-	EnablePipelineFlag bool
+	// PipelineStride is the number of views between two blocks that carry
+	// txs: 1 pipelines every view (Urd), 6 runs a block at a time (CoCSV)
+	PipelineStride int64
+	// MinBlockInterval is the least time between two proposals
+	MinBlockInterval time.Duration
+	MaxPartSize      int
+	// FirstBlockDelay is waited before the first proposal
+	FirstBlockDelay time.Duration
+	// MaxViews ends the run once this view is reached, 0 runs until Stop
+	MaxViews int64
+	// StoreBlocks keeps the executed blocks and their receipts for the RPC
+	// and the latency tool
+	StoreBlocks bool
 
 	HotStuffState *hotstuff.State
 
@@ -32,11 +50,17 @@ type State struct {
 
 	logger blocklogger.BlockWriter
 
-	signer        *sig.Signer
+	signer        sig.Signer
 	signerIndex   int
 	proposerIndex int
-	verifier      *sig.Verifier
+	verifier      sig.Verifier
+	votePool      *hotstuff.VotePool
 	stateLock     sync.Mutex
+	// qc_check verifies the QCs of the block being validated
+	qc_check *qcCheck
+	// published is what Status reports, it is refreshed under the state
+	// lock so that Status does not wait for a proposal or an execution
+	published atomic.Pointer[status.Consensus]
 
 	block_data *BlockData
 
@@ -53,24 +77,41 @@ type State struct {
 	intra_shard_bytes      int
 	bytesLock              sync.Mutex
 	start_time             time.Time
+	last_propose           time.Time
+
+	// view_start, view_votes and csm_wait_start feed the metrics of a view
+	view_start     time.Time
+	view_votes     int
+	csm_wait_start time.Time
+	// decided holds the shares of the cross-shard txs decided that no
+	// finish event counted yet
+	decided decisionShares
+
+	done     chan struct{}
+	doneOnce sync.Once
+	stopped  bool
+
+	// blockWrites feeds the writer of the executed blocks, which is
+	// closed and waited for by Stop
+	blockWrites chan *storedBlock
+	writerDone  chan struct{}
 }
 
-func NewState(view int64, round int32, signer *sig.Signer, signer_index int, shard_info *shardinfo.ShardInfo, chain_id string,
+func NewState(view int64, round int32, signer sig.Signer, signer_index int, shard_info *shardinfo.ShardInfo, chain_id string,
 	mempool inter.MempoolConn, cross_shard_mempool inter.MempoolConn,
 	abci inter.ABCIConn, p2p *p2p.Sender, storeDir string, logger blocklogger.BlockWriter,
 	max_bytes, max_cross_shard_bytes int) (*State, error) {
 	store := store.NewPrefixStore("consensus", storeDir)
 
 	shard := shard_info.Shards[chain_id]
-	var validator_keys []string
 	var perVotes []int
 	for _, peer := range shard.PeerList {
 		perVotes = append(perVotes, int(peer.Vote))
-		validator_keys = append(validator_keys, peer.Pubkey)
 	}
-	validators, err := sig.NewVerifier(validator_keys)
-	if err != nil {
-		return nil, err
+	// the shard's verifier has already checked every validator's proof of possession
+	validators := shard.Verifier()
+	if validators == nil {
+		return nil, fmt.Errorf("no verifier for shard %s", chain_id)
 	}
 
 	hotstuff_state := hotstuff.NewState(view, round, signer, signer_index, validators, perVotes)
@@ -81,7 +122,7 @@ func NewState(view int64, round int32, signer *sig.Signer, signer_index int, sha
 	block_data := NewBlockData(view, round, chain_id, lastHash, make(map[string]*types.CrossShardMessage))
 	var proposer_index int = shard.LeaderIndex
 
-	return &State{
+	state := &State{
 		HotStuffState: hotstuff_state,
 
 		mempool:             mempool,
@@ -109,17 +150,34 @@ func NewState(view int64, round int32, signer *sig.Signer, signer_index int, sha
 		max_bytes:             max_bytes,
 		max_cross_shard_bytes: max_cross_shard_bytes,
 
+		PipelineStride:  1,
+		MaxPartSize:     40960,
+		FirstBlockDelay: 10 * time.Second,
+
 		bytesLock: sync.Mutex{},
-	}, nil
+
+		done: make(chan struct{}),
+	}
+	state.votePool = hotstuff.NewVotePool(validators, 0, state.deliverVote, state.rejectVote)
+	return state, nil
 }
 
 func (cs *State) Start() {
 	//fmt.Println(cs.Height)
 	cs.stateLock.Lock()
 	defer cs.stateLock.Unlock()
+	if cs.stopped {
+		return
+	}
 	cs.start_time = time.Now()
+	cs.votePool.Start()
+	if cs.StoreBlocks {
+		cs.blockWrites = make(chan *storedBlock, blockWriteQueue)
+		cs.writerDone = make(chan struct{})
+		go cs.writeBlocks()
+	}
 	if cs.HotStuffState.View == 0 {
-		fmt.Printf("Consensus State: Starting with View %d, Round %d\n", cs.HotStuffState.View, cs.HotStuffState.Round)
+		cs.viewLog().Info("starting consensus")
 		if cs.isProposer() {
 			if err := cs.doPropose(); err != nil {
 				panic(err)
@@ -129,18 +187,133 @@ func (cs *State) Start() {
 			cs.enterNextView()
 			cs.step = STEP_VALIDATOR
 		}
+		cs.publishStatus()
 	} else {
 		cs.handle_state_transition()
 	}
 }
+
+// Stop waits for the message being handled, drops the later ones and closes
+// the store. It can be called more than once.
 func (state *State) Stop() {
+	state.stateLock.Lock()
+	defer state.stateLock.Unlock()
+	if state.stopped {
+		return
+	}
+	state.stopped = true
+	state.finish()
+	state.votePool.Stop()
+	if state.blockWrites != nil {
+		close(state.blockWrites)
+		<-state.writerDone
+	}
 	state.store.Close()
-	state.WriteCmd("Consensus State: View 100 reached, stopping")
+	if state.start_time.IsZero() {
+		state.viewLog().Info("stopped before starting")
+		return
+	}
+	state.bytesLock.Lock()
+	defer state.bytesLock.Unlock()
 	dur := float64(time.Since(state.start_time)) / float64(time.Second)
-	fmt.Printf("Intra Shard Bandwidth: %f MB/s\n", float64(state.intra_shard_bytes)/dur/1024.0/1024.0)
-	fmt.Printf("Cross Shard Bandwidth: %f MB/s\n", float64(state.cross_shard_data_bytes)/dur/1024.0/1024.0)
-	fmt.Printf("Cooperation Bandwidth: %f MB/s\n", float64(state.cooperation_bytes)/dur/1024.0/1024.0)
-	panic("Interupted")
+	mbps := func(bytes int) float64 { return float64(bytes) / dur / 1024.0 / 1024.0 }
+	state.viewLog().Info("stopped",
+		"intra_shard_mbps", mbps(state.intra_shard_bytes),
+		"cross_shard_mbps", mbps(state.cross_shard_data_bytes),
+		"cooperation_mbps", mbps(state.cooperation_bytes))
+}
+
+// Done is closed once MaxViews is reached or the state is stopped.
+func (state *State) Done() <-chan struct{} { return state.done }
+
+func (state *State) finish() {
+	state.doneOnce.Do(func() { close(state.done) })
+}
+
+// Status is the view, round and step, the shards whose cross-shard message
+// the leader still waits for and the last block, as of the last commit or
+// step change.
+func (state *State) Status() status.Consensus {
+	if s := state.published.Load(); s != nil {
+		return *s
+	}
+	return status.Consensus{WaitingFor: []string{}}
+}
+
+// publishStatus refreshes what Status reports, it is called with the state
+// lock held.
+func (state *State) publishStatus() {
+	s := status.Consensus{
+		Leader:     state.isProposer(),
+		View:       state.HotStuffState.View,
+		Round:      int64(state.HotStuffState.Round),
+		Step:       state.step,
+		WaitingFor: []string{},
+	}
+	if state.step == STEP_LEADER_WAIT {
+		for _, id := range state.shard_info.ShardIDList {
+			if _, ok := state.block_data.finished[id]; !ok {
+				s.WaitingFor = append(s.WaitingFor, id)
+			}
+		}
+	}
+	if tip := state.fetch_block(1); tip != nil {
+		s.Tip = status.Tip{View: tip.View, Hash: hex.EncodeToString(tip.Hash())}
+	}
+	state.published.Store(&s)
+}
+
+const blockWriteQueue = 256
+
+// storedBlock is an executed block encoded under the state lock, so that
+// the writer does not read the block while the consensus uses it.
+type storedBlock struct {
+	view     int64
+	hash     []byte
+	bz       []byte
+	receipts []byte
+}
+
+func (b *storedBlock) Hash() []byte       { return b.hash }
+func (b *storedBlock) ProtoBytes() []byte { return b.bz }
+
+// storeBlock queues an executed block for the writer, it blocks when the
+// writer is blockWriteQueue blocks behind.
+func (state *State) storeBlock(block *types.Block, resp *types.ABCIExecutionResponse) {
+	if state.blockWrites == nil {
+		return
+	}
+	state.blockWrites <- &storedBlock{
+		view:     block.View,
+		hash:     block.Hash(),
+		bz:       block.ProtoBytes(),
+		receipts: resp.ProtoBytes(),
+	}
+}
+
+func (state *State) writeBlocks() {
+	defer close(state.writerDone)
+	for b := range state.blockWrites {
+		if err := state.store.SetBlockByHeight(b.view, state.chain_id, b); err != nil {
+			log.Error("block not stored", "block_view", b.view, "err", err)
+		} else if err := state.store.SetReceiptsByHeight(b.view, state.chain_id, b.receipts); err != nil {
+			log.Error("receipts not stored", "block_view", b.view, "err", err)
+		}
+	}
+}
+
+// Block reads the block of view from the store, blocks are stored once they
+// are executed if StoreBlocks is set.
+func (state *State) Block(view int64) (*types.Block, error) {
+	bz, err := state.store.GetBlockByHeight(view, state.chain_id)
+	if err != nil || len(bz) == 0 {
+		return nil, err
+	}
+	block := types.NewBlockFromBytes(bz)
+	if block == nil {
+		return nil, fmt.Errorf("block of view %d does not unmarshal", view)
+	}
+	return block, nil
 }
 
 func (state *State) fetch_block(pre_index int) *types.Block {
@@ -150,7 +323,7 @@ func (state *State) fetch_block(pre_index int) *types.Block {
 	return state.block_pool[state.block_pool_size()-pre_index]
 }
 func (state *State) append_block(block *types.Block) {
-	state.WriteCmd(fmt.Sprintf("Append Block: %d", block.Header.View))
+	state.viewLog().Debug("appending block", "block_view", block.Header.View)
 	if state.block_pool_size() == 7 {
 		state.block_pool = append(state.block_pool[1:], block)
 	} else {
@@ -170,6 +343,7 @@ func (state *State) Receive(channelID byte, bz []byte, messageType uint32) error
 			return err
 		}
 		//state.WriteCmd(fmt.Sprintf("Received Part: %d", part.Index()))
+		metrics.PartsReceived.Inc()
 		state.stateLock.Lock()
 		defer state.stateLock.Unlock()
 		err := state.doMessage(part)
@@ -182,7 +356,15 @@ func (state *State) Receive(channelID byte, bz []byte, messageType uint32) error
 		if err := csm.ValidateBasic(); err != nil {
 			return err
 		}
-		state.WriteCmd(fmt.Sprintf("Received CSM: %s", csm.SourceChain))
+		state.viewLog().Debug("received cross-shard message", "msg_type", "CrossShardMessage", "source_shard", csm.SourceChain)
+		// the QC is checked before taking the lock, shard_info never changes
+		shard, ok := state.shard_info.Shards[csm.SourceChain]
+		if !ok {
+			return fmt.Errorf("shard %s does not exist", csm.SourceChain)
+		}
+		if csm.AggVote == nil || !shard.VerifyAggregateSignature(string(csm.AggVote.Sign), csm.AggVote.SignBytes(), csm.AggVote.SignerIndexer.Byte()) {
+			return fmt.Errorf("invalid aggregated signature of shard %s", csm.SourceChain)
+		}
 		state.stateLock.Lock()
 		defer state.stateLock.Unlock()
 		err = state.doMessage(csm)
@@ -195,7 +377,7 @@ func (state *State) Receive(channelID byte, bz []byte, messageType uint32) error
 		if err := proposal.ValidateBasic(); err != nil {
 			return err
 		}
-		state.WriteCmd(fmt.Sprintf("Received Proposal: %d", proposal.ProposerIndex))
+		state.viewLog().Debug("received proposal", "msg_type", "Proposal", "proposer", proposal.ProposerIndex)
 		state.stateLock.Lock()
 		defer state.stateLock.Unlock()
 		err := state.doMessage(proposal)
@@ -209,16 +391,19 @@ func (state *State) Receive(channelID byte, bz []byte, messageType uint32) error
 			return err
 		}
 		//state.WriteCmd(fmt.Sprintf("Received Vote: %d", vote.ValidatorIndex))
-		state.stateLock.Lock()
-		defer state.stateLock.Unlock()
-		err := state.doMessage(vote)
-		return err
+		// signatures are checked in batches by the vote pool, which then
+		// calls deliverVote, so that the state lock is not held meanwhile
+		state.votePool.Submit(vote)
+		return nil
 	default:
 		return fmt.Errorf("Consensus State: Unknown Message Type (" + fmt.Sprint(messageType) + ")")
 	}
 }
 
 func (state *State) doMessage(msg interface{}) error {
+	if state.stopped {
+		return nil
+	}
 	switch msg := msg.(type) {
 	case *types.Part:
 		if msg.ChainID != state.chain_id {
@@ -240,11 +425,6 @@ func (state *State) doMessage(msg interface{}) error {
 	case *types.CrossShardMessage:
 		//fmt.Println("Received CrossShardMessage:", msg.SourceChain, "Status:", len(state.block_data.finished))
 		key := fmt.Sprintf("%s:%s", msg.SourceChain, msg.GetLastHash())
-		if _, ok := state.shard_info.Shards[msg.SourceChain]; !ok {
-			return fmt.Errorf("shard does not exists")
-		} //else if !shard.VerifyAggregateSignature(string(msg.AggVote.Sign), msg.AggVote.SignBytes(), msg.AggVote.SignerIndexer.Byte()) {
-		//return fmt.Errorf("bad Signature")
-		//}
 		if has, err := state.store.HasSpecial([]byte(key)); err != nil {
 			return err
 		} else if has {
@@ -256,23 +436,45 @@ func (state *State) doMessage(msg interface{}) error {
 		} else if _, ok := state.block_data.finished[msg.SourceChain]; !ok {
 			if bytes.Equal(state.block_data.lastHash[msg.SourceChain], msg.GetLastHash()) {
 				state.extendHash(msg)
+				state.publishStatus()
 				//fmt.Println("Received CrossShardMessage:", msg.SourceChain, "Status:", len(state.block_data.finished))
 			}
 		}
 	case *hotstuff.Vote:
-		if err := state.HotStuffState.AddVote(msg); err != nil {
+		if err := state.HotStuffState.AddVerifiedVote(msg); err != nil {
 			return err
 		}
+		state.view_votes++
 	default:
 		return fmt.Errorf("Unknown message type: %s", msg)
 	}
 	return state.handle_state_transition()
 }
 
+func (state *State) deliverVote(vote *hotstuff.Vote) {
+	state.stateLock.Lock()
+	defer state.stateLock.Unlock()
+	if err := state.doMessage(vote); err != nil {
+		state.viewLog().Warn("vote not added", "msg_type", "Vote", "validator", vote.ValidatorIndex, "err", err)
+	}
+}
+
+func (state *State) rejectVote(vote *hotstuff.Vote, err error) {
+	state.viewLog().Warn("vote rejected", "msg_type", "Vote", "validator", vote.ValidatorIndex, "err", err)
+}
+
 func (state *State) extendHash(msg *types.CrossShardMessage) {
+	if msg.SourceChain != state.chain_id {
+		// a message that came before the leader waited for it was not waited for
+		wait := time.Duration(0)
+		if !state.csm_wait_start.IsZero() {
+			wait = time.Since(state.csm_wait_start)
+		}
+		metrics.CrossShardWait.With(msg.SourceChain).Observe(wait.Seconds())
+	}
 	state.block_data.finished[msg.SourceChain] = msg
 	state.block_data.lastHash[msg.SourceChain] = msg.AggVote.ForHash
-	state.WriteCmd(fmt.Sprintf("Extend Hash(%s): %s -> %s", msg.SourceChain, hex.EncodeToString(types.GetLastHashOfAggVote(msg.AggVote)), hex.EncodeToString(msg.AggVote.ForHash)))
+	state.viewLog().Debug("extended hash", "source_shard", msg.SourceChain, "from", types.GetLastHashOfAggVote(msg.AggVote), "to", msg.AggVote.ForHash)
 }
 
 func (state *State) redo_CrossShardMessage() error {
@@ -288,9 +490,20 @@ func (state *State) redo_CrossShardMessage() error {
 		} else if csm, err := types.NewCrossShardMessageFromBytes(bz); err != nil {
 			return err
 		} else {
-			state.WriteCmd(fmt.Sprintf("Redo CSM: %s", csm.SourceChain))
+			state.viewLog().Debug("redoing cross-shard message", "source_shard", csm.SourceChain)
 			state.extendHash(csm)
 		}
 	}
 	return nil
 }
+
+// countBytes adds sent bytes to the bandwidth printed on Stop and to the
+// metrics. The caller holds bytesLock.
+func (state *State) countBytes(intra_shard, cross_shard_data, cooperation int) {
+	state.intra_shard_bytes += intra_shard
+	state.cross_shard_data_bytes += cross_shard_data
+	state.cooperation_bytes += cooperation
+	metrics.ConsensusBytes.With("intra_shard").Add(float64(intra_shard))
+	metrics.ConsensusBytes.With("cross_shard_data").Add(float64(cross_shard_data))
+	metrics.ConsensusBytes.With("cooperation").Add(float64(cooperation))
+}
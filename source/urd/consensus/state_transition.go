@@ -3,9 +3,12 @@ package consensus
 import (
 	"bytes"
 	"emulator/core/hotstuff"
+	"emulator/logger/txtrace"
 	"emulator/urd/consensus/constypes"
 	"emulator/urd/definition"
+	"emulator/urd/events"
 	"emulator/urd/types"
+	"emulator/utils/metrics"
 	"emulator/utils/signer"
 	"encoding/hex"
 	"fmt"
@@ -44,9 +47,11 @@ func (state *State) handle_state_transition() error {
 		// validate the block
 		// execution block in the last round
 		if state.block_data.isComplete(view, round) {
-			fmt.Println("valid block", view, round)
+			state.viewLog().Debug("block complete", "block_view", view, "block_round", round)
 			if block, err := state.block_data.getBlock(view, round); err != nil {
 				return err
+			} else if !state.checkQCs(block) {
+				return nil
 			} else if err := state.doValidate(block); err != nil {
 				return err
 			}
@@ -76,55 +81,100 @@ func (state *State) handle_state_transition() error {
 	}
 
 	state.step = state.next_step()
+	state.publishStatus()
 
-	if state.isProposer() && (state.EnablePipelineFlag && state.HotStuffState.View > 100 || state.HotStuffState.View > 600) {
-		state.Stop()
-		panic("Interupted")
+	if state.MaxViews > 0 && state.HotStuffState.View > state.MaxViews {
+		// the node stops driving consensus, InitNode shuts it down
+		state.viewLog().Info("max_views reached, stopping", "max_views", state.MaxViews)
+		state.finish()
+		return nil
 	}
 	return state.handle_state_transition()
 }
 
+// qcCheck is the result of verifying the QCs of a block.
+type qcCheck struct {
+	hash []byte
+	done bool
+	err  error
+}
+
+// checkQCs reports whether the QCs of block have been verified. Otherwise it
+// starts verifying them without the state lock, like the vote pool does for
+// votes, and the state transition is resumed once they are.
+func (state *State) checkQCs(block *types.Block) bool {
+	hash := block.Hash()
+	if check := state.qc_check; check != nil && bytes.Equal(check.hash, hash) {
+		return check.done
+	}
+	check := &qcCheck{hash: hash}
+	state.qc_check = check
+	go func() {
+		err := state.verifyQCs(block)
+		state.stateLock.Lock()
+		defer state.stateLock.Unlock()
+		check.done, check.err = true, err
+		if state.qc_check != check || state.stopped {
+			return
+		}
+		if err := state.handle_state_transition(); err != nil {
+			state.viewLog().Warn("state transition failed", "err", err)
+		}
+	}()
+	return false
+}
+
+// verifyQCs verifies the QC of the shard and the QCs of the other shards
+// that a block carries. It reads nothing guarded by the state lock.
+func (state *State) verifyQCs(block *types.Block) error {
+	if block.View < 5 {
+		return nil
+	}
+	aggSig := block.AggSigVote
+	if !state.verifier.VerifyAggregateSignature(string(aggSig.Sign), aggSig.SignBytes(), aggSig.SignerIndexer.Byte()) {
+		return fmt.Errorf("error: invalid block AggSig")
+	}
+	if block.CI == nil || len(block.CI.AggregatedSignatures) != len(state.shard_info.ShardIDList) {
+		return fmt.Errorf("error: not enough aggregated signatures")
+	}
+	for i, id := range state.shard_info.ShardIDList {
+		if id == state.chain_id {
+			continue
+		}
+		aggSig := block.CI.AggregatedSignatures[i]
+		shard := state.shard_info.Shards[id]
+		if aggSig == nil || !shard.VerifyAggregateSignature(string(aggSig.Sign), aggSig.SignBytes(), aggSig.SignerIndexer.Byte()) {
+			return fmt.Errorf("error: invalid block CI of shard %s", id)
+		}
+	}
+	return nil
+}
+
 func (state *State) verify_block(block *types.Block) error {
 	state.WriteLogger(fmt.Sprintf("validate block"), false, false)
-	state.WriteCmd(fmt.Sprintf("validate block: (view=%d,round=%d", state.HotStuffState.View, state.HotStuffState.Round))
+	state.viewLog().Debug("validating block", "block_view", block.View)
+	// 1. validate aggregated signatures, checkQCs has verified them
+	if err := state.qc_check.err; err != nil {
+		return err
+	}
 	if block.View < 5 {
 		return nil
 	}
-	// 1. validate aggregated vote
-	//shard := state.shard_info.Shards[state.chain_id]
 	aggSig := block.AggSigVote
-	/*
-		if ok := shard.VerifyAggregateSignature(string(aggSig.Sign), aggSig.SignBytes(), aggSig.SignerIndexer.Byte()); !ok {
-			return fmt.Errorf("error: invalid block AggSig")
-		}
-	*/
 	if !bytes.Equal(state.fetch_block(2).Hash(), types.GetLastHashOfAggVote(aggSig)) {
 		return fmt.Errorf("error: hash of j-2 block header does not comsistent")
 	}
 	if !bytes.Equal(state.fetch_block(1).Hash(), aggSig.ForHash) {
 		return fmt.Errorf("error: hash of j-1 block header does not comsistent")
 	}
-	state.WriteCmd("valid aggregated signature")
+	state.viewLog().Debug("valid aggregated signature")
 
 	// 2. validate commitment intention
 	ci := block.CI
 	if len(ci.IntentionHash) != len(state.shard_info.ShardIDList) {
 		return fmt.Errorf("error: not enough intention hash")
 	}
-	for _, id := range state.shard_info.ShardIDList {
-		if id == state.chain_id {
-			continue
-		}
-		//aggSig := ci.AggregatedSignatures[i]
-		//shard := state.shard_info.Shards[id]
-		/*
-			if ok := shard.VerifyAggregateSignature(string(aggSig.Sign), aggSig.SignBytes(), aggSig.SignerIndexer.Byte()); !ok {
-				return fmt.Errorf("error: invalid block CI")
-			}
-		*/
-
-	}
-	state.WriteCmd("valid Commitment Intention")
+	state.viewLog().Debug("valid commitment intention")
 
 	// 3. validate commitment certificate
 	cc := block.CC
@@ -142,7 +192,7 @@ func (state *State) verify_block(block *types.Block) error {
 	return nil
 }
 
-func generate_vote_for_block(block *types.Block, lastHash []byte, blockErr error, index int, sr *signer.Signer) (*hotstuff.Vote, error) {
+func generate_vote_for_block(block *types.Block, lastHash []byte, blockErr error, index int, sr signer.Signer) (*hotstuff.Vote, error) {
 	vote := hotstuff.NewVote(block.View, block.Round, block.Hash(), nil, index)
 	if blockErr == nil {
 		vote.SetOK()
@@ -161,7 +211,7 @@ func generate_vote_for_block(block *types.Block, lastHash []byte, blockErr error
 func (state *State) doValidate(block *types.Block) error {
 	blockErr := state.verify_block(block)
 	if blockErr != nil {
-		state.WriteCmd(fmt.Sprintf("block validation failed: %s", blockErr))
+		state.viewLog().Warn("block validation failed", "block_view", block.View, "err", blockErr)
 	}
 	// block of j-1
 	lastBlock := state.fetch_block(1)
@@ -181,7 +231,7 @@ func (state *State) doValidate(block *types.Block) error {
 	}
 
 	state.bytesLock.Lock()
-	state.intra_shard_bytes += len(vote.ProtoBytes())
+	state.countBytes(len(vote.ProtoBytes()), 0, 0)
 	state.bytesLock.Unlock()
 
 	state.SendTo(state.chain_id, state.proposerIndex, vote.ProtoBytes(), definition.Vote)
@@ -193,18 +243,60 @@ func (state *State) commit_and_execution_j_2() (types.ABCIExecutionResponse, err
 	if block_j_2 := state.fetch_block(2); block_j_2 != nil {
 		// execution TXs of voting round j-2
 		// execution CTXs of voting round j-6, whose merkle root is included in block j-2 as a Commitment Certificate
-		state.WriteCmd(fmt.Sprintf("start to execute block for view %d", block_j_2.View))
-		resp := state.abci.Execution(block_j_2.PTXS, block_j_2.CrossShardTxs, block_j_2.CTXS)
-		state.WriteLogger(fmt.Sprintf("finish[%d,%d,%d]", block_j_2.PTXS.Size(), block_j_2.CrossShardTxs.Size()/2, block_j_2.CrossShardTxs.Size()/2), false, true)
+		state.viewLog().Info("executing block", "block_view", block_j_2.View)
+		resp, err := state.abci.Execution(block_j_2.View, block_j_2.PTXS, block_j_2.CrossShardTxs, block_j_2.CTXS)
+		if err != nil {
+			return types.ABCIExecutionResponse{}, err
+		}
+		// a cross-shard tx counts at each of its shards for its share of
+		// them, once decided
+		commit, count := state.decided.take(resp.DecisionShares())
+		state.WriteFinish(block_j_2.View, block_j_2.PTXS.Size(), commit, count)
+		// kept by view for the RPC and the latency tool, written by
+		// writeBlocks without the lock
+		state.storeBlock(block_j_2, resp)
+		if events.Active() {
+			publishBlock(block_j_2, resp)
+		}
 		return *resp, nil
 	}
 	return types.ABCIExecutionResponse{}, nil
 }
 
+// publishBlock tells the subscribers that block was committed and executed
+// into resp.
+func publishBlock(block *types.Block, resp *types.ABCIExecutionResponse) {
+	info := &events.BlockInfo{
+		Hash:          hex.EncodeToString(block.Hash()),
+		Txs:           block.PTXS.Size(),
+		CrossShardTxs: block.CrossShardTxs.Size(),
+	}
+	for _, receipt := range resp.Responses {
+		if !receipt.IsOK() {
+			info.Aborted++
+		}
+	}
+	info.CrossShardCommitted, info.CrossShardAborted = resp.Decisions()
+	for _, ctxs := range block.CTXS {
+		info.RelayedTxs += ctxs.Size()
+	}
+	events.Publish(events.Event{Type: events.Block, Shard: block.ChainID, View: block.View, Block: info})
+}
+
 func (state *State) enterNextView() {
+	if !state.view_start.IsZero() {
+		metrics.ViewDuration.ObserveSince(state.view_start)
+		if state.isProposer() {
+			metrics.VotesPerView.Observe(float64(state.view_votes))
+		}
+	}
+	state.view_start, state.view_votes, state.csm_wait_start = time.Now(), 0, time.Time{}
 	state.block_data.next(state.HotStuffState.View+1, 0)
 	state.HotStuffState.EnterNewView()
+	metrics.View.Set(float64(state.HotStuffState.View))
+	events.Publish(events.Event{Type: events.View, Shard: state.chain_id, View: state.HotStuffState.View})
 	state.WriteLogger("START", true, false)
+	state.publishStatus()
 }
 
 func (state *State) doPropose() error {
@@ -215,15 +307,24 @@ func (state *State) doPropose() error {
 	state.enterNextView()
 
 	if state.HotStuffState.View == 1 {
-		time.Sleep(10 * time.Second)
+		time.Sleep(state.FirstBlockDelay)
 	}
+	if wait := time.Until(state.last_propose.Add(state.MinBlockInterval)); wait > 0 {
+		time.Sleep(wait)
+	}
+	state.last_propose = time.Now()
 
 	new_block := state.make_block(resp)
-	partset, err := state.block_data.addBlock(new_block, 40960, state.HotStuffState.Round)
+	partset, err := state.block_data.addBlock(new_block, state.MaxPartSize, state.HotStuffState.Round)
 	if err != nil {
 		return err
 	}
-	state.HotStuffState.SetHash(new_block.Hash())
+	// the validators vote with the hash of block j-1, see doValidate
+	var lastHash []byte
+	if lastBlock := state.fetch_block(1); lastBlock != nil {
+		lastHash = lastBlock.Hash()
+	}
+	state.HotStuffState.SetHash(new_block.Hash(), [][]byte{lastHash})
 
 	proposal := constypes.NewProposal(partset.Header, state.signerIndex, partset.BlockHeaderHash)
 	sig, err := state.signer.SignType(proposal)
@@ -241,13 +342,11 @@ func (state *State) doPropose() error {
 		for i := range new_block.CTXS {
 			cross_shard_bytes += len(types.MustProtoBytes(new_block.CTXS[i].ToProto())) + len(types.MustProtoBytes(new_block.CTXSProof[i].ToProto()))
 		}
-		state.cross_shard_data_bytes += cross_shard_bytes
-		state.intra_shard_bytes += len(proposalBz) - cross_shard_bytes - coo_bytes
-		state.cooperation_bytes += coo_bytes
+		state.countBytes(len(proposalBz)-cross_shard_bytes-coo_bytes, cross_shard_bytes, coo_bytes)
 		state.SendToShard(state.chain_id, proposalBz, definition.Proposal)
 		for _, part := range partset.Parts {
 			state.SendToShard(state.chain_id, part.ProtoBytes(), definition.Part)
-			state.intra_shard_bytes += len(part.ProtoBytes())
+			state.countBytes(len(part.ProtoBytes()), 0, 0)
 		}
 	}()
 
@@ -267,7 +366,7 @@ func (state *State) doPropose() error {
 }
 
 func (state *State) make_block(execution_result types.ABCIExecutionResponse) *types.Block {
-	state.WriteCmd(fmt.Sprintf("start to generate a block for view %d", state.HotStuffState.View))
+	state.viewLog().Debug("generating block")
 	var block types.Block
 	lastBlock := state.fetch_block(1)
 	lastHash := lastBlock.Hash()
@@ -287,9 +386,9 @@ func (state *State) make_block(execution_result types.ABCIExecutionResponse) *ty
 		block.AggSigVote = state.block_data.j_1finished[state.chain_id].AggVote
 	}
 
-	state.WriteCmd(fmt.Sprintf("max_intra_shard: %d KB | max_cross_shard: %d KB", state.max_bytes/1024, state.max_cross_shard_bytes/1024))
+	state.viewLog().Debug("block size limits", "max_intra_shard_bytes", state.max_bytes, "max_cross_shard_bytes", state.max_cross_shard_bytes)
 	// PTXS
-	if state.EnablePipelineFlag || state.HotStuffState.View%6 == 0 {
+	if state.HotStuffState.View%state.PipelineStride == 0 {
 		if txs, _, err := state.mempool.ReapTx(state.max_bytes); err != nil {
 			return nil
 		} else {
@@ -346,11 +445,22 @@ func (state *State) make_block(execution_result types.ABCIExecutionResponse) *ty
 		block.CTXSProof = ctxs_proof
 	}
 
+	if txtrace.Enabled() {
+		for _, tx := range block.PTXS {
+			txtrace.Record(types.TxHash(tx), txtrace.Event{Stage: txtrace.Included})
+		}
+		for _, tx := range block.CrossShardTxs {
+			txtrace.Record(types.TxHash(tx), txtrace.Event{Stage: txtrace.Included, Cross: true})
+		}
+	}
+
 	// must call block.Hash() to ensure to fill in all the hashes
-	state.WriteCmd(fmt.Sprintf("successfully generate a block(hash=%s,itxs=%d,ctxs=%d)", hex.EncodeToString(block.Hash()), block.PTXS.Size(), block.CrossShardTxs.Size()))
+	state.viewLog().Info("generated block", "hash", block.Hash(), "itxs", block.PTXS.Size(), "ctxs", block.CrossShardTxs.Size())
 	return &block
 }
 func (state *State) CrossShardCommunicate() error {
+	// the leader has a quorum and now waits for the other shards
+	state.csm_wait_start = time.Now()
 	if err := state.redo_CrossShardMessage(); err != nil {
 		return err
 	}
@@ -393,17 +503,25 @@ func (state *State) CrossShardCommunicate() error {
 			csm.OPTXs = txs
 			csm.OutputTxsProof = proof
 		}
-		if id := state.shard_info.ShardIDList[index]; id == state.chain_id {
+		id := state.shard_info.ShardIDList[index]
+		if txtrace.Enabled() || events.Active() {
+			for _, relayTx := range csm.OPTXs {
+				hash := state.abci.RelayTxHash(relayTx)
+				txtrace.Record(hash, txtrace.Event{Stage: txtrace.Relayed, Cross: true, Peer: id})
+				events.Publish(events.Event{Type: events.Tx, Shard: state.chain_id, Tx: hex.EncodeToString(hash),
+					Status: events.Relayed, Peer: id})
+			}
+		}
+		if id == state.chain_id {
 			state.extendHash(&csm)
 		} else {
 			bz := csm.ProtoBytes()
 			state.bytesLock.Lock()
 			cs_bytes := len(types.MustProtoBytes(csm.OPTXs.ToProto()))
 			coo_byte := len(bz) - cs_bytes
-			fmt.Println("Broadcasting CrossShardMessage to shard", id, "with bytes:", len(bz), "cross_shard_data_bytes:", cs_bytes, "cooperation_bytes:", coo_byte)
-			fmt.Println("CrossShardMessage OPTXs size:", csm.OPTXs.Size())
-			state.cross_shard_data_bytes += cs_bytes
-			state.cooperation_bytes += coo_byte
+			state.viewLog().Debug("broadcasting cross-shard message", "msg_type", "CrossShardMessage", "target_shard", id,
+				"bytes", len(bz), "cross_shard_data_bytes", cs_bytes, "cooperation_bytes", coo_byte, "optxs", csm.OPTXs.Size())
+			state.countBytes(0, cs_bytes, coo_byte)
 			state.bytesLock.Unlock()
 			state.SendTo(id, state.shard_info.Shards[id].LeaderIndex, bz, definition.CrossShardMessage)
 		}
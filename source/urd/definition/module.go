@@ -3,6 +3,7 @@ package definition
 import (
 	"emulator/urd/types"
 	"emulator/utils/p2p"
+	"emulator/utils/status"
 )
 
 type MempoolConn interface {
@@ -10,15 +11,21 @@ type MempoolConn interface {
 	ReapTx(maxBytes int) (types.Txs, int, error)
 	Update(txs types.Txs, commitStatus []byte) error
 	RemoveTx(tx []byte) error
+	Size() int
 
 	p2p.Reactor
 }
 
 type ABCIConn interface {
 	ValidateTx(tx []byte, isCrossShard bool) bool
+	// RelayTxHash is the hash of the cross-shard tx a relayed tx is for
+	RelayTxHash(relayTx []byte) []byte
+	// TxResult and Account answer the queries of the RPC
+	TxResult(hash []byte) (*types.TxResult, bool)
+	Account(key string) (*types.Account, error)
 
-	// execution and commit
-	Execution(types.Txs, types.Txs, []types.Txs) *types.ABCIExecutionResponse
+	// execution and commit, of the block of a view
+	Execution(view int64, txs types.Txs, crossShardTxs types.Txs, ctxs []types.Txs) (*types.ABCIExecutionResponse, error)
 	Commit() []byte
 
 	Stop()
@@ -28,4 +35,9 @@ type ConsensusConn interface {
 	p2p.Reactor
 	Start()
 	Stop()
+	// Done is closed once the node has run for the configured length
+	Done() <-chan struct{}
+	Status() status.Consensus
+	// Block is the block of view once it is executed, nil if there is none
+	Block(view int64) (*types.Block, error)
 }
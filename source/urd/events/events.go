@@ -0,0 +1,181 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// What a node sees happen, pushed to the subscribers whose filter matches:
+// the blocks it commits, the receipts of intra-shard txs, the transitions of
+// cross-shard txs and its views. Nothing is built while no one subscribes,
+// publishers check Active first.
+
+type Type string
+
+const (
+	// Block is a block the shard committed and executed
+	Block Type = "block"
+	// Receipt is the ABCIExecutionReceipt of an intra-shard tx
+	Receipt Type = "receipt"
+	// Tx is a cross-shard tx reaching a Status
+	Tx Type = "tx"
+	// View is the node entering a view
+	View Type = "view"
+)
+
+// Relayed is the status of a tx event when the shard sends the relay of the
+// tx to Peer. The other statuses are those of types.TxResult: a cross-shard
+// tx is locked and relayed by every shard it touches before it is committed
+// or aborted.
+const Relayed = "relayed"
+
+type Event struct {
+	Type  Type      `json:"type"`
+	Shard string    `json:"shard"`
+	Time  time.Time `json:"time"`
+	View  int64     `json:"view,omitempty"`
+
+	// Tx is the hash of the tx of a receipt or tx event, in hex
+	Tx string `json:"tx,omitempty"`
+	// Accounts are the accounts the tx touches, unknown for a relayed tx
+	Accounts []string `json:"accounts,omitempty"`
+	// Shards are the shards the tx touches
+	Shards []string `json:"shards,omitempty"`
+
+	// Status and Reason are those of the tx at the shard
+	Status string `json:"status,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// Peer is the shard a relay is sent to
+	Peer string `json:"peer,omitempty"`
+
+	Receipt *ReceiptInfo `json:"receipt,omitempty"`
+	Block   *BlockInfo   `json:"block,omitempty"`
+}
+
+type ReceiptInfo struct {
+	Code int8   `json:"code"`
+	Info string `json:"info,omitempty"`
+}
+
+type BlockInfo struct {
+	Hash          string `json:"hash"`
+	Txs           int    `json:"txs"`
+	Aborted       int    `json:"aborted"`
+	CrossShardTxs int    `json:"cross_shard_txs"`
+	RelayedTxs    int    `json:"relayed_txs"`
+	// the cross-shard txs the block decided, of any earlier block
+	CrossShardCommitted int `json:"cross_shard_committed"`
+	CrossShardAborted   int `json:"cross_shard_aborted"`
+}
+
+// Filter picks the events of a subscription. Events must be of one of Types,
+// any type if empty, and if Txs or Accounts are given, about one of those
+// txs or accounts: block and view events then never match.
+type Filter struct {
+	Types    map[Type]bool
+	Txs      map[string]bool
+	Accounts map[string]bool
+}
+
+func (f *Filter) Match(e *Event) bool {
+	if len(f.Types) > 0 && !f.Types[e.Type] {
+		return false
+	}
+	if len(f.Txs) == 0 && len(f.Accounts) == 0 {
+		return true
+	}
+	if f.Txs[e.Tx] {
+		return true
+	}
+	for _, account := range e.Accounts {
+		if f.Accounts[account] {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrSlow ends a subscription whose buffer is full, a publisher never waits
+// for a subscriber.
+var ErrSlow = errors.New("subscriber too slow, events were dropped")
+
+type Subscription struct {
+	filter Filter
+	out    chan *Event
+	err    error
+}
+
+// Events are the events of the subscription, closed when it ends.
+func (s *Subscription) Events() <-chan *Event { return s.out }
+
+// Err is why the subscription ended, nil if it was closed.
+func (s *Subscription) Err() error {
+	bus.mtx.RLock()
+	defer bus.mtx.RUnlock()
+	return s.err
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() { bus.remove(s, nil) }
+
+type hub struct {
+	mtx  sync.RWMutex
+	subs map[*Subscription]bool
+	n    atomic.Int32
+}
+
+var bus = &hub{subs: map[*Subscription]bool{}}
+
+// Active tells whether anyone subscribes, to skip building events otherwise.
+func Active() bool { return bus.n.Load() > 0 }
+
+// Subscribe starts a subscription to the events matching filter, up to
+// buffer of them wait for the subscriber.
+func Subscribe(filter Filter, buffer int) *Subscription {
+	s := &Subscription{filter: filter, out: make(chan *Event, buffer)}
+	bus.mtx.Lock()
+	defer bus.mtx.Unlock()
+	bus.subs[s] = true
+	bus.n.Add(1)
+	return s
+}
+
+// Publish sends the event to the subscribers it matches, its time is filled
+// in.
+func Publish(e Event) {
+	if !Active() {
+		return
+	}
+	e.Time = time.Now()
+	var slow []*Subscription
+	bus.mtx.RLock()
+	for s := range bus.subs {
+		if !s.filter.Match(&e) {
+			continue
+		}
+		select {
+		case s.out <- &e:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	bus.mtx.RUnlock()
+	for _, s := range slow {
+		bus.remove(s, ErrSlow)
+	}
+}
+
+// remove ends the subscription s with err, unless it already ended.
+func (h *hub) remove(s *Subscription, err error) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if !h.subs[s] {
+		return
+	}
+	delete(h.subs, s)
+	h.n.Add(-1)
+	s.err = err
+	close(s.out)
+}
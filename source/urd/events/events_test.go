@@ -0,0 +1,42 @@
+package events
+
+import "testing"
+
+func TestSubscribe(t *testing.T) {
+	if Active() {
+		t.Fatal("active without subscribers")
+	}
+	all := Subscribe(Filter{}, 8)
+	accounts := Subscribe(Filter{Types: map[Type]bool{Receipt: true, Tx: true}, Accounts: map[string]bool{"10a": true}}, 8)
+	slow := Subscribe(Filter{Types: map[Type]bool{View: true}}, 1)
+
+	Publish(Event{Type: View, View: 1})
+	Publish(Event{Type: View, View: 2})
+	Publish(Event{Type: Receipt, Tx: "aa", Accounts: []string{"10b", "10a"}})
+	Publish(Event{Type: Tx, Tx: "bb", Accounts: []string{"10c"}})
+	Publish(Event{Type: Block, View: 2})
+
+	if n := len(all.Events()); n != 5 {
+		t.Fatalf("unfiltered subscription got %d events", n)
+	}
+	if n := len(accounts.Events()); n != 1 {
+		t.Fatalf("account subscription got %d events", n)
+	}
+	if e := <-accounts.Events(); e.Tx != "aa" || e.Time.IsZero() {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	// the second view did not fit
+	if e := <-slow.Events(); e.View != 1 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if _, ok := <-slow.Events(); ok || slow.Err() != ErrSlow {
+		t.Fatalf("slow subscription not ended, err %v", slow.Err())
+	}
+
+	all.Close()
+	accounts.Close()
+	accounts.Close()
+	if Active() || accounts.Err() != nil {
+		t.Fatalf("active after closing, err %v", accounts.Err())
+	}
+}
@@ -4,119 +4,57 @@ import (
 	"emulator/urd/abci/minibank"
 	"emulator/urd/shardinfo"
 	"emulator/utils"
+	"emulator/utils/config"
+	"emulator/utils/genesis"
+	"emulator/utils/keystore"
 	"emulator/utils/p2p"
 	"emulator/utils/signer"
+	"emulator/utils/topology"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sort"
+	"time"
 )
 
-const (
-	privateKeyPath = "config/private_key.txt"
-	shardInfoPath  = "config/shard_info.json"
-	configPath     = "config/config.toml"
-	configDir      = "config"
-	storeDir       = "database"
-	datasetDir     = "dataset"
-)
-
-const (
-	defaultMinBlockInterval          = "10ms"
-	defaultMaxBlockPartSize          = 200 * 1024 // 20 KB
-	defaultMaxBlockTxBytes           = 20 * 1024
-	defaultMaxBlockCrossShardTxBytes = 160 * 1024
-	defaultProtocal                  = "tendermint"
-	defaultABCI                      = "minibank"
-)
-
-type Config struct {
-	NodeName string
-	DirRoot  string
-	ChainID  string
-
-	// P2P
-	LocalIP   string
-	LocalPort int
+// Config is the config.toml of a node, see emulator/utils/config.
+type Config = config.Config
 
-	// consensus
-	Protocal                                   string
-	MinBlockInterval                           string
-	MaxPartSize                                int
-	MaxBlockTxBytes, MaxBlockCrossShardTxBytes int
-
-	SignerIndex int
-	IsLeader    bool
-
-	// abci
-	ABCIApp string
+// GetConfig loads a config.toml of urd with the environment and overrides
+// applied.
+func GetConfig(path string, overrides ...string) (*Config, error) {
+	return config.Load(path, genesis.ProtocolUrd, overrides)
 }
 
-func (c *Config) PrivateKeyPath() string { return filepath.Join(c.DirRoot, privateKeyPath) }
-func (c *Config) ShardInfoPath() string  { return filepath.Join(c.DirRoot, shardInfoPath) }
-func (c *Config) StoreDirRoot() string   { return filepath.Join(c.DirRoot, storeDir) }
-func (c *Config) ConfigPath() string     { return filepath.Join(c.DirRoot, configPath) }
-func (c *Config) ConfigDir() string      { return filepath.Join(c.DirRoot, configDir) }
-func (c *Config) DatasetDir() string     { return filepath.Join(c.DirRoot, datasetDir) }
-
-func GenerateConfigFiles(shard_config_path string, store_dir string) {
-	shardConfig := new(ShardConfig)
-	if err := shardConfig.ReadJSONFromFile(shard_config_path); err != nil {
+func GenerateConfigFiles(shard_config_path string, store_dir string, workload_path string, dataset_format string, passphrase string, genesisTime time.Time, overrides []string) {
+	shardConfig, err := topology.Load(shard_config_path, genesis.ProtocolUrd)
+	if err != nil {
 		panic(err)
 	}
-
-	// Assign IP addresses and port numbers through roulette wheel
-	IPPortToUse := map[string]int{}
-	IPs := []string{}
-	IPGun := []string{}
-	IPGunPointer := 0
-	maxCounter := -1
-	for key, value := range shardConfig.IPInUse {
-		IPs = append(IPs, key)
-		IPPortToUse[key] = 26601
-		if int(value) > maxCounter {
-			maxCounter = int(value)
-		}
-	}
-	sort.Strings(IPs)
-	for i := 0; i < maxCounter; i++ {
-		for _, ip := range IPs {
-			if shardConfig.IPInUse[ip] > uint32(i) {
-				IPGun = append(IPGun, ip)
-			}
-		}
-	}
-	GetIP := func() (string, int) {
-		if IPGunPointer == len(IPGun) {
-			IPGunPointer = 0
-		}
-		theip := IPGun[IPGunPointer]
-		theport := IPPortToUse[theip]
-		IPPortToUse[theip] = theport + 1
-		IPGunPointer++
-		return theip, theport
+	workload, err := resolveWorkload(shardConfig, workload_path)
+	if err != nil {
+		panic(err)
 	}
 
 	// calculate node nums
-	totalNodes := 0
-	for _, si := range shardConfig.Shards {
-		totalNodes += int(si.PeerNum)
-	}
+	totalNodes := int(shardConfig.Nodes())
 
 	// generate publicKeys, privKeys and corresponding IP
-	publicKeys := make([]string, totalNodes)
-	privateKeys := make([]string, totalNodes)
-	ipList := make([]string, totalNodes)
-	portList := make([]int, totalNodes)
-	for i := 0; i < totalNodes; i++ {
-		priveKey, pubkey, err := signer.NewBLSKeyPair(signer.BaseCurve)
+	publicKeys := make([]string, 0, totalNodes)
+	privateKeys := make([]string, 0, totalNodes)
+	pops := make([]string, 0, totalNodes)
+	addrs := shardConfig.Addresses()
+	schemes := make(map[string]signer.SchemeParams)
+	for _, si := range shardConfig.Shards {
+		keys, err := signer.GenerateKeys(si.SignScheme, int(si.PeerNum), si.Threshold)
 		if err != nil {
-			panic(err)
+			panic(fmt.Errorf("shard %s: %v", si.ChainID, err))
 		}
-		publicKeys[i] = pubkey
-		privateKeys[i] = priveKey
-		ipList[i], portList[i] = GetIP()
+		publicKeys = append(publicKeys, keys.PublicKeys...)
+		privateKeys = append(privateKeys, keys.PrivateKeys...)
+		pops = append(pops, keys.Pops...)
+		schemes[si.ChainID] = keys.Params
 	}
 
 	// generate ShardInfo
@@ -125,18 +63,51 @@ func GenerateConfigFiles(shard_config_path string, store_dir string) {
 	count := 0
 	for _, si := range shardConfig.Shards {
 		for i := 0; i < int(si.PeerNum); i++ {
-			peer, err := p2p.NewPeer(fmt.Sprintf("%s:%d", ipList[count], portList[count]),
+			peer, err := p2p.NewPeer(addrs[count].String(),
 				map[string]bool{si.ChainID: true},
 				publicKeys[count], 1)
 			if err != nil {
 				panic(err)
 			}
+			peer.Pop = pops[count]
 			PeerList[si.ChainID] = append(PeerList[si.ChainID], peer)
 			count++
 		}
 		keyRangeMap[si.ChainID] = si.KeyRange
 	}
-	var shard_info *shardinfo.ShardInfo = shardinfo.NewShardInfo(PeerList, 0, keyRangeMap)
+	// the first node of every shard is its leader
+	var shard_info *shardinfo.ShardInfo = shardinfo.NewShardInfo(PeerList, 0, keyRangeMap, schemes)
+	gen := &genesis.Genesis{
+		GenesisTime: genesisTime,
+		Protocol:    genesis.ProtocolUrd,
+		InitialState: &genesis.InitialState{
+			Balance:          minibank.DefaultInitialBalance,
+			AccountsPerShard: workload.AccountsPerShard,
+			SignedTxs:        workload.Signed,
+		},
+	}
+	rls := map[string]*utils.RangeList{}
+	for s, v := range keyRangeMap {
+		rls[s] = utils.NewRangeListFromString(v)
+	}
+	// the genesis binds the accounts of every shard to the keys the workload
+	// signs with
+	owners := map[string][]byte{}
+	for _, si := range shardConfig.Shards {
+		shard := si.GenesisShard()
+		shard.SignScheme = schemes[si.ChainID].Name()
+		shard.Validators = genesis.NewValidators(PeerList[si.ChainID])
+		if workload.Signed {
+			owners[si.ChainID], shard.Owners = minibank.OwnersFile(rls[si.ChainID], workload.AccountsPerShard, minibank.NewKeys(workload.KeySeed))
+		}
+		gen.Shards = append(gen.Shards, shard)
+	}
+
+	// every config starts from the defaults with overrides applied
+	base, err := config.DefaultWith(genesis.ProtocolUrd, overrides)
+	if err != nil {
+		panic(err)
+	}
 
 	// generate config
 	configList := make([]*Config, totalNodes)
@@ -144,36 +115,32 @@ func GenerateConfigFiles(shard_config_path string, store_dir string) {
 	for _, si := range shardConfig.Shards {
 		for i := 0; i < int(si.PeerNum); i++ {
 			nodeName := fmt.Sprintf("node%d", count+1)
-			dirRoot := filepath.Join(store_dir, ipList[count], nodeName)
-			configList[count] = &Config{
-				DirRoot:  dirRoot,
-				NodeName: nodeName,
-				ChainID:  si.ChainID,
-
-				LocalIP:   ipList[count],
-				LocalPort: portList[count],
-
-				Protocal:                  defaultProtocal,
-				MinBlockInterval:          defaultMinBlockInterval,
-				MaxPartSize:               defaultMaxBlockPartSize,
-				MaxBlockTxBytes:           defaultMaxBlockTxBytes,
-				MaxBlockCrossShardTxBytes: defaultMaxBlockCrossShardTxBytes,
-
-				SignerIndex: i,
-				IsLeader:    i == 0,
-
-				ABCIApp: defaultABCI,
+			dirRoot := filepath.Join(store_dir, addrs[count].IP, nodeName)
+			cfg := new(Config)
+			*cfg = *base
+			cfg.DirRoot = dirRoot
+			cfg.NodeName = nodeName
+			cfg.ChainID = si.ChainID
+			cfg.P2P.IP = addrs[count].IP
+			cfg.P2P.Port = addrs[count].Port
+			cfg.Consensus.SignerIndex = i
+			if err := cfg.Validate(); err != nil {
+				panic(err)
 			}
+			configList[count] = cfg
 			count++
 		}
 	}
 
-	rls := map[string]*utils.RangeList{}
-	for s, v := range keyRangeMap {
-		rls[s] = utils.NewRangeListFromString(v)
+	generator := minibank.NewImportorForGenerator(rls, workload)
+	// every leader gets the same dataset, it is generated once and copied
+	dataset := ""
+
+	gen.Params = configList[0].GenesisParams()
+	if err := gen.Validate(); err != nil {
+		panic(err)
 	}
-	generator := minibank.NewImportorForGenerator(rls)
-	txs := generator.GenerateTxs()
+	fmt.Println("genesis time:", gen.GenesisTime.Format(time.RFC3339))
 
 	for i, cfg := range configList {
 		if err := os.MkdirAll(cfg.StoreDirRoot(), os.ModePerm); err != nil {
@@ -182,45 +149,90 @@ func GenerateConfigFiles(shard_config_path string, store_dir string) {
 		if err := os.MkdirAll(cfg.ConfigDir(), os.ModePerm); err != nil {
 			panic(err)
 		}
-		privKey := privateKeys[i]
 		if str, err := json.MarshalIndent(shard_info, "", "    "); err != nil {
 			panic(err)
 		} else if err2 := os.WriteFile(cfg.ShardInfoPath(), []byte(str), 0666); err2 != nil {
 			panic(err)
 		}
-		if err := os.WriteFile(cfg.PrivateKeyPath(), []byte(privKey), 0666); err != nil {
+		scheme := schemes[cfg.ChainID].Name()
+		if kf, err := keystore.Encrypt(privateKeys[i], scheme, publicKeys[i], passphrase); err != nil {
+			panic(err)
+		} else if err := keystore.Write(cfg.PrivateKeyPath(), kf); err != nil {
+			panic(err)
+		}
+		if err := cfg.Write(cfg.ConfigPath()); err != nil {
 			panic(err)
 		}
-		if err := cfg.StoreConfig(cfg.ConfigPath()); err != nil {
+		if err := gen.Write(cfg.GenesisPath()); err != nil {
 			panic(err)
 		}
+		if bz, ok := owners[cfg.ChainID]; ok {
+			if err := os.WriteFile(filepath.Join(cfg.ConfigDir(), minibank.OwnersFileName), bz, 0666); err != nil {
+				panic(err)
+			}
+		}
 
-		if cfg.IsLeader {
+		if cfg.Consensus.SignerIndex == shard_info.Shards[cfg.ChainID].LeaderIndex {
 			if err := os.MkdirAll(cfg.DatasetDir(), os.ModePerm); err != nil {
 				panic(err)
 			}
 
-			if err := writeStringsToFile(txs, filepath.Join(cfg.DatasetDir(), "dataset.txt")); err != nil {
+			path := filepath.Join(cfg.DatasetDir(), minibank.DatasetFileName(dataset_format))
+			if dataset == "" {
+				if err := generator.GenerateDataset(path, dataset_format); err != nil {
+					panic(err)
+				}
+				dataset = path
+			} else if err := copyFile(dataset, path); err != nil {
+				panic(err)
+			}
+			if err := workload.WriteFile(filepath.Join(cfg.DatasetDir(), minibank.WorkloadFileName)); err != nil {
 				panic(err)
 			}
 		}
 	}
 }
 
+// resolveWorkload picks the --workload file over the workload section of the
+// shard config, and fixes the seed so that it can be recorded.
+func resolveWorkload(shardConfig *ShardConfig, workload_path string) (*minibank.Workload, error) {
+	workload := minibank.DefaultWorkload()
+	if workload_path != "" {
+		w, err := minibank.LoadWorkload(workload_path)
+		if err != nil {
+			return nil, err
+		}
+		workload = w
+	} else if len(shardConfig.Workload) > 0 {
+		workload = new(minibank.Workload)
+		if err := json.Unmarshal(shardConfig.Workload, workload); err != nil {
+			return nil, fmt.Errorf("workload: %v", err)
+		}
+	}
+	if err := workload.Validate(); err != nil {
+		return nil, err
+	}
+	if workload.Seed == 0 {
+		workload.Seed = time.Now().UnixNano()
+	}
+	fmt.Println("workload:", workload)
+	return workload, nil
+}
+
 // =========================================================================
-func writeStringsToFile(strs []string, filePath string) error {
-	file, err := os.Create(filePath)
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	for _, str := range strs {
-		_, err := file.WriteString(str + "\n")
-		if err != nil {
-			return err
-		}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
 }
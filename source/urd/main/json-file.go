@@ -1,53 +1,14 @@
 package main
 
-import (
-	"emulator/utils"
-	"encoding/json"
-	"os"
-)
+import "emulator/utils/topology"
 
-type ShardConfig struct {
-	IPInUse map[string]uint32 `json:"ip_in_use"`
-	Shards  []ShardInfo       `json:"shards"`
-}
-
-type ShardInfo struct {
-	ChainID  string `json:"chain_id"`
-	PeerNum  uint32 `json:"peer_num"`
-	KeyRange string `json:"key_range"`
-}
+// ShardConfig is the topology file --config points to, pyramid reads the
+// same file, see emulator/utils/topology.
+type ShardConfig = topology.Topology
 
-func (cfg *ShardConfig) SerializeToJSON() ([]byte, error) {
-	return json.MarshalIndent(cfg, "", "    ")
-}
-
-func (cfg *ShardConfig) DeserializeFromJSON(data []byte) error {
-	err := json.Unmarshal(data, cfg)
-	return err
-}
-
-func (cfg *ShardConfig) WriteJSONToFile(filePath string) error {
-	data, err := cfg.SerializeToJSON()
-	if err != nil {
-		return err
-	}
-	err = os.WriteFile(filePath, data, 0666)
-	return err
-}
-
-func (cfg *ShardConfig) ReadJSONFromFile(filePath string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return err
-	}
-	err = cfg.DeserializeFromJSON(data)
-	return err
-}
+type ShardInfo = topology.Shard
 
 func ExampleShardConfig() *ShardConfig {
-	rl := utils.NewRangeList()
-	rl.AddRange("10", "11")
-
 	return &ShardConfig{
 		IPInUse: map[string]uint32{
 			"192.168.200.51": 4,
@@ -55,11 +16,11 @@ func ExampleShardConfig() *ShardConfig {
 			"192.168.200.53": 6,
 			"192.168.200.49": 5,
 		},
-		Shards: []ShardInfo{
-			ShardInfo{
+		Shards: []*ShardInfo{
+			{
 				ChainID:  "i1",
 				PeerNum:  4,
-				KeyRange: rl.String(),
+				KeyRange: "10,11",
 			},
 		},
 	}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"emulator/urd/shardinfo"
+	"emulator/utils/keystore"
+	"emulator/utils/signer"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+// ./urd keys generate      --root=./mytestnet/127.0.0.1/node1 --password-file=./pass
+// ./urd keys import        --root=./mytestnet/127.0.0.1/node1 --key-file=./private_key.txt
+// ./urd keys export-pubkey --root=./mytestnet/127.0.0.1/node1
+// ./urd keys rotate        --root=./mytestnet/127.0.0.1/node1 [--new-password-file=./pass2]
+
+const keysUsage = "usage: urd keys <generate|import|export-pubkey|rotate> --root=<node dir> [flags]"
+
+func KeysCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(keysUsage)
+	}
+	fs := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	rootDir := fs.String("root", ".", "Root directory of the node")
+	passwordFile := fs.String("password-file", "", "File holding the keystore passphrase, "+keystore.PassphraseEnv+" is used if empty")
+	newPasswordFile := fs.String("new-password-file", "", "rotate: passphrase of the new key, defaults to the current one")
+	keyFile := fs.String("key-file", "", "import: file holding the plain hex private key")
+	scheme := fs.String("scheme", "", "Signature scheme of the key, read from shard_info.json if empty")
+	kdf := fs.String("kdf", keystore.DefaultKDF, "Key derivation function: scrypt or argon2id")
+	force := fs.Bool("force", false, "generate, import: overwrite an existing key file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	cfg := &Config{DirRoot: *rootDir}
+	if err := bls.Init(signer.BaseCurve); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "generate":
+		s, err := nodeScheme(cfg, *scheme)
+		if err != nil {
+			return err
+		}
+		privateKey, _, err := signer.NewKeyPairForScheme(s)
+		if err != nil {
+			return err
+		}
+		passphrase, err := keystore.ReadPassphrase(*passwordFile)
+		if err != nil {
+			return err
+		}
+		return storeKey(cfg, privateKey, s, passphrase, *kdf, *force)
+	case "import":
+		if *keyFile == "" {
+			return fmt.Errorf("import: --key-file is required")
+		}
+		s, err := nodeScheme(cfg, *scheme)
+		if err != nil {
+			return err
+		}
+		bz, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		passphrase, err := keystore.ReadPassphrase(*passwordFile)
+		if err != nil {
+			return err
+		}
+		if err := storeKey(cfg, strings.TrimSpace(string(bz)), s, passphrase, *kdf, *force); err != nil {
+			return err
+		}
+		fmt.Printf("Imported %s, it can be deleted now\n", *keyFile)
+		return nil
+	case "export-pubkey":
+		kf, err := keystore.Read(cfg.PrivateKeyPath())
+		if err != nil {
+			return err
+		}
+		fmt.Println(kf.PublicKey)
+		return nil
+	case "rotate":
+		passphrase, err := keystore.ReadPassphrase(*passwordFile)
+		if err != nil {
+			return err
+		}
+		_, s, err := keystore.Load(cfg.PrivateKeyPath(), passphrase)
+		if err != nil {
+			return err
+		}
+		privateKey, _, err := signer.NewKeyPairForScheme(s)
+		if err != nil {
+			return err
+		}
+		if *newPasswordFile != "" {
+			if passphrase, err = keystore.ReadPassphrase(*newPasswordFile); err != nil {
+				return err
+			}
+		}
+		backup := fmt.Sprintf("%s.%s.bak", cfg.PrivateKeyPath(), time.Now().UTC().Format("20060102T150405Z"))
+		if err := os.Rename(cfg.PrivateKeyPath(), backup); err != nil {
+			return err
+		}
+		fmt.Println("Previous key moved to", backup)
+		if err := storeKey(cfg, privateKey, s, passphrase, *kdf, false); err != nil {
+			return err
+		}
+		fmt.Println("Replace this validator's pubkey (and pop) in shard_info.json on every node before restarting")
+		return nil
+	default:
+		return fmt.Errorf(keysUsage)
+	}
+}
+
+// nodeScheme returns the signature scheme of the node's shard unless one is given.
+func nodeScheme(cfg *Config, scheme string) (string, error) {
+	if scheme != "" {
+		return scheme, signer.ValidateScheme(scheme)
+	}
+	nodeCfg, err := GetConfig(cfg.ConfigPath())
+	if err != nil {
+		return "", fmt.Errorf("cannot find the shard of the node, pass --scheme: %v", err)
+	}
+	shardInfoBz, err := os.ReadFile(cfg.ShardInfoPath())
+	if err != nil {
+		return "", fmt.Errorf("cannot find the shard of the node, pass --scheme: %v", err)
+	}
+	var si = new(shardinfo.ShardInfo)
+	if err := si.UnmarshalJson(shardInfoBz); err != nil {
+		return "", err
+	}
+	shard, ok := si.Shards[nodeCfg.ChainID]
+	if !ok {
+		return "", fmt.Errorf("shard %s is not in %s", nodeCfg.ChainID, cfg.ShardInfoPath())
+	}
+	return shard.Name(), nil
+}
+
+func storeKey(cfg *Config, privateKey, scheme, passphrase, kdf string, force bool) error {
+	if _, err := os.Stat(cfg.PrivateKeyPath()); err == nil && !force {
+		return fmt.Errorf("%s already exists, pass --force to overwrite it", cfg.PrivateKeyPath())
+	}
+	publicKey, err := signer.PublicKeyForScheme(scheme, privateKey)
+	if err != nil {
+		return err
+	}
+	kf, err := keystore.EncryptWithKDF(privateKey, scheme, publicKey, passphrase, kdf)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cfg.ConfigDir(), 0700); err != nil {
+		return err
+	}
+	if err := keystore.Write(cfg.PrivateKeyPath(), kf); err != nil {
+		return err
+	}
+	fmt.Println("Wrote", cfg.PrivateKeyPath())
+	fmt.Println("pubkey:", publicKey)
+	if scheme == signer.SchemeBLS {
+		pop, err := signer.BLSPop(privateKey)
+		if err != nil {
+			return err
+		}
+		fmt.Println("pop:", pop)
+	}
+	return nil
+}
@@ -1,35 +1,106 @@
 package main
 
 import (
+	"emulator/urd/abci/minibank"
+	"emulator/utils/config"
+	"emulator/utils/genesis"
+	"emulator/utils/keystore"
+	"emulator/utils/topology"
 	"flag"
 	"fmt"
+	"os"
 	"path/filepath"
+	"time"
 )
 
 // ./ours --method=example  --root=.
-// ./ours --method=generate --config=./example-shard-config.json --root=./mytestnet
-// ./ours --method=start --root=./mytestnet --start-time=0:53  --wait-time="20s"
+// ./ours --method=generate --config=./example-shard-config.json --root=./mytestnet [--workload=./workload.toml] [--dataset-format=bin.gz] [--genesis-time=+2m] [--set consensus.max_part_size=40960]
+// ./ours --method=start --root=./mytestnet/127.0.0.1/node1 [--set consensus.max_views=100]
+// ./ours topology validate --config=./example-shard-config.json [--protocol=all]
+// ./ours config validate --root=./mytestnet [--set consensus.max_part_size=40960] [--print]
+// ./ours genesis --root=./mytestnet --time=2024-05-01T15:29:00Z
+// ./ours keys <generate|import|export-pubkey|rotate> --root=./mytestnet/127.0.0.1/node1
+// ./ours testnet --root=./localnet --views=50
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		if err := KeysCommand(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "genesis" {
+		if err := genesis.SetTimeCommand(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := config.Command(genesis.ProtocolUrd, os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "topology" {
+		if err := topology.Command(genesis.ProtocolUrd, os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "testnet" {
+		if err := TestnetCommand(os.Args[2:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	rootDir := flag.String("root", ".", "Root directory")
 	jsonDir := flag.String("config", "./example-shard-config.json", "The JSON file of sharding topology structure")
-	enable_pipeline := flag.Bool("enable-pipeline", true, "Choose false to start a CoCSV, and true for Urd")
+	workloadPath := flag.String("workload", "", "JSON or TOML workload file, overrides the workload section of --config")
+	enable_pipeline := flag.Bool("enable-pipeline", true, "Choose false to start a CoCSV, and true for Urd; overrides consensus.pipeline_depth when given")
+	datasetFormat := flag.String("dataset-format", minibank.DefaultDatasetFormat, "Format of the generated dataset: bin.gz, bin or txt")
+	preload := flag.Bool("preload", true, "Load the dataset into the leaders' mempools before consensus starts, false when urd-client submits the txs")
+
+	genesisTime := flag.String("genesis-time", "+2m", "Time consensus starts at, RFC3339 (2024-05-01T15:29:00Z) or a duration from now (+2m); `genesis` changes it later")
 
-	var startTimeStr, method, waitTime string
-	flag.StringVar(&startTimeStr, "start-time", "", "Program startup time, in the format of HH:MM")
-	flag.StringVar(&waitTime, "wait-time", "10", "System sleep time delay, the unit is seconds, please enter a positive integer")
+	var overrides config.Overrides
+	flag.Var(&overrides, "set", "Override a setting of config.toml like consensus.max_part_size=40960, can be repeated; generate writes it into every config")
+
+	var method, passwordFile string
 	flag.StringVar(&method, "method", "start", "The command to be used")
+	flag.StringVar(&passwordFile, "password-file", "", "File holding the keystore passphrase, "+keystore.PassphraseEnv+" is used if empty")
 	flag.Parse()
 
-	fmt.Println(*rootDir)
-
 	if method == "generate" {
-		GenerateConfigFiles(*jsonDir, *rootDir)
+		passphrase, err := keystore.ReadPassphrase(passwordFile)
+		if err != nil {
+			panic(err)
+		}
+		t, err := genesis.ParseTime(*genesisTime, time.Now())
+		if err != nil {
+			panic(err)
+		}
+		GenerateConfigFiles(*jsonDir, *rootDir, *workloadPath, *datasetFormat, passphrase, t, overrides)
 	} else if method == "start" {
-		InitNode(*rootDir, startTimeStr, waitTime, *enable_pipeline)
+		// --enable-pipeline picks the pipeline depth unless --set does
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "enable-pipeline" {
+				depth := 1
+				if *enable_pipeline {
+					depth = config.MaxPipelineDepth
+				}
+				overrides = append(config.Overrides{fmt.Sprintf("consensus.pipeline_depth=%d", depth)}, overrides...)
+			}
+		})
+		InitNode(*rootDir, *preload, passwordFile, overrides)
 	} else if method == "example" {
 		config := ExampleShardConfig()
-		config.WriteJSONToFile(filepath.Join(*rootDir, "example-shard-config.json"))
+		config.Write(filepath.Join(*rootDir, "example-shard-config.json"))
 	} else {
 		panic("Undefined command")
 	}
@@ -3,51 +3,66 @@ package main
 import (
 	"context"
 	"emulator/urd/abci/minibank"
+	"emulator/urd/client"
 	"emulator/urd/consensus"
 	"emulator/urd/definition"
 	"emulator/urd/mempool"
+	"emulator/urd/rpc"
 	"emulator/urd/shardinfo"
 	"emulator/utils"
+	"emulator/utils/config"
+	"emulator/utils/genesis"
+	"emulator/utils/keystore"
+	"emulator/utils/logging"
+	"emulator/utils/metrics"
 	"emulator/utils/p2p"
 	"emulator/utils/signer"
-	"flag"
+	"emulator/utils/status"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"syscall"
 	"time"
 
 	"emulator/logger/blocklogger"
+	"emulator/logger/txtrace"
 
 	"github.com/herumi/bls-eth-go-binary/bls"
 )
 
-func InitNode(rootDir string, startTimeStr string, waitTime string, enable_pipeline bool) {
-	defer fmt.Println("test ending")
+var nodeLog = logging.New("node")
 
-	var cfg = new(Config)
-	cfg.DirRoot = rootDir
-	cfg, err := GetConfig(cfg.ConfigPath())
+func InitNode(rootDir string, preload bool, passwordFile string, overrides []string) {
+	defer nodeLog.Info("node stopped")
+
+	// SIGINT and SIGTERM stop the node, InitNode then returns normally
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	cfg, err := config.LoadNode(rootDir, genesis.ProtocolUrd, overrides)
 	if err != nil {
 		panic(err)
 	}
-	cfg.DirRoot = rootDir
-
-	priveKeyBz, err := os.ReadFile(cfg.PrivateKeyPath())
-	if err != nil {
+	if err := logging.Configure(os.Stdout, cfg.Log.Format, cfg.Log.Level, "node", cfg.NodeName, "chain", cfg.ChainID); err != nil {
 		panic(err)
 	}
+	nodeLog.Info("starting node", "root", rootDir, "protocol", cfg.Protocol)
 
-	if err := bls.Init(signer.BaseCurve); err != nil {
+	passphrase, err := keystore.ReadPassphrase(passwordFile)
+	if err != nil {
 		panic(err)
 	}
-	privateKey := string(priveKeyBz)
-	Signer, err := signer.NewSigner(privateKey)
+	privateKey, keyScheme, err := keystore.Load(cfg.PrivateKeyPath(), passphrase)
 	if err != nil {
 		panic(err)
 	}
 
+	if err := bls.Init(signer.BaseCurve); err != nil {
+		panic(err)
+	}
+
 	shardInfoBz, err := os.ReadFile(cfg.ShardInfoPath())
 	if err != nil {
 		panic(err)
@@ -56,57 +71,240 @@ func InitNode(rootDir string, startTimeStr string, waitTime string, enable_pipel
 	if err := shardInfo.UnmarshalJson(shardInfoBz); err != nil {
 		panic(err)
 	}
+	shard, ok := shardInfo.Shards[cfg.ChainID]
+	if !ok {
+		panic(fmt.Errorf("shard %s is not in %s", cfg.ChainID, cfg.ShardInfoPath()))
+	}
+	gen := loadGenesis(cfg, shardInfo)
+	minibank.SetInitialBalance(gen.InitialState.Balance)
+	minibank.RequireSignatures(gen.InitialState.SignedTxs)
 
-	abci := createABCI(cfg, shardInfo)
-	defer abci.Stop()
+	if keyScheme != shard.Name() {
+		panic(fmt.Errorf("the key in %s is a %s key, shard %s uses %s", cfg.PrivateKeyPath(), keyScheme, cfg.ChainID, shard.Name()))
+	}
+	Signer, err := signer.NewSignerForScheme(shard.Scheme, privateKey)
+	if err != nil {
+		panic(err)
+	}
+	nodeLog.Info("signature scheme", "scheme", shard.Name())
+
+	abci := createABCI(cfg, shardInfo, gen.Shard(cfg.ChainID).Owners)
 	mempool, cross_shard_mempool := createMempool(cfg, abci)
-	sender, receiver := createP2p(cfg, shardInfo)
+	sender, receiver := createP2p(ctx, cfg, shardInfo)
+	handshake := p2p.NewHandshake(sender, gen.Hash())
 	logger := blocklogger.NewBlockWriter(cfg.DirRoot, cfg.NodeName, cfg.ChainID)
 	if err := logger.OnStart(); err != nil {
 		panic(err)
 	}
-	defer logger.OnStop()
+	if cfg.Trace.Enabled {
+		if err := txtrace.Open(cfg.DirRoot, cfg.NodeName, cfg.ChainID, cfg.Trace.Sample); err != nil {
+			panic(err)
+		}
+		nodeLog.Info("tracing txs", "sample", cfg.Trace.Sample)
+	}
 	consensus := createConsensus(
 		cfg, shardInfo,
 		Signer,
 		mempool, cross_shard_mempool,
 		abci, sender,
 		logger,
-		enable_pipeline,
 	)
+	receiver.AddChennel(handshake, p2p.ChannelIDHandshake)
 	receiver.AddChennel(consensus, p2p.ChannelIDConsensusState)
 	receiver.AddChennel(mempool, p2p.ChannelIDMempool)
 	receiver.AddChennel(cross_shard_mempool, p2p.ChannelIDCrossShardMempool)
-	defer consensus.Stop()
 
-	receiver.Start()
-	importor := minibank.NewImportor(mempool, cross_shard_mempool, logger, cfg.ChainID, filepath.Join(cfg.DatasetDir(), "dataset.txt"), createKeyRangeTree(cfg, shardInfo),
-		cfg.SignerIndex == shardInfo.Shards[cfg.ChainID].LeaderIndex)
+	metricsServer := createMetrics(cfg, nodeStatus(cfg, consensus, mempool, cross_shard_mempool, sender))
+	rpcServer := createRPC(cfg, shardInfo, sender, mempool, cross_shard_mempool, abci, consensus)
+	if err := receiver.Start(); err != nil {
+		panic(err)
+	}
+	isLeader := cfg.Consensus.SignerIndex == shardInfo.Shards[cfg.ChainID].LeaderIndex
+	dataset := ""
+	if preload && isLeader {
+		if dataset, err = minibank.FindDataset(cfg.DatasetDir()); err != nil {
+			panic(err)
+		}
+	}
+	importor := minibank.NewImportor(mempool, cross_shard_mempool, logger, cfg.ChainID, dataset, createKeyRangeTree(cfg, shardInfo),
+		loadWorkload(cfg), preload && isLeader)
+	importor.MaxPending = cfg.Mempool.PreloadPending
 	if err := importor.Start(); err != nil {
 		panic(err)
 	}
+	// the components are stopped in order: no more messages and client
+	// requests come in, nothing is fed to the mempools, consensus finishes
+	// the message it handles and closes its store, then the peers are
+	// disconnected, the ABCI state is flushed to disk and the blocklogger and
+	// trace files are flushed last. The mempools only live in memory and have
+	// nothing to flush.
+	defer func() {
+		if rpcServer != nil {
+			rpcServer.Close()
+		}
+		receiver.Stop()
+		importor.Stop()
+		consensus.Stop()
+		sender.Stop()
+		abci.Stop()
+		if err := logger.OnStop(); err != nil {
+			nodeLog.Error("flushing the blocklogger failed", "err", err)
+		}
+		if err := txtrace.Close(); err != nil {
+			nodeLog.Error("flushing the tx trace failed", "err", err)
+		}
+		if metricsServer != nil {
+			metricsServer.Close()
+		}
+	}()
 
-	startTime := time_to_start(startTimeStr)
-	fmt.Println("Time until genesis time:", time.Until(startTime))
-	time.Sleep(time.Until(startTime))
-	time.Sleep(3 * time.Second)
-
-	// The SENDER is started after a certain delay to ensure that all RECEIVERS have been started completel
-	if err := sender.Start(); err != nil {
-		fmt.Println(err)
+	// consensus starts at genesis time, once every peer is connected
+	if err := runHandshake(ctx, handshake, cfg.P2P.HandshakeTimeout); err != nil {
+		nodeLog.Error("handshake failed", "err", err)
+		return
 	}
-
-	t, err := strconv.ParseInt(waitTime, 10, 32)
-	if err == nil && t >= 0 {
-		time.Sleep(time.Duration(t) * time.Second)
+	if wait := time.Until(gen.GenesisTime); wait > 0 {
+		nodeLog.Info("waiting for the genesis time", "genesis_time", gen.GenesisTime.Format(time.RFC3339), "wait", wait)
+		if !sleep(ctx, wait) {
+			return
+		}
 	} else {
-		time.Sleep(10 * time.Second)
+		nodeLog.Info("genesis time passed, starting now", "genesis_time", gen.GenesisTime.Format(time.RFC3339), "late", -wait)
 	}
 
-	fmt.Println("Starting consensus...", shardInfo.ShardIDList)
+	nodeLog.Info("starting consensus", "shards", shardInfo.ShardIDList)
 	consensus.Start()
 
-	select {}
+	select {
+	case <-ctx.Done():
+		nodeLog.Info("interrupted, stopping the node")
+	case <-consensus.Done():
+		nodeLog.Info("max_views reached, stopping the node", "max_views", cfg.Consensus.MaxViews)
+	}
+}
+
+// createMetrics serves the metrics and the status of the node, nil if they
+// are disabled.
+func createMetrics(cfg *Config, nodeStatus func() *status.Status) *http.Server {
+	if !cfg.Metrics.Enabled {
+		return nil
+	}
+	metrics.Default.SetConstLabels("node", cfg.NodeName, "chain_id", cfg.ChainID, "protocol", cfg.Protocol)
+	server, err := metrics.Default.Serve(cfg.MetricsAddress(), status.Handlers(nodeStatus))
+	if err != nil {
+		panic(err)
+	}
+	nodeLog.Info("serving metrics", "url", "http://"+cfg.MetricsAddress()+"/metrics", "status", "http://"+cfg.MetricsAddress()+"/status")
+	return server
+}
+
+// createRPC serves the client API of the node, nil if it is disabled.
+func createRPC(cfg *Config, si *shardinfo.ShardInfo, sender *p2p.Sender, mempool, cross_shard_mempool definition.MempoolConn,
+	abci definition.ABCIConn, consensus definition.ConsensusConn) *http.Server {
+	if !cfg.RPC.Enabled {
+		return nil
+	}
+	router, err := client.NewRouter(si)
+	if err != nil {
+		panic(err)
+	}
+	server, err := rpc.NewServer(cfg.ChainID, router, sender, mempool, cross_shard_mempool, abci, consensus).Serve(cfg.RPCAddress())
+	if err != nil {
+		panic(err)
+	}
+	nodeLog.Info("serving rpc", "url", "http://"+cfg.RPCAddress())
+	return server
+}
+
+// nodeStatus reports what consensus, the mempools and the connections to
+// the peers are at.
+func nodeStatus(cfg *Config, consensus definition.ConsensusConn, mempool, cross_shard_mempool definition.MempoolConn,
+	sender *p2p.Sender) func() *status.Status {
+	start := time.Now()
+	return func() *status.Status {
+		return &status.Status{
+			Node:              cfg.NodeName,
+			ChainID:           cfg.ChainID,
+			Protocol:          cfg.Protocol,
+			Time:              time.Now(),
+			Uptime:            time.Since(start).Seconds(),
+			Consensus:         consensus.Status(),
+			Mempool:           mempool.Size(),
+			CrossShardMempool: cross_shard_mempool.Size(),
+			Peers:             status.Peers(sender),
+		}
+	}
+}
+
+// sleep waits for d, it returns false if ctx is cancelled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		nodeLog.Info("interrupted, stopping the node")
+		return false
+	}
+}
+
+// runHandshake waits for the peers of the node, giving up after timeout
+// unless it is 0.
+func runHandshake(ctx context.Context, handshake *p2p.Handshake, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := handshake.Run(ctx)
+	if err == context.DeadlineExceeded {
+		return fmt.Errorf("peers not ready after p2p.handshake_timeout %v, stopping the node", timeout)
+	} else if err != nil {
+		return fmt.Errorf("interrupted, stopping the node")
+	}
+	return nil
+}
+
+// loadGenesis reads the genesis of the node and checks it against the
+// node's config and shard_info.json.
+func loadGenesis(cfg *Config, si *shardinfo.ShardInfo) *genesis.Genesis {
+	gen, err := genesis.Load(cfg.GenesisPath())
+	if err != nil {
+		panic(err)
+	}
+	if gen.Protocol != genesis.ProtocolUrd {
+		panic(fmt.Errorf("%s is a %s genesis", cfg.GenesisPath(), gen.Protocol))
+	}
+	shard := gen.Shard(cfg.ChainID)
+	if shard == nil {
+		panic(fmt.Errorf("shard %s is not in %s", cfg.ChainID, cfg.GenesisPath()))
+	}
+	if err := shard.CheckValidators(si.Shards[cfg.ChainID].PeerList); err != nil {
+		panic(err)
+	}
+	if err := gen.Params.Check(cfg.GenesisParams()); err != nil {
+		panic(err)
+	}
+	nodeLog.Info("genesis loaded", "hash", gen.Hash(), "genesis_time", gen.GenesisTime.Format(time.RFC3339))
+	return gen
+}
+
+// loadWorkload returns the workload recorded with the node's dataset, only
+// leaders have one.
+func loadWorkload(cfg *Config) *minibank.Workload {
+	path := filepath.Join(cfg.DatasetDir(), minibank.WorkloadFileName)
+	if _, err := os.Stat(path); err != nil {
+		return minibank.DefaultWorkload()
+	}
+	workload, err := minibank.LoadWorkload(path)
+	if err != nil {
+		panic(err)
+	}
+	return workload
 }
 
 func createKeyRangeTree(cfg *Config, si *shardinfo.ShardInfo) map[string]*utils.RangeList {
@@ -121,12 +319,24 @@ func createKeyRangeTree(cfg *Config, si *shardinfo.ShardInfo) map[string]*utils.
 	return out
 }
 
-func createABCI(cfg *Config, si *shardinfo.ShardInfo) definition.ABCIConn {
+// createABCI starts the application, owners is the hash of the owners file
+// of the shard in the genesis.
+func createABCI(cfg *Config, si *shardinfo.ShardInfo, owners string) definition.ABCIConn {
 	chain_id := cfg.ChainID
 	rangeLists := createKeyRangeTree(cfg, si)
-	switch cfg.ABCIApp {
-	case "minibank":
+	switch cfg.ABCI.App {
+	case config.ABCIMinibank:
 		app := minibank.NewApplication(cfg.StoreDirRoot(), chain_id, rangeLists, si)
+		app.LeaseLocks(cfg.ABCI.LockLeaseViews)
+		app.ShareLocks(cfg.ABCI.SharedLocks)
+		if minibank.SignaturesRequired() {
+			if err := app.BindOwners(filepath.Join(cfg.ConfigDir(), minibank.OwnersFileName), owners); err != nil {
+				panic(err)
+			}
+		}
+		if cfg.RPC.Enabled {
+			app.IndexTxs(cfg.RPC.TxIndexSize)
+		}
 		return app
 	default:
 		panic("Undefined ABCI")
@@ -134,11 +344,11 @@ func createABCI(cfg *Config, si *shardinfo.ShardInfo) definition.ABCIConn {
 }
 
 func createMempool(cfg *Config, abci definition.ABCIConn) (definition.MempoolConn, definition.MempoolConn) {
-	return mempool.NewMempool(false, abci), mempool.NewMempool(true, abci)
+	return mempool.NewMempool(false, abci, cfg.Mempool.Size), mempool.NewMempool(true, abci, cfg.Mempool.CrossShardSize)
 }
-func createP2p(cfg *Config, si *shardinfo.ShardInfo) (*p2p.Sender, *p2p.Receiver) {
-	sender := p2p.NewSender(fmt.Sprintf("%s:%d", cfg.LocalIP, cfg.LocalPort))
-	receiver := p2p.NewReceiver(cfg.LocalIP, cfg.LocalPort, context.Background())
+func createP2p(ctx context.Context, cfg *Config, si *shardinfo.ShardInfo) (*p2p.Sender, *p2p.Receiver) {
+	sender := p2p.NewSender(fmt.Sprintf("%s:%d", cfg.P2P.IP, cfg.P2P.Port))
+	receiver := p2p.NewReceiver(cfg.P2P.IP, cfg.P2P.Port, ctx)
 
 	for _, shard := range si.Shards {
 		for _, peer := range shard.PeerList {
@@ -147,69 +357,24 @@ func createP2p(cfg *Config, si *shardinfo.ShardInfo) (*p2p.Sender, *p2p.Receiver
 	}
 	return sender, receiver
 }
-func createConsensus(cfg *Config, si *shardinfo.ShardInfo, s *signer.Signer, mmp, cmmp definition.MempoolConn,
-	abci definition.ABCIConn, sender *p2p.Sender, logger blocklogger.BlockWriter, enable_pipeline bool) definition.ConsensusConn {
+func createConsensus(cfg *Config, si *shardinfo.ShardInfo, s signer.Signer, mmp, cmmp definition.MempoolConn,
+	abci definition.ABCIConn, sender *p2p.Sender, logger blocklogger.BlockWriter) definition.ConsensusConn {
 	state, err := consensus.NewState(
 		0, 0,
-		s, cfg.SignerIndex,
+		s, cfg.Consensus.SignerIndex,
 		si, cfg.ChainID,
 		mmp, cmmp, abci, sender, cfg.StoreDirRoot(), logger,
-		cfg.MaxBlockTxBytes, cfg.MaxBlockCrossShardTxBytes,
+		cfg.Consensus.MaxBlockTxBytes, cfg.Consensus.MaxBlockCrossShardTxBytes,
 	)
-	state.EnablePipelineFlag = enable_pipeline
 	if err != nil {
 		panic(err)
 	}
+	state.PipelineStride = int64(cfg.Consensus.PipelineStride())
+	state.MinBlockInterval = cfg.Consensus.MinBlockInterval
+	state.MaxPartSize = cfg.Consensus.MaxPartSize
+	state.FirstBlockDelay = cfg.Consensus.FirstBlockDelay
+	state.MaxViews = cfg.Consensus.MaxViews
+	state.StoreBlocks = cfg.RPC.Enabled
 	return state
 
 }
-
-func parseStartTime(startTimeStr string) (int, int, error) {
-	// Split the time string by ":"
-	parts := strings.Split(startTimeStr, ":")
-	if len(parts) != 2 {
-		return 0, 0, fmt.Errorf("Incorrect time format, should be HH:MM")
-	}
-
-	// Parse hours and minutes
-	hours, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return 0, 0, fmt.Errorf("Error parsing hours: %v", err)
-	}
-	minutes, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return 0, 0, fmt.Errorf("Error parsing minutes: %v", err)
-	}
-
-	// Check if hours and minutes are valid
-	if hours < 0 || hours > 23 || minutes < 0 || minutes > 59 {
-		return 0, 0, fmt.Errorf("Invalid hours or minutes")
-	}
-
-	return hours, minutes, nil
-}
-
-func time_to_start(startTimeStr string) time.Time {
-
-	// Parse command - line arguments
-	flag.Parse()
-
-	// Check if the --start - time flag is provided
-	if startTimeStr == "" {
-		//fmt.Println("Please use the --start - time flag to specify the program startup time")
-		return time.Now()
-	}
-
-	// Parse the startup time string
-	hours, minutes, err := parseStartTime(startTimeStr)
-	if err != nil {
-		panic(fmt.Sprintln("Incorrect startup time format:", err))
-
-	}
-
-	// Get the current date
-	now := time.Now()
-
-	// Set the startup time
-	return time.Date(now.Year(), now.Month(), now.Day(), hours, minutes, 0, 0, time.Local)
-}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"emulator/urd/abci/minibank"
+	"emulator/urd/shardinfo"
+	"emulator/utils"
+	"emulator/utils/config"
+	"emulator/utils/genesis"
+	"emulator/utils/testnet"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// ./urd testnet --root=./localnet --views=50 [--config=./example-shard-config.json] [--workload=./workload.toml] [--set consensus.max_part_size=40960]
+
+// TestnetCommand generates a testnet on localhost from a shard config, runs
+// every node as a child process until the leaders reach --views, then stops
+// them and prints the results.
+func TestnetCommand(args []string) error {
+	var workloadPath string
+	var enablePipeline bool
+	return testnet.Command{
+		Protocol: genesis.ProtocolUrd,
+		Example:  ExampleShardConfig,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&workloadPath, "workload", "", "JSON or TOML workload file, overrides the workload section of --config")
+			fs.BoolVar(&enablePipeline, "enable-pipeline", true, "Choose false to start a CoCSV, and true for Urd")
+		},
+		Generate: func(topologyPath, rootDir, passphrase string, overrides config.Overrides) {
+			// the overrides are written into the configs, so that
+			// genesis.json matches them
+			depth := 1
+			if enablePipeline {
+				depth = config.MaxPipelineDepth
+			}
+			overrides = append(config.Overrides{fmt.Sprintf("consensus.pipeline_depth=%d", depth)}, overrides...)
+			GenerateConfigFiles(topologyPath, rootDir, workloadPath, minibank.DefaultDatasetFormat, passphrase, time.Now(), overrides)
+		},
+		Leader: testnetLeader,
+		Latency: func(node *testnet.Node) (*utils.Latency, error) {
+			return minibank.MeasureLatency(node.Dir, node.ChainID)
+		},
+	}.Run(args)
+}
+
+// testnetLeader reports whether the node is the leader shard_info.json gives
+// its shard.
+func testnetLeader(dir string, cfg *config.Config) (bool, error) {
+	si, err := shardinfo.Read(cfg.ShardInfoPath())
+	if err != nil {
+		return false, err
+	}
+	shard, ok := si.Shards[cfg.ChainID]
+	if !ok {
+		return false, fmt.Errorf("shard %s is not in %s", cfg.ChainID, cfg.ShardInfoPath())
+	}
+	return cfg.Consensus.SignerIndex == shard.LeaderIndex, nil
+}
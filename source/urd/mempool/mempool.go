@@ -4,8 +4,11 @@ package mempool
 // 这个Mempool是不安全的，它无法拒绝重复事务，仅停留在实验室基础上
 import (
 	"emulator/libs/clist"
+	"emulator/logger/txtrace"
 	"emulator/urd/definition"
 	"emulator/urd/types"
+	"emulator/utils/logging"
+	"emulator/utils/metrics"
 	"fmt"
 	"sync"
 )
@@ -16,15 +19,24 @@ type Mempool struct {
 
 	abci                definition.ABCIConn
 	isCrossShardMempool bool
+	// size caps the txs held, 0 is unbounded
+	size int
+
+	sizeMetric metrics.Gauge
+	log        *logging.Logger
 }
 
-func NewMempool(isCrossShardMempool bool, abci definition.ABCIConn) *Mempool {
+func NewMempool(isCrossShardMempool bool, abci definition.ABCIConn, size int) *Mempool {
 	return &Mempool{
 		txs:    clist.New(),
 		txsMap: sync.Map{},
 
 		abci:                abci,
 		isCrossShardMempool: isCrossShardMempool,
+		size:                size,
+
+		sizeMetric: metrics.MempoolSize.With(metrics.Mempool(isCrossShardMempool)),
+		log:        logging.New("mempool").With("mempool", metrics.Mempool(isCrossShardMempool)),
 	}
 }
 
@@ -36,11 +48,18 @@ func (mpl *Mempool) Receive(chID byte, message []byte, messageType uint32) error
 }
 
 func (mpl *Mempool) AddTx(tx []byte) error {
+	if mpl.size > 0 && mpl.txs.Len() >= mpl.size {
+		return fmt.Errorf("mempool is full (%d txs)", mpl.size)
+	}
 	if !mpl.abci.ValidateTx(tx, mpl.isCrossShardMempool) {
 		return fmt.Errorf("An invalid transaction")
 	}
 	e := mpl.txs.PushBack(&tx)
 	mpl.txsMap.Store(types.TxKey(tx), e)
+	mpl.sizeMetric.Set(float64(mpl.txs.Len()))
+	if txtrace.Enabled() {
+		txtrace.Record(types.TxHash(tx), txtrace.Event{Stage: txtrace.Admit, Cross: mpl.isCrossShardMempool})
+	}
 	return nil
 }
 
@@ -49,17 +68,22 @@ func (mpl *Mempool) RemoveTx(tx []byte) error {
 		if u, ok := e.(*clist.CElement); ok {
 			mpl.txs.Remove(u)
 			mpl.txsMap.Delete(types.TxKey(tx))
+			mpl.sizeMetric.Set(float64(mpl.txs.Len()))
 		}
 	}
 	return nil
 }
 
+// Size returns the number of txs waiting in the mempool.
+func (mpl *Mempool) Size() int {
+	return mpl.txs.Len()
+}
+
 func (mpl *Mempool) ReapTx(maxTxsBz int) (types.Txs, int, error) {
 	var (
 		txs = make(types.Txs, 0)
 	)
-	fmt.Println("ReapTx called, maxTxs:", maxTxsBz)
-	fmt.Println("Current mempool size:", mpl.txs.Len())
+	mpl.log.Debug("reaping txs", "max_bytes", maxTxsBz, "size", mpl.txs.Len())
 	current := 0
 	for e := mpl.txs.Front(); e != nil; e = e.Next() {
 		memTx := *e.Value.(*[]byte)
@@ -0,0 +1,450 @@
+package rpc
+
+import (
+	"emulator/urd/abci/minibank"
+	"emulator/urd/client"
+	"emulator/urd/definition"
+	"emulator/urd/events"
+	"emulator/urd/types"
+	"emulator/utils"
+	"emulator/utils/logging"
+	"emulator/utils/p2p"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// The client API of a node, JSON over HTTP:
+//
+//	POST /broadcast_tx   {"tx":"<hex>"} or {"transfer":{"from":[..],"from_money":[..],"to":[..],"to_money":[..]}}
+//	GET  /broadcast_tx?tx=<hex>
+//	GET  /tx_status?hash=<hex>
+//	GET  /block?view=<view>
+//	GET  /account?key=<account>
+//	GET  /shard_for_key?key=<account>
+//	GET  /subscribe?type=<block,receipt,tx,view>&tx=<hex>&account=<account>, a websocket
+//
+// A tx is sent to the leader of every shard it touches, to the cross-shard
+// mempools if there are several. tx_status, block and account answer for the
+// shard of the node.
+//
+// A subscription streams the events of the node as JSON messages, the ones
+// of the types given, for the txs or accounts given if any; tx and account
+// may be repeated. Relays are only sent by the leader. A subscriber that
+// falls behind by more than subscriptionBuffer events gets an error message
+// and is disconnected.
+
+var log = logging.New("rpc")
+
+// pendingSize is how many of the txs it accepted a server remembers as
+// pending until their shard executes them.
+const pendingSize = 1 << 16
+
+const subscriptionBuffer = 1024
+
+type Server struct {
+	chainID string
+	router  *client.Router
+	sender  *p2p.Sender
+
+	mempool             definition.MempoolConn
+	cross_shard_mempool definition.MempoolConn
+	abci                definition.ABCIConn
+	consensus           definition.ConsensusConn
+
+	pending *recent
+}
+
+func NewServer(chainID string, router *client.Router, sender *p2p.Sender, mempool, cross_shard_mempool definition.MempoolConn,
+	abci definition.ABCIConn, consensus definition.ConsensusConn) *Server {
+	return &Server{
+		chainID:             chainID,
+		router:              router,
+		sender:              sender,
+		mempool:             mempool,
+		cross_shard_mempool: cross_shard_mempool,
+		abci:                abci,
+		consensus:           consensus,
+		pending:             newRecent(pendingSize),
+	}
+}
+
+// Transfer is a transfer given by its accounts, the node fills in its shards
+// and time.
+type Transfer struct {
+	From      []string `json:"from"`
+	FromMoney []uint32 `json:"from_money"`
+	To        []string `json:"to"`
+	ToMoney   []uint32 `json:"to_money"`
+}
+
+type BroadcastRequest struct {
+	// Tx is an encoded tx in hex, or else Transfer is set
+	Tx       string    `json:"tx,omitempty"`
+	Transfer *Transfer `json:"transfer,omitempty"`
+}
+
+type BroadcastResult struct {
+	Hash   string   `json:"hash"`
+	Shards []string `json:"shards"`
+}
+
+type TxStatus struct {
+	Hash  string `json:"hash"`
+	Shard string `json:"shard"`
+	types.TxResult
+}
+
+type Block struct {
+	View        int64     `json:"view"`
+	Round       int32     `json:"round"`
+	Shard       string    `json:"shard"`
+	Time        time.Time `json:"time"`
+	Hash        string    `json:"hash"`
+	HashPointer string    `json:"hash_pointer"`
+	// Txs and CrossShardTxs are the hashes of the txs of the block
+	Txs           []string `json:"txs"`
+	CrossShardTxs []string `json:"cross_shard_txs"`
+	// RelayedTxs is the number of relayed txs the block commits
+	RelayedTxs int `json:"relayed_txs"`
+}
+
+type Account struct {
+	Shard string `json:"shard"`
+	types.Account
+}
+
+type KeyShard struct {
+	Key    string `json:"key"`
+	Shard  string `json:"shard"`
+	Leader string `json:"leader"`
+}
+
+// Handler routes the operations of the API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/broadcast_tx", s.handleBroadcastTx)
+	mux.HandleFunc("/tx_status", s.handleTxStatus)
+	mux.HandleFunc("/block", s.handleBlock)
+	mux.HandleFunc("/account", s.handleAccount)
+	mux.HandleFunc("/shard_for_key", s.handleShardForKey)
+	mux.HandleFunc("/subscribe", s.handleSubscribe)
+	return mux
+}
+
+// Serve listens on addr and serves the API until the server is closed.
+func (s *Server) Serve(addr string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("rpc: %v", err)
+	}
+	server := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Error("serving failed", "err", err)
+		}
+	}()
+	return server, nil
+}
+
+// BroadcastTx sends tx to the leaders of the shards it touches.
+func (s *Server) BroadcastTx(tx []byte) (*BroadcastResult, error) {
+	transfer, err := minibank.NewTransferTxFromBytes(tx)
+	if err != nil {
+		return nil, fmt.Errorf("not a transfer: %v", err)
+	}
+	shards, err := s.router.Route(transfer)
+	if err != nil {
+		return nil, err
+	}
+	if !utils.StrEqual(transfer.Shards, shards) {
+		return nil, fmt.Errorf("tx is for shards %v, its accounts are in %v", transfer.Shards, shards)
+	}
+	channel, mempool := byte(p2p.ChannelIDMempool), s.mempool
+	if len(shards) > 1 {
+		channel, mempool = p2p.ChannelIDCrossShardMempool, s.cross_shard_mempool
+	}
+	for _, shard := range shards {
+		leader := s.router.Leader(shard)
+		if leader.GetIP() == s.sender.MyIP {
+			err = mempool.AddTx(tx)
+		} else {
+			err = s.sender.Send(leader, channel, tx, 0)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %v", shard, err)
+		}
+	}
+	hash := types.TxHash(tx)
+	s.pending.add(hash)
+	return &BroadcastResult{Hash: hex.EncodeToString(hash), Shards: shards}, nil
+}
+
+// BroadcastTransfer encodes transfer as a tx of the shards of its accounts
+// and broadcasts it, unsigned.
+func (s *Server) BroadcastTransfer(transfer *Transfer) (*BroadcastResult, error) {
+	if minibank.SignaturesRequired() {
+		return nil, fmt.Errorf("txs must be signed by their From accounts, broadcast a signed tx")
+	}
+	tx := minibank.NewTransferTx(transfer.From, transfer.FromMoney, transfer.To, transfer.ToMoney, nil)
+	if err := minibank.ValidateTransferTx(tx); err != nil {
+		return nil, err
+	}
+	shards, err := s.router.Route(tx)
+	if err != nil {
+		return nil, err
+	}
+	tx.Shards = shards
+	return s.BroadcastTx(minibank.TransferBytes(tx))
+}
+
+// TxStatus is what the shard of the node made of the tx with hash, false if
+// it does not know the tx.
+func (s *Server) TxStatus(hash []byte) (*TxStatus, bool) {
+	status := &TxStatus{Hash: hex.EncodeToString(hash), Shard: s.chainID}
+	if result, ok := s.abci.TxResult(hash); ok {
+		status.TxResult = *result
+		return status, true
+	}
+	if s.pending.has(hash) {
+		status.Status = types.TxPending
+		return status, true
+	}
+	return nil, false
+}
+
+func (s *Server) Block(view int64) (*Block, error) {
+	block, err := s.consensus.Block(view)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	out := &Block{
+		View:          block.View,
+		Round:         block.Round,
+		Shard:         block.ChainID,
+		Time:          block.Time,
+		Hash:          hex.EncodeToString(block.Hash()),
+		HashPointer:   hex.EncodeToString(block.HashPointer),
+		Txs:           txHashes(block.PTXS),
+		CrossShardTxs: txHashes(block.CrossShardTxs),
+	}
+	for _, ctxs := range block.CTXS {
+		out.RelayedTxs += ctxs.Size()
+	}
+	return out, nil
+}
+
+func txHashes(txs types.Txs) []string {
+	out := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		out = append(out, types.TxKey(tx))
+	}
+	return out
+}
+
+func (s *Server) handleBroadcastTx(w http.ResponseWriter, req *http.Request) {
+	var request BroadcastRequest
+	switch req.Method {
+	case http.MethodGet:
+		request.Tx = req.URL.Query().Get("tx")
+	case http.MethodPost:
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s is not GET or POST", req.Method))
+		return
+	}
+	var result *BroadcastResult
+	var err error
+	switch {
+	case request.Tx != "":
+		var tx []byte
+		if tx, err = decodeHex(request.Tx); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("tx: %v", err))
+			return
+		}
+		result, err = s.BroadcastTx(tx)
+	case request.Transfer != nil:
+		result, err = s.BroadcastTransfer(request.Transfer)
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("neither tx nor transfer is given"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleTxStatus(w http.ResponseWriter, req *http.Request) {
+	hash, err := decodeHex(req.URL.Query().Get("hash"))
+	if err != nil || len(hash) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("hash is not a hex tx hash"))
+		return
+	}
+	status, ok := s.TxStatus(hash)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("tx %x is unknown to shard %s", hash, s.chainID))
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handleBlock(w http.ResponseWriter, req *http.Request) {
+	view, err := strconv.ParseInt(req.URL.Query().Get("view"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("view: %v", err))
+		return
+	}
+	block, err := s.Block(view)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	} else if block == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("shard %s has not executed a block of view %d", s.chainID, view))
+		return
+	}
+	writeJSON(w, http.StatusOK, block)
+}
+
+func (s *Server) handleAccount(w http.ResponseWriter, req *http.Request) {
+	key := req.URL.Query().Get("key")
+	shard, err := s.router.Shard(key)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	} else if shard != s.chainID {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("account %s is in shard %s, ask one of its nodes", key, shard))
+		return
+	}
+	account, err := s.abci.Account(key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &Account{Shard: shard, Account: *account})
+}
+
+func (s *Server) handleShardForKey(w http.ResponseWriter, req *http.Request) {
+	key := req.URL.Query().Get("key")
+	shard, err := s.router.Shard(key)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &KeyShard{Key: key, Shard: shard, Leader: s.router.Leader(shard).GetIP()})
+}
+
+// ParseFilter reads the filter of a subscription from the query of its URL.
+func ParseFilter(query map[string][]string) (events.Filter, error) {
+	filter := events.Filter{Types: map[events.Type]bool{}, Txs: map[string]bool{}, Accounts: map[string]bool{}}
+	for _, list := range query["type"] {
+		for _, t := range strings.Split(list, ",") {
+			switch t := events.Type(t); t {
+			case events.Block, events.Receipt, events.Tx, events.View:
+				filter.Types[t] = true
+			default:
+				return filter, fmt.Errorf("unknown event type %q", t)
+			}
+		}
+	}
+	for _, tx := range query["tx"] {
+		hash, err := decodeHex(tx)
+		if err != nil || len(hash) == 0 {
+			return filter, fmt.Errorf("tx %q is not a hex tx hash", tx)
+		}
+		filter.Txs[hex.EncodeToString(hash)] = true
+	}
+	for _, account := range query["account"] {
+		filter.Accounts[account] = true
+	}
+	return filter, nil
+}
+
+func (s *Server) handleSubscribe(w http.ResponseWriter, req *http.Request) {
+	filter, err := ParseFilter(req.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// any origin, the clients are benchmarks and explorers rather than pages
+	websocket.Server{Handshake: func(*websocket.Config, *http.Request) error { return nil }, Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		sub := events.Subscribe(filter, subscriptionBuffer)
+		defer sub.Close()
+		log.Debug("subscribed", "remote", req.RemoteAddr, "filter", req.URL.RawQuery)
+		go func() {
+			// the client sends nothing, a read ends when it leaves
+			var discard []byte
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+			sub.Close()
+		}()
+		for e := range sub.Events() {
+			if err := websocket.JSON.Send(ws, e); err != nil {
+				return
+			}
+		}
+		if err := sub.Err(); err != nil {
+			websocket.JSON.Send(ws, map[string]string{"error": err.Error()})
+		}
+		log.Debug("unsubscribed", "remote", req.RemoteAddr, "err", sub.Err())
+	}}.ServeHTTP(w, req)
+}
+
+func decodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(s, "0x"))
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// recent remembers the latest hashes added, the oldest are forgotten first.
+type recent struct {
+	mtx   sync.Mutex
+	set   map[string]bool
+	order []string
+	next  int
+}
+
+func newRecent(capacity int) *recent {
+	return &recent{set: make(map[string]bool, capacity), order: make([]string, capacity)}
+}
+
+func (r *recent) add(hash []byte) {
+	key := string(hash)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.set[key] {
+		return
+	}
+	if old := r.order[r.next]; old != "" {
+		delete(r.set, old)
+	}
+	r.set[key], r.order[r.next] = true, key
+	r.next = (r.next + 1) % len(r.order)
+}
+
+func (r *recent) has(hash []byte) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.set[string(hash)]
+}
@@ -0,0 +1,166 @@
+package rpc
+
+import (
+	"bytes"
+	"emulator/urd/client"
+	"emulator/urd/definition"
+	"emulator/urd/events"
+	"emulator/urd/shardinfo"
+	"emulator/urd/types"
+	"emulator/utils/p2p"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+type fakeMempool struct {
+	definition.MempoolConn
+	txs [][]byte
+}
+
+func (m *fakeMempool) AddTx(tx []byte) error {
+	m.txs = append(m.txs, tx)
+	return nil
+}
+
+type fakeABCI struct {
+	definition.ABCIConn
+	results map[string]*types.TxResult
+}
+
+func (a *fakeABCI) TxResult(hash []byte) (*types.TxResult, bool) {
+	r, ok := a.results[string(hash)]
+	return r, ok
+}
+func (a *fakeABCI) Account(key string) (*types.Account, error) {
+	return &types.Account{Key: key, Balance: 7, Lock: types.LockWrite}, nil
+}
+
+func newTestServer(t *testing.T) (*Server, *fakeMempool, *fakeABCI) {
+	peer := func(ip, shard string) *p2p.Peer {
+		return &p2p.Peer{IP: ip, Chain: map[string]bool{shard: true}}
+	}
+	router, err := client.NewRouter(&shardinfo.ShardInfo{Shards: map[string]*shardinfo.Shard{
+		"i1": {PeerList: []*p2p.Peer{peer("127.0.0.1:26601", "i1")}, KeyRange: "10,11"},
+		"i2": {PeerList: []*p2p.Peer{peer("127.0.0.1:26602", "i2")}, KeyRange: "11,12"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mempool, abci := &fakeMempool{}, &fakeABCI{results: map[string]*types.TxResult{}}
+	return NewServer("i1", router, p2p.NewSender("127.0.0.1:26601"), mempool, &fakeMempool{}, abci, nil), mempool, abci
+}
+
+func TestServer(t *testing.T) {
+	s, mempool, abci := newTestServer(t)
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	get := func(path string, code int, v interface{}) {
+		t.Helper()
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != code {
+			t.Fatalf("%s: %s, want %d", path, resp.Status, code)
+		}
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// the node leads i1, a tx of i1 goes to its own mempool
+	body, _ := json.Marshal(&BroadcastRequest{Transfer: &Transfer{From: []string{"10a"}, FromMoney: []uint32{1}, To: []string{"10b"}, ToMoney: []uint32{1}}})
+	resp, err := http.Post(server.URL+"/broadcast_tx", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result BroadcastResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(mempool.txs) != 1 || len(result.Shards) != 1 || result.Hash != types.TxKey(mempool.txs[0]) {
+		t.Fatalf("unexpected broadcast %s %+v", resp.Status, result)
+	}
+
+	var status TxStatus
+	get("/tx_status?hash="+result.Hash, http.StatusOK, &status)
+	if status.Status != types.TxPending {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	hash, _ := hex.DecodeString(result.Hash)
+	abci.results[string(hash)] = &types.TxResult{Status: types.TxAborted, Reason: "lock_conflict"}
+	get("/tx_status?hash="+result.Hash, http.StatusOK, &status)
+	if status.Status != types.TxAborted || status.Reason != "lock_conflict" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	get("/tx_status?hash=00", http.StatusNotFound, nil)
+
+	var shard KeyShard
+	get("/shard_for_key?key=11x", http.StatusOK, &shard)
+	if shard.Shard != "i2" || shard.Leader != "127.0.0.1:26602" {
+		t.Fatalf("unexpected shard %+v", shard)
+	}
+	get("/shard_for_key?key=99", http.StatusNotFound, nil)
+
+	var account Account
+	get("/account?key=10a", http.StatusOK, &account)
+	if account.Balance != 7 || account.Lock != types.LockWrite || account.Shard != "i1" {
+		t.Fatalf("unexpected account %+v", account)
+	}
+	// an account of another shard is asked of its nodes
+	get("/account?key=11x", http.StatusBadRequest, nil)
+}
+
+func TestSubscribe(t *testing.T) {
+	s, _, _ := newTestServer(t)
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	if _, err := websocket.Dial(url+"/subscribe?type=votes", "", server.URL); err == nil {
+		t.Fatal("subscribed to an unknown type")
+	}
+	ws, err := websocket.Dial(url+"/subscribe?type=receipt,tx&tx=0xAB&account=10a", "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	for deadline := time.Now().Add(5 * time.Second); !events.Active(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("not subscribed")
+		}
+	}
+	events.Publish(events.Event{Type: events.View, View: 3})
+	events.Publish(events.Event{Type: events.Receipt, Tx: "cd", Accounts: []string{"10b"}})
+	events.Publish(events.Event{Type: events.Tx, Tx: "ab", Status: types.TxLocked})
+	events.Publish(events.Event{Type: events.Receipt, Tx: "ef", Accounts: []string{"10a"}, Receipt: &events.ReceiptInfo{Code: types.CodeTypeOK}})
+
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []string{"ab", "ef"} {
+		var e events.Event
+		if err := websocket.JSON.Receive(ws, &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Tx != want {
+			t.Fatalf("got event %+v, want tx %s", e, want)
+		}
+	}
+	ws.Close()
+	for deadline := time.Now().Add(5 * time.Second); events.Active(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("still subscribed after the client left")
+		}
+	}
+}
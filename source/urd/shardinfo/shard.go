@@ -16,7 +16,9 @@ type Shard struct {
 	LeaderIndex int         `json:"leader_index"`
 	KeyRange    string      `json:"key_range"`
 
-	verifier *signer.Verifier
+	signer.SchemeParams
+
+	verifier signer.Verifier
 }
 
 func (shard *Shard) ToProto() *protoinfo.Shard {
@@ -27,6 +29,9 @@ func (shard *Shard) ToProto() *protoinfo.Shard {
 	return &protoinfo.Shard{
 		PeerList:   peers,
 		TotalVotes: shard.TotalVotes,
+		SignScheme: shard.Scheme,
+		GroupKey:   shard.GroupKey,
+		Threshold:  int32(shard.Threshold),
 	}
 }
 
@@ -42,11 +47,21 @@ func NewShardeFromProto(p *protoinfo.Shard) *Shard {
 	return &Shard{
 		PeerList:   peers,
 		TotalVotes: p.TotalVotes,
+		SchemeParams: signer.SchemeParams{
+			Scheme:    p.SignScheme,
+			GroupKey:  p.GroupKey,
+			Threshold: int(p.Threshold),
+		},
 	}
 }
 
 func (shard *Shard) Hash() []byte {
-	shardBasic := [][]byte{utils.IntToBytes(shard.TotalVotes)}
+	shardBasic := [][]byte{
+		utils.IntToBytes(shard.TotalVotes),
+		[]byte(shard.Name()),
+		[]byte(shard.GroupKey),
+		utils.IntToBytes(int32(shard.Threshold)),
+	}
 	for _, peer := range shard.PeerList {
 		shardBasic = append(shardBasic,
 			merkle.HashFromByteSlices(
@@ -62,11 +77,12 @@ func (shard *Shard) Hash() []byte {
 }
 
 func (shard *Shard) generateVerifier() error {
-	pubkeys := []string{}
+	pubkeys, pops := []string{}, []string{}
 	for _, peer := range shard.PeerList {
 		pubkeys = append(pubkeys, peer.Pubkey)
+		pops = append(pops, peer.Pop)
 	}
-	verifier, err := signer.NewVerifier(pubkeys)
+	verifier, err := signer.NewVerifierForScheme(shard.SchemeParams, pubkeys, pops)
 	if err != nil {
 		return err
 	}
@@ -74,6 +90,9 @@ func (shard *Shard) generateVerifier() error {
 	return nil
 }
 
+func (shard *Shard) Verifier() signer.Verifier {
+	return shard.verifier
+}
 func (shard *Shard) Size() int {
 	return shard.verifier.Size()
 }
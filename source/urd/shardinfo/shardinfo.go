@@ -3,18 +3,23 @@ package shardinfo
 import (
 	"emulator/crypto/merkle"
 	protoinfo "emulator/proto/urd/shardinfo"
+	"emulator/utils/logging"
 	"emulator/utils/p2p"
+	"emulator/utils/signer"
 	"encoding/json"
 	"fmt"
+	"os"
 	"sort"
 )
 
+var log = logging.New("node")
+
 type ShardInfo struct {
 	Shards      map[string]*Shard
 	ShardIDList []string
 }
 
-func NewShardInfo(peers map[string][]*p2p.Peer, leader_index int, keyRanges map[string]string) (si *ShardInfo) {
+func NewShardInfo(peers map[string][]*p2p.Peer, leader_index int, keyRanges map[string]string, schemes map[string]signer.SchemeParams) (si *ShardInfo) {
 	shards := make(map[string]*Shard)
 	for shard, ps := range peers {
 		var totalVotes int32 = 0
@@ -22,10 +27,11 @@ func NewShardInfo(peers map[string][]*p2p.Peer, leader_index int, keyRanges map[
 			totalVotes += p.Vote
 		}
 		shards[shard] = &Shard{
-			PeerList:    ps,
-			TotalVotes:  totalVotes,
-			LeaderIndex: leader_index,
-			KeyRange:    keyRanges[shard],
+			PeerList:     ps,
+			TotalVotes:   totalVotes,
+			LeaderIndex:  leader_index,
+			KeyRange:     keyRanges[shard],
+			SchemeParams: schemes[shard],
 		}
 	}
 	si = &ShardInfo{
@@ -41,9 +47,9 @@ func (si *ShardInfo) init() error {
 	si.ShardIDList = []string{}
 	for id, shard := range si.Shards {
 		if err := shard.generateVerifier(); err != nil {
-			return err
+			return fmt.Errorf("shard %s: %v", id, err)
 		}
-		fmt.Println("Generated Verifier for Shard:", id)
+		log.Debug("generated verifier", "shard", id)
 		si.ShardIDList = append(si.ShardIDList, id)
 	}
 	sort.Strings(si.ShardIDList)
@@ -69,7 +75,7 @@ func NewShardInfoFromProto(p *protoinfo.ShardInfo) *ShardInfo {
 		Shards: shards,
 	}
 	if err := si.init(); err != nil {
-		fmt.Println("ShardInfo init error:", err)
+		log.Error("shard info is invalid", "err", err)
 		return nil
 	}
 	return si
@@ -101,3 +107,16 @@ func (si *ShardInfo) UnmarshalJson(data []byte) error {
 	}
 	return nil
 }
+
+// Read loads the shard info written at path.
+func Read(path string) (*ShardInfo, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	si := new(ShardInfo)
+	if err := si.UnmarshalJson(bz); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return si, nil
+}
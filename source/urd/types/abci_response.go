@@ -3,6 +3,7 @@ package types
 import (
 	prototypes "emulator/proto/urd/types"
 	"sort"
+	"strconv"
 
 	"google.golang.org/protobuf/proto"
 )
@@ -16,11 +17,84 @@ const (
 )
 
 type ABCIExecutionResponse struct {
-	Responses           []*ABCIExecutionReceipt
-	OPTxs               []Txs
+	Responses []*ABCIExecutionReceipt
+	OPTxs     []Txs
+	// CrossShardResponses are the receipts of the cross-shard txs the block
+	// locked, with status TxLocked or TxAborted, and of those it decided, with
+	// status TxCommitted or TxAborted. Every shard of a tx reaches the same
+	// decision, and gives one receipt that is not TxLocked for it.
 	CrossShardResponses []*ABCIExecutionReceipt
 }
 
+// Decisions counts the cross-shard txs committed and aborted in resp.
+func (resp *ABCIExecutionResponse) Decisions() (committed, aborted int) {
+	for _, receipt := range resp.CrossShardResponses {
+		switch receipt.Status() {
+		case TxCommitted:
+			committed++
+		case TxAborted:
+			aborted++
+		}
+	}
+	return committed, aborted
+}
+
+// DecisionShares is Decisions with each tx weighted by its share of the
+// shards that decide it, so that the shards of a tx count it once together.
+func (resp *ABCIExecutionResponse) DecisionShares() (committed, aborted float64) {
+	for _, receipt := range resp.CrossShardResponses {
+		share := 1 / float64(receipt.Shards())
+		switch receipt.Status() {
+		case TxCommitted:
+			committed += share
+		case TxAborted:
+			aborted += share
+		}
+	}
+	return committed, aborted
+}
+
+// ToProto leaves out the raw txs of the receipts, which are in the blocks.
+func (resp *ABCIExecutionResponse) ToProto() *prototypes.ABCIExecutionResponse {
+	receipts := func(list []*ABCIExecutionReceipt) []*prototypes.ABCIExecutionReceipt {
+		out := make([]*prototypes.ABCIExecutionReceipt, len(list))
+		for i, r := range list {
+			options := make(map[string]string, len(r.Options))
+			for key, value := range r.Options {
+				if key != "rawTx" {
+					options[key] = value
+				}
+			}
+			out[i] = (&ABCIExecutionReceipt{Code: r.Code, Log: r.Log, Info: r.Info, Options: options}).ToProto()
+		}
+		return out
+	}
+	return &prototypes.ABCIExecutionResponse{
+		Responses:           receipts(resp.Responses),
+		CrossShardResponses: receipts(resp.CrossShardResponses),
+	}
+}
+func (resp *ABCIExecutionResponse) ProtoBytes() []byte {
+	return MustProtoBytes(resp.ToProto())
+}
+func NewABCIExecutionResponseFromBytes(bz []byte) (*ABCIExecutionResponse, error) {
+	p := new(prototypes.ABCIExecutionResponse)
+	if err := proto.Unmarshal(bz, p); err != nil {
+		return nil, err
+	}
+	resp := &ABCIExecutionResponse{
+		Responses:           make([]*ABCIExecutionReceipt, len(p.Responses)),
+		CrossShardResponses: make([]*ABCIExecutionReceipt, len(p.CrossShardResponses)),
+	}
+	for i, r := range p.Responses {
+		resp.Responses[i] = NewABCIReceiptFromProto(r)
+	}
+	for i, r := range p.CrossShardResponses {
+		resp.CrossShardResponses[i] = NewABCIReceiptFromProto(r)
+	}
+	return resp, nil
+}
+
 type ABCIPreExecutionResponse struct {
 	NewCrossShardTxs [][]byte
 	OPTs             []Txs
@@ -48,6 +122,32 @@ func (r *ABCIExecutionReceipt) GetRawTx() []byte {
 	return []byte(tx)
 }
 
+// SetStatus sets the TxResult status of a cross-shard tx, and its hash.
+func (r *ABCIExecutionReceipt) SetStatus(status string, hash []byte) {
+	if r.Options == nil {
+		r.Options = make(map[string]string)
+	}
+	r.Options["status"], r.Options["hash"] = status, string(hash)
+}
+func (r *ABCIExecutionReceipt) Status() string { return r.Options["status"] }
+
+// SetShards sets the number of shards of a cross-shard tx.
+func (r *ABCIExecutionReceipt) SetShards(shards int) {
+	if r.Options == nil {
+		r.Options = make(map[string]string)
+	}
+	r.Options["shards"] = strconv.Itoa(shards)
+}
+
+// Shards is the number of shards of a cross-shard tx, 1 if not set.
+func (r *ABCIExecutionReceipt) Shards() int {
+	if n, err := strconv.Atoi(r.Options["shards"]); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
+func (r *ABCIExecutionReceipt) TxHash() []byte { return []byte(r.Options["hash"]) }
+
 func (r *ABCIExecutionReceipt) IsOK() bool { return r.Code == CodeTypeOK }
 
 func (r *ABCIExecutionReceipt) ToProto() *prototypes.ABCIExecutionReceipt {
@@ -57,7 +157,7 @@ func (r *ABCIExecutionReceipt) ToProto() *prototypes.ABCIExecutionReceipt {
 	}
 	sort.Strings(opKeyList)
 	for _, key := range opKeyList {
-		opKeyList = append(opKeyList, r.Options[key])
+		opValueList = append(opValueList, r.Options[key])
 	}
 	return &prototypes.ABCIExecutionReceipt{
 		Code:        int32(r.Code),
@@ -5,6 +5,7 @@ import (
 	"emulator/core/hotstuff"
 	"emulator/crypto/merkle"
 	"emulator/utils"
+	"emulator/utils/logging"
 	"encoding/hex"
 	"fmt"
 	"time"
@@ -14,6 +15,8 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+var log = logging.New("consensus")
+
 type Block struct {
 	Header `json:"header"`
 
@@ -283,10 +286,10 @@ func (b *Block) ValidateBasic() error {
 	intentions, errs := b.CC.Result()
 	for i, intention := range intentions {
 		if errs[i] {
-			fmt.Println("Skipping CTXSProof validation for intention with error:", hex.EncodeToString(intention), errs[i])
+			log.Debug("skipping CTXSProof of an aborted intention", "intention", intention)
 			continue
 		}
-		fmt.Println("Validating CTXSProof for intention:", hex.EncodeToString(intention))
+		log.Debug("validating CTXSProof", "intention", intention)
 		if err := b.CTXSProof[i].Verify(GetLastHashOfAggVote(b.CI.AggregatedSignatures[i]), b.CTXS[i]); err != nil {
 			return err
 		}
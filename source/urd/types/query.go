@@ -0,0 +1,36 @@
+package types
+
+// What the ABCI answers the queries of the RPC with.
+
+// The outcome of a tx at a shard.
+const (
+	TxPending   = "pending"
+	TxLocked    = "locked"
+	TxCommitted = "committed"
+	TxAborted   = "aborted"
+)
+
+type TxResult struct {
+	Status string `json:"status"`
+	// Reason is why an aborted tx was aborted, like lock_conflict, and Error
+	// the error it was aborted with
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// The lock an account is under.
+const (
+	LockFree  = "free"
+	LockRead  = "read"
+	LockWrite = "write"
+)
+
+type Account struct {
+	Key     string `json:"key"`
+	Balance uint32 `json:"balance"`
+	Lock    string `json:"lock"`
+	// Nonce is the highest nonce of the txs of the account, which the
+	// genesis binds to PubKey
+	Nonce  uint64 `json:"nonce"`
+	PubKey string `json:"pub_key,omitempty"`
+}
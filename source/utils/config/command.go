@@ -0,0 +1,103 @@
+package config
+
+import (
+	"emulator/utils/genesis"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LoadNode loads the config of the node in root, like Load.
+func LoadNode(root string, protocol string, overrides []string) (*Config, error) {
+	c, err := Load((&Config{DirRoot: root}).ConfigPath(), protocol, overrides)
+	if err != nil {
+		return nil, err
+	}
+	c.DirRoot = root
+	return c, nil
+}
+
+// Command is the config subcommand of both binaries. "config validate"
+// loads the config of a node, or of every node of a testnet, as the node
+// would and checks it against the node's genesis.
+func Command(protocol string, args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return fmt.Errorf("usage: config validate --root=<node or testnet dir> [--set key=value]... [--print]")
+	}
+	flags := flag.NewFlagSet("config validate", flag.ExitOnError)
+	root := flags.String("root", ".", "Directory of a node, or of a testnet whose nodes are all checked")
+	show := flags.Bool("print", false, "Print the config of every node after the environment and --set are applied")
+	var overrides Overrides
+	flags.Var(&overrides, "set", "Override a setting like consensus.max_part_size=40960, can be repeated")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	nodes, err := findNodes(*root)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("no node config under %s", *root)
+	}
+	invalid := 0
+	for _, node := range nodes {
+		c, err := validateNode(node, protocol, overrides)
+		if err != nil {
+			fmt.Println(err)
+			invalid++
+			continue
+		}
+		fmt.Printf("%s: ok\n", c.ConfigPath())
+		if *show {
+			if err := configTemplate.Execute(os.Stdout, c); err != nil {
+				return err
+			}
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d configs are invalid", invalid, len(nodes))
+	}
+	return nil
+}
+
+func validateNode(root string, protocol string, overrides []string) (*Config, error) {
+	c, err := LoadNode(root, protocol, overrides)
+	if err != nil {
+		return nil, err
+	}
+	gen, err := genesis.Load(c.GenesisPath())
+	if err != nil {
+		return nil, err
+	}
+	if err := gen.Params.Check(c.GenesisParams()); err != nil {
+		return nil, fmt.Errorf("%s: %v", c.ConfigPath(), err)
+	}
+	if gen.Shard(c.ChainID) == nil {
+		return nil, fmt.Errorf("%s: shard %s is not in %s", c.ConfigPath(), c.ChainID, c.GenesisPath())
+	}
+	return c, nil
+}
+
+// findNodes returns the directories under root that hold a node config.
+func findNodes(root string) ([]string, error) {
+	nodes := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || d.Name() != configDir {
+			return nil
+		}
+		for _, name := range []string{configFile, configFileYML} {
+			if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+				nodes = append(nodes, filepath.Dir(path))
+				break
+			}
+		}
+		return filepath.SkipDir
+	})
+	return nodes, err
+}
@@ -0,0 +1,411 @@
+package config
+
+import (
+	"emulator/utils/genesis"
+	"emulator/utils/logging"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// config.toml is the config of a single node, urd and pyramid share its
+// schema and leave out the settings of the other protocol. A setting is
+// read, in increasing precedence, from the defaults below, the file, the
+// environment (URD_CONSENSUS_MAX_PART_SIZE for consensus.max_part_size of
+// urd) and --set consensus.max_part_size=40960 on the command line. Every
+// setting is used by the node, unknown settings are rejected.
+
+const (
+	configDir      = "config"
+	configFile     = "config.toml"
+	configFileYML  = "config.yaml"
+	privateKeyFile = "private_key.json"
+	shardInfoFile  = "shard_info.json"
+	storeDir       = "database"
+	datasetDir     = "dataset"
+)
+
+// MaxPipelineDepth is the number of views from the proposal of a block to the
+// commit certificate of its cross-shard txs. At this depth, Urd, every view
+// takes new txs; at depth 1, CoCSV, only every MaxPipelineDepth-th view does.
+const MaxPipelineDepth = 6
+
+const ABCIMinibank = "minibank"
+
+// MetricsPortOffset is added to p2p.port for the metrics port, unless
+// metrics.listen is set.
+const MetricsPortOffset = 1000
+
+// RPCPortOffset is added to p2p.port for the RPC port of urd, unless
+// rpc.listen is set.
+const RPCPortOffset = 2000
+
+type Config struct {
+	NodeName string `mapstructure:"node_name"`
+	DirRoot  string `mapstructure:"dir_root"`
+	ChainID  string `mapstructure:"chain_id"`
+	// Protocol is genesis.ProtocolUrd or genesis.ProtocolPyramid
+	Protocol string `mapstructure:"protocol"`
+
+	P2P       P2P       `mapstructure:"p2p"`
+	Consensus Consensus `mapstructure:"consensus"`
+	Mempool   Mempool   `mapstructure:"mempool"`
+	ABCI      ABCI      `mapstructure:"abci"`
+	Metrics   Metrics   `mapstructure:"metrics"`
+	Log       Log       `mapstructure:"log"`
+	// Trace and RPC are only used by urd
+	Trace Trace `mapstructure:"trace"`
+	RPC   RPC   `mapstructure:"rpc"`
+	// Shard is only used by pyramid
+	Shard Shard `mapstructure:"shard"`
+}
+
+type P2P struct {
+	IP   string `mapstructure:"ip"`
+	Port int    `mapstructure:"port"`
+	// HandshakeTimeout stops the node if its peers are not ready in time,
+	// 0 waits until they are
+	HandshakeTimeout time.Duration `mapstructure:"handshake_timeout"`
+}
+
+type Consensus struct {
+	SignerIndex      int           `mapstructure:"signer_index"`
+	MinBlockInterval time.Duration `mapstructure:"min_block_interval"`
+	MaxPartSize      int           `mapstructure:"max_part_size"`
+
+	// urd
+	MaxBlockTxBytes           int           `mapstructure:"max_block_tx_bytes"`
+	MaxBlockCrossShardTxBytes int           `mapstructure:"max_cross_shard_tx_bytes"`
+	PipelineDepth             int           `mapstructure:"pipeline_depth"`
+	FirstBlockDelay           time.Duration `mapstructure:"first_block_delay"`
+	// MaxViews stops the node cleanly once reached, 0 runs until interrupted
+	MaxViews int64 `mapstructure:"max_views"`
+
+	// pyramid
+	MaxBlockTxNum int `mapstructure:"max_block_tx_num"`
+	// MaxHeight stops the node cleanly once reached, 0 runs until interrupted
+	MaxHeight int64 `mapstructure:"max_height"`
+}
+
+type Mempool struct {
+	// Size and CrossShardSize cap the txs a mempool holds, 0 is unbounded
+	Size           int `mapstructure:"size"`
+	CrossShardSize int `mapstructure:"cross_shard_size"`
+	// PreloadPending is how full urd's importer keeps a mempool while it
+	// streams the dataset
+	PreloadPending int `mapstructure:"preload_pending"`
+}
+
+type ABCI struct {
+	App string `mapstructure:"app"`
+	// LockLeaseViews aborts a cross-shard tx that holds locks for this many
+	// views without being decided, 0 holds them until it is (urd)
+	LockLeaseViews int64 `mapstructure:"lock_lease_views"`
+	// SharedLocks read locks the accounts a cross-shard tx only credits,
+	// false write locks all of them (urd)
+	SharedLocks bool `mapstructure:"shared_locks"`
+}
+
+type Metrics struct {
+	// Enabled serves the metrics of the node on http://<listen>/metrics and
+	// its status on http://<listen>/status
+	Enabled bool `mapstructure:"enabled"`
+	// Listen defaults to p2p.ip at p2p.port + MetricsPortOffset
+	Listen string `mapstructure:"listen"`
+}
+
+type Log struct {
+	// Level is "info", or per module like "consensus:debug,p2p:warn,*:info"
+	Level string `mapstructure:"level"`
+	// Format is json or text
+	Format string `mapstructure:"format"`
+}
+
+type Trace struct {
+	// Enabled writes the lifecycle of txs to <node>-txtrace.txt
+	Enabled bool `mapstructure:"enabled"`
+	// Sample is the share of txs traced, picked by their hash so that every
+	// shard traces the same txs
+	Sample float64 `mapstructure:"sample"`
+}
+
+type RPC struct {
+	// Enabled serves the client API of the node on http://<listen> and keeps
+	// the executed blocks, which the latency of a run is measured from
+	Enabled bool `mapstructure:"enabled"`
+	// Listen defaults to p2p.ip at p2p.port + RPCPortOffset
+	Listen string `mapstructure:"listen"`
+	// TxIndexSize is how many of the latest tx outcomes tx_status remembers
+	TxIndexSize int `mapstructure:"tx_index_size"`
+}
+
+type Shard struct {
+	IsI       bool `mapstructure:"is_i_shard"`
+	BShardNum int  `mapstructure:"b_shard_num"`
+	IShardNum int  `mapstructure:"i_shard_num"`
+}
+
+// Default returns the config of a node of protocol before the node specific
+// settings are filled in.
+func Default(protocol string) *Config {
+	c := &Config{
+		Protocol: protocol,
+		Consensus: Consensus{
+			MinBlockInterval: 10 * time.Millisecond,
+		},
+		ABCI:    ABCI{App: ABCIMinibank},
+		Metrics: Metrics{Enabled: true},
+		Log:     Log{Level: "info", Format: logging.FormatJSON},
+		Trace:   Trace{Sample: 1},
+	}
+	switch protocol {
+	case genesis.ProtocolUrd:
+		c.Consensus.MaxPartSize = 40 * 1024
+		c.Consensus.MaxBlockTxBytes = 20 * 1024
+		c.Consensus.MaxBlockCrossShardTxBytes = 160 * 1024
+		c.Consensus.PipelineDepth = MaxPipelineDepth
+		c.Consensus.FirstBlockDelay = 10 * time.Second
+		c.Mempool.PreloadPending = 20000
+		c.ABCI.LockLeaseViews = 60
+		c.ABCI.SharedLocks = true
+		c.RPC = RPC{Enabled: true, TxIndexSize: 100000}
+	case genesis.ProtocolPyramid:
+		c.Consensus.MaxPartSize = 200 * 1024
+		c.Consensus.MaxBlockTxNum = 4096
+	}
+	return c
+}
+
+func (c *Config) PrivateKeyPath() string { return filepath.Join(c.DirRoot, configDir, privateKeyFile) }
+func (c *Config) ShardInfoPath() string  { return filepath.Join(c.DirRoot, configDir, shardInfoFile) }
+func (c *Config) StoreDirRoot() string   { return filepath.Join(c.DirRoot, storeDir) }
+func (c *Config) ConfigDir() string      { return filepath.Join(c.DirRoot, configDir) }
+func (c *Config) DatasetDir() string     { return filepath.Join(c.DirRoot, datasetDir) }
+func (c *Config) GenesisPath() string    { return filepath.Join(c.DirRoot, configDir, genesis.FileName) }
+
+// ConfigPath is config/config.toml, or config/config.yaml if only that
+// exists.
+func (c *Config) ConfigPath() string {
+	path := filepath.Join(c.DirRoot, configDir, configFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		yml := filepath.Join(c.DirRoot, configDir, configFileYML)
+		if _, err := os.Stat(yml); err == nil {
+			return yml
+		}
+	}
+	return path
+}
+
+// MetricsAddress is the address the metrics are served on.
+func (c *Config) MetricsAddress() string {
+	if c.Metrics.Listen != "" {
+		return c.Metrics.Listen
+	}
+	return net.JoinHostPort(c.P2P.IP, strconv.Itoa(c.P2P.Port+MetricsPortOffset))
+}
+
+// RPCAddress is the address the RPC server listens on.
+func (c *Config) RPCAddress() string {
+	if c.RPC.Listen != "" {
+		return c.RPC.Listen
+	}
+	return net.JoinHostPort(c.P2P.IP, strconv.Itoa(c.P2P.Port+RPCPortOffset))
+}
+
+// GenesisParams are the parameters of the config that every node must share.
+func (c *Config) GenesisParams() genesis.Params {
+	return genesis.Params{
+		MinBlockInterval:          c.Consensus.MinBlockInterval.String(),
+		MaxPartSize:               c.Consensus.MaxPartSize,
+		MaxBlockTxBytes:           c.Consensus.MaxBlockTxBytes,
+		MaxBlockCrossShardTxBytes: c.Consensus.MaxBlockCrossShardTxBytes,
+		MaxBlockTxNum:             c.Consensus.MaxBlockTxNum,
+		LockLeaseViews:            c.ABCI.LockLeaseViews,
+		SharedLocks:               c.ABCI.SharedLocks,
+	}
+}
+
+// PipelineStride is the number of views between two views that take new txs.
+func (c *Consensus) PipelineStride() int {
+	return MaxPipelineDepth / c.PipelineDepth
+}
+
+// Load reads the config of a node of protocol at path, TOML or YAML by its
+// extension, and applies the environment and overrides, each "key=value".
+func Load(path string, protocol string, overrides []string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetEnvPrefix(envPrefix(protocol))
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	c, err := load(v, protocol, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// DefaultWith is Default with overrides applied, for generating configs.
+func DefaultWith(protocol string, overrides []string) (*Config, error) {
+	return load(viper.New(), protocol, overrides)
+}
+
+func load(v *viper.Viper, protocol string, overrides []string) (*Config, error) {
+	known := map[string]bool{}
+	settings(Default(protocol), func(key string, value interface{}) {
+		known[key] = true
+		v.SetDefault(key, value)
+	})
+	for _, o := range overrides {
+		key, value, ok := strings.Cut(o, "=")
+		if !ok {
+			return nil, fmt.Errorf("override %q is not key=value", o)
+		}
+		if !known[key] {
+			return nil, fmt.Errorf("override %q: unknown setting %s", o, key)
+		}
+		v.Set(key, value)
+	}
+
+	c := new(Config)
+	if err := v.UnmarshalExact(c); err != nil {
+		return nil, err
+	}
+	if c.Protocol != protocol {
+		return nil, fmt.Errorf("a %s config, this is %s", c.Protocol, protocol)
+	}
+	return c, nil
+}
+
+func envPrefix(protocol string) string { return strings.ToUpper(protocol) }
+
+// settings calls f with the key and value of every setting of c.
+func settings(c *Config, f func(key string, value interface{})) {
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			key := t.Field(i).Tag.Get("mapstructure")
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if t.Field(i).Type.Kind() == reflect.Struct {
+				walk(key, v.Field(i))
+			} else {
+				f(key, v.Field(i).Interface())
+			}
+		}
+	}
+	walk("", reflect.ValueOf(c).Elem())
+}
+
+// Validate returns every setting that is out of range, or set although the
+// protocol does not use it.
+func (c *Config) Validate() error {
+	errs := []string{}
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+	unused := func(key string, zero bool) {
+		check(zero, "%s is not used by %s", key, c.Protocol)
+	}
+
+	check(c.NodeName != "", "node_name is not set")
+	check(c.ChainID != "", "chain_id is not set")
+	check(c.P2P.IP != "", "p2p.ip is not set")
+	check(c.P2P.Port > 0 && c.P2P.Port < 65536, "p2p.port %d is not a port", c.P2P.Port)
+	check(c.P2P.HandshakeTimeout >= 0, "p2p.handshake_timeout is negative")
+
+	check(c.Consensus.SignerIndex >= 0, "consensus.signer_index is negative")
+	check(c.Consensus.MinBlockInterval >= 0, "consensus.min_block_interval is negative")
+	check(c.Consensus.MaxPartSize > 0, "consensus.max_part_size must be positive")
+
+	check(c.Mempool.Size >= 0, "mempool.size is negative")
+	check(c.Mempool.CrossShardSize >= 0, "mempool.cross_shard_size is negative")
+	check(c.ABCI.App == ABCIMinibank, "abci.app %q is not %s", c.ABCI.App, ABCIMinibank)
+	if c.Metrics.Listen != "" {
+		_, port, err := net.SplitHostPort(c.Metrics.Listen)
+		check(err == nil && port != "", "metrics.listen %q is not host:port", c.Metrics.Listen)
+	} else {
+		check(!c.Metrics.Enabled || c.P2P.Port+MetricsPortOffset < 65536,
+			"the metrics port %d is not a port, set metrics.listen", c.P2P.Port+MetricsPortOffset)
+	}
+	if _, err := logging.ParseLevels(c.Log.Level); err != nil {
+		check(false, "log.level: %v", err)
+	}
+	check(c.Log.Format == logging.FormatJSON || c.Log.Format == logging.FormatText,
+		"log.format %q is not %s or %s", c.Log.Format, logging.FormatJSON, logging.FormatText)
+	check(c.Trace.Sample > 0 && c.Trace.Sample <= 1, "trace.sample %v is not in (0, 1]", c.Trace.Sample)
+
+	switch c.Protocol {
+	case genesis.ProtocolUrd:
+		check(c.Consensus.MaxBlockTxBytes > 0, "consensus.max_block_tx_bytes must be positive")
+		check(c.Consensus.MaxBlockCrossShardTxBytes > 0, "consensus.max_cross_shard_tx_bytes must be positive")
+		check(c.Consensus.PipelineDepth > 0 && MaxPipelineDepth%c.Consensus.PipelineDepth == 0,
+			"consensus.pipeline_depth %d does not divide %d", c.Consensus.PipelineDepth, MaxPipelineDepth)
+		check(c.Consensus.FirstBlockDelay >= 0, "consensus.first_block_delay is negative")
+		check(c.Consensus.MaxViews >= 0, "consensus.max_views is negative")
+		check(c.Mempool.PreloadPending > 0, "mempool.preload_pending must be positive")
+		for _, size := range []int{c.Mempool.Size, c.Mempool.CrossShardSize} {
+			check(size == 0 || size >= c.Mempool.PreloadPending,
+				"mempool.preload_pending %d does not fit a mempool of %d txs", c.Mempool.PreloadPending, size)
+		}
+		if c.RPC.Listen != "" {
+			_, port, err := net.SplitHostPort(c.RPC.Listen)
+			check(err == nil && port != "", "rpc.listen %q is not host:port", c.RPC.Listen)
+		} else {
+			check(!c.RPC.Enabled || c.P2P.Port+RPCPortOffset < 65536,
+				"the rpc port %d is not a port, set rpc.listen", c.P2P.Port+RPCPortOffset)
+		}
+		check(c.RPC.TxIndexSize >= 0, "rpc.tx_index_size is negative")
+		check(c.ABCI.LockLeaseViews >= 0, "abci.lock_lease_views is negative")
+		unused("consensus.max_block_tx_num", c.Consensus.MaxBlockTxNum == 0)
+		unused("consensus.max_height", c.Consensus.MaxHeight == 0)
+		unused("shard", c.Shard == Shard{})
+	case genesis.ProtocolPyramid:
+		check(c.Consensus.MaxBlockTxNum > 0, "consensus.max_block_tx_num must be positive")
+		check(c.Consensus.MaxHeight >= 0, "consensus.max_height is negative")
+		check(c.Shard.BShardNum > 0, "shard.b_shard_num must be positive")
+		check(c.Shard.IShardNum >= 0, "shard.i_shard_num is negative")
+		unused("consensus.max_block_tx_bytes", c.Consensus.MaxBlockTxBytes == 0)
+		unused("consensus.max_cross_shard_tx_bytes", c.Consensus.MaxBlockCrossShardTxBytes == 0)
+		unused("consensus.pipeline_depth", c.Consensus.PipelineDepth == 0)
+		unused("consensus.first_block_delay", c.Consensus.FirstBlockDelay == 0)
+		unused("consensus.max_views", c.Consensus.MaxViews == 0)
+		unused("mempool.preload_pending", c.Mempool.PreloadPending == 0)
+		unused("rpc", c.RPC == RPC{})
+		unused("abci.lock_lease_views", c.ABCI.LockLeaseViews == 0)
+		unused("abci.shared_locks", !c.ABCI.SharedLocks)
+		check(!c.Shard.IsI || c.Mempool.CrossShardSize == 0, "mempool.cross_shard_size is not used by an I-shard")
+	default:
+		errs = append(errs, fmt.Sprintf("unknown protocol %q", c.Protocol))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Overrides collects repeated --set key=value flags.
+type Overrides []string
+
+func (o *Overrides) String() string { return strings.Join(*o, ",") }
+func (o *Overrides) Set(s string) error {
+	*o = append(*o, s)
+	return nil
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"emulator/utils/genesis"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig(protocol string) *Config {
+	c := Default(protocol)
+	c.NodeName = "node1"
+	c.ChainID = "i1"
+	c.P2P.IP = "127.0.0.1"
+	c.P2P.Port = 26601
+	if protocol == genesis.ProtocolPyramid {
+		c.Shard = Shard{IsI: true, BShardNum: 2, IShardNum: 1}
+	}
+	return c
+}
+
+func writeConfig(t *testing.T, c *Config) string {
+	path := filepath.Join(t.TempDir(), configFile)
+	if err := c.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWriteLoad(t *testing.T) {
+	for _, protocol := range []string{genesis.ProtocolUrd, genesis.ProtocolPyramid} {
+		want := testConfig(protocol)
+		got, err := Load(writeConfig(t, want), protocol, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != *want {
+			t.Fatalf("%s: loaded %+v, wrote %+v", protocol, got, want)
+		}
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	path := writeConfig(t, testConfig(genesis.ProtocolUrd))
+	t.Setenv("URD_CONSENSUS_MAX_PART_SIZE", "1024")
+	t.Setenv("URD_P2P_HANDSHAKE_TIMEOUT", "30s")
+	c, err := Load(path, genesis.ProtocolUrd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Consensus.MaxPartSize != 1024 || c.P2P.HandshakeTimeout != 30*time.Second {
+		t.Fatalf("the environment was not applied: %+v", c)
+	}
+	// --set wins over the environment
+	c, err = Load(path, genesis.ProtocolUrd, []string{"consensus.max_part_size=2048", "consensus.pipeline_depth=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Consensus.MaxPartSize != 2048 || c.Consensus.PipelineStride() != MaxPipelineDepth {
+		t.Fatalf("the overrides were not applied: %+v", c)
+	}
+	for _, o := range []string{"consensus.max_part_sizes=1", "max_part_size", "consensus.pipeline_depth=4", "consensus.max_height=10"} {
+		if _, err := Load(path, genesis.ProtocolUrd, []string{o}); err == nil {
+			t.Fatalf("override %s was accepted", o)
+		}
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	path := writeConfig(t, testConfig(genesis.ProtocolUrd))
+	if _, err := Load(path, genesis.ProtocolPyramid, nil); err == nil {
+		t.Fatal("a urd config was loaded by pyramid")
+	}
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bz = []byte(strings.Replace(string(bz), "[mempool]", "[mempool]\nmax_txs = 10", 1))
+	if err := os.WriteFile(path, bz, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, genesis.ProtocolUrd, nil); err == nil || !strings.Contains(err.Error(), "max_txs") {
+		t.Fatalf("an unknown setting was accepted: %v", err)
+	}
+}
+
+func TestLoadYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), configFileYML)
+	yml := `node_name: node1
+chain_id: b1
+protocol: pyramid
+p2p:
+  ip: 127.0.0.1
+  port: 26601
+consensus:
+  min_block_interval: 50ms
+shard:
+  b_shard_num: 1
+`
+	if err := os.WriteFile(path, []byte(yml), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(path, genesis.ProtocolPyramid, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Consensus.MinBlockInterval != 50*time.Millisecond || c.Consensus.MaxBlockTxNum != Default(genesis.ProtocolPyramid).Consensus.MaxBlockTxNum {
+		t.Fatalf("unexpected config %+v", c)
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"os"
+	"text/template"
+)
+
+const configTOML = `# ===================================================
+#              Config of a {{.Protocol}} node
+# ===================================================
+# Any setting can be overridden by the environment, like
+# {{envPrefix .Protocol}}_CONSENSUS_MAX_PART_SIZE=40960, or when starting the node with
+# --set consensus.max_part_size=40960. "config validate" shows the result.
+
+node_name = "{{.NodeName}}"
+dir_root  = "{{.DirRoot}}"
+chain_id  = "{{.ChainID}}"
+protocol  = "{{.Protocol}}"
+
+# ===================================================
+#              P2P Module
+# ===================================================
+[p2p]
+ip   = "{{.P2P.IP}}"
+port = {{.P2P.Port}}
+# stop the node if its peers are not ready by then, 0 waits for them
+handshake_timeout = "{{.P2P.HandshakeTimeout}}"
+
+# ===================================================
+#              Consensus Module
+# ===================================================
+[consensus]
+signer_index       = {{.Consensus.SignerIndex}}
+# the leader proposes at most one block per interval
+min_block_interval = "{{.Consensus.MinBlockInterval}}"
+# blocks are sent in parts of at most this many bytes
+max_part_size      = {{.Consensus.MaxPartSize}}
+{{- if eq .Protocol "urd"}}
+max_block_tx_bytes       = {{.Consensus.MaxBlockTxBytes}}
+max_cross_shard_tx_bytes = {{.Consensus.MaxBlockCrossShardTxBytes}}
+# views in flight that carry txs: 6 takes txs every view (Urd), 1 every 6th
+# view (CoCSV), 2 and 3 are in between
+pipeline_depth           = {{.Consensus.PipelineDepth}}
+# the leader waits this long before the first block
+first_block_delay        = "{{.Consensus.FirstBlockDelay}}"
+# stop cleanly once this view is reached, 0 runs until SIGINT/SIGTERM
+max_views                = {{.Consensus.MaxViews}}
+{{- else}}
+max_block_tx_num   = {{.Consensus.MaxBlockTxNum}}
+# stop cleanly once this height is reached, 0 runs until SIGINT/SIGTERM
+max_height         = {{.Consensus.MaxHeight}}
+{{- end}}
+
+# ===================================================
+#              Mempool
+# ===================================================
+[mempool]
+# txs held at most, more are rejected; 0 is unbounded
+size             = {{.Mempool.Size}}
+cross_shard_size = {{.Mempool.CrossShardSize}}
+{{- if eq .Protocol "urd"}}
+# the preloaded dataset is streamed into a mempool holding fewer txs
+preload_pending  = {{.Mempool.PreloadPending}}
+{{- end}}
+
+# ===================================================
+#              ABCI Module
+# ===================================================
+[abci]
+app = "{{.ABCI.App}}"
+{{- if eq .Protocol "urd"}}
+# a cross-shard tx still undecided this many views after a shard locked it is
+# aborted by all its shards; 0 keeps the locks until it is decided
+lock_lease_views = {{.ABCI.LockLeaseViews}}
+# read lock the accounts a cross-shard tx only credits, so that txs crediting
+# the same account do not conflict; false write locks every account
+shared_locks     = {{.ABCI.SharedLocks}}
+{{- end}}
+
+# ===================================================
+#              Metrics
+# ===================================================
+[metrics]
+# serve Prometheus metrics on http://<listen>/metrics, the status of the node
+# on http://<listen>/status and a page of it on http://<listen>/
+enabled = {{.Metrics.Enabled}}
+# empty listens on p2p.ip at p2p.port + 1000
+listen  = "{{.Metrics.Listen}}"
+
+# ===================================================
+#              Logging
+# ===================================================
+[log]
+# "info", or a level per module like "consensus:debug,p2p:warn,*:info";
+# the modules are consensus, p2p, mempool, abci, importer, rpc and node, the
+# levels debug, info, warn, error and none
+level  = "{{.Log.Level}}"
+# json writes a JSON object per line, text is easier to read
+format = "{{.Log.Format}}"
+{{- if eq .Protocol "urd"}}
+
+# ===================================================
+#              Tx tracing
+# ===================================================
+[trace]
+# write when txs are admitted, included, locked, relayed, unlocked and
+# committed or aborted to <node>-txtrace.txt; ./logger trace joins the files
+# of all shards into end-to-end latencies
+enabled = {{.Trace.Enabled}}
+# share of txs traced, picked by hash so that every shard traces the same
+sample  = {{.Trace.Sample}}
+
+# ===================================================
+#              RPC
+# ===================================================
+[rpc]
+# serve the client API on http://<listen>: broadcast_tx, tx_status, block,
+# account and shard_for_key, and keep the executed blocks for the latency
+enabled       = {{.RPC.Enabled}}
+# empty listens on p2p.ip at p2p.port + 2000
+listen        = "{{.RPC.Listen}}"
+# outcomes of the latest txs tx_status answers from
+tx_index_size = {{.RPC.TxIndexSize}}
+{{- end}}
+{{- if eq .Protocol "pyramid"}}
+
+# ===================================================
+#              Sharding
+# ===================================================
+[shard]
+is_i_shard  = {{.Shard.IsI}}
+b_shard_num = {{.Shard.BShardNum}}
+i_shard_num = {{.Shard.IShardNum}}
+{{- end}}
+`
+
+var configTemplate = template.Must(template.New("config").Funcs(template.FuncMap{
+	"envPrefix": envPrefix,
+}).Parse(configTOML))
+
+// Write saves the config as TOML.
+func (c *Config) Write(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := configTemplate.Execute(f, c); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
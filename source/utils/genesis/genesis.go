@@ -0,0 +1,294 @@
+package genesis
+
+import (
+	"crypto/sha256"
+	"emulator/utils/p2p"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The genesis document is generated with shard_info.json and is the same for
+// every node of a testnet. It fixes the shards and their validators, the
+// state accounts start with, the protocol parameters every node must agree
+// on, and the time at which consensus starts. Nodes exchange its hash in the
+// readiness handshake, so a node started with a stale document is noticed
+// before consensus starts.
+
+const (
+	FileName = "genesis.json"
+
+	ProtocolUrd     = "urd"
+	ProtocolPyramid = "pyramid"
+)
+
+type Genesis struct {
+	// GenesisTime is written in RFC3339, in UTC
+	GenesisTime  time.Time     `json:"genesis_time"`
+	Protocol     string        `json:"protocol"`
+	Shards       []*Shard      `json:"shards"`
+	InitialState *InitialState `json:"initial_state"`
+	Params       Params        `json:"params"`
+}
+
+type Shard struct {
+	ChainID    string       `json:"chain_id"`
+	KeyRange   string       `json:"key_range"`
+	SignScheme string       `json:"sign_scheme,omitempty"`
+	Validators []*Validator `json:"validators"`
+	// Owners is the hash of the file binding the accounts of the shard to
+	// their keys, when txs are signed
+	Owners string `json:"owners,omitempty"`
+
+	// the I/B-shard layout of the topology, only pyramid acts on it
+	IsIShard      bool     `json:"is_ishard,omitempty"`
+	RelatedShards []string `json:"related_shards,omitempty"`
+}
+
+type Validator struct {
+	Address string `json:"address"`
+	PubKey  string `json:"pub_key"`
+	Power   int32  `json:"power"`
+}
+
+// InitialState is the minibank state before the first block: every account
+// of a shard exists lazily with Balance.
+type InitialState struct {
+	Balance          uint32 `json:"initial_balance"`
+	AccountsPerShard int    `json:"accounts_per_shard"`
+	// SignedTxs makes urd only execute transfers signed by their From
+	// accounts
+	SignedTxs bool `json:"signed_txs,omitempty"`
+}
+
+// Params are the consensus parameters that must be the same on every node,
+// the config.toml of a node is checked against them. Fields a protocol does
+// not use are left out.
+type Params struct {
+	MinBlockInterval          string `json:"min_block_interval,omitempty"`
+	MaxPartSize               int    `json:"max_part_size,omitempty"`
+	MaxBlockTxBytes           int    `json:"max_block_tx_bytes,omitempty"`
+	MaxBlockCrossShardTxBytes int    `json:"max_block_cross_shard_tx_bytes,omitempty"`
+	MaxBlockTxNum             int    `json:"max_block_tx_num,omitempty"`
+	LockLeaseViews            int64  `json:"lock_lease_views,omitempty"`
+	SharedLocks               bool   `json:"shared_locks,omitempty"`
+}
+
+// NewValidators lists the peers of a shard as its validator set.
+func NewValidators(peers []*p2p.Peer) []*Validator {
+	out := make([]*Validator, len(peers))
+	for i, peer := range peers {
+		out[i] = &Validator{Address: peer.GetIP(), PubKey: peer.PubkeyStr(), Power: peer.Vote}
+	}
+	return out
+}
+
+func Load(path string) (*Genesis, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	g := new(Genesis)
+	if err := json.Unmarshal(bz, g); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := g.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return g, nil
+}
+
+// Write saves the genesis, its time rounded down to the second.
+func (g *Genesis) Write(path string) error {
+	g.GenesisTime = g.GenesisTime.UTC().Truncate(time.Second)
+	bz, err := json.MarshalIndent(g, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, bz, 0666)
+}
+
+func (g *Genesis) Validate() error {
+	if g.GenesisTime.IsZero() {
+		return fmt.Errorf("genesis_time is not set")
+	}
+	if _, offset := g.GenesisTime.Zone(); offset != 0 {
+		return fmt.Errorf("genesis_time %s is not in UTC", g.GenesisTime.Format(time.RFC3339))
+	}
+	if g.Protocol != ProtocolUrd && g.Protocol != ProtocolPyramid {
+		return fmt.Errorf("unknown protocol %q", g.Protocol)
+	}
+	if len(g.Shards) == 0 {
+		return fmt.Errorf("no shards")
+	}
+	seen := map[string]bool{}
+	for _, shard := range g.Shards {
+		if shard.ChainID == "" {
+			return fmt.Errorf("a shard has no chain_id")
+		}
+		if seen[shard.ChainID] {
+			return fmt.Errorf("shard %s is listed twice", shard.ChainID)
+		}
+		seen[shard.ChainID] = true
+		if g.InitialState != nil && g.InitialState.SignedTxs && shard.Owners == "" {
+			return fmt.Errorf("shard %s: txs are signed, but its accounts are bound to no keys", shard.ChainID)
+		}
+		if len(shard.Validators) == 0 {
+			return fmt.Errorf("shard %s has no validators", shard.ChainID)
+		}
+		for i, v := range shard.Validators {
+			if v.Address == "" || v.PubKey == "" {
+				return fmt.Errorf("shard %s: validator %d has no address or pub_key", shard.ChainID, i)
+			}
+			if v.Power <= 0 {
+				return fmt.Errorf("shard %s: validator %d has power %d", shard.ChainID, i, v.Power)
+			}
+		}
+	}
+	for _, shard := range g.Shards {
+		for _, related := range shard.RelatedShards {
+			if !seen[related] {
+				return fmt.Errorf("shard %s: related shard %s does not exist", shard.ChainID, related)
+			}
+		}
+	}
+	if g.InitialState == nil {
+		return fmt.Errorf("initial_state is not set")
+	}
+	return nil
+}
+
+func (g *Genesis) ChainIDs() []string {
+	out := make([]string, len(g.Shards))
+	for i, shard := range g.Shards {
+		out[i] = shard.ChainID
+	}
+	return out
+}
+
+func (g *Genesis) Shard(chain_id string) *Shard {
+	for _, shard := range g.Shards {
+		if shard.ChainID == chain_id {
+			return shard
+		}
+	}
+	return nil
+}
+
+// Hash identifies the document, nodes only start together if it is the same.
+func (g *Genesis) Hash() []byte {
+	c := *g
+	c.GenesisTime = c.GenesisTime.UTC()
+	bz, err := json.Marshal(&c)
+	if err != nil {
+		panic(err)
+	}
+	h := sha256.Sum256(bz)
+	return h[:]
+}
+
+// CheckValidators returns an error if peers, from shard_info.json, are not
+// the validators of the shard in the genesis.
+func (s *Shard) CheckValidators(peers []*p2p.Peer) error {
+	if len(peers) != len(s.Validators) {
+		return fmt.Errorf("shard %s has %d validators in the genesis and %d peers in shard_info.json", s.ChainID, len(s.Validators), len(peers))
+	}
+	for i, peer := range peers {
+		v := s.Validators[i]
+		if v.Address != peer.GetIP() || v.PubKey != peer.PubkeyStr() || v.Power != peer.Vote {
+			return fmt.Errorf("shard %s: validator %d is %s in the genesis and %s in shard_info.json", s.ChainID, i, v.Address, peer.GetIP())
+		}
+	}
+	return nil
+}
+
+// Check compares the parameters of a node with those of the genesis.
+func (p Params) Check(local Params) error {
+	diffs := []string{}
+	check := func(name string, want, got interface{}) {
+		if want != got {
+			diffs = append(diffs, fmt.Sprintf("%s is %v, the genesis has %v", name, got, want))
+		}
+	}
+	check("min_block_interval", p.MinBlockInterval, local.MinBlockInterval)
+	check("max_part_size", p.MaxPartSize, local.MaxPartSize)
+	check("max_block_tx_bytes", p.MaxBlockTxBytes, local.MaxBlockTxBytes)
+	check("max_cross_shard_tx_bytes", p.MaxBlockCrossShardTxBytes, local.MaxBlockCrossShardTxBytes)
+	check("max_block_tx_num", p.MaxBlockTxNum, local.MaxBlockTxNum)
+	check("lock_lease_views", p.LockLeaseViews, local.LockLeaseViews)
+	check("shared_locks", p.SharedLocks, local.SharedLocks)
+	if len(diffs) > 0 {
+		return fmt.Errorf("the config does not match the genesis: %s", strings.Join(diffs, "; "))
+	}
+	return nil
+}
+
+// ParseTime reads a genesis time, either in RFC3339 or as a duration from
+// now such as "+2m".
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if strings.HasPrefix(s, "+") {
+		d, err := time.ParseDuration(s[1:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("genesis time %q: %v", s, err)
+		}
+		return now.Add(d).UTC().Truncate(time.Second), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("genesis time %q is neither RFC3339 nor +<duration>: %v", s, err)
+	}
+	return t.UTC(), nil
+}
+
+// SetTime rewrites the genesis time of every genesis file under root, it
+// returns the files it changed.
+func SetTime(root string, t time.Time) ([]string, error) {
+	changed := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != FileName {
+			return nil
+		}
+		g, err := Load(path)
+		if err != nil {
+			return err
+		}
+		g.GenesisTime = t
+		if err := g.Write(path); err != nil {
+			return err
+		}
+		changed = append(changed, path)
+		return nil
+	})
+	return changed, err
+}
+
+// SetTimeCommand is the genesis subcommand of both binaries.
+func SetTimeCommand(args []string) error {
+	flags := flag.NewFlagSet("genesis", flag.ExitOnError)
+	root := flags.String("root", ".", "Directory searched for "+FileName+" files, like a generated testnet")
+	at := flags.String("time", "+1m", "New genesis time, RFC3339 (2024-05-01T15:29:00Z) or a duration from now (+2m)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	t, err := ParseTime(*at, time.Now())
+	if err != nil {
+		return err
+	}
+	changed, err := SetTime(*root, t)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		return fmt.Errorf("no %s under %s", FileName, *root)
+	}
+	fmt.Printf("Genesis time of %d nodes set to %s\n", len(changed), t.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,118 @@
+package genesis
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testGenesis() *Genesis {
+	return &Genesis{
+		GenesisTime: time.Date(2024, 5, 1, 15, 29, 0, 0, time.UTC),
+		Protocol:    ProtocolUrd,
+		Shards: []*Shard{{
+			ChainID:    "i1",
+			KeyRange:   "10,11",
+			Validators: []*Validator{{Address: "127.0.0.1:26601", PubKey: "aa", Power: 1}},
+		}},
+		InitialState: &InitialState{Balance: 1000000, AccountsPerShard: 100},
+		Params:       Params{MinBlockInterval: "10ms", MaxPartSize: 1024},
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 23, 59, 30, 500, time.FixedZone("CST", 8*3600))
+	for s, want := range map[string]time.Time{
+		"+2m":                       time.Date(2024, 5, 1, 16, 1, 30, 0, time.UTC),
+		"2024-05-02T00:01:00+08:00": time.Date(2024, 5, 1, 16, 1, 0, 0, time.UTC),
+		"2024-05-01T16:01:00Z":      time.Date(2024, 5, 1, 16, 1, 0, 0, time.UTC),
+	} {
+		got, err := ParseTime(s, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Fatalf("%s: got %v, expected %v", s, got, want)
+		}
+	}
+	if _, err := ParseTime("15:29", now); err == nil {
+		t.Fatal("HH:MM is not a genesis time")
+	}
+}
+
+func TestSetTime(t *testing.T) {
+	root := t.TempDir()
+	paths := []string{filepath.Join(root, "a", FileName), filepath.Join(root, "b", FileName)}
+	g := testGenesis()
+	hash := g.Hash()
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.Write(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded, err := Load(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.Hash(), hash) {
+		t.Fatal("the hash changed through Write and Load")
+	}
+
+	at := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	changed, err := SetTime(root, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != len(paths) {
+		t.Fatalf("%d files changed, expected %d", len(changed), len(paths))
+	}
+	for _, path := range paths {
+		loaded, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !loaded.GenesisTime.Equal(at) {
+			t.Fatalf("%s: genesis time is %v", path, loaded.GenesisTime)
+		}
+		if bytes.Equal(loaded.Hash(), hash) {
+			t.Fatal("the hash does not cover the genesis time")
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for name, change := range map[string]func(g *Genesis){
+		"local time":      func(g *Genesis) { g.GenesisTime = g.GenesisTime.In(time.FixedZone("CST", 8*3600)) },
+		"no time":         func(g *Genesis) { g.GenesisTime = time.Time{} },
+		"no validators":   func(g *Genesis) { g.Shards[0].Validators = nil },
+		"zero power":      func(g *Genesis) { g.Shards[0].Validators[0].Power = 0 },
+		"unknown related": func(g *Genesis) { g.Shards[0].RelatedShards = []string{"b1"} },
+		"duplicate shard": func(g *Genesis) { g.Shards = append(g.Shards, g.Shards[0]) },
+	} {
+		g := testGenesis()
+		change(g)
+		if err := g.Validate(); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+	if err := testGenesis().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParamsCheck(t *testing.T) {
+	g := testGenesis()
+	if err := g.Params.Check(g.Params); err != nil {
+		t.Fatal(err)
+	}
+	local := g.Params
+	local.MaxPartSize = 40960
+	if err := g.Params.Check(local); err == nil {
+		t.Fatal("a different max_part_size was accepted")
+	}
+}
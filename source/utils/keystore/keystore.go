@@ -0,0 +1,251 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// A key file keeps a validator's private key encrypted with AES-256-GCM under
+// a key derived from a passphrase. The scheme and public key are stored in
+// clear so that they can be read without the passphrase, and are bound to the
+// ciphertext as additional data so that they cannot be swapped.
+
+const (
+	KDFScrypt   = "scrypt"
+	KDFArgon2id = "argon2id"
+	DefaultKDF  = KDFScrypt
+
+	CipherAESGCM = "aes-256-gcm"
+
+	// PassphraseEnv is read when no password file is given.
+	PassphraseEnv = "URD_KEYSTORE_PASSWORD"
+
+	// FileMode keeps key files readable by their owner only.
+	FileMode = 0600
+
+	version = 1
+	keyLen  = 32
+	saltLen = 16
+
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+)
+
+type KDFParams struct {
+	Salt string `json:"salt"`
+
+	// scrypt
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+
+	// argon2id
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+}
+
+type CryptoParams struct {
+	KDF        string    `json:"kdf"`
+	KDFParams  KDFParams `json:"kdf_params"`
+	Cipher     string    `json:"cipher"`
+	Nonce      string    `json:"nonce"`
+	Ciphertext string    `json:"ciphertext"`
+}
+
+type KeyFile struct {
+	Version   int          `json:"version"`
+	Scheme    string       `json:"sign_scheme"`
+	PublicKey string       `json:"public_key"`
+	Crypto    CryptoParams `json:"crypto"`
+}
+
+// Encrypt seals privateKey with the default KDF.
+func Encrypt(privateKey, scheme, publicKey, passphrase string) (*KeyFile, error) {
+	return EncryptWithKDF(privateKey, scheme, publicKey, passphrase, DefaultKDF)
+}
+
+func EncryptWithKDF(privateKey, scheme, publicKey, passphrase, kdf string) (*KeyFile, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	kf := &KeyFile{
+		Version:   version,
+		Scheme:    scheme,
+		PublicKey: publicKey,
+		Crypto: CryptoParams{
+			KDF:    kdf,
+			Cipher: CipherAESGCM,
+		},
+	}
+	params := &kf.Crypto.KDFParams
+	params.Salt = hex.EncodeToString(salt)
+	switch kdf {
+	case KDFScrypt:
+		params.N, params.R, params.P = scryptN, scryptR, scryptP
+	case KDFArgon2id:
+		params.Time, params.Memory, params.Threads = argon2Time, argon2Memory, argon2Threads
+	default:
+		return nil, fmt.Errorf("unknown kdf %q", kdf)
+	}
+	aead, err := kf.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	kf.Crypto.Nonce = hex.EncodeToString(nonce)
+	kf.Crypto.Ciphertext = hex.EncodeToString(aead.Seal(nil, nonce, []byte(privateKey), kf.additionalData()))
+	return kf, nil
+}
+
+func (kf *KeyFile) Decrypt(passphrase string) (string, error) {
+	if kf.Version != version {
+		return "", fmt.Errorf("unsupported key file version %d", kf.Version)
+	}
+	if kf.Crypto.Cipher != CipherAESGCM {
+		return "", fmt.Errorf("unsupported cipher %q", kf.Crypto.Cipher)
+	}
+	aead, err := kf.aead(passphrase)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := hex.DecodeString(kf.Crypto.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return "", fmt.Errorf("invalid nonce in key file")
+	}
+	ciphertext, err := hex.DecodeString(kf.Crypto.Ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext in key file")
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, kf.additionalData())
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt the key: wrong passphrase or corrupted key file")
+	}
+	return string(plaintext), nil
+}
+
+func (kf *KeyFile) additionalData() []byte {
+	return []byte(fmt.Sprintf("%d|%s|%s", kf.Version, kf.Scheme, kf.PublicKey))
+}
+
+func (kf *KeyFile) aead(passphrase string) (cipher.AEAD, error) {
+	params := kf.Crypto.KDFParams
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("invalid salt in key file")
+	}
+	var key []byte
+	switch kf.Crypto.KDF {
+	case KDFScrypt:
+		if key, err = scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, keyLen); err != nil {
+			return nil, err
+		}
+	case KDFArgon2id:
+		if params.Time == 0 || params.Memory == 0 || params.Threads == 0 {
+			return nil, fmt.Errorf("invalid argon2id parameters in key file")
+		}
+		key = argon2.IDKey([]byte(passphrase), salt, params.Time, params.Memory, params.Threads, keyLen)
+	default:
+		return nil, fmt.Errorf("unknown kdf %q", kf.Crypto.KDF)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Write stores kf at path with FileMode. The file is written next to its
+// final location and renamed, so an interrupted write never leaves a
+// truncated key behind.
+func Write(path string, kf *KeyFile) error {
+	bz, err := json.MarshalIndent(kf, "", "    ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(FileMode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(bz); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Read loads a key file and refuses it if other users can access it.
+func Read(path string) (*KeyFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("key file %s is accessible by other users (mode %o), run chmod 600 on it", path, info.Mode().Perm())
+	}
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	kf := new(KeyFile)
+	if err := json.Unmarshal(bz, kf); err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", path, err)
+	}
+	return kf, nil
+}
+
+// Load reads the key file at path and returns the private key and its scheme.
+func Load(path, passphrase string) (string, string, error) {
+	kf, err := Read(path)
+	if err != nil {
+		return "", "", err
+	}
+	privateKey, err := kf.Decrypt(passphrase)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %v", path, err)
+	}
+	return privateKey, kf.Scheme, nil
+}
+
+// ReadPassphrase returns the content of passwordFile without its trailing
+// newline, or the PassphraseEnv variable when passwordFile is empty.
+func ReadPassphrase(passwordFile string) (string, error) {
+	if passwordFile != "" {
+		bz, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(bz), "\r\n"), nil
+	}
+	if passphrase, ok := os.LookupEnv(PassphraseEnv); ok {
+		return passphrase, nil
+	}
+	return "", fmt.Errorf("no passphrase: pass --password-file or set %s", PassphraseEnv)
+}
@@ -0,0 +1,63 @@
+package keystore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyFileRoundTrip(t *testing.T) {
+	for _, kdf := range []string{KDFScrypt, KDFArgon2id} {
+		path := filepath.Join(t.TempDir(), "private_key.json")
+		kf, err := EncryptWithKDF("00ff", "bls", "pub", "secret", kdf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(path, kf); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Stat(path); err != nil {
+			t.Fatal(err)
+		} else if info.Mode().Perm() != FileMode {
+			t.Fatalf("%s: key file has mode %o", kdf, info.Mode().Perm())
+		}
+		privateKey, scheme, err := Load(path, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if privateKey != "00ff" || scheme != "bls" {
+			t.Fatalf("%s: loaded %q %q", kdf, privateKey, scheme)
+		}
+		if _, _, err := Load(path, "wrong"); err == nil {
+			t.Fatalf("%s: decrypted with a wrong passphrase", kdf)
+		}
+	}
+}
+
+func TestKeyFileRejectsTampering(t *testing.T) {
+	kf, err := Encrypt("00ff", "bls", "pub", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kf.PublicKey = "other"
+	if _, err := kf.Decrypt("secret"); err == nil {
+		t.Fatal("decrypted a key file whose public key was replaced")
+	}
+}
+
+func TestReadRejectsOpenPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "private_key.json")
+	kf, err := Encrypt("00ff", "bls", "pub", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(path, kf); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(path); err == nil {
+		t.Fatal("read a world readable key file")
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import "time"
+
+// Latency sums how long the txs of a node's blocks took from their Time to
+// the commit of their block.
+type Latency struct {
+	IntraShardTxs   int
+	CrossShardTxs   int
+	IntraShardTotal time.Duration
+	CrossShardTotal time.Duration
+	// the aborted txs, which are not in the latencies
+	IntraShardAborted int
+	CrossShardAborted int
+}
+
+func (l *Latency) Add(other *Latency) {
+	l.IntraShardTxs += other.IntraShardTxs
+	l.CrossShardTxs += other.CrossShardTxs
+	l.IntraShardTotal += other.IntraShardTotal
+	l.CrossShardTotal += other.CrossShardTotal
+	l.IntraShardAborted += other.IntraShardAborted
+	l.CrossShardAborted += other.CrossShardAborted
+}
+
+func (l *Latency) Txs() int { return l.IntraShardTxs + l.CrossShardTxs }
+
+// IntraShard returns the average latency of intra-shard txs, 0 without any.
+func (l *Latency) IntraShard() time.Duration { return average(l.IntraShardTotal, l.IntraShardTxs) }
+
+func (l *Latency) CrossShard() time.Duration { return average(l.CrossShardTotal, l.CrossShardTxs) }
+
+func (l *Latency) Average() time.Duration {
+	return average(l.IntraShardTotal+l.CrossShardTotal, l.Txs())
+}
+
+func average(total time.Duration, n int) time.Duration {
+	if n == 0 {
+		return 0
+	}
+	return total / time.Duration(n)
+}
@@ -0,0 +1,235 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A leveled logger that writes one JSON object per line, so that the output
+// of a large run can be filtered with jq or grep by node, chain, view or
+// message type. Every logger belongs to a module (consensus, p2p, mempool,
+// abci, importer, rpc, node) whose level is set on its own.
+
+type Level int8
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	// LevelNone silences a module
+	LevelNone
+)
+
+var levelNames = []string{"debug", "info", "warn", "error", "none"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelNone {
+		return fmt.Sprintf("level(%d)", l)
+	}
+	return levelNames[l]
+}
+
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, want one of %s", s, strings.Join(levelNames, ", "))
+}
+
+// Levels is the level of every module, parsed from "info" or
+// "consensus:debug,p2p:warn,*:info" where * is every other module.
+type Levels struct {
+	Default Level
+	Modules map[string]Level
+}
+
+func ParseLevels(spec string) (*Levels, error) {
+	levels := &Levels{Default: LevelInfo, Modules: map[string]Level{}}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		module, name, ok := strings.Cut(part, ":")
+		if !ok {
+			module, name = "*", part
+		}
+		level, err := ParseLevel(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		if module = strings.TrimSpace(module); module == "*" {
+			levels.Default = level
+		} else {
+			levels.Modules[module] = level
+		}
+	}
+	return levels, nil
+}
+
+func (ls *Levels) Of(module string) Level {
+	if level, ok := ls.Modules[module]; ok {
+		return level
+	}
+	return ls.Default
+}
+
+const (
+	FormatJSON = "json"
+	// FormatText is easier to read on a terminal
+	FormatText = "text"
+)
+
+type sink struct {
+	mtx    sync.Mutex
+	w      io.Writer
+	format string
+	levels *Levels
+	// fields are added to every line, like the node and its chain
+	fields []interface{}
+}
+
+var current atomic.Pointer[sink]
+
+func init() {
+	current.Store(&sink{w: os.Stdout, format: FormatJSON, levels: &Levels{Default: LevelInfo}})
+}
+
+// Configure sets where and how every logger writes, the levels of the
+// modules and the key/value fields added to every line. Loggers created
+// before keep working and follow the new settings.
+func Configure(w io.Writer, format string, levels string, fields ...interface{}) error {
+	if format != FormatJSON && format != FormatText {
+		return fmt.Errorf("unknown log format %q, want %s or %s", format, FormatJSON, FormatText)
+	}
+	ls, err := ParseLevels(levels)
+	if err != nil {
+		return err
+	}
+	current.Store(&sink{w: w, format: format, levels: ls, fields: fields})
+	return nil
+}
+
+// Logger writes the lines of a module with the fields it was given by With.
+type Logger struct {
+	module string
+	fields []interface{}
+}
+
+func New(module string) *Logger {
+	return &Logger{module: module}
+}
+
+// With returns a logger that adds the key/value pairs to every line.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(append(fields, l.fields...), keyvals...)
+	return &Logger{module: l.module, fields: fields}
+}
+
+// Enabled tells whether a line of level would be written, to skip building
+// expensive fields.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= current.Load().levels.Of(l.module)
+}
+
+func (l *Logger) Debug(msg string, keyvals ...interface{}) { l.log(LevelDebug, msg, keyvals) }
+func (l *Logger) Info(msg string, keyvals ...interface{})  { l.log(LevelInfo, msg, keyvals) }
+func (l *Logger) Warn(msg string, keyvals ...interface{})  { l.log(LevelWarn, msg, keyvals) }
+func (l *Logger) Error(msg string, keyvals ...interface{}) { l.log(LevelError, msg, keyvals) }
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	s := current.Load()
+	if level < s.levels.Of(l.module) {
+		return
+	}
+	var b bytes.Buffer
+	now := time.Now()
+	if s.format == FormatText {
+		fmt.Fprintf(&b, "%s %-5s %-9s %s", now.Format("15:04:05.000000"), strings.ToUpper(level.String()), l.module, msg)
+		for _, kvs := range [][]interface{}{s.fields, l.fields, keyvals} {
+			eachPair(kvs, func(key string, value interface{}) {
+				fmt.Fprintf(&b, " %s=%s", key, text(value))
+			})
+		}
+	} else {
+		b.WriteString(`{"time":`)
+		writeJSON(&b, now.Format(time.RFC3339Nano))
+		b.WriteString(`,"level":`)
+		writeJSON(&b, level.String())
+		b.WriteString(`,"module":`)
+		writeJSON(&b, l.module)
+		b.WriteString(`,"msg":`)
+		writeJSON(&b, msg)
+		for _, kvs := range [][]interface{}{s.fields, l.fields, keyvals} {
+			eachPair(kvs, func(key string, value interface{}) {
+				b.WriteByte(',')
+				writeJSON(&b, key)
+				b.WriteByte(':')
+				writeJSON(&b, plain(value))
+			})
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte('\n')
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.w.Write(b.Bytes())
+}
+
+// eachPair calls f with every key/value pair, a key without a value gets
+// "!MISSING".
+func eachPair(keyvals []interface{}, f func(key string, value interface{})) {
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		if i+1 == len(keyvals) {
+			f(key, "!MISSING")
+			return
+		}
+		f(key, keyvals[i+1])
+	}
+}
+
+// plain turns the values JSON does not encode well into strings: errors by
+// their message and bytes, like hashes, in hex.
+func plain(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case []byte:
+		return hex.EncodeToString(v)
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return value
+}
+
+func text(value interface{}) string {
+	s := fmt.Sprint(plain(value))
+	if strings.ContainsAny(s, " \"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+func writeJSON(b *bytes.Buffer, value interface{}) {
+	bz, err := json.Marshal(value)
+	if err != nil {
+		bz, _ = json.Marshal(fmt.Sprint(value))
+	}
+	b.Write(bz)
+}
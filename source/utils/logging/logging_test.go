@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	var b strings.Builder
+	if err := Configure(&b, FormatJSON, "consensus:debug,p2p:none,*:warn", "node", "node1", "chain", "i1"); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(&b, FormatJSON, "info")
+
+	consensus := New("consensus").With("view", int64(3), "step", "propose")
+	consensus.Debug("received proposal", "msg_type", "Proposal", "hash", []byte{0xab})
+	New("p2p").Error("dropped")
+	New("mempool").Info("dropped")
+	New("mempool").Warn("full", "err", errors.New("mempool is full"), "odd")
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines:\n%s", len(lines), b.String())
+	}
+	var first map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"level": "debug", "module": "consensus", "msg": "received proposal", "node": "node1", "chain": "i1",
+		"view": 3.0, "step": "propose", "msg_type": "Proposal", "hash": "ab",
+	}
+	for key, value := range want {
+		if first[key] != value {
+			t.Fatalf("%s is %v, want %v in %s", key, first[key], value, lines[0])
+		}
+	}
+	if !strings.HasSuffix(lines[1], `"err":"mempool is full","odd":"!MISSING"}`) {
+		t.Fatalf("unexpected line %s", lines[1])
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	ls, err := ParseLevels("warn, consensus:debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls.Of("consensus") != LevelDebug || ls.Of("p2p") != LevelWarn {
+		t.Fatalf("got %+v", ls)
+	}
+	if _, err := ParseLevels("consensus:loud"); err == nil {
+		t.Fatal("accepted an unknown level")
+	}
+}
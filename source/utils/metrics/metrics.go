@@ -0,0 +1,312 @@
+package metrics
+
+import (
+	"emulator/utils/logging"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A small registry of counters, gauges and histograms that are served in the
+// Prometheus text format on /metrics, so that a run can be watched live. The
+// metrics both protocols report are declared in node.go.
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+var log = logging.New("metrics")
+
+// DefBuckets suit durations in seconds, from a millisecond to a minute.
+var DefBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+type Registry struct {
+	mtx      sync.Mutex
+	families map[string]*family
+	// constLabels are added to every series, like the node and its chain
+	constLabels []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// Default is the registry of the node.
+var Default = NewRegistry()
+
+// SetConstLabels adds the name/value pairs to every series the registry
+// writes.
+func (r *Registry) SetConstLabels(pairs ...string) {
+	if len(pairs)%2 == 1 {
+		panic("metrics: labels must be name/value pairs")
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.constLabels = pairs
+}
+
+type family struct {
+	name, help, kind string
+	labelNames       []string
+	buckets          []float64
+
+	mtx    sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	// value holds the float64 bits of a counter or gauge
+	value atomic.Uint64
+
+	mtx    sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (r *Registry) register(name, help, kind string, buckets []float64, labelNames []string) *family {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if f, ok := r.families[name]; ok {
+		if f.kind != kind || strings.Join(f.labelNames, ",") != strings.Join(labelNames, ",") {
+			panic(fmt.Errorf("metrics: %s is registered twice differently", name))
+		}
+		return f
+	}
+	f := &family{name: name, help: help, kind: kind, labelNames: labelNames, buckets: buckets, series: map[string]*series{}}
+	r.families[name] = f
+	return f
+}
+
+func (f *family) with(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Errorf("metrics: %s takes labels %v, got %v", f.name, f.labelNames, labelValues))
+	}
+	key := strings.Join(labelValues, "\xff")
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (s *series) add(v float64) {
+	for {
+		old := s.value.Load()
+		if s.value.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (s *series) load() float64 { return math.Float64frombits(s.value.Load()) }
+
+// Counter only goes up.
+type Counter struct{ s *series }
+
+func (c Counter) Inc()          { c.s.add(1) }
+func (c Counter) Add(v float64) { c.s.add(v) }
+
+// Gauge goes up and down.
+type Gauge struct{ s *series }
+
+func (g Gauge) Set(v float64) { g.s.value.Store(math.Float64bits(v)) }
+func (g Gauge) Add(v float64) { g.s.add(v) }
+func (g Gauge) Inc()          { g.s.add(1) }
+func (g Gauge) Dec()          { g.s.add(-1) }
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	s       *series
+	buckets []float64
+}
+
+func (h Histogram) Observe(v float64) {
+	h.s.mtx.Lock()
+	defer h.s.mtx.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.s.counts[i]++
+		}
+	}
+	h.s.sum += v
+	h.s.count++
+}
+
+// ObserveSince observes the seconds passed since start.
+func (h Histogram) ObserveSince(start time.Time) { h.Observe(time.Since(start).Seconds()) }
+
+type CounterVec struct{ f *family }
+type GaugeVec struct{ f *family }
+type HistogramVec struct{ f *family }
+
+func (v CounterVec) With(labelValues ...string) Counter { return Counter{v.f.with(labelValues)} }
+func (v GaugeVec) With(labelValues ...string) Gauge     { return Gauge{v.f.with(labelValues)} }
+func (v HistogramVec) With(labelValues ...string) Histogram {
+	return Histogram{s: v.f.with(labelValues), buckets: v.f.buckets}
+}
+
+func (r *Registry) NewCounter(name, help string) Counter {
+	return r.NewCounterVec(name, help).With()
+}
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) CounterVec {
+	return CounterVec{r.register(name, help, kindCounter, nil, labelNames)}
+}
+func (r *Registry) NewGauge(name, help string) Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) GaugeVec {
+	return GaugeVec{r.register(name, help, kindGauge, nil, labelNames)}
+}
+
+// NewHistogram takes ascending bucket upper bounds, nil is DefBuckets.
+func (r *Registry) NewHistogram(name, help string, buckets []float64) Histogram {
+	return r.NewHistogramVec(name, help, buckets).With()
+}
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Errorf("metrics: buckets of %s are not sorted", name))
+	}
+	return HistogramVec{r.register(name, help, kindHistogram, buckets, labelNames)}
+}
+
+// WriteText writes every metric in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mtx.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	constLabels := r.constLabels
+	r.mtx.Unlock()
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		r.mtx.Lock()
+		f := r.families[name]
+		r.mtx.Unlock()
+		f.write(&b, constLabels)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (f *family) write(b *strings.Builder, constLabels []string) {
+	f.mtx.Lock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	all := make([]*series, 0, len(keys))
+	sort.Strings(keys)
+	for _, key := range keys {
+		all = append(all, f.series[key])
+	}
+	f.mtx.Unlock()
+	if len(all) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "# HELP %s %s\n", f.name, escape(f.help, false))
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.name, f.kind)
+	for _, s := range all {
+		pairs := append([]string(nil), constLabels...)
+		for i, name := range f.labelNames {
+			pairs = append(pairs, name, s.labelValues[i])
+		}
+		if f.kind != kindHistogram {
+			fmt.Fprintf(b, "%s%s %s\n", f.name, labels(pairs), formatFloat(s.load()))
+			continue
+		}
+		s.mtx.Lock()
+		for i, upper := range f.buckets {
+			fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, labels(append(pairs, "le", formatFloat(upper))), s.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, labels(append(pairs, "le", "+Inf")), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", f.name, labels(pairs), formatFloat(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", f.name, labels(pairs), s.count)
+		s.mtx.Unlock()
+	}
+}
+
+func labels(pairs []string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", pairs[i], escape(pairs[i+1], true)))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escape(s string, quote bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quote {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Handler serves the registry on /metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WriteText(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Serve serves the registry on http://addr/metrics, and the handlers on
+// their paths, until the server is closed.
+func (r *Registry) Serve(addr string, handlers map[string]http.Handler) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r.Handler())
+	for path, handler := range handlers {
+		mux.Handle(path, handler)
+	}
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Error("serving failed", "addr", addr, "err", err)
+		}
+	}()
+	return server, nil
+}
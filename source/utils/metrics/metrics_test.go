@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	r.SetConstLabels("chain_id", "i1")
+	r.NewCounterVec("sent_bytes_total", "Bytes sent.", "peer").With(`a"b`).Add(3)
+	r.NewGauge("mempool_size", "Txs.").Set(7)
+	h := r.NewHistogram("wait_seconds", "Waits.", []float64{1, 2})
+	h.Observe(0.5)
+	h.Observe(1.5)
+	h.Observe(5)
+	r.NewCounterVec("unused_total", "No series yet.", "reason")
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP mempool_size Txs.
+# TYPE mempool_size gauge
+mempool_size{chain_id="i1"} 7
+# HELP sent_bytes_total Bytes sent.
+# TYPE sent_bytes_total counter
+sent_bytes_total{chain_id="i1",peer="a\"b"} 3
+# HELP wait_seconds Waits.
+# TYPE wait_seconds histogram
+wait_seconds_bucket{chain_id="i1",le="1"} 1
+wait_seconds_bucket{chain_id="i1",le="2"} 2
+wait_seconds_bucket{chain_id="i1",le="+Inf"} 3
+wait_seconds_sum{chain_id="i1"} 7
+wait_seconds_count{chain_id="i1"} 3
+`
+	if b.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("parts_total", "Parts.").Inc()
+	server := httptest.NewServer(r.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	bz, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bz), "\nparts_total 1\n") {
+		t.Fatalf("unexpected body %s", bz)
+	}
+}
+
+func TestAbortReason(t *testing.T) {
+	locked := Abort(AbortKeyLocked, errors.New("one of its keys is locked"))
+	for err, want := range map[error]string{
+		nil:                           "unknown",
+		locked:                        AbortKeyLocked,
+		fmt.Errorf("%w: 10a", locked): AbortKeyLocked,
+		errors.New("balance is locked, a conflict"): AbortInvalid,
+	} {
+		if got := AbortReason(err); got != want {
+			t.Fatalf("%v counts under %s, want %s", err, got, want)
+		}
+	}
+}
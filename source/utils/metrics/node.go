@@ -0,0 +1,91 @@
+package metrics
+
+import "errors"
+
+// The metrics of a node. Both protocols report under the same names, so that
+// one dashboard compares them; pyramid reports its height as the view.
+
+var (
+	View = Default.NewGauge("consensus_view",
+		"Current view of urd, or height of pyramid.")
+	ViewDuration = Default.NewHistogram("consensus_view_duration_seconds",
+		"Time from entering a view to entering the next.", nil)
+	VotesPerView = Default.NewHistogram("consensus_votes_per_view",
+		"Votes received in a view, pyramid counts prevotes and precommits.",
+		[]float64{1, 2, 4, 8, 16, 32, 64, 128, 256})
+	CrossShardWait = Default.NewHistogramVec("consensus_cross_shard_wait_seconds",
+		"Time waited for the cross-shard message of a source shard once the shard is ready for it, 0 if it came earlier.",
+		nil, "source_shard")
+	PartsReceived = Default.NewCounter("consensus_parts_received_total",
+		"Block parts received.")
+	ConsensusBytes = Default.NewCounterVec("consensus_bytes_total",
+		"Bytes of consensus messages sent, by intra_shard, cross_shard_data and cooperation.", "kind")
+
+	MempoolSize = Default.NewGaugeVec("mempool_size",
+		"Txs waiting in a mempool, intra or cross shard.", "mempool")
+
+	ExecutionDuration = Default.NewHistogramVec("abci_execution_seconds",
+		"Time spent in a phase of block execution.", nil, "phase")
+	Aborts = Default.NewCounterVec("abci_aborts_total",
+		"Aborted txs by reason.", "reason")
+	LockedKeys = Default.NewGauge("abci_locked_keys",
+		"Keys of the shard locked by undecided cross-shard txs.")
+	OldestLock = Default.NewGauge("abci_oldest_lock_views",
+		"Views since the shard took its oldest lock still held, 0 without locks.")
+	LockHeld = Default.NewHistogram("abci_lock_held_views",
+		"Views a cross-shard tx held the locks of the shard, observed when they are released.",
+		[]float64{1, 2, 4, 8, 16, 32, 64, 128, 256})
+
+	PeerSentBytes = Default.NewCounterVec("p2p_peer_sent_bytes_total",
+		"Bytes sent to a peer.", "peer")
+	ReceivedBytes = Default.NewCounterVec("p2p_received_bytes_total",
+		"Bytes received on a channel.", "channel")
+)
+
+// Mempool labels of MempoolSize.
+func Mempool(isCrossShard bool) string {
+	if isCrossShard {
+		return "cross"
+	}
+	return "intra"
+}
+
+// AbortCrossShard labels the txs of a cross-shard block that was aborted.
+const AbortCrossShard = "cross_shard_abort"
+
+// The reasons txs abort for, the labels of Aborts besides AbortCrossShard.
+const (
+	AbortLeaseExpired = "lease_expired"
+	AbortBadSignature = "bad_signature"
+	AbortBadNonce     = "bad_nonce"
+	AbortBalance      = "insufficient_balance"
+	AbortLockConflict = "lock_conflict"
+	AbortKeyLocked    = "key_locked"
+	AbortDuplicate    = "duplicate"
+	AbortInvalid      = "invalid"
+)
+
+// An AbortError is an error a tx aborts with, that counts under Reason.
+type AbortError struct {
+	Reason string
+	Err    error
+}
+
+// Abort gives err, which txs abort with, the reason it counts under.
+func Abort(reason string, err error) error { return &AbortError{reason, err} }
+
+func (e *AbortError) Error() string { return e.Err.Error() }
+func (e *AbortError) Unwrap() error { return e.Err }
+
+// AbortReason is the label of Aborts for the error a tx aborted with:
+// the reason of the AbortError it wraps, if any.
+func AbortReason(err error) string {
+	if err == nil {
+		return "unknown"
+	}
+	var abort *AbortError
+	if errors.As(err, &abort) {
+		return abort.Reason
+	}
+	return AbortInvalid
+}
@@ -0,0 +1,170 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MessageTypeReady is the only message of the handshake channel
+const MessageTypeReady uint32 = 1
+
+const (
+	handshakeRetry  = time.Second
+	handshakeReport = 10 * time.Second
+)
+
+// readyMessage tells a peer that the sender is connected to all of its peers
+// and runs the genesis with this hash.
+type readyMessage struct {
+	Address string `json:"address"`
+	Genesis []byte `json:"genesis"`
+	// Reply is set on the answer to a peer that is still waiting
+	Reply bool `json:"reply,omitempty"`
+}
+
+// Handshake makes sure every peer of a node is up before consensus starts.
+// A node dials all of its peers, then tells them it is ready, and is done
+// once every peer has told it the same. Peers with another genesis are never
+// counted as ready.
+type Handshake struct {
+	sender  *Sender
+	genesis []byte
+	peers   []*Peer
+
+	mtx       sync.Mutex
+	ready     map[string]bool
+	changed   chan struct{}
+	connected bool
+}
+
+var _ Reactor = (*Handshake)(nil)
+
+func NewHandshake(sender *Sender, genesisHash []byte) *Handshake {
+	return &Handshake{
+		sender:  sender,
+		genesis: genesisHash,
+		peers:   sender.Peers(),
+		ready:   make(map[string]bool),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+func (h *Handshake) Receive(channel_id byte, bz []byte, messageType uint32) error {
+	if messageType != MessageTypeReady {
+		return fmt.Errorf("handshake: unknown message type %d", messageType)
+	}
+	msg := new(readyMessage)
+	if err := json.Unmarshal(bz, msg); err != nil {
+		return fmt.Errorf("handshake: %v", err)
+	}
+	if !bytes.Equal(msg.Genesis, h.genesis) {
+		return fmt.Errorf("handshake: %s runs genesis %s, this node runs %s",
+			msg.Address, hex.EncodeToString(msg.Genesis), hex.EncodeToString(h.genesis))
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	for _, peer := range h.peers {
+		if peer.GetIP() != msg.Address {
+			continue
+		}
+		if !h.ready[msg.Address] {
+			h.ready[msg.Address] = true
+			select {
+			case h.changed <- struct{}{}:
+			default:
+			}
+		}
+		// the peer may have missed our ready message, it is answered even
+		// once this node is done
+		if h.connected && !msg.Reply {
+			go h.send(peer, true)
+		}
+	}
+	return nil
+}
+
+func (h *Handshake) send(peer *Peer, reply bool) {
+	msg, err := json.Marshal(&readyMessage{Address: h.sender.MyIP, Genesis: h.genesis, Reply: reply})
+	if err != nil {
+		panic(err)
+	}
+	if err := h.sender.Send(peer, ChannelIDHandshake, msg, MessageTypeReady); err != nil {
+		log.Warn("handshake: sending ready failed", "peer", peer.GetIP(), "err", err)
+	}
+}
+
+// Run returns once the node is connected to every peer and every peer is
+// ready, or with the error of ctx.
+func (h *Handshake) Run(ctx context.Context) error {
+	ticker := time.NewTicker(handshakeRetry)
+	defer ticker.Stop()
+	lastReport := time.Now()
+
+	log.Info("handshake: dialing peers", "peers", len(h.peers))
+	for {
+		err := h.sender.Connect()
+		if err == nil {
+			break
+		}
+		if time.Since(lastReport) >= handshakeReport {
+			log.Info("handshake: waiting for peers to listen", "err", err)
+			lastReport = time.Now()
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	log.Info("handshake: connected to peers", "peers", len(h.peers))
+	h.mtx.Lock()
+	h.connected = true
+	h.mtx.Unlock()
+
+	send := true
+	for {
+		missing := h.missing()
+		if len(missing) == 0 {
+			log.Info("handshake: all peers are ready", "peers", len(h.peers))
+			return nil
+		}
+		// ready messages are idempotent, they are repeated every second until
+		// every peer has answered
+		if send {
+			for _, peer := range h.peers {
+				h.send(peer, false)
+			}
+			send = false
+		}
+		if time.Since(lastReport) >= handshakeReport {
+			log.Info("handshake: waiting for peers", "missing", missing)
+			lastReport = time.Now()
+		}
+		select {
+		case <-h.changed:
+		case <-ticker.C:
+			send = true
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (h *Handshake) missing() []string {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	out := []string{}
+	for _, peer := range h.peers {
+		if !h.ready[peer.GetIP()] {
+			out = append(out, peer.GetIP())
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -1,6 +1,7 @@
 package p2p
 
 const (
+	ChannelIDHandshake         = 0x11
 	ChannelIDConsensusState    = 0x21
 	ChannelIDMempool           = 0x31
 	ChannelIDCrossShardMempool = 0x32
@@ -12,6 +12,7 @@ type Peer struct {
 	Chain  map[string]bool `json:"chain"`
 	Pubkey string          `json:"pubkey"`
 	Vote   int32           `json:"vote"`
+	Pop    string          `json:"pop"`
 }
 
 func NewPeer(ip string, chains map[string]bool, publicKey string, vote int32) (*Peer, error) {
@@ -47,6 +48,7 @@ func (peer *Peer) ToProto() *protop2p.Peer {
 		Chain:  peer.ChainID(),
 		Pubkey: peer.PubkeyStr(),
 		Vote:   peer.Vote,
+		Pop:    peer.Pop,
 	}
 }
 
@@ -55,7 +57,12 @@ func (peer *Peer) ProtoBytes() []byte {
 }
 
 func NewPeerFromProto(p *protop2p.Peer) (*Peer, error) {
-	return NewPeer(p.IP, p.Chain, p.Pubkey, p.Vote)
+	peer, err := NewPeer(p.IP, p.Chain, p.Pubkey, p.Vote)
+	if err != nil {
+		return nil, err
+	}
+	peer.Pop = p.Pop
+	return peer, nil
 }
 
 func NewPeerFromBytes(bz []byte) (*Peer, error) {
@@ -3,10 +3,10 @@ package p2p
 import (
 	"bufio"
 	"context"
+	"emulator/utils/metrics"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"sync"
 	"time"
@@ -16,21 +16,29 @@ type Receiver struct {
 	localIP   string
 	localPort int
 
-	ctx context.Context
+	// the receiver stops once ctx is cancelled or Stop is called
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	channelMap map[byte]Reactor
 
-	mtx sync.Mutex
+	mtx      sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
 }
 
 func NewReceiver(localIP string, localPort int, ctx context.Context) *Receiver {
+	ctx, cancel := context.WithCancel(ctx)
 	return &Receiver{
 		localIP:   localIP,
 		localPort: localPort,
 
 		ctx:        ctx,
+		cancel:     cancel,
 		channelMap: make(map[byte]Reactor),
 		mtx:        sync.Mutex{},
+		conns:      make(map[net.Conn]struct{}),
 	}
 }
 
@@ -45,70 +53,137 @@ func (r *Receiver) Start() error {
 		return fmt.Errorf(fmt.Sprintln("Error listening:", err))
 	}
 
-	log.Println("Receiver has started. Listening", ipPort)
+	log.Info("receiver listening", "address", ipPort)
+	r.mtx.Lock()
+	r.listener = listener
+	r.mtx.Unlock()
 
 	go func() {
-		defer listener.Close()
+		<-r.ctx.Done()
+		r.close()
+	}()
+	go func() {
 		for {
 			conn, err := listener.Accept()
 			if err != nil {
-				fmt.Println("Error accepting connection:", err)
+				if r.ctx.Err() != nil {
+					return
+				}
+				log.Warn("accepting connection failed", "err", err)
 				continue
 			}
+			if !r.track(conn) {
+				conn.Close()
+				return
+			}
 			go r.handleConnection(conn)
 		}
 	}()
 	return nil
 }
 
+// track registers a connection so that Stop can close it, it returns false
+// once the receiver is stopping.
+func (r *Receiver) track(conn net.Conn) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.ctx.Err() != nil {
+		return false
+	}
+	r.conns[conn] = struct{}{}
+	r.wg.Add(1)
+	return true
+}
+
+func (r *Receiver) untrack(conn net.Conn) {
+	r.mtx.Lock()
+	delete(r.conns, conn)
+	r.mtx.Unlock()
+	conn.Close()
+	r.wg.Done()
+}
+
+// close closes the listener and every connection, which unblocks their reads.
+func (r *Receiver) close() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.listener != nil {
+		r.listener.Close()
+	}
+	for conn := range r.conns {
+		conn.Close()
+	}
+}
+
 func (r *Receiver) handleConnection(conn net.Conn) {
-	defer conn.Close()
+	defer r.untrack(conn)
 	if err := conn.SetDeadline(time.Now().Add(30 * time.Minute)); err != nil {
 		panic(err)
 	}
 	clientReader := bufio.NewReader(conn)
-	//log.Println("start")
 	for {
 		bz, err := clientReader.ReadBytes('\n')
-		if len(bz) == 0 {
-			continue
-		}
-		if bz[len(bz)-1] == '\n' {
+		if len(bz) > 0 && bz[len(bz)-1] == '\n' {
 			bz = bz[:len(bz)-1]
 		}
 
 		select {
 		case <-r.ctx.Done():
-			log.Println("client closed the connection by terminating the process")
 			return
 		default:
 		}
 
 		switch err {
 		case nil:
+			if len(bz) == 0 {
+				continue
+			}
 			var channelMessage = new(Envelop)
 			if err := json.Unmarshal(bz, channelMessage); err != nil {
-				log.Printf("envelop marshal error: %v\n", err)
-				//log.Println(clientRequest)
-				//fmt.Println(string(clientRequest))
+				log.Warn("envelop unmarshal failed", "peer", conn.RemoteAddr(), "err", err)
 			} else {
 				chid := channelMessage.Channel_id
+				metrics.ReceivedBytes.With(channelName(chid)).Add(float64(len(bz) + 1))
 				if reactor, ok := r.channelMap[chid]; ok {
 					if err := reactor.Receive(chid, channelMessage.GetMessage(), channelMessage.MessageType); err != nil {
-						fmt.Println(err)
+						log.Warn("message rejected", "channel", channelName(chid), "msg_type", channelMessage.MessageType, "err", err)
 					}
 				} else {
-					log.Printf("error: channel of %c doesn't exist\n", chid)
+					log.Warn("unknown channel", "channel", chid, "peer", conn.RemoteAddr())
 				}
 			}
 		case io.EOF:
-			log.Println("client closed the connection by terminating the process")
+			log.Debug("peer closed the connection", "peer", conn.RemoteAddr())
 			return
 		default:
-			log.Printf("error: %v\n", err)
+			if r.ctx.Err() == nil {
+				log.Warn("reading connection failed", "peer", conn.RemoteAddr(), "err", err)
+			}
 			return
 		}
 	}
 }
 
-func (r *Receiver) Stop() {}
+// Stop closes the listener and every connection, and returns once the
+// messages being delivered to the reactors have been handled.
+func (r *Receiver) Stop() {
+	r.cancel()
+	r.close()
+	r.wg.Wait()
+}
+
+// channelName labels the bytes received on a channel.
+func channelName(chid byte) string {
+	switch chid {
+	case ChannelIDHandshake:
+		return "handshake"
+	case ChannelIDConsensusState:
+		return "consensus"
+	case ChannelIDMempool:
+		return "mempool"
+	case ChannelIDCrossShardMempool:
+		return "cross_shard_mempool"
+	default:
+		return fmt.Sprintf("%#x", chid)
+	}
+}
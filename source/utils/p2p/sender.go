@@ -1,21 +1,26 @@
 package p2p
 
 import (
+	"emulator/utils/logging"
+	"emulator/utils/metrics"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
+var log = logging.New("p2p")
+
 type Sender struct {
 	shardMap    map[string][]*Peer
 	connMapLock sync.Mutex
 
 	connMap map[string]net.Conn
 	lockMap map[string]*sync.Mutex
+	stopped bool
 
 	RetryDuration time.Duration
 
@@ -35,10 +40,24 @@ func NewSender(ip string) *Sender {
 }
 
 func (s *Sender) Start() error {
+	peers := map[string][]string{}
+	for cid, pl := range s.shardMap {
+		for _, p := range pl {
+			peers[cid] = append(peers[cid], p.GetIP())
+		}
+	}
+	log.Info("starting sender", "peers", peers)
+	return s.Connect()
+}
+
+// Connect dials the peers that are not connected yet.
+func (s *Sender) Connect() error {
 	errList := []string{}
-	log.Println("我的广播列表", s.shardMap)
 	s.connMapLock.Lock()
 	defer s.connMapLock.Unlock()
+	if s.stopped {
+		return fmt.Errorf("sender stopped")
+	}
 	for _, pl := range s.shardMap {
 		for _, p := range pl {
 			if p.GetIP() != s.MyIP && s.connMap[p.GetIP()] == nil {
@@ -49,7 +68,7 @@ func (s *Sender) Start() error {
 						panic(err)
 					}
 					s.connMap[p.GetIP()] = conn
-					fmt.Println(time.Now(), "--节点", p.GetIP(), "添加成功")
+					log.Debug("connected to peer", "peer", p.GetIP())
 				}
 			}
 		}
@@ -61,9 +80,14 @@ func (s *Sender) Start() error {
 	}
 }
 
+// Stop closes every connection, messages sent afterwards are dropped.
 func (s *Sender) Stop() error {
-	for _, conn := range s.connMap {
+	s.connMapLock.Lock()
+	defer s.connMapLock.Unlock()
+	s.stopped = true
+	for addr, conn := range s.connMap {
 		conn.Close()
+		delete(s.connMap, addr)
 	}
 	return nil
 }
@@ -87,6 +111,30 @@ func (s *Sender) AddPeer(peer *Peer) {
 	}
 }
 
+// Peers lists the peers messages are sent to, without this node.
+func (s *Sender) Peers() []*Peer {
+	seen := map[string]bool{s.MyIP: true}
+	out := []*Peer{}
+	for _, pl := range s.shardMap {
+		for _, p := range pl {
+			if !seen[p.GetIP()] {
+				seen[p.GetIP()] = true
+				out = append(out, p)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].GetIP() < out[j].GetIP() })
+	return out
+}
+
+// Connected tells whether the sender holds a working connection to addr, as
+// far as the last message sent there knows.
+func (s *Sender) Connected(addr string) bool {
+	s.connMapLock.Lock()
+	defer s.connMapLock.Unlock()
+	return s.connMap[addr] != nil
+}
+
 func (s *Sender) Send(peer *Peer, channel_id byte, message []byte, messageType uint32) error {
 	if s.MyIP == peer.GetIP() {
 		return nil
@@ -97,16 +145,18 @@ func (s *Sender) Send(peer *Peer, channel_id byte, message []byte, messageType u
 		panic(err)
 	}
 
-	//log.Println(bz)
-
 	// 暂定允许重试五次
-	return s.TcpDial(bz, peer.GetIP(), 5)
+	if err := s.TcpDial(bz, peer.GetIP(), 5); err != nil {
+		return err
+	}
+	metrics.PeerSentBytes.With(peer.GetIP()).Add(float64(len(bz) + 1))
+	return nil
 }
 
 func (s *Sender) SendToShard(shardID string, channel_id byte, message []byte, messageType uint32) error {
 	peers := s.shardMap[shardID]
 	for _, peer := range peers {
-		go s.Send(peer, channel_id, message, messageType)
+		go s.sendAsync(peer, channel_id, message, messageType)
 	}
 	return nil
 }
@@ -115,15 +165,26 @@ func (s *Sender) SendToShardIndex(shardID string, index int, channel_id byte, me
 	if len(peers) <= index {
 		return fmt.Errorf("peer of [shard,index] = [%s,%d] does not exist", shardID, index)
 	}
-	go s.Send(peers[index], channel_id, message, messageType)
+	go s.sendAsync(peers[index], channel_id, message, messageType)
 	return nil
 }
 
+// sendAsync is Send for the messages no caller waits for, it logs the error.
+func (s *Sender) sendAsync(peer *Peer, channel_id byte, message []byte, messageType uint32) {
+	if err := s.Send(peer, channel_id, message, messageType); err != nil {
+		log.Warn("send failed", "peer", peer.GetIP(), "channel", channelName(channel_id), "msg_type", messageType, "err", err)
+	}
+}
+
 // depth是允许重试次数
 func (s *Sender) TcpDial(context []byte, addr string, depth int) error {
 	var conn net.Conn
 	var err error
 	s.connMapLock.Lock()
+	if s.stopped {
+		s.connMapLock.Unlock()
+		return fmt.Errorf("sender stopped")
+	}
 	conn = s.connMap[addr]
 	if conn == nil {
 		conn, err = net.Dial("tcp", addr)
@@ -135,7 +196,7 @@ func (s *Sender) TcpDial(context []byte, addr string, depth int) error {
 			s.connMapLock.Unlock()
 			panic(err)
 		}
-		fmt.Println(time.Now(), "--节点", addr, "添加成功")
+		log.Debug("connected to peer", "peer", addr)
 		s.connMap[addr] = conn
 	}
 	s.connMapLock.Unlock()
@@ -145,6 +206,13 @@ func (s *Sender) TcpDial(context []byte, addr string, depth int) error {
 	if err == nil {
 		return nil
 	}
+	// the connection is broken, the retry dials again
+	s.connMapLock.Lock()
+	if s.connMap[addr] == conn {
+		delete(s.connMap, addr)
+		conn.Close()
+	}
+	s.connMapLock.Unlock()
 	if depth <= 0 {
 		return err
 	}
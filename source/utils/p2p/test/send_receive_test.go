@@ -4,6 +4,7 @@ import (
 	"context"
 	"emulator/utils/p2p"
 	"fmt"
+	"net"
 	"strings"
 	"sync"
 	"testing"
@@ -62,6 +63,87 @@ func TestMain(t *testing.T) {
 
 }
 
+func TestReceiverStop(t *testing.T) {
+	addr := "127.0.0.1:26605"
+	peer, _ := p2p.NewPeer(addr, map[string]bool{"testchain": true}, "", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	server := p2p.NewReceiver("127.0.0.1", 26605, ctx)
+	server.AddChennel(&myReactor{}, 0x01)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	client := p2p.NewSender("127.0.0.1:26606")
+	client.AddPeer(peer)
+	if err := client.Send(peer, 0x01, []byte("a"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// cancelling the context closes the listener and the open connection
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		server.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the receiver did not stop")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("the port is still in use: %v", err)
+	}
+	listener.Close()
+
+	client.Stop()
+	if err := client.Send(peer, 0x01, []byte("a"), 0); err == nil {
+		t.Fatal("a stopped sender sent a message")
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	addrs := []string{"127.0.0.1:26607", "127.0.0.1:26608"}
+	peers := make([]*p2p.Peer, len(addrs))
+	for i, addr := range addrs {
+		peers[i], _ = p2p.NewPeer(addr, map[string]bool{"testchain": true}, "", 1)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	errs := make(chan error, len(addrs))
+	for i, addr := range addrs {
+		sender := p2p.NewSender(addr)
+		for _, peer := range peers {
+			sender.AddPeer(peer)
+		}
+		defer sender.Stop()
+		handshake := p2p.NewHandshake(sender, []byte("genesis"))
+		receiver := p2p.NewReceiver("127.0.0.1", 26607+i, ctx)
+		receiver.AddChennel(handshake, p2p.ChannelIDHandshake)
+		defer receiver.Stop()
+		// the second node only listens once the first one is waiting for it
+		if i > 0 {
+			time.Sleep(2 * time.Second)
+		}
+		if err := receiver.Start(); err != nil {
+			t.Fatal(err)
+		}
+		go func() { errs <- handshake.Run(ctx) }()
+	}
+	for range addrs {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	other := p2p.NewHandshake(p2p.NewSender(addrs[0]), []byte("genesis"))
+	msg := []byte(`{"address":"127.0.0.1:26608","genesis":"b3RoZXI="}`)
+	if err := other.Receive(p2p.ChannelIDHandshake, msg, p2p.MessageTypeReady); err == nil {
+		t.Fatal("a peer with another genesis was accepted")
+	}
+}
+
 type myReactor struct{}
 
 var _ p2p.Reactor = (*myReactor)(nil)
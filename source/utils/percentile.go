@@ -0,0 +1,15 @@
+package utils
+
+import "math"
+
+// Percentile of sorted values by the nearest rank, 0 when there are none.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
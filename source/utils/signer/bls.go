@@ -11,27 +11,32 @@ const (
 	BaseCurve = bls.BLS12_381
 )
 
-type Signer struct {
+// BLSSigner and BLSVerifier implement the bls scheme: a certificate is the
+// sum of the signatures and is checked against the sum of the signers' keys.
+type BLSSigner struct {
 	privateKey *bls.SecretKey
 }
 
-type Verifier struct {
+type BLSVerifier struct {
 	publicKeys []*bls.PublicKey
 }
 
-func NewSigner(privateKeyStr string) (*Signer, error) {
+var _ Signer = (*BLSSigner)(nil)
+var _ Verifier = (*BLSVerifier)(nil)
+
+func NewSigner(privateKeyStr string) (*BLSSigner, error) {
 	privateKey := new(bls.SecretKey)
 
 	if err := privateKey.DeserializeHexStr(privateKeyStr); err != nil {
 		return nil, fmt.Errorf("Wrong private key: " + err.Error())
 	}
 
-	return &Signer{
+	return &BLSSigner{
 		privateKey: privateKey,
 	}, nil
 }
 
-func NewVerifier(publicKeysStr []string) (*Verifier, error) {
+func NewVerifier(publicKeysStr []string) (*BLSVerifier, error) {
 	publicKeys := make([]*bls.PublicKey, len(publicKeysStr))
 	for i, publicKeyStr := range publicKeysStr {
 		key := new(bls.PublicKey)
@@ -41,23 +46,37 @@ func NewVerifier(publicKeysStr []string) (*Verifier, error) {
 			publicKeys[i] = key
 		}
 	}
-	return &Verifier{
+	return &BLSVerifier{
 		publicKeys: publicKeys,
 	}, nil
 }
 
+// NewVerifierWithPop only accepts keys whose proof of possession is valid,
+// which makes summing them in VerifyAggregateSignature safe against rogue keys.
+func NewVerifierWithPop(publicKeysStr []string, pops []string) (*BLSVerifier, error) {
+	if len(publicKeysStr) != len(pops) {
+		return nil, fmt.Errorf("%d public keys but %d proofs of possession", len(publicKeysStr), len(pops))
+	}
+	for i := range publicKeysStr {
+		if err := VerifyBLSPop(publicKeysStr[i], pops[i]); err != nil {
+			return nil, fmt.Errorf("validator %d: %v", i, err)
+		}
+	}
+	return NewVerifier(publicKeysStr)
+}
+
 // This function is used to sign a given byte slice
-func (s *Signer) Sign(data []byte) (string, error) {
+func (s *BLSSigner) Sign(data []byte) (string, error) {
 	sig := s.privateKey.SignByte(data)
 	return sig.SerializeToHexStr(), nil
 }
 
-func (s *Signer) SignType(data SignableType) (string, error) {
+func (s *BLSSigner) SignType(data SignableType) (string, error) {
 	return s.Sign(data.SignBytes())
 }
 
 // VerifyAggregateSignature is used to verify an aggregated signature by BLS
-func (s *Verifier) VerifyAggregateSignature(aggSig string, msg []byte, bitMapBytes []byte) bool {
+func (s *BLSVerifier) VerifyAggregateSignature(aggSig string, msg []byte, bitMapBytes []byte) bool {
 	bitMap := utils.NewBitArrayFromByte(bitMapBytes)
 	if len(s.publicKeys) != bitMap.Size() {
 		fmt.Println("public keys size not match bitmap size")
@@ -81,7 +100,7 @@ func (s *Verifier) VerifyAggregateSignature(aggSig string, msg []byte, bitMapByt
 	return sig.VerifyByte(publicKey, msg)
 }
 
-func (s *Verifier) Verify(aggSig string, msg []byte, index int) bool {
+func (s *BLSVerifier) Verify(aggSig string, msg []byte, index int) bool {
 	if index >= len(s.publicKeys) || s.publicKeys[index] == nil {
 		return false
 	}
@@ -91,7 +110,64 @@ func (s *Verifier) Verify(aggSig string, msg []byte, index int) bool {
 	}
 	return sig.VerifyByte(s.publicKeys[index], msg)
 }
-func (s *Verifier) Size() int {
+
+// VerifyBatch checks signatures that the validators at indexes made over the
+// same msg. Each signature and its public key are scaled by the same random
+// factor and summed, so that the whole batch costs one pairing check; without
+// the factors two invalid signatures that cancel out would pass. Only when the
+// check fails is the batch split in half until the bad signatures are
+// isolated. The result has one entry per signature.
+func (s *BLSVerifier) VerifyBatch(sigs []string, msg []byte, indexes []int) []bool {
+	valid := make([]bool, len(sigs))
+	weightedSigs := make([]bls.Sign, len(sigs))
+	weightedKeys := make([]bls.PublicKey, len(sigs))
+	candidates := []int{}
+	for i, sigStr := range sigs {
+		if indexes[i] < 0 || indexes[i] >= len(s.publicKeys) || s.publicKeys[indexes[i]] == nil {
+			continue
+		}
+		sig := new(bls.Sign)
+		if err := sig.DeserializeHexStr(sigStr); err != nil {
+			continue
+		}
+		var r bls.Fr
+		r.SetByCSPRNG()
+		bls.G2Mul(bls.CastFromSign(&weightedSigs[i]), bls.CastFromSign(sig), &r)
+		bls.G1Mul(bls.CastFromPublicKey(&weightedKeys[i]), bls.CastFromPublicKey(s.publicKeys[indexes[i]]), &r)
+		candidates = append(candidates, i)
+	}
+	bisect(weightedSigs, weightedKeys, msg, candidates, valid)
+	return valid
+}
+
+func bisect(sigs []bls.Sign, keys []bls.PublicKey, msg []byte, batch []int, valid []bool) {
+	if len(batch) == 0 {
+		return
+	}
+	aggSig, aggPub := new(bls.Sign), new(bls.PublicKey)
+	for _, i := range batch {
+		aggSig.Add(&sigs[i])
+		aggPub.Add(&keys[i])
+	}
+	if aggSig.VerifyByte(aggPub, msg) {
+		for _, i := range batch {
+			valid[i] = true
+		}
+		return
+	}
+	if len(batch) == 1 {
+		return
+	}
+	bisect(sigs, keys, msg, batch[:len(batch)/2], valid)
+	bisect(sigs, keys, msg, batch[len(batch)/2:], valid)
+}
+
+// Aggregate sums the signatures, their order does not matter.
+func (s *BLSVerifier) Aggregate(sigs []string, indexes []int) (string, error) {
+	return AggregateSignatures(sigs)
+}
+
+func (s *BLSVerifier) Size() int {
 	return len(s.publicKeys)
 }
 
@@ -124,5 +200,40 @@ func BLSPubkey(privateKey string) (string, error) {
 	if err := p.DeserializeHexStr(privateKey); err != nil {
 		return "", err
 	}
-	return p.GetPop().SerializeToHexStr(), nil
+	return p.GetPublicKey().SerializeToHexStr(), nil
+}
+
+// A proof of possession is a signature over the validator's own public key.
+// Aggregate verification simply sums public keys, so without it a validator
+// could register pk' = pk_evil - sum(pk_honest) and forge a quorum alone.
+// The domain tag keeps proofs apart from ordinary consensus signatures.
+var popDomain = []byte("URD_BLS_POP_")
+
+func popMessage(pub *bls.PublicKey) []byte {
+	return append(append([]byte{}, popDomain...), pub.Serialize()...)
+}
+
+// BLSPop returns the hex proof of possession for privateKey.
+func BLSPop(privateKey string) (string, error) {
+	p := bls.SecretKey{}
+	if err := p.DeserializeHexStr(privateKey); err != nil {
+		return "", err
+	}
+	return p.SignByte(popMessage(p.GetPublicKey())).SerializeToHexStr(), nil
+}
+
+// VerifyBLSPop checks that pop proves possession of the secret key behind publicKey.
+func VerifyBLSPop(publicKey string, pop string) error {
+	pub := new(bls.PublicKey)
+	if err := pub.DeserializeHexStr(publicKey); err != nil {
+		return err
+	}
+	sig := new(bls.Sign)
+	if err := sig.DeserializeHexStr(pop); err != nil {
+		return fmt.Errorf("invalid proof of possession: %v", err)
+	}
+	if !sig.VerifyByte(pub, popMessage(pub)) {
+		return fmt.Errorf("proof of possession does not match public key %s", publicKey)
+	}
+	return nil
 }
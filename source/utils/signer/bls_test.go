@@ -68,3 +68,86 @@ func TestBLS(t *testing.T) {
 
 	fmt.Println(verifier.VerifyAggregateSignature(aggSig, msg, bv.Byte()))
 }
+
+func TestBLSPop(t *testing.T) {
+	privateKey1, publicKey1, err := NewBLSKeyPair(bls.BLS12_381)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, publicKey2, err := NewBLSKeyPair(bls.BLS12_381)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pop1, err := BLSPop(privateKey1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBLSPop(publicKey1, pop1); err != nil {
+		t.Fatalf("valid proof rejected: %v", err)
+	}
+	if err := VerifyBLSPop(publicKey2, pop1); err == nil {
+		t.Fatal("proof accepted for a key it was not made with")
+	}
+
+	// a key derived from others (here pk1 + pk2) has no secret key behind it, so it has no proof
+	var pub1, rogue bls.PublicKey
+	if err := pub1.DeserializeHexStr(publicKey1); err != nil {
+		t.Fatal(err)
+	}
+	if err := rogue.DeserializeHexStr(publicKey2); err != nil {
+		t.Fatal(err)
+	}
+	rogue.Add(&pub1)
+	if _, err := NewVerifierWithPop([]string{publicKey1, rogue.SerializeToHexStr()}, []string{pop1, pop1}); err == nil {
+		t.Fatal("verifier accepted a rogue key")
+	}
+	if _, err := NewVerifierWithPop([]string{publicKey1}, nil); err == nil {
+		t.Fatal("verifier accepted a key without proof")
+	}
+	if _, err := NewVerifierWithPop([]string{publicKey1}, []string{pop1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	const n = 9
+	msg := []byte("vote")
+	publicKeys := make([]string, n)
+	sigs := make([]string, n)
+	indexes := make([]int, n)
+	for i := 0; i < n; i++ {
+		privateKey, publicKey, err := NewBLSKeyPair(bls.BLS12_381)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewSigner(privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKeys[i] = publicKey
+		indexes[i] = i
+		if sigs[i], err = s.Sign(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	verifier, err := NewVerifier(publicKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ok := range verifier.VerifyBatch(sigs, msg, indexes) {
+		if !ok {
+			t.Fatalf("valid signature %d rejected", i)
+		}
+	}
+
+	// swap two signatures, corrupt one, and point one at an unknown validator
+	sigs[1], sigs[6] = sigs[6], sigs[1]
+	sigs[3] = "zz"
+	indexes[8] = n
+	bad := map[int]bool{1: true, 3: true, 6: true, 8: true}
+	for i, ok := range verifier.VerifyBatch(sigs, msg, indexes) {
+		if ok == bad[i] {
+			t.Fatalf("signature %d: got valid=%v", i, ok)
+		}
+	}
+}
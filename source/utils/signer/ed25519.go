@@ -0,0 +1,145 @@
+package signer
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"emulator/utils"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Ed25519Signer and Ed25519Verifier implement the ed25519 scheme. Ed25519
+// signatures cannot be combined, so a certificate is the list of signatures
+// ordered by validator index, next to the usual bit map.
+type Ed25519Signer struct {
+	privateKey ed25519.PrivateKey
+}
+
+type Ed25519Verifier struct {
+	publicKeys []ed25519.PublicKey
+}
+
+var _ Signer = (*Ed25519Signer)(nil)
+var _ Verifier = (*Ed25519Verifier)(nil)
+
+func NewEd25519KeyPair() (string, string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(priv.Seed()), hex.EncodeToString(pub), nil
+}
+
+func NewEd25519Signer(privateKeyStr string) (*Ed25519Signer, error) {
+	seed, err := hex.DecodeString(privateKeyStr)
+	if err != nil {
+		return nil, fmt.Errorf("Wrong private key: " + err.Error())
+	} else if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("Wrong private key: expected %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	return &Ed25519Signer{
+		privateKey: ed25519.NewKeyFromSeed(seed),
+	}, nil
+}
+
+func Ed25519Pubkey(privateKey string) (string, error) {
+	s, err := NewEd25519Signer(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(s.privateKey.Public().(ed25519.PublicKey)), nil
+}
+
+func NewEd25519Verifier(publicKeysStr []string) (*Ed25519Verifier, error) {
+	publicKeys := make([]ed25519.PublicKey, len(publicKeysStr))
+	for i, publicKeyStr := range publicKeysStr {
+		key, err := hex.DecodeString(publicKeyStr)
+		if err != nil {
+			return nil, err
+		} else if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("validator %d: expected a %d byte public key, got %d", i, ed25519.PublicKeySize, len(key))
+		}
+		publicKeys[i] = key
+	}
+	return &Ed25519Verifier{
+		publicKeys: publicKeys,
+	}, nil
+}
+
+func (s *Ed25519Signer) Sign(data []byte) (string, error) {
+	return hex.EncodeToString(ed25519.Sign(s.privateKey, data)), nil
+}
+
+func (s *Ed25519Signer) SignType(data SignableType) (string, error) {
+	return s.Sign(data.SignBytes())
+}
+
+func (s *Ed25519Verifier) Verify(sig string, msg []byte, index int) bool {
+	if index < 0 || index >= len(s.publicKeys) {
+		return false
+	}
+	bz, err := hex.DecodeString(sig)
+	if err != nil || len(bz) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(s.publicKeys[index], msg, bz)
+}
+
+func (s *Ed25519Verifier) VerifyBatch(sigs []string, msg []byte, indexes []int) []bool {
+	valid := make([]bool, len(sigs))
+	for i := range sigs {
+		valid[i] = s.Verify(sigs[i], msg, indexes[i])
+	}
+	return valid
+}
+
+func (s *Ed25519Verifier) Aggregate(sigs []string, indexes []int) (string, error) {
+	if len(sigs) != len(indexes) {
+		return "", fmt.Errorf("%d signatures but %d indexes", len(sigs), len(indexes))
+	}
+	order := make([]int, len(sigs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return indexes[order[a]] < indexes[order[b]] })
+	aggSig := make([]byte, 0, len(sigs)*ed25519.SignatureSize)
+	for i, j := range order {
+		if i > 0 && indexes[order[i-1]] == indexes[j] {
+			return "", fmt.Errorf("two signatures from validator %d", indexes[j])
+		}
+		bz, err := hex.DecodeString(sigs[j])
+		if err != nil {
+			return "", err
+		} else if len(bz) != ed25519.SignatureSize {
+			return "", fmt.Errorf("signature of validator %d has %d bytes", indexes[j], len(bz))
+		}
+		aggSig = append(aggSig, bz...)
+	}
+	return hex.EncodeToString(aggSig), nil
+}
+
+func (s *Ed25519Verifier) VerifyAggregateSignature(aggSig string, msg []byte, bitMapBytes []byte) bool {
+	bitMap := utils.NewBitArrayFromByte(bitMapBytes)
+	if len(s.publicKeys) != bitMap.Size() {
+		return false
+	}
+	bz, err := hex.DecodeString(aggSig)
+	if err != nil || len(bz)%ed25519.SignatureSize != 0 {
+		return false
+	}
+	for i := 0; i < bitMap.Size(); i++ {
+		if !bitMap.GetIndex(i) {
+			continue
+		}
+		if len(bz) == 0 || !ed25519.Verify(s.publicKeys[i], msg, bz[:ed25519.SignatureSize]) {
+			return false
+		}
+		bz = bz[ed25519.SignatureSize:]
+	}
+	return len(bz) == 0
+}
+
+func (s *Ed25519Verifier) Size() int {
+	return len(s.publicKeys)
+}
@@ -0,0 +1,193 @@
+package signer
+
+import (
+	"fmt"
+
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+const (
+	SchemeBLS       = "bls"
+	SchemeEd25519   = "ed25519"
+	SchemeThreshold = "bls-threshold"
+
+	DefaultScheme = SchemeBLS
+)
+
+type Signer interface {
+	Sign(data []byte) (string, error)
+	SignType(data SignableType) (string, error)
+}
+
+// Verifier checks signatures of the validators of one shard, identified by
+// their index in the shard's peer list. Aggregated signatures come with a bit
+// map of the validators that took part.
+type Verifier interface {
+	Verify(sig string, msg []byte, index int) bool
+	VerifyBatch(sigs []string, msg []byte, indexes []int) []bool
+	VerifyAggregateSignature(aggSig string, msg []byte, bitMapBytes []byte) bool
+	Size() int
+	Aggregator
+}
+
+// Aggregator builds the certificate of a quorum from the signatures the
+// validators at indexes made over the same message.
+type Aggregator interface {
+	Aggregate(sigs []string, indexes []int) (string, error)
+}
+
+// SchemeParams is the public, per shard part of a signature scheme's setup.
+type SchemeParams struct {
+	Scheme    string `json:"sign_scheme,omitempty"`
+	GroupKey  string `json:"group_key,omitempty"`
+	Threshold int    `json:"threshold,omitempty"`
+}
+
+func (p SchemeParams) Name() string {
+	if p.Scheme == "" {
+		return DefaultScheme
+	}
+	return p.Scheme
+}
+
+// KeySet holds the keys of every validator of one shard.
+type KeySet struct {
+	Params      SchemeParams
+	PrivateKeys []string
+	PublicKeys  []string
+	Pops        []string
+}
+
+func ValidateScheme(scheme string) error {
+	switch scheme {
+	case "", SchemeBLS, SchemeEd25519, SchemeThreshold:
+		return nil
+	default:
+		return fmt.Errorf("unknown signature scheme %q", scheme)
+	}
+}
+
+// GenerateKeys creates keys for n validators. threshold is only used by the
+// threshold scheme, it must be 0 or Quorum(n).
+func GenerateKeys(scheme string, n int, threshold int) (*KeySet, error) {
+	keys := &KeySet{
+		Params:      SchemeParams{Scheme: scheme},
+		PrivateKeys: make([]string, n),
+		PublicKeys:  make([]string, n),
+		Pops:        make([]string, n),
+	}
+	switch keys.Params.Name() {
+	case SchemeBLS:
+		for i := 0; i < n; i++ {
+			priv, pub, err := NewBLSKeyPair(BaseCurve)
+			if err != nil {
+				return nil, err
+			}
+			pop, err := BLSPop(priv)
+			if err != nil {
+				return nil, err
+			}
+			keys.PrivateKeys[i], keys.PublicKeys[i], keys.Pops[i] = priv, pub, pop
+		}
+	case SchemeEd25519:
+		for i := 0; i < n; i++ {
+			priv, pub, err := NewEd25519KeyPair()
+			if err != nil {
+				return nil, err
+			}
+			keys.PrivateKeys[i], keys.PublicKeys[i] = priv, pub
+		}
+	case SchemeThreshold:
+		if threshold == 0 {
+			threshold = Quorum(n)
+		} else if threshold != Quorum(n) {
+			return nil, fmt.Errorf("threshold %d is not the quorum %d of %d validators", threshold, Quorum(n), n)
+		}
+		groupKey, privs, pubs, err := NewThresholdKeys(n, threshold)
+		if err != nil {
+			return nil, err
+		}
+		keys.Params.GroupKey, keys.Params.Threshold = groupKey, threshold
+		keys.PrivateKeys, keys.PublicKeys = privs, pubs
+	default:
+		return nil, ValidateScheme(scheme)
+	}
+	return keys, nil
+}
+
+func NewSignerForScheme(scheme string, privateKey string) (Signer, error) {
+	switch scheme {
+	case "", SchemeBLS, SchemeThreshold:
+		// a threshold share is an ordinary BLS secret key
+		if err := bls.Init(BaseCurve); err != nil {
+			return nil, err
+		}
+		if s, err := NewSigner(privateKey); err != nil {
+			return nil, err
+		} else {
+			return s, nil
+		}
+	case SchemeEd25519:
+		if s, err := NewEd25519Signer(privateKey); err != nil {
+			return nil, err
+		} else {
+			return s, nil
+		}
+	default:
+		return nil, ValidateScheme(scheme)
+	}
+}
+
+func NewVerifierForScheme(params SchemeParams, publicKeys []string, pops []string) (Verifier, error) {
+	switch params.Name() {
+	case SchemeBLS:
+		if v, err := NewVerifierWithPop(publicKeys, pops); err != nil {
+			return nil, err
+		} else {
+			return v, nil
+		}
+	case SchemeEd25519:
+		if v, err := NewEd25519Verifier(publicKeys); err != nil {
+			return nil, err
+		} else {
+			return v, nil
+		}
+	case SchemeThreshold:
+		if v, err := NewThresholdVerifier(params.GroupKey, params.Threshold, publicKeys); err != nil {
+			return nil, err
+		} else {
+			return v, nil
+		}
+	default:
+		return nil, ValidateScheme(params.Scheme)
+	}
+}
+
+// NewKeyPairForScheme creates a standalone key pair. Threshold shares can only
+// be dealt together, see GenerateKeys.
+func NewKeyPairForScheme(scheme string) (string, string, error) {
+	switch scheme {
+	case "", SchemeBLS:
+		return NewBLSKeyPair(BaseCurve)
+	case SchemeEd25519:
+		return NewEd25519KeyPair()
+	case SchemeThreshold:
+		return "", "", fmt.Errorf("%s keys are dealt for a whole shard at once", scheme)
+	default:
+		return "", "", ValidateScheme(scheme)
+	}
+}
+
+func PublicKeyForScheme(scheme string, privateKey string) (string, error) {
+	switch scheme {
+	case "", SchemeBLS, SchemeThreshold:
+		if err := bls.Init(BaseCurve); err != nil {
+			return "", err
+		}
+		return BLSPubkey(privateKey)
+	case SchemeEd25519:
+		return Ed25519Pubkey(privateKey)
+	default:
+		return "", ValidateScheme(scheme)
+	}
+}
@@ -0,0 +1,90 @@
+package signer
+
+import (
+	"emulator/utils"
+	"testing"
+)
+
+func TestSchemes(t *testing.T) {
+	const n = 4
+	msg := []byte("vote")
+	for _, scheme := range []string{SchemeBLS, SchemeEd25519, SchemeThreshold} {
+		keys, err := GenerateKeys(scheme, n, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		verifier, err := NewVerifierForScheme(keys.Params, keys.PublicKeys, keys.Pops)
+		if err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		sigs := make([]string, n)
+		for i := 0; i < n; i++ {
+			s, err := NewSignerForScheme(scheme, keys.PrivateKeys[i])
+			if err != nil {
+				t.Fatalf("%s: %v", scheme, err)
+			}
+			if sigs[i], err = s.Sign(msg); err != nil {
+				t.Fatalf("%s: %v", scheme, err)
+			}
+			if !verifier.Verify(sigs[i], msg, i) {
+				t.Fatalf("%s: signature of validator %d rejected", scheme, i)
+			}
+		}
+		if verifier.Verify(sigs[0], msg, 1) {
+			t.Fatalf("%s: signature accepted for the wrong validator", scheme)
+		}
+
+		// a quorum of validators 3, 0 and 2, given out of order
+		aggSig, err := verifier.Aggregate([]string{sigs[3], sigs[0], sigs[2]}, []int{3, 0, 2})
+		if err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		bv := utils.NewBitVector(n)
+		bv.SetIndex(0, true)
+		bv.SetIndex(2, true)
+		bv.SetIndex(3, true)
+		if !verifier.VerifyAggregateSignature(aggSig, msg, bv.Byte()) {
+			t.Fatalf("%s: aggregate signature rejected", scheme)
+		}
+		if verifier.VerifyAggregateSignature(aggSig, []byte("other"), bv.Byte()) {
+			t.Fatalf("%s: aggregate signature accepted for another message", scheme)
+		}
+		if scheme != SchemeThreshold {
+			// the threshold certificate does not depend on who signed
+			bv.SetIndex(1, true)
+			if verifier.VerifyAggregateSignature(aggSig, msg, bv.Byte()) {
+				t.Fatalf("%s: aggregate signature accepted with a wrong bit map", scheme)
+			}
+		}
+		t.Logf("%s: aggregate of 3 signatures is %d hex chars", scheme, len(aggSig))
+	}
+}
+
+func TestThresholdNeedsQuorum(t *testing.T) {
+	if _, err := GenerateKeys(SchemeThreshold, 7, 3); err == nil {
+		t.Fatal("dealt shares with a threshold below the quorum")
+	}
+	keys, err := GenerateKeys(SchemeThreshold, 7, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifierForScheme(keys.Params, keys.PublicKeys, keys.Pops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSignerForScheme(SchemeThreshold, keys.PrivateKeys[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := s.Sign([]byte("vote"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Aggregate([]string{sig}, []int{1}); err == nil {
+		t.Fatal("aggregated fewer shares than the threshold")
+	}
+	// the same share given for the whole quorum is one share
+	if _, err := verifier.Aggregate([]string{sig, sig, sig, sig}, []int{1, 1, 1, 1}); err == nil {
+		t.Fatal("aggregated one share counted four times")
+	}
+}
@@ -0,0 +1,138 @@
+package signer
+
+import (
+	"emulator/utils"
+	"fmt"
+	"strconv"
+
+	"github.com/herumi/bls-eth-go-binary/bls"
+)
+
+// ThresholdVerifier implements the bls-threshold scheme. A dealer splits one
+// group secret into shares so that any threshold of them can sign: each
+// validator signs with its share like in the bls scheme, and a certificate is
+// the group signature interpolated from a quorum of shares. It has the size of
+// a single signature and is checked against the group key only.
+type ThresholdVerifier struct {
+	*BLSVerifier
+
+	groupKey  *bls.PublicKey
+	threshold int
+}
+
+var _ Verifier = (*ThresholdVerifier)(nil)
+
+// Quorum is the number of the n validators of a shard whose votes certify a
+// block, the threshold of a bls-threshold shard must be this quorum.
+func Quorum(n int) int {
+	if t := 2 * n / 3; t > 0 {
+		return t
+	}
+	return 1
+}
+
+// validators are numbered from 1, id 0 would be the group secret itself
+func shareID(index int) (bls.ID, error) {
+	var id bls.ID
+	err := id.SetDecString(strconv.Itoa(index + 1))
+	return id, err
+}
+
+// NewThresholdKeys deals n shares of a fresh group secret, any threshold of
+// which can produce a group signature.
+func NewThresholdKeys(n int, threshold int) (string, []string, []string, error) {
+	if threshold < 1 || threshold > n {
+		return "", nil, nil, fmt.Errorf("threshold %d out of range [1,%d]", threshold, n)
+	}
+	if err := bls.Init(BaseCurve); err != nil {
+		return "", nil, nil, err
+	}
+	var groupSecret bls.SecretKey
+	groupSecret.SetByCSPRNG()
+	polynomial := groupSecret.GetMasterSecretKey(threshold)
+	privateKeys, publicKeys := make([]string, n), make([]string, n)
+	for i := 0; i < n; i++ {
+		id, err := shareID(i)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		var share bls.SecretKey
+		if err := share.Set(polynomial, &id); err != nil {
+			return "", nil, nil, err
+		}
+		privateKeys[i] = share.SerializeToHexStr()
+		publicKeys[i] = share.GetPublicKey().SerializeToHexStr()
+	}
+	return groupSecret.GetPublicKey().SerializeToHexStr(), privateKeys, publicKeys, nil
+}
+
+func NewThresholdVerifier(groupKeyStr string, threshold int, publicKeysStr []string) (*ThresholdVerifier, error) {
+	// the share keys come from the dealer and are never summed, so they need
+	// no proof of possession
+	shares, err := NewVerifier(publicKeysStr)
+	if err != nil {
+		return nil, err
+	}
+	if q := Quorum(len(publicKeysStr)); threshold != q {
+		return nil, fmt.Errorf("threshold %d is not the quorum %d of %d validators", threshold, q, len(publicKeysStr))
+	}
+	groupKey := new(bls.PublicKey)
+	if err := groupKey.DeserializeHexStr(groupKeyStr); err != nil {
+		return nil, fmt.Errorf("invalid group key: %v", err)
+	}
+	return &ThresholdVerifier{
+		BLSVerifier: shares,
+		groupKey:    groupKey,
+		threshold:   threshold,
+	}, nil
+}
+
+func (s *ThresholdVerifier) Aggregate(sigs []string, indexes []int) (string, error) {
+	if len(sigs) != len(indexes) {
+		return "", fmt.Errorf("%d signatures but %d indexes", len(sigs), len(indexes))
+	}
+	// the shares are counted by validator, a share given twice is one
+	seen := make(map[int]bool, len(indexes))
+	for _, index := range indexes {
+		if index < 0 || index >= s.Size() {
+			return "", fmt.Errorf("validator %d out of range [0,%d)", index, s.Size())
+		} else if seen[index] {
+			return "", fmt.Errorf("two signatures of validator %d", index)
+		}
+		seen[index] = true
+	}
+	if len(seen) < s.threshold {
+		return "", fmt.Errorf("%d signatures, the threshold is %d", len(seen), s.threshold)
+	}
+	sigVec, idVec := make([]bls.Sign, len(sigs)), make([]bls.ID, len(sigs))
+	for i := range sigs {
+		if err := sigVec[i].DeserializeHexStr(sigs[i]); err != nil {
+			return "", err
+		}
+		id, err := shareID(indexes[i])
+		if err != nil {
+			return "", err
+		}
+		idVec[i] = id
+	}
+	var groupSig bls.Sign
+	if err := groupSig.Recover(sigVec, idVec); err != nil {
+		return "", err
+	}
+	return groupSig.SerializeToHexStr(), nil
+}
+
+// VerifyAggregateSignature checks the group signature only. The bit map is
+// not signed by anyone, so the signers are not counted from it: a group
+// signature can only be recovered from threshold shares, and the threshold
+// is the quorum.
+func (s *ThresholdVerifier) VerifyAggregateSignature(aggSig string, msg []byte, bitMapBytes []byte) bool {
+	if s.Size() != utils.NewBitArrayFromByte(bitMapBytes).Size() {
+		return false
+	}
+	sig := new(bls.Sign)
+	if err := sig.DeserializeHexStr(aggSig); err != nil {
+		return false
+	}
+	return sig.VerifyByte(s.groupKey, msg)
+}
@@ -0,0 +1,166 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"emulator/utils/p2p"
+)
+
+// Peers lists the peers of the sender and whether it is connected to them.
+func Peers(sender *p2p.Sender) []Peer {
+	peers := []Peer{}
+	for _, peer := range sender.Peers() {
+		shards := []string{}
+		for shard := range peer.ChainID() {
+			shards = append(shards, shard)
+		}
+		sort.Strings(shards)
+		peers = append(peers, Peer{Address: peer.GetIP(), Shards: shards, Connected: sender.Connected(peer.GetIP())})
+	}
+	return peers
+}
+
+// Target is a node of a shard_info.json, by its p2p address.
+type Target struct {
+	Address string   `json:"address"`
+	Shards  []string `json:"shards"`
+}
+
+// ReadTargets lists the nodes of a shard_info.json of urd or of pyramid.
+func ReadTargets(shardInfo []byte) ([]Target, error) {
+	var raw struct {
+		// urd
+		Shards map[string]struct {
+			PeerList []*p2p.Peer `json:"peer_list"`
+		} `json:"Shards"`
+		// pyramid
+		PeerList map[string][]*p2p.Peer `json:"peer_list"`
+	}
+	if err := json.Unmarshal(shardInfo, &raw); err != nil {
+		return nil, err
+	}
+	shards := map[string][]string{}
+	add := func(shard string, peers []*p2p.Peer) {
+		for _, peer := range peers {
+			shards[peer.GetIP()] = append(shards[peer.GetIP()], shard)
+		}
+	}
+	for shard, s := range raw.Shards {
+		add(shard, s.PeerList)
+	}
+	for shard, peers := range raw.PeerList {
+		add(shard, peers)
+	}
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("no peers in the shard info")
+	}
+	targets := make([]Target, 0, len(shards))
+	for addr, ss := range shards {
+		sort.Strings(ss)
+		targets = append(targets, Target{Address: addr, Shards: ss})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if a, b := strings.Join(targets[i].Shards, ","), strings.Join(targets[j].Shards, ","); a != b {
+			return a < b
+		}
+		return targets[i].Address < targets[j].Address
+	})
+	return targets, nil
+}
+
+// URL is where the status of the node at the p2p address is served, on the
+// metrics port: the p2p port plus portOffset.
+func URL(p2pAddress string, portOffset int) (string, error) {
+	host, port, err := net.SplitHostPort(p2pAddress)
+	if err != nil {
+		return "", err
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", p2pAddress, err)
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(p+portOffset)) + "/status", nil
+}
+
+// Fetch gets the status at url.
+func Fetch(client *http.Client, url string) (*Status, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	s := new(Status)
+	if err := json.NewDecoder(resp.Body).Decode(s); err != nil {
+		return nil, fmt.Errorf("%s: %v", url, err)
+	}
+	return s, nil
+}
+
+// Result is the status of a target, or why there is none.
+type Result struct {
+	Target
+	Status *Status `json:"status,omitempty"`
+	Err    error   `json:"-"`
+	// Error is Err for the JSON output
+	Error string `json:"error,omitempty"`
+}
+
+// Poll fetches the status of every target at once.
+func Poll(targets []Target, portOffset int, timeout time.Duration) []Result {
+	client := &http.Client{Timeout: timeout}
+	results := make([]Result, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		results[i].Target = target
+		wg.Add(1)
+		go func(r *Result) {
+			defer wg.Done()
+			url, err := URL(r.Address, portOffset)
+			if err == nil {
+				r.Status, err = Fetch(client, url)
+			}
+			if err != nil {
+				r.Err, r.Error = err, err.Error()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	return results
+}
+
+// WriteTable writes a line per node, the ones that did not answer with
+// their error.
+func WriteTable(w io.Writer, results []Result) {
+	fmt.Fprintf(w, "%-8s %-8s %-22s %-3s %8s %6s %-16s %-12s %9s %9s %8s %6s\n", "shard", "node", "address", "ldr",
+		"view", "round", "step", "waiting", "mempool", "cross", "tip", "peers")
+	for _, r := range results {
+		shards := strings.Join(r.Shards, ",")
+		if r.Err != nil {
+			fmt.Fprintf(w, "%-8s %-8s %-22s %v\n", shards, "?", r.Address, r.Err)
+			continue
+		}
+		s := r.Status
+		leader, waiting := "", "-"
+		if s.Leader {
+			leader = "*"
+		}
+		if len(s.WaitingFor) > 0 {
+			waiting = strings.Join(s.WaitingFor, ",")
+		}
+		fmt.Fprintf(w, "%-8s %-8s %-22s %-3s %8d %6d %-16s %-12s %9d %9d %8d %6s\n", shards, s.Node, r.Address, leader,
+			s.View, s.Round, s.Step, waiting, s.Mempool, s.CrossShardMempool, s.Tip.View,
+			fmt.Sprintf("%d/%d", s.ConnectedPeers(), len(s.Peers)))
+	}
+}
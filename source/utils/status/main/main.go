@@ -0,0 +1,59 @@
+package main
+
+import (
+	"emulator/utils/config"
+	"emulator/utils/status"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ./cluster-status --shard-info=./mytestnet/127.0.0.1/node1/config/shard_info.json
+// ./cluster-status --shard-info=... --watch=2s
+// ./cluster-status --shard-info=... --format=json
+
+func main() {
+	shardInfoPath := flag.String("shard-info", "./shard_info.json", "The shard_info.json of the testnet, urd or pyramid")
+	portOffset := flag.Int("port-offset", config.MetricsPortOffset, "The metrics port of a node is its p2p port plus this offset")
+	timeout := flag.Duration("timeout", 2*time.Second, "How long to wait for a node to answer")
+	watch := flag.Duration("watch", 0, "Poll again at this interval, 0 polls once")
+	format := flag.String("format", "text", "Output format: text or json")
+	flag.Parse()
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown format %q, want text or json\n", *format)
+		os.Exit(2)
+	}
+	shardInfoBz, err := os.ReadFile(*shardInfoPath)
+	if err != nil {
+		panic(err)
+	}
+	targets, err := status.ReadTargets(shardInfoBz)
+	if err != nil {
+		panic(err)
+	}
+	for {
+		results := status.Poll(targets, *portOffset, *timeout)
+		if *watch > 0 && *format == "text" {
+			// clear the terminal
+			fmt.Print("\033[H\033[2J")
+			fmt.Println(time.Now().Format(time.RFC3339))
+		}
+		switch *format {
+		case "text":
+			status.WriteTable(os.Stdout, results)
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(results); err != nil {
+				panic(err)
+			}
+		}
+		if *watch <= 0 {
+			return
+		}
+		time.Sleep(*watch)
+	}
+}
@@ -0,0 +1,111 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// What a node is doing, served as JSON on /status and as a page on / next to
+// the metrics, so that a run can be watched while it goes.
+
+// Consensus is the part of the status the consensus of either protocol
+// reports.
+type Consensus struct {
+	Leader bool `json:"leader"`
+	// View is the height of pyramid
+	View  int64  `json:"view"`
+	Round int64  `json:"round"`
+	Step  string `json:"step"`
+	// WaitingFor are the shards whose cross-shard messages the node still
+	// waits for before it can move on
+	WaitingFor []string `json:"waiting_for"`
+	// Tip is the last block the node has
+	Tip Tip `json:"tip"`
+}
+
+type Tip struct {
+	View int64  `json:"view"`
+	Hash string `json:"hash"`
+}
+
+type Peer struct {
+	Address   string   `json:"address"`
+	Shards    []string `json:"shards"`
+	Connected bool     `json:"connected"`
+}
+
+type Status struct {
+	Node     string    `json:"node"`
+	ChainID  string    `json:"chain_id"`
+	Protocol string    `json:"protocol"`
+	Time     time.Time `json:"time"`
+	Uptime   float64   `json:"uptime_s"`
+
+	Consensus
+	Mempool           int    `json:"mempool"`
+	CrossShardMempool int    `json:"cross_shard_mempool"`
+	Peers             []Peer `json:"peers"`
+}
+
+// ConnectedPeers is the number of peers the node has a connection to.
+func (s *Status) ConnectedPeers() int {
+	n := 0
+	for _, p := range s.Peers {
+		if p.Connected {
+			n++
+		}
+	}
+	return n
+}
+
+// Handlers serve the status status returns on /status and /.
+func Handlers(status func() *Status) map[string]http.Handler {
+	return map[string]http.Handler{
+		"/status": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(status()); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		}),
+		"/": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/" {
+				http.NotFound(w, req)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := page.Execute(w, status()); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		}),
+	}
+}
+
+var page = template.Must(template.New("status").Funcs(template.FuncMap{
+	"seconds": func(s float64) string { return fmt.Sprintf("%.0fs", s) },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta http-equiv="refresh" content="2">
+<title>{{.Node}} ({{.ChainID}})</title>
+<style>body{font-family:monospace}td,th{padding:2px 12px;text-align:left}.down{color:#c00}</style>
+</head><body>
+<h3>{{.Node}}, shard {{.ChainID}}, {{.Protocol}}{{if .Leader}}, leader{{end}}, up {{seconds .Uptime}}</h3>
+<table>
+<tr><th>view</th><td>{{.View}}</td></tr>
+<tr><th>round</th><td>{{.Round}}</td></tr>
+<tr><th>step</th><td>{{.Step}}</td></tr>
+<tr><th>waiting for</th><td>{{range .WaitingFor}}{{.}} {{else}}-{{end}}</td></tr>
+<tr><th>tip</th><td>{{.Tip.View}} {{.Tip.Hash}}</td></tr>
+<tr><th>mempool</th><td>{{.Mempool}}</td></tr>
+<tr><th>cross-shard mempool</th><td>{{.CrossShardMempool}}</td></tr>
+</table>
+<h4>peers, {{.ConnectedPeers}} of {{len .Peers}} connected</h4>
+<table>
+{{range .Peers}}<tr{{if not .Connected}} class="down"{{end}}><td>{{.Address}}</td><td>{{range .Shards}}{{.}} {{end}}</td><td>{{if .Connected}}connected{{else}}not connected{{end}}</td></tr>
+{{end}}</table>
+<p><a href="/status">/status</a> <a href="/metrics">/metrics</a></p>
+</body></html>
+`))
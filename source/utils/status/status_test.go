@@ -0,0 +1,61 @@
+package status
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadTargets(t *testing.T) {
+	urd := []byte(`{"Shards":{"1":{"peer_list":[{"ip":"127.0.0.1:26601","chain":{"1":true}}]},
+		"2":{"peer_list":[{"ip":"127.0.0.1:26605","chain":{"2":true}}]}}}`)
+	pyramid := []byte(`{"peer_list":{"b1":[{"ip":"127.0.0.1:26601"}],"i1":[{"ip":"127.0.0.1:26601"},{"ip":"127.0.0.1:26602"}]}}`)
+
+	targets, err := ReadTargets(urd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[0].Address != "127.0.0.1:26601" || targets[1].Shards[0] != "2" {
+		t.Fatalf("unexpected urd targets %+v", targets)
+	}
+	targets, err = ReadTargets(pyramid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a node of a B shard is also in the I shards it spans
+	if len(targets) != 2 || len(targets[0].Shards) != 2 || targets[1].Address != "127.0.0.1:26602" {
+		t.Fatalf("unexpected pyramid targets %+v", targets)
+	}
+	if _, err := ReadTargets([]byte(`{}`)); err == nil {
+		t.Fatal("expected an error without peers")
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	mux := http.NewServeMux()
+	for path, h := range Handlers(func() *Status {
+		return &Status{Node: "node1", ChainID: "1", Consensus: Consensus{View: 7, WaitingFor: []string{"2"}},
+			Peers: []Peer{{Address: "127.0.0.1:26602", Connected: true}, {Address: "127.0.0.1:26603"}}}
+	}) {
+		mux.Handle(path, h)
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	s, err := Fetch(&http.Client{Timeout: time.Second}, server.URL+"/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Node != "node1" || s.View != 7 || s.WaitingFor[0] != "2" || s.ConnectedPeers() != 1 {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("page: %s", resp.Status)
+	}
+}
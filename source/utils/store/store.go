@@ -119,6 +119,14 @@ func (p *PrefixStore) SetBlockByHash(hs []byte, block ProtoBytesInterface) error
 	return p.Set(hs, block.ProtoBytes())
 }
 
+// The receipts a block was executed into are kept by its height.
+func (p *PrefixStore) GetReceiptsByHeight(height int64, chain string) ([]byte, error) {
+	return p.GetSpecial([]byte(fmt.Sprintf("receipts/%d:%s", height, chain)))
+}
+func (p *PrefixStore) SetReceiptsByHeight(height int64, chain string, receipts []byte) error {
+	return p.SetSpecial([]byte(fmt.Sprintf("receipts/%d:%s", height, chain)), receipts)
+}
+
 type PrefixIterator struct {
 	iter dbm.Iterator
 }
@@ -143,6 +151,9 @@ type PrefixBatch struct {
 func (pi *PrefixBatch) Set(key, value []byte) error {
 	return pi.batch.Set(toDataKey(key), value)
 }
+func (pi *PrefixBatch) SetSpecial(key, value []byte) error {
+	return pi.batch.Set(toSpecialKey(key), value)
+}
 func (pi *PrefixBatch) Delete(key []byte) error {
 	return pi.batch.Delete(toDataKey(key))
 }
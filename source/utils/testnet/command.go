@@ -0,0 +1,148 @@
+package testnet
+
+import (
+	"crypto/rand"
+	"emulator/utils"
+	"emulator/utils/config"
+	"emulator/utils/genesis"
+	"emulator/utils/keystore"
+	"emulator/utils/topology"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Command is the testnet subcommand of a protocol binary, the hooks hold
+// what the protocols do differently.
+type Command struct {
+	Protocol string
+	// Example is the topology used when --config is empty
+	Example func() *topology.Topology
+	// Flags adds the flags of the protocol, it may be nil
+	Flags func(fs *flag.FlagSet)
+	// Generate writes the configs of the topology at topologyPath into rootDir
+	Generate func(topologyPath, rootDir, passphrase string, overrides config.Overrides)
+	// Leader reports whether the node generated in dir leads its shard
+	Leader func(dir string, cfg *config.Config) (bool, error)
+	// Latency measures the latency of the txs a leader committed
+	Latency func(node *Node) (*utils.Latency, error)
+}
+
+// Run generates a testnet on localhost from a shard config, runs every node
+// as a child process until the leaders reach --views, then stops them and
+// prints the results.
+func (c Command) Run(args []string) error {
+	fs := flag.NewFlagSet("testnet", flag.ExitOnError)
+	rootDir := fs.String("root", "./localnet", "Directory the testnet is generated in, it must not exist")
+	jsonDir := fs.String("config", "", "The JSON file of sharding topology structure, its IPs are replaced by "+LocalIP+"; the example config if empty")
+	views := fs.Int64("views", 50, "Stop once the leader of every shard has reached this view")
+	timeout := fs.Duration("timeout", 10*time.Minute, "Stop even if some shards have not reached --views, 0 waits forever")
+	genesisDelay := fs.Duration("genesis-delay", 10*time.Second, "Time between starting the nodes and their genesis time")
+	var overrides config.Overrides
+	fs.Var(&overrides, "set", "Override a setting of every generated config like consensus.max_part_size=40960, can be repeated")
+	if c.Flags != nil {
+		c.Flags(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(*rootDir); err == nil {
+		return fmt.Errorf("%s already exists, remove it or pick another --root", *rootDir)
+	}
+
+	shardConfig := c.Example()
+	if *jsonDir != "" {
+		var err error
+		if shardConfig, err = topology.Read(*jsonDir); err != nil {
+			return err
+		}
+	}
+	shardConfig.IPInUse = map[string]uint32{LocalIP: shardConfig.Nodes()}
+	if err := shardConfig.Validate(c.Protocol); err != nil {
+		return fmt.Errorf("%s: %v", *jsonDir, err)
+	}
+	if err := os.MkdirAll(*rootDir, os.ModePerm); err != nil {
+		return err
+	}
+	localConfig := filepath.Join(*rootDir, "shard-config.json")
+	if err := shardConfig.Write(localConfig); err != nil {
+		return err
+	}
+
+	// the keys of a throwaway testnet are sealed with a random passphrase
+	// that is handed to the nodes through the environment
+	bz := make([]byte, 16)
+	if _, err := rand.Read(bz); err != nil {
+		return err
+	}
+	passphrase := hex.EncodeToString(bz)
+	c.Generate(localConfig, *rootDir, passphrase, overrides)
+
+	nodes, err := c.nodes(*rootDir)
+	if err != nil {
+		return err
+	}
+	binary, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// the genesis time is only fixed once the configs are generated
+	if _, err := genesis.SetTime(*rootDir, time.Now().Add(*genesisDelay)); err != nil {
+		return err
+	}
+	cluster := NewCluster(nodes, Options{
+		Binary:  binary,
+		Env:     []string{keystore.PassphraseEnv + "=" + passphrase},
+		Views:   *views,
+		Timeout: *timeout,
+	})
+	if err := cluster.Start(); err != nil {
+		cluster.Stop()
+		return err
+	}
+	waitErr := cluster.WaitViews()
+	cluster.Stop()
+	if waitErr != nil {
+		fmt.Println("The testnet stopped early:", waitErr)
+	}
+
+	logDir := filepath.Join(*rootDir, "logs")
+	if err := cluster.CollectLogs(logDir); err != nil {
+		return err
+	}
+	fmt.Println("Logs collected in", logDir)
+	cluster.Report(c.Latency).Print(os.Stdout)
+	return waitErr
+}
+
+// nodes lists the nodes generated in rootDir.
+func (c Command) nodes(rootDir string) ([]*Node, error) {
+	dirs, err := filepath.Glob(filepath.Join(rootDir, LocalIP, "*"))
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*Node, 0, len(dirs))
+	for _, dir := range dirs {
+		cfg, err := config.LoadNode(dir, c.Protocol, nil)
+		if err != nil {
+			return nil, err
+		}
+		leader, err := c.Leader(dir, cfg)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, &Node{
+			Name:    cfg.NodeName,
+			Dir:     dir,
+			ChainID: cfg.ChainID,
+			Leader:  leader,
+		})
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no node was generated in %s", rootDir)
+	}
+	return nodes, nil
+}
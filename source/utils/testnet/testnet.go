@@ -0,0 +1,327 @@
+// Package testnet runs a cluster of nodes on localhost as child processes of
+// the urd or pyramid binary, waits for them to make progress and reports the
+// throughput, abort rate and latency of the run.
+package testnet
+
+import (
+	"bufio"
+	"emulator/logger/blocklogger"
+	"emulator/logger/txtrace"
+	"emulator/utils"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// LocalIP is the address every node of a testnet listens on
+	LocalIP = "127.0.0.1"
+
+	pollInterval = time.Second
+	stopTimeout  = 10 * time.Second
+)
+
+// Node is one node of the testnet. Leaders are the nodes whose blocklogger
+// files the results are read from.
+type Node struct {
+	Name    string
+	Dir     string
+	ChainID string
+	Leader  bool
+
+	cmd    *exec.Cmd
+	log    *os.File
+	done   chan struct{}
+	err    error
+	height int64
+	offset int64
+}
+
+func (n *Node) BriefLogPath() string {
+	return filepath.Join(n.Dir, n.Name+"-blocklogger-brief.txt")
+}
+
+func (n *Node) LogPath() string {
+	return filepath.Join(n.Dir, n.Name+".log")
+}
+
+type Options struct {
+	// Binary runs the nodes with --method=start --root=<node dir> and Args
+	Binary string
+	Args   []string
+	// Env is added to the environment of the nodes
+	Env []string
+
+	// Views every leader must reach before the testnet is stopped
+	Views int64
+	// Timeout stops the testnet even if the leaders are behind, 0 waits forever
+	Timeout time.Duration
+}
+
+type Cluster struct {
+	opts  Options
+	nodes []*Node
+}
+
+func NewCluster(nodes []*Node, opts Options) *Cluster {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].ChainID != nodes[j].ChainID {
+			return nodes[i].ChainID < nodes[j].ChainID
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+	return &Cluster{opts: opts, nodes: nodes}
+}
+
+func (c *Cluster) Nodes() []*Node { return c.nodes }
+
+// Start launches every node, their output goes to <node dir>/<name>.log.
+func (c *Cluster) Start() error {
+	for _, node := range c.nodes {
+		log, err := os.Create(node.LogPath())
+		if err != nil {
+			return err
+		}
+		args := append([]string{"--method=start", "--root=" + node.Dir}, c.opts.Args...)
+		cmd := exec.Command(c.opts.Binary, args...)
+		cmd.Stdout, cmd.Stderr = log, log
+		cmd.Env = append(os.Environ(), c.opts.Env...)
+		if err := cmd.Start(); err != nil {
+			log.Close()
+			return fmt.Errorf("starting %s: %v", node.Name, err)
+		}
+		node.cmd, node.log, node.done = cmd, log, make(chan struct{})
+		go func(node *Node) {
+			node.err = node.cmd.Wait()
+			close(node.done)
+		}(node)
+		fmt.Printf("Started %s (shard %s, pid %d)\n", node.Name, node.ChainID, cmd.Process.Pid)
+	}
+	return nil
+}
+
+// WaitViews returns once every leader committed Views blocks. It gives up
+// when a node exits or the timeout expires.
+func (c *Cluster) WaitViews() error {
+	var deadline <-chan time.Time
+	if c.opts.Timeout > 0 {
+		deadline = time.After(c.opts.Timeout)
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	lastReport := time.Now()
+	for {
+		for _, node := range c.nodes {
+			select {
+			case <-node.done:
+				return fmt.Errorf("%s exited: %v, see %s", node.Name, node.err, node.LogPath())
+			default:
+			}
+		}
+		behind := []string{}
+		for _, node := range c.nodes {
+			if !node.Leader {
+				continue
+			}
+			if err := node.pollHeight(); err != nil {
+				return err
+			}
+			if node.height < c.opts.Views {
+				behind = append(behind, fmt.Sprintf("%s=%d", node.ChainID, node.height))
+			}
+		}
+		if len(behind) == 0 {
+			return nil
+		}
+		if time.Since(lastReport) >= 10*time.Second {
+			fmt.Printf("[%v] waiting for view %d: %s\n", time.Now().Format(time.TimeOnly), c.opts.Views, strings.Join(behind, " "))
+			lastReport = time.Now()
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			return fmt.Errorf("timeout after %v, shards behind: %s", c.opts.Timeout, strings.Join(behind, " "))
+		}
+	}
+}
+
+// pollHeight reads the events the node logged since the last poll.
+func (n *Node) pollHeight() error {
+	f, err := os.Open(n.BriefLogPath())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(n.offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			// a partial line is read again at the next poll
+			return nil
+		}
+		n.offset += int64(len(line))
+		if event, err := blocklogger.NewConsensusEventFromJson(line); err == nil && event.Height > n.height {
+			n.height = event.Height
+		}
+	}
+}
+
+// Stop interrupts every node and kills those still running after stopTimeout.
+func (c *Cluster) Stop() {
+	for _, node := range c.nodes {
+		if node.cmd != nil {
+			node.cmd.Process.Signal(syscall.SIGINT)
+		}
+	}
+	timeout := time.After(stopTimeout)
+	for _, node := range c.nodes {
+		if node.cmd == nil {
+			continue
+		}
+		select {
+		case <-node.done:
+		case <-timeout:
+			fmt.Printf("%s did not stop in %v, killing it\n", node.Name, stopTimeout)
+			node.cmd.Process.Kill()
+			<-node.done
+		}
+		node.log.Close()
+	}
+}
+
+// CollectLogs copies the output and blocklogger files of every node to dir.
+func (c *Cluster) CollectLogs(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	for _, node := range c.nodes {
+		for _, path := range []string{
+			node.LogPath(),
+			node.BriefLogPath(),
+			filepath.Join(node.Dir, node.Name+"-blocklogger.txt"),
+			filepath.Join(node.Dir, node.Name+txtrace.Suffix),
+		} {
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			if err := copyFile(path, filepath.Join(dir, filepath.Base(path))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+type ShardReport struct {
+	ChainID string
+	Leader  string
+	Views   int64
+	// TPS and CommitRate over the blocks that had txs, as the logger tool
+	// computes them
+	TPS        float64
+	CommitRate float64
+	Latency    *utils.Latency
+	Err        error
+}
+
+func (s *ShardReport) AbortRate() float64 { return 100 - s.CommitRate }
+
+type Report struct {
+	Shards []*ShardReport
+}
+
+// Report reads the results of every leader, latency measures the latency of
+// a stopped node.
+func (c *Cluster) Report(latency func(node *Node) (*utils.Latency, error)) *Report {
+	report := new(Report)
+	for _, node := range c.nodes {
+		if !node.Leader {
+			continue
+		}
+		node.pollHeight()
+		shard := &ShardReport{ChainID: node.ChainID, Leader: node.Name, Views: node.height}
+		report.Shards = append(report.Shards, shard)
+		if shard.TPS, shard.CommitRate, shard.Err = throughput(node.BriefLogPath()); shard.Err != nil {
+			continue
+		}
+		shard.Latency, shard.Err = latency(node)
+	}
+	return report
+}
+
+func throughput(briefPath string) (tps float64, commitRate float64, err error) {
+	if _, err := os.Stat(briefPath); err != nil {
+		return 0, 0, err
+	}
+	reader, err := blocklogger.NewReader(briefPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	starts, ends, err := reader.NoneZeroPeriods()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(starts) == 0 || len(ends) == 0 || ends[len(ends)-1] <= starts[0] {
+		return 0, 0, fmt.Errorf("no blocks with txs in %s", briefPath)
+	}
+	if tps, commitRate, err = reader.CalculateTPS(starts[0], ends[len(ends)-1]); math.IsNaN(commitRate) {
+		// no cross-shard txs, nothing was aborted
+		commitRate = 100
+	}
+	return tps, commitRate, err
+}
+
+func (r *Report) Print(w io.Writer) {
+	total := new(utils.Latency)
+	var tps, commitRate float64
+	measured := 0
+	fmt.Fprintf(w, "%-8s %-8s %8s %12s %10s %12s %12s\n", "shard", "leader", "views", "tps", "abort %", "intra (s)", "cross (s)")
+	for _, shard := range r.Shards {
+		if shard.Err != nil {
+			fmt.Fprintf(w, "%-8s %-8s %8d  %v\n", shard.ChainID, shard.Leader, shard.Views, shard.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%-8s %-8s %8d %12.3f %10.3f %12.3f %12.3f\n", shard.ChainID, shard.Leader, shard.Views,
+			shard.TPS, shard.AbortRate(), shard.Latency.IntraShard().Seconds(), shard.Latency.CrossShard().Seconds())
+		tps += shard.TPS
+		commitRate += shard.CommitRate
+		total.Add(shard.Latency)
+		measured++
+	}
+	if measured == 0 {
+		return
+	}
+	fmt.Fprintf(w, "total: %.3f tps, %.3f %% aborted, latency %.3f s (intra-shard %.3f s, cross-shard %.3f s) over %d txs, %d aborted\n",
+		tps, 100-commitRate/float64(measured), total.Average().Seconds(),
+		total.IntraShard().Seconds(), total.CrossShard().Seconds(), total.Txs(),
+		total.IntraShardAborted+total.CrossShardAborted)
+}
@@ -0,0 +1,45 @@
+package topology
+
+import (
+	"emulator/utils/genesis"
+	"flag"
+	"fmt"
+)
+
+// Command is the topology subcommand of both binaries. "topology validate"
+// checks a topology file for urd and pyramid, so that a file meant for a
+// comparison is known to work with both before any config is generated.
+func Command(protocol string, args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return fmt.Errorf("usage: topology validate --config=<topology file> [--protocol=urd|pyramid|all]")
+	}
+	flags := flag.NewFlagSet("topology validate", flag.ExitOnError)
+	path := flags.String("config", "./example-shard-config.json", "The JSON file of sharding topology structure")
+	only := flags.String("protocol", protocol, "Protocol to check the topology for, all checks both")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	protocols := []string{*only}
+	if *only == "all" {
+		protocols = []string{genesis.ProtocolUrd, genesis.ProtocolPyramid}
+	}
+
+	t, err := Read(*path)
+	if err != nil {
+		return err
+	}
+	invalid := 0
+	for _, p := range protocols {
+		if err := t.Validate(p); err != nil {
+			fmt.Printf("%s: %s: %v\n", *path, p, err)
+			invalid++
+			continue
+		}
+		inum, bnum := t.ShardNums()
+		fmt.Printf("%s: %s: ok, %d shards (%d I-shards, %d B-shards), %d nodes\n", *path, p, len(t.Shards), inum, bnum, t.Nodes())
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%s is invalid for %d of %d protocols", *path, invalid, len(protocols))
+	}
+	return nil
+}
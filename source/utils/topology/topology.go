@@ -0,0 +1,370 @@
+package topology
+
+import (
+	"emulator/utils/genesis"
+	"emulator/utils/signer"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// A topology file lays out the shards of a testnet: the key range each shard
+// owns, how many nodes run it and on which machines, and for pyramid which
+// shards are I-shards and which B-shards bridge them. urd and pyramid both
+// generate their node configs from it, so that a comparison runs both on
+// exactly the same shards. urd runs every shard alike and only records the
+// I/B-shard layout in genesis.json.
+
+// BasePort is the port of the first node on every machine.
+const BasePort = 26601
+
+type Topology struct {
+	IPInUse map[string]uint32 `json:"ip_in_use"`
+	// Keyspace is the key range the shards split between them, written like
+	// a key_range. It defaults to the span of the shards' ranges.
+	Keyspace string   `json:"keyspace,omitempty"`
+	Shards   []*Shard `json:"shards"`
+
+	// Workload is used by urd unless a --workload file is given, see
+	// emulator/urd/abci/minibank
+	Workload json.RawMessage `json:"workload,omitempty"`
+}
+
+type Shard struct {
+	ChainID string `json:"chain_id"`
+	PeerNum uint32 `json:"peer_num"`
+	// KeyRange lists [low,high) ranges as "10,11,15,16" (urd) or
+	// "[10,11]+[15,16]" (pyramid), either protocol accepts both
+	KeyRange string `json:"key_range"`
+
+	// RelatedShards of an I-shard are the B-shards bridging it, and those of
+	// a B-shard the I-shards it bridges
+	RelatedShards []string `json:"related_shards,omitempty"`
+	IsI           bool     `json:"is_ishard"`
+
+	// SignScheme is one of bls (default), ed25519 and bls-threshold, pyramid
+	// only supports bls. Threshold only applies to bls-threshold, it is the
+	// quorum of 2/3 of PeerNum and may be left 0.
+	SignScheme string `json:"sign_scheme,omitempty"`
+	Threshold  int    `json:"threshold,omitempty"`
+}
+
+// Load reads a topology file, validates it for the protocol and returns it
+// with every key range in the protocol's format.
+func Load(path string, protocol string) (*Topology, error) {
+	t, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Validate(protocol); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, s := range t.Shards {
+		ranges, _ := ParseKeyRange(s.KeyRange)
+		s.KeyRange = FormatKeyRange(ranges, protocol)
+	}
+	return t, nil
+}
+
+// Read reads a topology file without validating it.
+func Read(path string) (*Topology, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := new(Topology)
+	if err := json.Unmarshal(bz, t); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return t, nil
+}
+
+func (t *Topology) Write(path string) error {
+	bz, err := json.MarshalIndent(t, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, bz, 0666)
+}
+
+// Shard returns the shard with the chain ID, nil if there is none.
+func (t *Topology) Shard(chain_id string) *Shard {
+	for _, s := range t.Shards {
+		if s.ChainID == chain_id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Nodes is the number of nodes of all shards.
+func (t *Topology) Nodes() uint32 {
+	total := uint32(0)
+	for _, s := range t.Shards {
+		total += s.PeerNum
+	}
+	return total
+}
+
+// ShardNums counts the I-shards and B-shards.
+func (t *Topology) ShardNums() (inum, bnum int) {
+	for _, s := range t.Shards {
+		if s.IsI {
+			inum++
+		} else {
+			bnum++
+		}
+	}
+	return inum, bnum
+}
+
+type Address struct {
+	IP   string
+	Port int
+}
+
+func (a Address) String() string { return fmt.Sprintf("%s:%d", a.IP, a.Port) }
+
+// Addresses assigns every node, in the order of the shards, a machine of
+// ip_in_use through a roulette wheel weighted by the nodes each machine
+// takes, and the next free port on it.
+func (t *Topology) Addresses() []Address {
+	ips := []string{}
+	maxCounter := uint32(0)
+	for ip, n := range t.IPInUse {
+		ips = append(ips, ip)
+		if n > maxCounter {
+			maxCounter = n
+		}
+	}
+	sort.Strings(ips)
+	gun := []string{}
+	for i := uint32(0); i < maxCounter; i++ {
+		for _, ip := range ips {
+			if t.IPInUse[ip] > i {
+				gun = append(gun, ip)
+			}
+		}
+	}
+	ports := map[string]int{}
+	out := make([]Address, t.Nodes())
+	for i := range out {
+		ip := gun[i%len(gun)]
+		if _, ok := ports[ip]; !ok {
+			ports[ip] = BasePort
+		}
+		out[i] = Address{IP: ip, Port: ports[ip]}
+		ports[ip]++
+	}
+	return out
+}
+
+// GenesisShard is the shard's entry of genesis.json without validators.
+func (s *Shard) GenesisShard() *genesis.Shard {
+	return &genesis.Shard{
+		ChainID:       s.ChainID,
+		KeyRange:      s.KeyRange,
+		SignScheme:    s.SignScheme,
+		IsIShard:      s.IsI,
+		RelatedShards: s.RelatedShards,
+	}
+}
+
+// Validate checks the topology for the protocol: the shards' key ranges are
+// disjoint and cover the keyspace, related shards exist, are related back
+// and pair I-shards with B-shards, and every machine and shard is usable.
+func (t *Topology) Validate(protocol string) error {
+	if protocol != genesis.ProtocolUrd && protocol != genesis.ProtocolPyramid {
+		return fmt.Errorf("unknown protocol %q", protocol)
+	}
+	if len(t.Shards) == 0 {
+		return fmt.Errorf("no shards")
+	}
+	if len(t.IPInUse) == 0 {
+		return fmt.Errorf("ip_in_use lists no machines")
+	}
+	for ip, n := range t.IPInUse {
+		if n == 0 {
+			return fmt.Errorf("ip_in_use: %s takes no nodes", ip)
+		}
+	}
+
+	shards := map[string]*Shard{}
+	for _, s := range t.Shards {
+		if s.ChainID == "" {
+			return fmt.Errorf("a shard has no chain_id")
+		}
+		if shards[s.ChainID] != nil {
+			return fmt.Errorf("shard %s is listed twice", s.ChainID)
+		}
+		shards[s.ChainID] = s
+		if s.PeerNum == 0 {
+			return fmt.Errorf("shard %s has no nodes", s.ChainID)
+		}
+		if protocol == genesis.ProtocolPyramid && s.SignScheme != "" && s.SignScheme != signer.SchemeBLS {
+			return fmt.Errorf("shard %s: pyramid only supports sign_scheme %s", s.ChainID, signer.SchemeBLS)
+		}
+		if q := signer.Quorum(int(s.PeerNum)); s.Threshold != 0 && s.Threshold != q {
+			return fmt.Errorf("shard %s: threshold %d is not the quorum %d of its nodes", s.ChainID, s.Threshold, q)
+		}
+	}
+	if err := t.validateKeyRanges(); err != nil {
+		return err
+	}
+
+	for _, s := range t.Shards {
+		seen := map[string]bool{}
+		for _, r := range s.RelatedShards {
+			related := shards[r]
+			switch {
+			case related == nil:
+				return fmt.Errorf("shard %s: related shard %s does not exist", s.ChainID, r)
+			case related == s:
+				return fmt.Errorf("shard %s is related to itself", s.ChainID)
+			case seen[r]:
+				return fmt.Errorf("shard %s: related shard %s is listed twice", s.ChainID, r)
+			case related.IsI == s.IsI:
+				return fmt.Errorf("shard %s and its related shard %s are both %ss", s.ChainID, r, kind(s))
+			case !contains(related.RelatedShards, s.ChainID):
+				return fmt.Errorf("shard %s is related to %s, but %s is not related to %s", s.ChainID, r, r, s.ChainID)
+			}
+			seen[r] = true
+		}
+	}
+
+	if protocol == genesis.ProtocolPyramid {
+		inum, bnum := t.ShardNums()
+		if inum == 0 || bnum == 0 {
+			return fmt.Errorf("pyramid needs I-shards and B-shards, there are %d and %d", inum, bnum)
+		}
+	}
+	return nil
+}
+
+type keyRange struct {
+	low, high string
+	chain_id  string
+}
+
+func (t *Topology) validateKeyRanges() error {
+	all := []keyRange{}
+	for _, s := range t.Shards {
+		ranges, err := ParseKeyRange(s.KeyRange)
+		if err != nil {
+			return fmt.Errorf("shard %s: %v", s.ChainID, err)
+		}
+		// accounts are named after the start key of the shard, so it must
+		// not be a prefix of the end of its range
+		first := ranges[0]
+		for _, r := range ranges {
+			if r[0] < first[0] {
+				first = r
+			}
+			all = append(all, keyRange{low: r[0], high: r[1], chain_id: s.ChainID})
+		}
+		if strings.HasPrefix(first[1], first[0]) {
+			return fmt.Errorf("shard %s: the accounts starting with %s do not fit in [%s,%s)", s.ChainID, first[0], first[0], first[1])
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].low < all[j].low })
+
+	low, high := all[0].low, all[len(all)-1].high
+	if t.Keyspace != "" {
+		ks, err := ParseKeyRange(t.Keyspace)
+		if err != nil {
+			return fmt.Errorf("keyspace: %v", err)
+		}
+		if len(ks) != 1 {
+			return fmt.Errorf("keyspace %s is not a single range", t.Keyspace)
+		}
+		low, high = ks[0][0], ks[0][1]
+	}
+	if all[0].low != low {
+		return fmt.Errorf("keys [%s,%s) of the keyspace belong to no shard", low, all[0].low)
+	}
+	for i := 1; i < len(all); i++ {
+		prev, r := all[i-1], all[i]
+		if r.low < prev.high {
+			return fmt.Errorf("the key ranges of shards %s and %s overlap at [%s,%s)", prev.chain_id, r.chain_id, r.low, prev.high)
+		}
+		if r.low > prev.high {
+			return fmt.Errorf("keys [%s,%s) of the keyspace belong to no shard", prev.high, r.low)
+		}
+	}
+	if last := all[len(all)-1]; last.high != high {
+		return fmt.Errorf("shard %s owns [%s,%s), but the keyspace ends at %s", last.chain_id, last.low, last.high, high)
+	}
+	return nil
+}
+
+// ParseKeyRange reads a key range in the format of either protocol.
+func ParseKeyRange(s string) ([][2]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("empty key range")
+	}
+	var keys []string
+	if strings.HasPrefix(s, "[") {
+		for _, pair := range strings.Split(s, "+") {
+			if !strings.HasPrefix(pair, "[") || !strings.HasSuffix(pair, "]") {
+				return nil, fmt.Errorf("key range %s: %s is not [low,high]", s, pair)
+			}
+			values := strings.Split(pair[1:len(pair)-1], ",")
+			if len(values) != 2 {
+				return nil, fmt.Errorf("key range %s: %s is not [low,high]", s, pair)
+			}
+			keys = append(keys, values...)
+		}
+	} else {
+		keys = strings.Split(s, ",")
+		if len(keys)%2 == 1 {
+			return nil, fmt.Errorf("key range %s has an odd number of keys", s)
+		}
+	}
+	out := make([][2]string, 0, len(keys)/2)
+	for i := 0; i < len(keys); i += 2 {
+		low, high := keys[i], keys[i+1]
+		if low == "" || high == "" {
+			return nil, fmt.Errorf("key range %s has an empty key", s)
+		}
+		if low >= high {
+			return nil, fmt.Errorf("key range %s: [%s,%s) is empty", s, low, high)
+		}
+		out = append(out, [2]string{low, high})
+	}
+	return out, nil
+}
+
+// FormatKeyRange writes a key range in the protocol's format.
+func FormatKeyRange(ranges [][2]string, protocol string) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		if protocol == genesis.ProtocolPyramid {
+			parts[i] = fmt.Sprintf("[%s,%s]", r[0], r[1])
+		} else {
+			parts[i] = r[0] + "," + r[1]
+		}
+	}
+	if protocol == genesis.ProtocolPyramid {
+		return strings.Join(parts, "+")
+	}
+	return strings.Join(parts, ",")
+}
+
+func kind(s *Shard) string {
+	if s.IsI {
+		return "I-shard"
+	}
+	return "B-shard"
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
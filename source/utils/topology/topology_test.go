@@ -0,0 +1,96 @@
+package topology
+
+import (
+	"emulator/utils/genesis"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testTopology() *Topology {
+	return &Topology{
+		IPInUse: map[string]uint32{"127.0.0.1": 8, "127.0.0.2": 4},
+		Shards: []*Shard{
+			{ChainID: "i1", PeerNum: 4, KeyRange: "10,11", IsI: true, RelatedShards: []string{"b1"}},
+			{ChainID: "i2", PeerNum: 4, KeyRange: "[11,12]+[13,20]", IsI: true, RelatedShards: []string{"b1"}},
+			{ChainID: "b1", PeerNum: 4, KeyRange: "12,13,20,21", RelatedShards: []string{"i1", "i2"}},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, protocol := range []string{genesis.ProtocolUrd, genesis.ProtocolPyramid} {
+		if err := testTopology().Validate(protocol); err != nil {
+			t.Fatalf("%s: %v", protocol, err)
+		}
+	}
+	cases := map[string]func(*Topology){
+		"overlap":      func(tp *Topology) { tp.Shards[1].KeyRange = "[105,12]+[13,20]" },
+		"belong to no": func(tp *Topology) { tp.Shards[2].KeyRange = "12,13,205,21" },
+		"ends at":      func(tp *Topology) { tp.Keyspace = "10,23" },
+		"keyspace":     func(tp *Topology) { tp.Keyspace = "10,20" },
+		"do not fit":   func(tp *Topology) { tp.Shards[0].KeyRange = "10,105"; tp.Shards[1].KeyRange = "105,12,13,20" },
+		"does not":     func(tp *Topology) { tp.Shards[0].RelatedShards = []string{"b2"} },
+		"is not":       func(tp *Topology) { tp.Shards[1].RelatedShards = nil },
+		"both":         func(tp *Topology) { tp.Shards[0].RelatedShards = []string{"i2"} },
+		"odd":          func(tp *Topology) { tp.Shards[2].KeyRange = "12,13,20" },
+		"quorum":       func(tp *Topology) { tp.Shards[0].SignScheme, tp.Shards[0].Threshold = "bls-threshold", 3 },
+	}
+	for want, breakIt := range cases {
+		tp := testTopology()
+		breakIt(tp)
+		if err := tp.Validate(genesis.ProtocolUrd); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: got %v", want, err)
+		}
+	}
+	// urd runs shards without I/B-shard semantics, pyramid needs them
+	tp := testTopology()
+	for _, s := range tp.Shards {
+		s.IsI, s.RelatedShards = false, nil
+	}
+	if err := tp.Validate(genesis.ProtocolUrd); err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.Validate(genesis.ProtocolPyramid); err == nil {
+		t.Fatal("pyramid accepted a topology without I-shards")
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topology.json")
+	if err := testTopology().Write(path); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		genesis.ProtocolUrd:     {"10,11", "11,12,13,20", "12,13,20,21"},
+		genesis.ProtocolPyramid: {"[10,11]", "[11,12]+[13,20]", "[12,13]+[20,21]"},
+	}
+	for protocol, ranges := range want {
+		tp, err := Load(path, protocol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, s := range tp.Shards {
+			if s.KeyRange != ranges[i] {
+				t.Fatalf("%s: shard %s has key range %s, want %s", protocol, s.ChainID, s.KeyRange, ranges[i])
+			}
+		}
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	got := testTopology().Addresses()
+	if len(got) != 12 {
+		t.Fatalf("%d addresses for 12 nodes", len(got))
+	}
+	perIP := map[string]int{}
+	for i, a := range got {
+		if a.Port != BasePort+perIP[a.IP] {
+			t.Fatalf("node %d got %s", i, a)
+		}
+		perIP[a.IP]++
+	}
+	if perIP["127.0.0.1"] != 8 || perIP["127.0.0.2"] != 4 {
+		t.Fatalf("nodes per machine %v", perIP)
+	}
+}